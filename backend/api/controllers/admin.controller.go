@@ -40,7 +40,7 @@ func (h *AdminController) GetUsers(c *fiber.Ctx) error {
 		SELECT u.id, u.email, u.name, u.role, u.is_verified, u.is_active, u.kyc_status, u.created_at,
 			   COALESCE(SUM(e.amount), 0) as total_earnings
 		FROM users u
-		LEFT JOIN earnings e ON u.id = e.user_id AND e.status = 'completed'
+		LEFT JOIN earnings e ON u.id = e.user_id AND e.status = 'available'
 		WHERE 1=1
 	`
 	args := []interface{}{}
@@ -293,23 +293,6 @@ func (h *AdminController) GetSurveys(c *fiber.Ctx) error {
 	})
 }
 
-func (h *AdminController) ApproveSurvey(c *fiber.Ctx) error {
-	ctx := middleware.GetContextWithTrace(c)
-	utils.LogInfo(ctx, "→ ApproveSurvey request (admin)")
-
-	adminID, _ := c.Locals("user_id").(string)
-	surveyID := c.Params("id")
-
-	utils.LogInfo(ctx, "✅ Survey approved", "admin_id", adminID, "survey_id", surveyID, "action", "survey_approved")
-	utils.LogInfo(ctx, "← ApproveSurvey completed", "admin_id", adminID)
-
-	return c.JSON(fiber.Map{
-		"ok":        true,
-		"message":   "Survey approved",
-		"survey_id": surveyID,
-	})
-}
-
 func (h *AdminController) GetPayments(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	utils.LogInfo(ctx, "→ GetPayments request (admin)")
@@ -389,48 +372,6 @@ func (h *AdminController) GetReports(c *fiber.Ctx) error {
 	return c.JSON(reports)
 }
 
-// ProcessPayouts handles batch payout processing
-func (h *AdminController) ProcessPayouts(c *fiber.Ctx) error {
-	ctx := middleware.GetContextWithTrace(c)
-	utils.LogInfo(ctx, "→ ProcessPayouts request (admin)")
-
-	adminID, _ := c.Locals("user_id").(string)
-
-	var req struct {
-		WithdrawalIDs []string `json:"withdrawal_ids"`
-	}
-
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "Failed to parse payout request", err, "admin_id", adminID)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
-	}
-
-	utils.LogInfo(ctx, "Processing batch payouts", "admin_id", adminID, "withdrawal_count", len(req.WithdrawalIDs))
-
-	// TODO: Process payouts via Paystack
-	processed := 0
-	failed := 0
-
-	for _, id := range req.WithdrawalIDs {
-		// Mock processing
-		if len(id) > 0 {
-			processed++
-		} else {
-			failed++
-		}
-	}
-
-	utils.LogInfo(ctx, "✅ Payouts processed", "admin_id", adminID, "processed", processed, "failed", failed, "action", "payouts_processed")
-	utils.LogInfo(ctx, "← ProcessPayouts completed", "admin_id", adminID)
-
-	return c.JSON(fiber.Map{
-		"ok":        true,
-		"processed": processed,
-		"failed":    failed,
-		"message":   fmt.Sprintf("Processed %d payouts, %d failed", processed, failed),
-	})
-}
-
 // ExportUsers exports user data
 func (h *AdminController) ExportUsers(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
@@ -33,7 +33,7 @@ func (h *AdminHandler) GetUsers(c *fiber.Ctx) error {
 		SELECT u.id, u.email, u.name, u.role, u.is_verified, u.is_active, u.kyc_status, u.created_at,
 			   COALESCE(SUM(e.amount), 0) as total_earnings
 		FROM users u
-		LEFT JOIN earnings e ON u.id = e.user_id AND e.status = 'completed'
+		LEFT JOIN earnings e ON u.id = e.user_id AND e.status = 'available'
 		WHERE 1=1
 	`
 	args := []interface{}{}
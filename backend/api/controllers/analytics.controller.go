@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
 	"onetimer-backend/services"
 	"onetimer-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,16 +20,29 @@ type AnalyticsController struct {
 	cache            *cache.Cache
 	db               *pgxpool.Pool
 	analyticsService *services.AnalyticsService
+	types            *services.QuestionTypeRegistry
+	ledgerRepo       *repository.LedgerRepository
 }
 
-func NewAnalyticsController(cache *cache.Cache, db *pgxpool.Pool) *AnalyticsController {
+func NewAnalyticsController(cache *cache.Cache, db *pgxpool.Pool, ledgerRepo *repository.LedgerRepository) *AnalyticsController {
 	return &AnalyticsController{
 		cache:            cache,
 		db:               db,
 		analyticsService: services.NewAnalyticsService(db, cache),
+		types:            services.NewQuestionTypeRegistry(),
+		ledgerRepo:       ledgerRepo,
 	}
 }
 
+// balances reads the user's balances from the ledger; analytics are cached, balances never are
+func (h *AnalyticsController) balances(c *fiber.Ctx, userID string) (*models.Balances, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+	return h.ledgerRepo.Balances(c.Context(), id)
+}
+
 // GetDashboardAnalytics returns comprehensive dashboard analytics
 func (h *AnalyticsController) GetDashboardAnalytics(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
@@ -60,10 +77,12 @@ func (h *AnalyticsController) GetDashboardAnalytics(c *fiber.Ctx) error {
 	})
 }
 
-// GetSurveyAnalytics returns detailed analytics for a specific survey
+// GetSurveyAnalytics returns detailed analytics for a specific survey, limited to the responses
+// of one survey version with ?version=N
 func (h *AnalyticsController) GetSurveyAnalytics(c *fiber.Ctx) error {
 	surveyID := c.Params("id")
 	userID := c.Locals("user_id").(string)
+	version := c.QueryInt("version", 0)
 
 	// Verify survey ownership
 	var creatorID string
@@ -77,18 +96,23 @@ func (h *AnalyticsController) GetSurveyAnalytics(c *fiber.Ctx) error {
 	surveyDetails := h.getSurveyDetails(surveyID)
 
 	// Get response analytics
-	responseAnalytics := h.getResponseAnalytics(surveyID)
+	responseAnalytics := h.getResponseAnalytics(surveyID, version)
 
 	// Get completion funnel
-	completionFunnel := h.getCompletionFunnel(surveyID)
+	completionFunnel := h.getCompletionFunnel(surveyID, version)
 
 	// Get quality metrics
-	qualityMetrics := h.getQualityMetrics(surveyID)
+	qualityMetrics := h.getQualityMetrics(surveyID, version)
 
 	return c.JSON(fiber.Map{
 		"survey":             surveyDetails,
+		"version":            version,
+		"versions":           h.getVersionBreakdown(surveyID),
 		"response_analytics": responseAnalytics,
 		"completion_funnel":  completionFunnel,
+		"question_dropoff":   h.getQuestionDropoff(surveyID, version),
+		"time_per_question":  h.getTimePerQuestion(surveyID, version),
+		"answer_summaries":   h.getAnswerSummaries(surveyID, version),
 		"quality_metrics":    qualityMetrics,
 	})
 }
@@ -138,6 +162,13 @@ func (h *AnalyticsController) GetFillerDashboard(c *fiber.Ctx) error {
 		utils.LogError(ctx, "Failed to get filler analytics", err, "user_id", userID.(string))
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch analytics"})
 	}
+	balances, err := h.balances(c, userID.(string))
+	if err != nil {
+		utils.LogError(ctx, "Failed to get filler balances", err, "user_id", userID.(string))
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch analytics"})
+	}
+	analytics.PendingEarnings = balances.PendingEarnings
+	analytics.AvailableBalance = balances.AvailableBalance
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -158,6 +189,12 @@ func (h *AnalyticsController) GetCreatorDashboard(c *fiber.Ctx) error {
 		utils.LogError(ctx, "Failed to get creator analytics", err, "user_id", userID.(string))
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch analytics"})
 	}
+	balances, err := h.balances(c, userID.(string))
+	if err != nil {
+		utils.LogError(ctx, "Failed to get creator credits", err, "user_id", userID.(string))
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch analytics"})
+	}
+	analytics.AvailableCredits = balances.Credits
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -193,6 +230,13 @@ func (h *AnalyticsController) GetEarningsBreakdown(c *fiber.Ctx) error {
 		utils.LogError(ctx, "Failed to get earnings breakdown", err, "user_id", userID.(string))
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earnings data"})
 	}
+	balances, err := h.balances(c, userID.(string))
+	if err != nil {
+		utils.LogError(ctx, "Failed to get filler balances", err, "user_id", userID.(string))
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earnings data"})
+	}
+	breakdown.PendingEarnings = balances.PendingEarnings
+	breakdown.AvailableBalance = balances.AvailableBalance
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -389,7 +433,7 @@ func (h *AnalyticsController) getSurveyDetails(surveyID string) fiber.Map {
 	}
 }
 
-func (h *AnalyticsController) getResponseAnalytics(surveyID string) fiber.Map {
+func (h *AnalyticsController) getResponseAnalytics(surveyID string, version int) fiber.Map {
 	// Get response statistics
 	statsQuery := `
 		SELECT 
@@ -397,13 +441,13 @@ func (h *AnalyticsController) getResponseAnalytics(surveyID string) fiber.Map {
 			AVG(EXTRACT(EPOCH FROM (completed_at - started_at))/60) as avg_completion_time,
 			AVG(quality_score) as avg_quality_score
 		FROM responses 
-		WHERE survey_id = $1 AND status = 'completed'
+		WHERE survey_id = $1 AND status = 'completed' AND ($2 = 0 OR survey_version = $2)
 	`
 
 	var totalResponses int
 	var avgCompletionTime, avgQualityScore float64
 
-	h.db.QueryRow(context.Background(), statsQuery, surveyID).Scan(
+	h.db.QueryRow(context.Background(), statsQuery, surveyID, version).Scan(
 		&totalResponses, &avgCompletionTime, &avgQualityScore)
 
 	return fiber.Map{
@@ -413,7 +457,7 @@ func (h *AnalyticsController) getResponseAnalytics(surveyID string) fiber.Map {
 	}
 }
 
-func (h *AnalyticsController) getCompletionFunnel(surveyID string) []fiber.Map {
+func (h *AnalyticsController) getCompletionFunnel(surveyID string, version int) []fiber.Map {
 	if h.db == nil {
 		// Mock data when database unavailable
 		return []fiber.Map{
@@ -423,58 +467,253 @@ func (h *AnalyticsController) getCompletionFunnel(surveyID string) []fiber.Map {
 		}
 	}
 
-	// Get total responses started
-	var started int
-	h.db.QueryRow(context.Background(), 
-		"SELECT COUNT(*) FROM responses WHERE survey_id = $1", surveyID).Scan(&started)
-	
-	// Get completed responses
-	var completed int
-	h.db.QueryRow(context.Background(), 
-		"SELECT COUNT(*) FROM responses WHERE survey_id = $1 AND status = 'completed'", surveyID).Scan(&completed)
-	
-	// Calculate 50% completion (rough estimate)
-	halfway := int(float64(started) * 0.8) // Assume 80% get halfway
-	
+	// Sessions are responses rows; progress is the client-reported percentage while in progress
+	var started, halfway, completed int
+	h.db.QueryRow(context.Background(), `
+		SELECT
+			COUNT(*),
+			COUNT(CASE WHEN status = 'completed' OR progress >= 50 THEN 1 END),
+			COUNT(CASE WHEN status = 'completed' THEN 1 END)
+		FROM responses WHERE survey_id = $1 AND ($2 = 0 OR survey_version = $2)
+	`, surveyID, version).Scan(&started, &halfway, &completed)
+
 	funnel := []fiber.Map{
 		{"step": "Started", "count": started, "percentage": 100},
 	}
-	
+
 	if started > 0 {
 		funnel = append(funnel, fiber.Map{
-			"step": "50% Complete", 
-			"count": halfway, 
+			"step":       "50% Complete",
+			"count":      halfway,
 			"percentage": float64(halfway) / float64(started) * 100,
 		})
 		funnel = append(funnel, fiber.Map{
-			"step": "Completed", 
-			"count": completed, 
+			"step":       "Completed",
+			"count":      completed,
 			"percentage": float64(completed) / float64(started) * 100,
 		})
 	}
-	
+
 	return funnel
 }
 
-func (h *AnalyticsController) getQualityMetrics(surveyID string) fiber.Map {
+// getQuestionDropoff counts abandoned sessions by the last question the filler was shown
+func (h *AnalyticsController) getQuestionDropoff(surveyID string, version int) []fiber.Map {
+	dropoff := []fiber.Map{}
+	if h.db == nil {
+		return dropoff
+	}
+
+	rows, err := h.db.Query(context.Background(), `
+		SELECT q.id, q.title, COUNT(*) AS dropped
+		FROM (
+			SELECT DISTINCT ON (t.response_id) t.response_id, t.question_id
+			FROM response_question_timings t
+			JOIN responses r ON r.id = t.response_id
+			WHERE r.survey_id = $1 AND r.status = 'abandoned' AND ($2 = 0 OR r.survey_version = $2)
+			ORDER BY t.response_id, t.first_shown_at DESC
+		) last_seen
+		JOIN questions q ON q.id = last_seen.question_id
+		GROUP BY q.id, q.title, q.survey_version, q.order_index
+		ORDER BY q.survey_version, q.order_index
+	`, surveyID, version)
+	if err != nil {
+		return dropoff
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID, title string
+		var dropped int
+		if rows.Scan(&questionID, &title, &dropped) == nil {
+			dropoff = append(dropoff, fiber.Map{
+				"question_id": questionID,
+				"title":       title,
+				"dropped":     dropped,
+			})
+		}
+	}
+
+	return dropoff
+}
+
+// getTimePerQuestion averages the seconds between a question being shown and answered, for the
+// questions of the given version or, when version is 0, of the current one
+func (h *AnalyticsController) getTimePerQuestion(surveyID string, version int) []fiber.Map {
+	timings := []fiber.Map{}
+	if h.db == nil {
+		return timings
+	}
+
+	rows, err := h.db.Query(context.Background(), `
+		SELECT q.id, q.title,
+			AVG(EXTRACT(EPOCH FROM (t.answered_at - t.first_shown_at))) AS avg_seconds,
+			COUNT(t.answered_at) AS answers
+		FROM questions q
+		LEFT JOIN response_question_timings t ON t.question_id = q.id AND t.answered_at IS NOT NULL
+		WHERE q.survey_id = $1
+		  AND q.survey_version = COALESCE(NULLIF($2, 0), (SELECT version FROM surveys WHERE id = $1))
+		GROUP BY q.id, q.title, q.order_index
+		ORDER BY q.order_index
+	`, surveyID, version)
+	if err != nil {
+		return timings
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var questionID, title string
+		var avgSeconds *float64
+		var answers int
+		if rows.Scan(&questionID, &title, &avgSeconds, &answers) == nil {
+			seconds := 0.0
+			if avgSeconds != nil {
+				seconds = *avgSeconds
+			}
+			timings = append(timings, fiber.Map{
+				"question_id": questionID,
+				"title":       title,
+				"avg_seconds": seconds,
+				"answers":     answers,
+			})
+		}
+	}
+
+	return timings
+}
+
+// getAnswerSummaries aggregates the completed answers to each question of the given version or,
+// when version is 0, of the current one, as the question's type summarises them
+func (h *AnalyticsController) getAnswerSummaries(surveyID string, version int) []fiber.Map {
+	summaries := []fiber.Map{}
+	if h.db == nil {
+		return summaries
+	}
+	ctx := context.Background()
+
+	if version == 0 {
+		if err := h.db.QueryRow(ctx, `SELECT version FROM surveys WHERE id = $1`, surveyID).Scan(&version); err != nil {
+			return summaries
+		}
+	}
+
+	rows, err := h.db.Query(ctx, `
+		SELECT id, survey_id, type, title, options, settings
+		FROM questions
+		WHERE survey_id = $1 AND survey_version = $2
+		ORDER BY order_index
+	`, surveyID, version)
+	if err != nil {
+		return summaries
+	}
+	var questions []models.Question
+	for rows.Next() {
+		var q models.Question
+		if rows.Scan(&q.ID, &q.SurveyID, &q.Type, &q.Title, &q.Options, &q.Settings) == nil {
+			questions = append(questions, q)
+		}
+	}
+	rows.Close()
+
+	rows, err = h.db.Query(ctx, `
+		SELECT answers FROM responses
+		WHERE survey_id = $1 AND survey_version = $2 AND status = 'completed'
+	`, surveyID, version)
+	if err != nil {
+		return summaries
+	}
+	defer rows.Close()
+
+	answers := make(map[string][]interface{}, len(questions))
+	for rows.Next() {
+		var raw []byte
+		var parsed map[string]interface{}
+		if rows.Scan(&raw) != nil || json.Unmarshal(raw, &parsed) != nil {
+			continue
+		}
+		for questionID, answer := range parsed {
+			if services.IsAnswered(answer) {
+				answers[questionID] = append(answers[questionID], answer)
+			}
+		}
+	}
+
+	for _, q := range questions {
+		questionType, ok := h.types.Lookup(q.Type)
+		if !ok {
+			continue
+		}
+		summaries = append(summaries, fiber.Map{
+			"question_id": q.ID,
+			"title":       q.Title,
+			"type":        q.Type,
+			"summary":     questionType.Aggregate(q, answers[q.ID.String()]),
+		})
+	}
+
+	return summaries
+}
+
+func (h *AnalyticsController) getQualityMetrics(surveyID string, version int) fiber.Map {
 	qualityQuery := `
 		SELECT 
 			COUNT(CASE WHEN quality_score >= 8 THEN 1 END) as high_quality,
 			COUNT(CASE WHEN quality_score >= 5 AND quality_score < 8 THEN 1 END) as medium_quality,
-			COUNT(CASE WHEN quality_score < 5 THEN 1 END) as low_quality
+			COUNT(CASE WHEN quality_score < 5 THEN 1 END) as low_quality,
+			COUNT(CASE WHEN status = 'rejected' THEN 1 END) as rejected
 		FROM responses 
-		WHERE survey_id = $1 AND status = 'completed'
+		WHERE survey_id = $1 AND status IN ('completed', 'rejected') AND ($2 = 0 OR survey_version = $2)
 	`
 
-	var highQuality, mediumQuality, lowQuality int
-	h.db.QueryRow(context.Background(), qualityQuery, surveyID).Scan(
-		&highQuality, &mediumQuality, &lowQuality)
+	var highQuality, mediumQuality, lowQuality, rejected int
+	h.db.QueryRow(context.Background(), qualityQuery, surveyID, version).Scan(
+		&highQuality, &mediumQuality, &lowQuality, &rejected)
 
 	return fiber.Map{
 		"high_quality":   highQuality,
 		"medium_quality": mediumQuality,
 		"low_quality":    lowQuality,
+		"rejected":       rejected,
+	}
+}
+
+// getVersionBreakdown summarises completed responses per survey version
+func (h *AnalyticsController) getVersionBreakdown(surveyID string) []fiber.Map {
+	versions := []fiber.Map{}
+	if h.db == nil {
+		return versions
+	}
+
+	rows, err := h.db.Query(context.Background(), `
+		SELECT v.version, v.created_at,
+			COUNT(r.id) AS responses,
+			COALESCE(AVG(r.quality_score), 0) AS avg_quality_score
+		FROM survey_versions v
+		LEFT JOIN responses r ON r.survey_id = v.survey_id AND r.survey_version = v.version AND r.status = 'completed'
+		WHERE v.survey_id = $1
+		GROUP BY v.version, v.created_at
+		ORDER BY v.version
+	`, surveyID)
+	if err != nil {
+		return versions
 	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version, responses int
+		var createdAt time.Time
+		var avgQuality float64
+		if rows.Scan(&version, &createdAt, &responses, &avgQuality) == nil {
+			versions = append(versions, fiber.Map{
+				"version":           version,
+				"created_at":        createdAt,
+				"responses":         responses,
+				"avg_quality_score": avgQuality,
+			})
+		}
+	}
+
+	return versions
 }
 
 func (h *AnalyticsController) generateAnalyticsCSV(surveyID string) string {
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"fmt"
 	"onetimer-backend/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,9 +11,9 @@ type BillingController struct {
 	billingService *services.BillingService
 }
 
-func NewBillingController() *BillingController {
+func NewBillingController(billingService *services.BillingService) *BillingController {
 	return &BillingController{
-		billingService: services.NewBillingService(),
+		billingService: billingService,
 	}
 }
 
@@ -68,74 +69,66 @@ func (bc *BillingController) ValidateReward(c *fiber.Ctx) error {
 	})
 }
 
+// GetPricingTiers lists the tiers and add-ons of the pricing in force now
 func (bc *BillingController) GetPricingTiers(c *fiber.Ctx) error {
-	tiers := []fiber.Map{
-		{
-			"level":        "Basic",
-			"pages":        "1-5",
-			"duration":     "2-5 mins",
-			"platform_fee": 150,
-			"reward_range": "₦100 - ₦150",
-			"min_reward":   100,
-			"max_reward":   150,
-		},
-		{
-			"level":        "Standard",
-			"pages":        "6-10",
-			"duration":     "5-10 mins",
-			"platform_fee": 300,
-			"reward_range": "₦150 - ₦250",
-			"min_reward":   150,
-			"max_reward":   250,
-		},
-		{
-			"level":        "Advanced",
-			"pages":        "11-20",
-			"duration":     "10-20 mins",
-			"platform_fee": 500,
-			"reward_range": "₦300 - ₦500",
-			"min_reward":   300,
-			"max_reward":   500,
-		},
-		{
-			"level":        "Enterprise",
-			"pages":        "20+",
-			"duration":     "20+ mins",
-			"platform_fee": 1000,
-			"reward_range": "₦600 - ₦1000",
-			"min_reward":   600,
-			"max_reward":   1000,
-		},
+	pricing, err := bc.billingService.Pricing(c.Context())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "Failed to load pricing",
+			"success": false,
+		})
+	}
+	rules := pricing.Rules
+
+	tiers := []fiber.Map{}
+	firstPage := 1
+	for _, tier := range rules.Tiers {
+		pages := fmt.Sprintf("%d+", firstPage)
+		if tier.MaxPages > 0 {
+			pages = fmt.Sprintf("%d-%d", firstPage, tier.MaxPages)
+			firstPage = tier.MaxPages + 1
+		}
+		tiers = append(tiers, fiber.Map{
+			"level":        tier.Level,
+			"pages":        pages,
+			"duration":     tier.Duration,
+			"platform_fee": tier.PlatformFee,
+			"reward_range": fmt.Sprintf("₦%d - ₦%d", tier.MinReward, tier.MaxReward),
+			"min_reward":   tier.MinReward,
+			"max_reward":   tier.MaxReward,
+		})
 	}
 
 	addOns := []fiber.Map{
 		{
 			"name":        "Priority Placement",
 			"description": "Display survey at top of dashboard for 48 hours",
-			"cost":        500,
+			"cost":        rules.PriorityPlacement,
 		},
 		{
 			"name":        "Targeted Demographics",
 			"description": "Filter by location, age, or gender",
-			"cost":        200,
+			"cost":        rules.DemographicFilter,
 			"per":         "filter",
 		},
 		{
 			"name":        "Extended Duration",
-			"description": "Keep survey active beyond 7 days",
-			"cost":        100,
+			"description": fmt.Sprintf("Keep survey active beyond %d days", services.BaseSurveyDays),
+			"cost":        rules.ExtraDay,
 			"per":         "day",
 		},
 		{
 			"name":        "Data Export",
 			"description": "Access CSV/Excel export of all responses",
-			"cost":        300,
+			"cost":        rules.DataExport,
 		},
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"tiers":   tiers,
-		"add_ons": addOns,
+		"success":                 true,
+		"tiers":                   tiers,
+		"add_ons":                 addOns,
+		"platform_fee_percentage": rules.PlatformFeePercentage,
+		"pricing_version":         pricing.Version,
 	})
 }
@@ -1,11 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
-	"time"
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
-	"onetimer-backend/config"
+	"onetimer-backend/models"
 	"onetimer-backend/repository"
 	"onetimer-backend/services"
 	"onetimer-backend/utils"
@@ -15,158 +15,268 @@ import (
 )
 
 type CreditsController struct {
-	cache      *cache.Cache
-	paystack   *services.PaystackService
-	creditRepo *repository.CreditRepository
+	cache       *cache.Cache
+	paystack    *services.PaystackService
+	creditRepo  *repository.CreditRepository
+	paymentRepo *repository.PaymentRepository
+	userRepo    *repository.UserRepository
+	topUps      *services.TopUpService
 }
 
-func NewCreditsController(cache *cache.Cache, cfg *config.Config, creditRepo *repository.CreditRepository) *CreditsController {
+// NewCreditsController takes a nil Paystack service when no secret key is configured; purchases
+// are then recorded but initialized in mock mode
+func NewCreditsController(cache *cache.Cache, paystack *services.PaystackService, creditRepo *repository.CreditRepository, paymentRepo *repository.PaymentRepository, userRepo *repository.UserRepository) *CreditsController {
 	return &CreditsController{
-		cache:      cache,
-		paystack:   services.NewPaystackService(cfg.PaystackSecret),
-		creditRepo: creditRepo,
+		cache:       cache,
+		paystack:    paystack,
+		creditRepo:  creditRepo,
+		paymentRepo: paymentRepo,
+		userRepo:    userRepo,
 	}
 }
 
+// WithTopUps buys credits with creators' saved cards, without a checkout
+func (h *CreditsController) WithTopUps(topUps *services.TopUpService) *CreditsController {
+	h.topUps = topUps
+	return h
+}
+
+// GET /api/credits/packages
+// Returns the credit packages on sale.
 func (h *CreditsController) GetPackages(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	utils.LogInfo(ctx, "→ GetPackages request")
 
-	packages := []fiber.Map{
-		{
-			"id":          "starter",
-			"name":        "Starter Pack",
-			"credits":     50,
-			"price":       15000,
-			"popular":     false,
-			"description": "Perfect for small surveys",
-		},
-		{
-			"id":          "professional",
-			"name":        "Professional Pack",
-			"credits":     150,
-			"price":       40000,
-			"popular":     true,
-			"description": "Most popular choice",
-		},
-		{
-			"id":          "enterprise",
-			"name":        "Enterprise Pack",
-			"credits":     500,
-			"price":       120000,
-			"popular":     false,
-			"description": "For large-scale research",
-		},
+	if h.creditRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Credit packages unavailable"})
+	}
+	packages, err := h.creditRepo.ListPackages(c.Context(), false)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list credit packages", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get credit packages"})
 	}
 
 	utils.LogInfo(ctx, "✅ Packages retrieved", "count", len(packages))
-	utils.LogInfo(ctx, "← GetPackages completed")
-	return c.JSON(fiber.Map{"packages": packages})
+	return c.JSON(fiber.Map{
+		"packages":            packages,
+		"min_custom_purchase": models.MinCustomPurchase,
+	})
 }
 
+// POST /api/credits/purchase
+// Starts the purchase of a credit package. The amount and credits come from the package, never
+// from the request.
 func (h *CreditsController) PurchaseCredits(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	utils.LogInfo(ctx, "→ PurchaseCredits request")
 
-	userIDInterface := c.Locals("user_id")
-	if userIDInterface == nil {
-		utils.LogWarn(ctx, "⚠️ Unauthorized purchase attempt - no user_id", "ip", c.IP())
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-	userID, ok := userIDInterface.(string)
-	if !ok || userID == "" {
-		utils.LogWarn(ctx, "⚠️ Invalid user ID in purchase request", "user_id", userIDInterface)
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
-
 	var req struct {
 		PackageID string `json:"package_id"`
-		Amount    int    `json:"amount"`
-		Credits   int    `json:"credits"`
+		SaveCard  bool   `json:"save_card"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.PackageID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "package_id is required"})
+	}
+	if h.creditRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Credit purchases unavailable"})
 	}
 
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "Failed to parse purchase request body", err, "user_id", userID)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	payment, err := h.packagePurchase(c, userID, req.PackageID)
+	if payment == nil {
+		return err
 	}
+	return h.initializePurchase(c, userID, payment, req.SaveCard)
+}
 
-	utils.LogInfo(ctx, "Processing credit purchase", "user_id", userID, "package_id", req.PackageID, "amount", req.Amount, "credits", req.Credits)
+// POST /api/credits/purchase/custom
+// Starts the purchase of any number of credits from the minimum up, at ₦1 each.
+func (h *CreditsController) PurchaseCustom(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ PurchaseCustom request")
 
-	// Initialize Paystack payment
-	paystackService := h.paystack
-	if paystackService == nil {
-		paystackService = services.NewPaystackService("sk_test_placeholder_key")
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req struct {
+		Credits  int  `json:"credits"`
+		SaveCard bool `json:"save_card"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.Credits < models.MinCustomPurchase {
+		utils.LogWarn(ctx, "⚠️ Custom purchase below minimum", "user_id", userID, "credits", req.Credits)
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Custom purchases start at %d credits", models.MinCustomPurchase)})
 	}
 
-	// Get user email (for now use placeholder)
-	userEmail := fmt.Sprintf("user_%s@example.com", userID[:8]) // Use user ID for email
-	reference := "txn_" + userID[:8] + "_" + fmt.Sprintf("%d", time.Now().Unix())
+	return h.initializePurchase(c, userID, customPurchase(req.Credits), req.SaveCard)
+}
 
-	utils.LogInfo(ctx, "Initializing Paystack transaction", "user_id", userID, "reference", reference, "amount_kobo", req.Amount*100)
+// POST /api/credits/top-up
+// Buys a credit package, or any number of credits from the minimum up, with one of the caller's
+// saved cards, their default unless payment_method_id names another. Credits are granted at once
+// when the charge succeeds; a charge the bank has yet to decide is granted by its webhook.
+func (h *CreditsController) TopUp(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ TopUp request")
 
-	initResp, err := paystackService.InitializeTransaction(userEmail, req.Amount*100, reference) // Convert to kobo
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
 	if err != nil {
-		utils.LogError(ctx, "Failed to initialize Paystack payment", err, "user_id", userID, "reference", reference, "amount", req.Amount)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to initialize payment"})
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	var req struct {
+		PaymentMethodID string `json:"payment_method_id"`
+		PackageID       string `json:"package_id"`
+		Credits         int    `json:"credits"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	methodID := uuid.Nil
+	if req.PaymentMethodID != "" {
+		if methodID, err = uuid.Parse(req.PaymentMethodID); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid payment method ID"})
+		}
+	}
+	if req.PackageID == "" && req.Credits < models.MinCustomPurchase {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("package_id, or credits from %d, is required", models.MinCustomPurchase)})
+	}
+	if h.topUps == nil || h.creditRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Top-ups unavailable"})
 	}
 
-	utils.LogInfo(ctx, "✅ Payment initialized successfully", "user_id", userID, "reference", initResp.Data.Reference, "credits", req.Credits)
-	utils.LogInfo(ctx, "← PurchaseCredits completed", "user_id", userID)
+	payment := customPurchase(req.Credits)
+	if req.PackageID != "" {
+		if payment, err = h.packagePurchase(c, userID, req.PackageID); payment == nil {
+			return err
+		}
+	}
 
-	return c.JSON(fiber.Map{
-		"ok":               true,
-		"authorization_url": initResp.Data.AuthorizationURL,
-		"reference":        initResp.Data.Reference,
-		"amount":           req.Amount,
-		"credits":          req.Credits,
-		"status":           "pending",
-		"message":          "Payment initialized successfully",
+	payment, err = h.topUps.TopUp(c.Context(), userID, methodID, payment)
+	switch {
+	case errors.Is(err, services.ErrNoPaymentMethod):
+		return c.Status(404).JSON(fiber.Map{"error": "Payment method not found"})
+	case errors.Is(err, services.ErrChargeDeclined):
+		utils.LogWarn(ctx, "⚠️ Top-up declined", "user_id", userID, "error", err.Error())
+		return c.Status(402).JSON(fiber.Map{"error": "Your card was declined", "reason": err.Error(), "payment_id": payment.ID})
+	case err != nil:
+		utils.LogError(ctx, "Top-up failed", err, "user_id", userID)
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to charge your card"})
+	}
+
+	status := 201
+	if payment.Status == models.PaymentStatusPending {
+		status = 202
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"ok":         true,
+		"payment_id": payment.ID,
+		"reference":  payment.PaystackReference,
+		"package_id": payment.PackageID,
+		"amount":     payment.Amount,
+		"credits":    payment.Credits,
+		"status":     payment.Status,
 	})
 }
 
-func (h *CreditsController) PurchaseCustom(c *fiber.Ctx) error {
+// packagePurchase prices the purchase of an active credit package. When there is none it writes
+// the error response and returns a nil purchase.
+func (h *CreditsController) packagePurchase(c *fiber.Ctx, userID uuid.UUID, packageID string) (*models.PaymentTransaction, error) {
 	ctx := middleware.GetContextWithTrace(c)
-	utils.LogInfo(ctx, "→ PurchaseCustom request")
-
-	userIDInterface := c.Locals("user_id")
-	if userIDInterface == nil {
-		utils.LogWarn(ctx, "⚠️ Unauthorized custom purchase attempt - no user_id", "ip", c.IP())
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	pkg, err := h.creditRepo.GetPackage(c.Context(), packageID)
+	if err == nil && !pkg.Active {
+		err = repository.ErrCreditPackageNotFound
 	}
-	userID, ok := userIDInterface.(string)
-	if !ok || userID == "" {
-		utils.LogWarn(ctx, "⚠️ Invalid user ID in custom purchase", "user_id", userIDInterface)
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
+	if errors.Is(err, repository.ErrCreditPackageNotFound) {
+		utils.LogWarn(ctx, "⚠️ Purchase of unknown credit package", "user_id", userID, "package_id", packageID)
+		return nil, c.Status(404).JSON(fiber.Map{"error": "Credit package not found"})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to load credit package", err, "package_id", packageID)
+		return nil, c.Status(500).JSON(fiber.Map{"error": "Failed to initialize payment"})
 	}
+	return &models.PaymentTransaction{
+		Amount:      pkg.Price,
+		Credits:     pkg.Credits,
+		PackageID:   &pkg.ID,
+		Description: &pkg.Name,
+	}, nil
+}
 
-	var req struct {
-		Credits int `json:"credits"`
+// customPurchase prices the purchase of any number of credits at ₦1 each
+func customPurchase(credits int) *models.PaymentTransaction {
+	description := "Custom credit purchase"
+	return &models.PaymentTransaction{
+		Amount:      credits,
+		Credits:     credits,
+		Description: &description,
 	}
+}
 
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "Failed to parse custom purchase request", err, "user_id", userID)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+// initializePurchase records a priced purchase as pending for the buyer, then initializes its
+// Paystack checkout with their email. A checkout Paystack refuses fails the purchase. With saveCard
+// set, the card paid with is saved when the payment is verified.
+func (h *CreditsController) initializePurchase(c *fiber.Ctx, userID uuid.UUID, payment *models.PaymentTransaction, saveCard bool) error {
+	ctx := middleware.GetContextWithTrace(c)
+	if h.paymentRepo == nil || h.userRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Credit purchases unavailable"})
+	}
+	user, err := h.userRepo.GetByID(c.Context(), userID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to load buyer", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to initialize payment"})
 	}
 
-	if req.Credits < 10 {
-		utils.LogWarn(ctx, "⚠️ Custom purchase below minimum credits", "user_id", userID, "credits", req.Credits, "minimum", 10)
-		return c.Status(400).JSON(fiber.Map{"error": "Minimum 10 credits required"})
+	payment.ID = uuid.New()
+	reference := payment.ID.String()
+	payment.UserID = &userID
+	payment.PaystackReference = &reference
+	if err := h.paymentRepo.CreatePurchase(c.Context(), payment); err != nil {
+		utils.LogError(ctx, "Failed to record credit purchase", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to initialize payment"})
 	}
 
-	amount := req.Credits * 300 // ₦300 per credit
-	transactionID := "txn_custom_" + userID[:8]
+	response := fiber.Map{
+		"ok":         true,
+		"payment_id": payment.ID,
+		"reference":  reference,
+		"package_id": payment.PackageID,
+		"amount":     payment.Amount,
+		"credits":    payment.Credits,
+		"status":     payment.Status,
+	}
+	if h.paystack == nil {
+		utils.LogWarn(ctx, "Paystack not configured, purchase initialized in mock mode", "user_id", userID, "reference", reference)
+		response["message"] = "Payment initialization (mock mode)"
+		return c.Status(201).JSON(response)
+	}
 
-	utils.LogInfo(ctx, "✅ Custom credits purchase completed", "user_id", userID, "credits", req.Credits, "amount", amount, "transaction_id", transactionID)
-	utils.LogInfo(ctx, "← PurchaseCustom completed", "user_id", userID)
+	metadata := map[string]interface{}{"user_id": userID.String(), "payment_id": payment.ID.String(), "credits": payment.Credits}
+	if payment.PackageID != nil {
+		metadata["package_id"] = *payment.PackageID
+	}
+	if saveCard {
+		metadata["save_card"] = true
+	}
+	result, err := h.paystack.InitializeTransaction(user.Email, payment.Amount*100, reference, metadata)
+	if err != nil {
+		utils.LogError(ctx, "Failed to initialize Paystack payment", err, "user_id", userID, "reference", reference)
+		if err := h.paymentRepo.FailPurchase(c.Context(), reference); err != nil {
+			utils.LogError(ctx, "Failed to mark credit purchase failed", err, "reference", reference)
+		}
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to initialize payment"})
+	}
 
-	return c.JSON(fiber.Map{
-		"ok":             true,
-		"transaction_id": transactionID,
-		"amount":         amount,
-		"credits":        req.Credits,
-		"status":         "success",
-		"message":        "Custom credits purchased successfully",
-	})
+	utils.LogInfo(ctx, "✅ Payment initialized", "user_id", userID, "reference", reference, "amount", payment.Amount, "credits", payment.Credits)
+	response["authorization_url"] = result.Data.AuthorizationURL
+	response["access_code"] = result.Data.AccessCode
+	response["message"] = "Payment initialized successfully"
+	return c.Status(201).JSON(response)
 }
 
 func (h *CreditsController) GetCredits(c *fiber.Ctx) error {
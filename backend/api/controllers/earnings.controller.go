@@ -3,29 +3,34 @@ package controllers
 import (
 	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
 	"onetimer-backend/config"
-	"onetimer-backend/services"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
 	"onetimer-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type EarningsController struct {
-	cache    *cache.Cache
-	db       *pgxpool.Pool
-	paystack *services.PaystackService
+	cache          *cache.Cache
+	db             *pgxpool.Pool
+	ledgerRepo     *repository.LedgerRepository
+	withdrawalRepo *repository.WithdrawalRepository
 }
 
-func NewEarningsController(cache *cache.Cache, db *pgxpool.Pool, cfg *config.Config) *EarningsController {
+func NewEarningsController(cache *cache.Cache, db *pgxpool.Pool, cfg *config.Config, ledgerRepo *repository.LedgerRepository, withdrawalRepo *repository.WithdrawalRepository) *EarningsController {
 	return &EarningsController{
-		cache:    cache,
-		db:       db,
-		paystack: services.NewPaystackService(cfg.PaystackSecret),
+		cache:          cache,
+		db:             db,
+		ledgerRepo:     ledgerRepo,
+		withdrawalRepo: withdrawalRepo,
 	}
 }
 
@@ -76,15 +81,23 @@ func (h *EarningsController) GetEarnings(c *fiber.Ctx) error {
 	defer cancel()
 
 	// Get total earnings
-	var totalEarned, balance int
-	if err := h.db.QueryRow(dbCtx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE user_id = $1 AND status = 'completed'", userID).Scan(&totalEarned); err != nil {
+	var totalEarned int
+	if err := h.db.QueryRow(dbCtx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE user_id = $1 AND status = 'available'", userID).Scan(&totalEarned); err != nil {
 		utils.LogError(ctx, "⚠️ Failed to fetch total earnings from database", err, "user_id", userID)
 	}
-	if err := h.db.QueryRow(dbCtx, "SELECT COALESCE(balance, 0) FROM fillers WHERE user_id = $1", userID).Scan(&balance); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to fetch balance from database", err, "user_id", userID)
+
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID in earnings request", "user_id", userID)
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	balances, err := h.ledgerRepo.Balances(dbCtx, fillerID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch balance from ledger", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earnings", "success": false})
 	}
 
-	utils.LogInfo(ctx, "Earnings calculated", "user_id", userID, "total_earned", totalEarned, "balance", balance)
+	utils.LogInfo(ctx, "Earnings calculated", "user_id", userID, "total_earned", totalEarned, "balance", balances.AvailableBalance)
 
 	// Get recent transactions
 	rows, err := h.db.Query(dbCtx, "SELECT id, amount, type, status, created_at FROM earnings WHERE user_id = $1 ORDER BY created_at DESC LIMIT 20", userID)
@@ -109,7 +122,8 @@ func (h *EarningsController) GetEarnings(c *fiber.Ctx) error {
 	}
 
 	earnings := fiber.Map{
-		"balance": balance, "total_earned": totalEarned, "transactions": transactions, "success": true,
+		"balance": balances.AvailableBalance, "pending": balances.PendingEarnings, "total_earned": totalEarned,
+		"transactions": transactions, "success": true,
 	}
 
 	// Cache for 2 minutes
@@ -159,48 +173,48 @@ func (h *EarningsController) WithdrawEarnings(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid amount"})
 	}
 
-	// Generate withdrawal reference
-	withdrawalRef := "WITHDRAW_" + userID[:8] + "_" + time.Now().Format("20060102150405")
-	utils.LogInfo(ctx, "Withdrawal reference generated", "user_id", userID, "ref", withdrawalRef)
-
-	// For now, we would need to:
-	// 1. Create/get transfer recipient from bank details
-	// 2. Initiate the transfer
-	// Since we don't have CreateTransferRecipient yet, we'll use the InitiateTransfer directly
-	// with a placeholder recipient ID (in real implementation, you'd create the recipient first)
-
-	// Mock recipient ID - in production, create from bank details first
-	recipientID := 0 // Would be obtained from CreateTransferRecipient
-
-	if h.paystack != nil {
-		utils.LogInfo(ctx, "Initiating Paystack transfer", "user_id", userID, "amount", req.Amount, "ref", withdrawalRef)
-
-		// Initiate transfer with amount and reason
-		result, err := h.paystack.InitiateTransfer(req.Amount, recipientID, "OneTimer withdrawal", withdrawalRef)
-		if err != nil {
-			utils.LogError(ctx, "⚠️ Paystack transfer failed", err, "user_id", userID, "amount", req.Amount, "ref", withdrawalRef)
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to process withdrawal: " + err.Error()})
-		}
+	if req.BankCode == "" || req.AccountNumber == "" {
+		utils.LogWarn(ctx, "⚠️ Withdrawal without bank details", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Bank code and account number are required"})
+	}
 
-		utils.LogInfo(ctx, "✅ Withdrawal processed successfully via Paystack", "user_id", userID, "amount", req.Amount, "ref", withdrawalRef, "status", result.Data.Status)
-
-		return c.JSON(fiber.Map{
-			"ok":             true,
-			"withdrawal_ref": result.Data.Reference,
-			"transfer_code":  result.Data.TransferCode,
-			"amount":         req.Amount,
-			"status":         result.Data.Status,
-			"account_name":   req.AccountName,
-			"account_number": req.AccountNumber,
-			"message":        "Withdrawal processed successfully",
-		})
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if h.withdrawalRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Withdrawals unavailable"})
 	}
 
-	utils.LogWarn(ctx, "⚠️ Paystack service unavailable, processing without transfer", "user_id", userID, "amount", req.Amount)
+	// The withdrawal is paid out by the payout worker once an admin approves it
+	withdrawal := models.Withdrawal{
+		ID:            uuid.New(),
+		UserID:        fillerID,
+		Amount:        req.Amount,
+		BankName:      req.BankName,
+		AccountNumber: req.AccountNumber,
+		AccountName:   req.AccountName,
+		BankCode:      req.BankCode,
+	}
+	err = h.withdrawalRepo.Create(c.Context(), &withdrawal)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		utils.LogWarn(ctx, "⚠️ Insufficient balance for withdrawal", "user_id", userID, "amount", req.Amount)
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to create withdrawal", err, "user_id", userID, "amount", req.Amount)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create withdrawal request"})
+	}
 
-	return c.JSON(fiber.Map{
-		"ok":      true,
-		"message": "Withdrawal processed successfully",
+	utils.LogInfo(ctx, "✅ Withdrawal requested", "user_id", userID, "withdrawal_id", withdrawal.ID, "amount", req.Amount)
+	return c.Status(201).JSON(fiber.Map{
+		"ok":             true,
+		"withdrawal_id":  withdrawal.ID,
+		"amount":         req.Amount,
+		"status":         withdrawal.Status,
+		"account_name":   req.AccountName,
+		"account_number": req.AccountNumber,
+		"message":        "Withdrawal request submitted successfully",
 	})
 }
 
@@ -7,7 +7,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/services"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,18 +21,22 @@ import (
 )
 
 type ExportController struct {
-	cache *cache.Cache
-	db    *pgxpool.Pool
+	cache        *cache.Cache
+	db           *pgxpool.Pool
+	types        *services.QuestionTypeRegistry
+	translations *services.TranslationService
 }
 
 func NewExportController(cache *cache.Cache, db *pgxpool.Pool) *ExportController {
-	return &ExportController{cache: cache, db: db}
+	return &ExportController{cache: cache, db: db, types: services.NewQuestionTypeRegistry(), translations: services.NewTranslationService()}
 }
 
-// ExportSurveyResponses exports survey responses in various formats
+// ExportSurveyResponses exports survey responses in various formats, limited to one survey
+// version with ?version=N
 func (h *ExportController) ExportSurveyResponses(c *fiber.Ctx) error {
 	surveyID := c.Params("id")
 	format := c.Query("format", "csv") // csv, json, xlsx
+	version := c.QueryInt("version", 0)
 
 	// Validate survey ID
 	if _, err := uuid.Parse(surveyID); err != nil {
@@ -37,26 +44,51 @@ func (h *ExportController) ExportSurveyResponses(c *fiber.Ctx) error {
 	}
 
 	// Get survey responses
-	responses, err := h.getSurveyResponsesForExport(surveyID)
+	responses, err := h.getSurveyResponsesForExport(surveyID, version)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch responses"})
 	}
+	questions, err := h.getSurveyQuestionsForExport(surveyID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
+	h.canonicalizeAnswers(responses, questions)
 
 	switch format {
 	case "csv":
-		return h.exportCSV(c, responses, surveyID)
+		return h.exportCSV(c, responses, questions, surveyID)
 	case "json":
 		return h.exportJSON(c, responses, surveyID)
 	case "pdf":
-		return h.exportPDF(c, responses, surveyID)
+		return h.exportPDF(c, responses, questions, surveyID)
 	case "docx":
-		return h.exportDOCX(c, responses, surveyID)
+		return h.exportDOCX(c, responses, questions, surveyID)
 	default:
 		return c.Status(400).JSON(fiber.Map{"error": "Unsupported format"})
 	}
 }
 
-func (h *ExportController) exportCSV(c *fiber.Ctx, responses []SurveyResponse, surveyID string) error {
+// canonicalizeAnswers maps answers given in a translated survey back to the canonical option, row
+// and column labels, so every response reports choices the same way whatever language it was in
+func (h *ExportController) canonicalizeAnswers(responses []SurveyResponse, questions map[string]models.Question) {
+	list := make([]models.Question, 0, len(questions))
+	for _, q := range questions {
+		list = append(list, q)
+	}
+	for i := range responses {
+		responses[i].Answers = h.translations.CanonicalAnswers(list, responses[i].Locale, responses[i].Answers)
+	}
+}
+
+// formatAnswer renders an answer as its question type writes it, or as its plain value when the question is unknown
+func (h *ExportController) formatAnswer(questions map[string]models.Question, questionID string, answer interface{}) string {
+	if q, ok := questions[questionID]; ok {
+		return h.types.Format(q, answer)
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+func (h *ExportController) exportCSV(c *fiber.Ctx, responses []SurveyResponse, questions map[string]models.Question, surveyID string) error {
 	filename := fmt.Sprintf("survey_%s_responses_%s.csv", surveyID[:8], time.Now().Format("20060102"))
 
 	c.Set("Content-Type", "text/csv")
@@ -71,14 +103,22 @@ func (h *ExportController) exportCSV(c *fiber.Ctx, responses []SurveyResponse, s
 	}
 
 	// Write header
-	header := []string{"Response ID", "Filler Email", "Submitted At", "Quality Score"}
+	header := []string{"Response ID", "Filler Email", "Submitted At", "Quality Score", "Survey Version", "Locale", "Question Order", "Option Order"}
 
-	// Add question headers
-	if len(responses) > 0 {
-		for questionID := range responses[0].Answers {
-			header = append(header, fmt.Sprintf("Question_%s", questionID[:8]))
+	// Add question headers; versions have different questions, so collect them across all responses
+	var questionIDs []string
+	seen := make(map[string]bool)
+	for _, response := range responses {
+		for questionID := range response.Answers {
+			if !seen[questionID] {
+				seen[questionID] = true
+				questionIDs = append(questionIDs, questionID)
+			}
 		}
 	}
+	for _, questionID := range questionIDs {
+		header = append(header, fmt.Sprintf("Question_%s", shortQuestionID(questionID)))
+	}
 	writer.Write(header)
 
 	// Write data rows
@@ -88,12 +128,16 @@ func (h *ExportController) exportCSV(c *fiber.Ctx, responses []SurveyResponse, s
 			response.FillerEmail,
 			response.CompletedAt.Format("2006-01-02 15:04:05"),
 			strconv.Itoa(response.QualityScore),
+			strconv.Itoa(response.SurveyVersion),
+			response.Locale,
+			formatQuestionOrder(response.ServedOrder),
+			formatOptionOrder(response.ServedOrder),
 		}
 
 		// Add answers
-		for questionID := range responses[0].Answers {
+		for _, questionID := range questionIDs {
 			if answer, exists := response.Answers[questionID]; exists {
-				row = append(row, fmt.Sprintf("%v", answer))
+				row = append(row, h.formatAnswer(questions, questionID, answer))
 			} else {
 				row = append(row, "")
 			}
@@ -121,23 +165,59 @@ func (h *ExportController) exportJSON(c *fiber.Ctx, responses []SurveyResponse,
 }
 
 type SurveyResponse struct {
-	ID           string                 `json:"id"`
-	FillerEmail  string                 `json:"filler_email"`
-	Answers      map[string]interface{} `json:"answers"`
-	CompletedAt  time.Time              `json:"completed_at"`
-	QualityScore int                    `json:"quality_score"`
+	ID            string                 `json:"id"`
+	FillerEmail   string                 `json:"filler_email"`
+	Answers       map[string]interface{} `json:"answers"`
+	CompletedAt   time.Time              `json:"completed_at"`
+	QualityScore  int                    `json:"quality_score"`
+	SurveyVersion int                    `json:"survey_version"`
+	Locale        string                 `json:"locale"`
+	ServedOrder   *models.ServedOrder    `json:"served_order,omitempty"`
+}
+
+// shortQuestionID abbreviates a question ID the way CSV question headers do
+func shortQuestionID(questionID string) string {
+	return questionID[:min(8, len(questionID))]
+}
+
+// formatQuestionOrder lists the questions in the order the filler was served them
+func formatQuestionOrder(order *models.ServedOrder) string {
+	if order == nil {
+		return ""
+	}
+	ids := make([]string, len(order.Questions))
+	for i, id := range order.Questions {
+		ids[i] = shortQuestionID(id)
+	}
+	return strings.Join(ids, " > ")
 }
 
-func (h *ExportController) getSurveyResponsesForExport(surveyID string) ([]SurveyResponse, error) {
+// formatOptionOrder lists the served option order of each question whose options were shuffled
+func formatOptionOrder(order *models.ServedOrder) string {
+	if order == nil {
+		return ""
+	}
+	var parts []string
+	for _, id := range order.Questions {
+		if options, ok := order.Options[id]; ok {
+			parts = append(parts, fmt.Sprintf("%s: %s", shortQuestionID(id), strings.Join(options, " > ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// getSurveyResponsesForExport loads completed responses grouped by survey version, newest first
+// within each version; version 0 exports every version
+func (h *ExportController) getSurveyResponsesForExport(surveyID string, version int) ([]SurveyResponse, error) {
 	query := `
-		SELECT r.id, u.email, r.answers, r.completed_at, r.quality_score
+		SELECT r.id, u.email, r.answers, r.completed_at, r.quality_score, r.survey_version, r.served_order, r.locale
 		FROM responses r
 		JOIN users u ON r.filler_id = u.id
-		WHERE r.survey_id = $1 AND r.status = 'completed'
-		ORDER BY r.completed_at DESC
+		WHERE r.survey_id = $1 AND r.status = 'completed' AND ($2 = 0 OR r.survey_version = $2)
+		ORDER BY r.survey_version, r.completed_at DESC
 	`
 
-	rows, err := h.db.Query(context.Background(), query, surveyID)
+	rows, err := h.db.Query(context.Background(), query, surveyID, version)
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +227,7 @@ func (h *ExportController) getSurveyResponsesForExport(surveyID string) ([]Surve
 	for rows.Next() {
 		var response SurveyResponse
 		var answersJSON string
+		var servedOrder []byte
 
 		err := rows.Scan(
 			&response.ID,
@@ -154,6 +235,9 @@ func (h *ExportController) getSurveyResponsesForExport(surveyID string) ([]Surve
 			&answersJSON,
 			&response.CompletedAt,
 			&response.QualityScore,
+			&response.SurveyVersion,
+			&servedOrder,
+			&response.Locale,
 		)
 		if err != nil {
 			continue
@@ -164,13 +248,38 @@ func (h *ExportController) getSurveyResponsesForExport(surveyID string) ([]Surve
 			response.Answers = make(map[string]interface{})
 		}
 
+		stored := models.Response{ServedOrder: servedOrder}
+		response.ServedOrder = stored.ParsedServedOrder()
+
 		responses = append(responses, response)
 	}
 
 	return responses, nil
 }
 
-func (h *ExportController) exportPDF(c *fiber.Ctx, responses []SurveyResponse, surveyID string) error {
+// getSurveyQuestionsForExport loads the questions of every version of a survey, keyed by question ID
+func (h *ExportController) getSurveyQuestionsForExport(surveyID string) (map[string]models.Question, error) {
+	rows, err := h.db.Query(context.Background(), `
+		SELECT id, survey_id, type, title, options, settings, translations
+		FROM questions
+		WHERE survey_id = $1`, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := make(map[string]models.Question)
+	for rows.Next() {
+		var q models.Question
+		if err := rows.Scan(&q.ID, &q.SurveyID, &q.Type, &q.Title, &q.Options, &q.Settings, &q.Translations); err != nil {
+			continue
+		}
+		questions[q.ID.String()] = q
+	}
+	return questions, rows.Err()
+}
+
+func (h *ExportController) exportPDF(c *fiber.Ctx, responses []SurveyResponse, questions map[string]models.Question, surveyID string) error {
 	filename := fmt.Sprintf("survey_%s_responses_%s.pdf", surveyID[:8], time.Now().Format("20060102"))
 
 	pdf := gofpdf.New("P", "mm", "A4", "")
@@ -184,7 +293,7 @@ func (h *ExportController) exportPDF(c *fiber.Ctx, responses []SurveyResponse, s
 		pdf.Cell(40, 10, fmt.Sprintf("Response %d: %s", i+1, response.FillerEmail))
 		pdf.Ln(8)
 		for qID, answer := range response.Answers {
-			pdf.Cell(40, 10, fmt.Sprintf("Q%s: %v", qID[:4], answer))
+			pdf.Cell(40, 10, fmt.Sprintf("Q%s: %s", qID[:4], h.formatAnswer(questions, qID, answer)))
 			pdf.Ln(6)
 		}
 		pdf.Ln(4)
@@ -198,7 +307,7 @@ func (h *ExportController) exportPDF(c *fiber.Ctx, responses []SurveyResponse, s
 	return c.Send(buf.Bytes())
 }
 
-func (h *ExportController) exportDOCX(c *fiber.Ctx, responses []SurveyResponse, surveyID string) error {
+func (h *ExportController) exportDOCX(c *fiber.Ctx, responses []SurveyResponse, questions map[string]models.Question, surveyID string) error {
 	filename := fmt.Sprintf("survey_%s_responses_%s.docx", surveyID[:8], time.Now().Format("20060102"))
 
 	doc := document.New()
@@ -217,7 +326,7 @@ func (h *ExportController) exportDOCX(c *fiber.Ctx, responses []SurveyResponse,
 		for qID, answer := range response.Answers {
 			para = doc.AddParagraph()
 			run = para.AddRun()
-			run.AddText(fmt.Sprintf("Q%s: %v", qID[:4], answer))
+			run.AddText(fmt.Sprintf("Q%s: %s", qID[:4], h.formatAnswer(questions, qID, answer)))
 		}
 	}
 
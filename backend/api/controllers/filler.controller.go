@@ -4,22 +4,32 @@ import (
 	"context"
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
+	"onetimer-backend/services"
 	"onetimer-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type FillerController struct {
-	cache *cache.Cache
-	db    *pgxpool.Pool
+	cache      *cache.Cache
+	db         *pgxpool.Pool
+	surveyRepo *repository.SurveyRepository
+	ledgerRepo *repository.LedgerRepository
+	targets    *services.TargetingService
 }
 
-func NewFillerController(cache *cache.Cache, db *pgxpool.Pool) *FillerController {
+func NewFillerController(cache *cache.Cache, db *pgxpool.Pool, surveyRepo *repository.SurveyRepository, ledgerRepo *repository.LedgerRepository) *FillerController {
 	return &FillerController{
-		cache: cache,
-		db:    db,
+		cache:      cache,
+		db:         db,
+		surveyRepo: surveyRepo,
+		ledgerRepo: ledgerRepo,
+		targets:    services.NewTargetingService(),
 	}
 }
 
@@ -77,16 +87,24 @@ func (h *FillerController) GetDashboard(c *fiber.Ctx) error {
 	// Get completed surveys count
 	var completedCount int
 	err = h.db.QueryRow(context.Background(),
-		"SELECT COUNT(*) FROM responses WHERE filler_id = $1",
+		"SELECT COUNT(*) FROM responses WHERE filler_id = $1 AND status = 'completed'",
 		userID).Scan(&completedCount)
 
-	// Get total earnings
+	// Get total earnings, leaving out rewards reversed by the creator
 	var totalEarnings int
 	err = h.db.QueryRow(context.Background(),
-		"SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE user_id = $1",
-		userID).Scan(&totalEarnings)
+		"SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE user_id = $1 AND status <> $2",
+		userID, models.EarningStatusReversed).Scan(&totalEarnings)
+
+	balances := &models.Balances{}
+	if fillerID, err := uuid.Parse(userID); err == nil {
+		if balances, err = h.ledgerRepo.Balances(c.Context(), fillerID); err != nil {
+			utils.LogError(ctx, "⚠️ Failed to fetch balances", err, "user_id", userID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch balances"})
+		}
+	}
 
-	utils.LogInfo(ctx, "Stats retrieved", "active_surveys", activeSurveyCount, "completed", completedCount, "earnings", totalEarnings)
+	utils.LogInfo(ctx, "Stats retrieved", "active_surveys", activeSurveyCount, "completed", completedCount, "earnings", totalEarnings, "balance", balances.AvailableBalance)
 
 	// Get recent surveys (limit 5)
 	rows, err := h.db.Query(context.Background(),
@@ -129,6 +147,8 @@ func (h *FillerController) GetDashboard(c *fiber.Ctx) error {
 				"active_surveys":    activeSurveyCount,
 				"completed_surveys": completedCount,
 				"total_earnings":    totalEarnings,
+				"pending_earnings":  balances.PendingEarnings,
+				"balance":           balances.AvailableBalance,
 			},
 			"recent_surveys": recentSurveys,
 		},
@@ -155,9 +175,22 @@ func (h *FillerController) GetAvailableSurveys(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"success": true, "data": mockSurveys, "count": len(mockSurveys)})
 	}
 
+	userID, _ := c.Locals("user_id").(string)
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID")
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	// Surveys are only listed to fillers inside their targeting whose segment quotas are still open
+	profile, err := h.surveyRepo.GetFillerProfile(dbCtx, fillerID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch filler profile", err, "user_id", fillerID)
+	}
+
 	// Optimized query with limit and timeout
 	rows, err := h.db.Query(dbCtx,
-		"SELECT id, title, description, reward_amount, category, estimated_duration FROM surveys WHERE status = $1 ORDER BY created_at DESC LIMIT 50",
+		"SELECT id, title, description, reward_amount, category, estimated_duration, targeting, quotas FROM surveys WHERE status = $1 AND (expires_at IS NULL OR expires_at > NOW()) ORDER BY created_at DESC LIMIT 50",
 		"active")
 
 	if err != nil {
@@ -167,22 +200,40 @@ func (h *FillerController) GetAvailableSurveys(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	var surveys []fiber.Map
+	var candidates []models.Survey
 	for rows.Next() {
-		var id, title, description, category string
-		var rewardAmount, estimatedDuration int
+		var survey models.Survey
+		if err := rows.Scan(&survey.ID, &survey.Title, &survey.Description, &survey.RewardAmount, &survey.Category, &survey.EstimatedDuration, &survey.Targeting, &survey.Quotas); err != nil {
+			continue
+		}
+		candidates = append(candidates, survey)
+	}
+	rows.Close()
 
-		if err := rows.Scan(&id, &title, &description, &rewardAmount, &category, &estimatedDuration); err != nil {
+	var surveys []fiber.Map
+	for _, survey := range candidates {
+		if len(h.targets.Mismatches(survey.TargetingCriteria(), profile)) > 0 {
 			continue
 		}
+		if quotas := h.targets.ApplicableQuotas(survey.SegmentQuotas(), profile); len(quotas) > 0 {
+			full, err := h.surveyRepo.FullSegments(dbCtx, survey.ID, quotas)
+			if err != nil || len(full) > 0 {
+				continue
+			}
+		}
+
+		var category string
+		if survey.Category != nil {
+			category = *survey.Category
+		}
 
 		surveys = append(surveys, fiber.Map{
-			"id":                 id,
-			"title":              title,
-			"description":        description,
-			"reward":             rewardAmount,
+			"id":                 survey.ID.String(),
+			"title":              survey.Title,
+			"description":        survey.Description,
+			"reward":             survey.RewardAmount,
 			"category":           category,
-			"estimated_duration": estimatedDuration,
+			"estimated_duration": survey.EstimatedDuration,
 		})
 	}
 
@@ -224,7 +275,7 @@ func (h *FillerController) GetCompletedSurveys(c *fiber.Ctx) error {
 	}
 
 	rows, err := h.db.Query(context.Background(),
-		"SELECT s.id, s.title, r.completed_at FROM surveys s JOIN responses r ON s.id = r.survey_id WHERE r.filler_id = $1 ORDER BY r.completed_at DESC",
+		"SELECT s.id, s.title, r.completed_at FROM surveys s JOIN responses r ON s.id = r.survey_id WHERE r.filler_id = $1 AND r.status = 'completed' ORDER BY r.completed_at DESC",
 		userID)
 
 	if err != nil {
@@ -275,22 +326,47 @@ func (h *FillerController) GetEarningsHistory(c *fiber.Ctx) error {
 
 	utils.LogInfo(ctx, "Fetching earnings history", "user_id", userID)
 
-	// Return mock earnings data (same as general earnings endpoint)
-	mockEarnings := []fiber.Map{
-		{"id": "e1", "amount": 300, "source": "survey_completion", "status": "completed", "created_at": time.Now().AddDate(0, 0, -1), "title": "Survey #1 - Consumer Preferences", "type": "earning"},
-		{"id": "e2", "amount": 450, "source": "survey_completion", "status": "completed", "created_at": time.Now().AddDate(0, 0, -2), "title": "Survey #2 - Technology Usage", "type": "earning"},
-		{"id": "e3", "amount": 1000, "source": "referral", "status": "completed", "created_at": time.Now().AddDate(0, 0, -3), "title": "Referral Bonus", "type": "referral"},
+	if h.ledgerRepo == nil {
+		utils.LogWarn(ctx, "⚠️ Ledger unavailable, returning mock data")
+		// Return mock earnings data (same as general earnings endpoint)
+		mockEarnings := []fiber.Map{
+			{"id": "e1", "amount": 300, "source": "survey_completion", "status": "completed", "created_at": time.Now().AddDate(0, 0, -1), "title": "Survey #1 - Consumer Preferences", "type": "earning"},
+			{"id": "e2", "amount": 450, "source": "survey_completion", "status": "completed", "created_at": time.Now().AddDate(0, 0, -2), "title": "Survey #2 - Technology Usage", "type": "earning"},
+			{"id": "e3", "amount": 1000, "source": "referral", "status": "completed", "created_at": time.Now().AddDate(0, 0, -3), "title": "Referral Bonus", "type": "referral"},
+		}
+		return c.JSON(fiber.Map{"success": true, "data": mockEarnings, "count": len(mockEarnings), "total_earnings": 1750, "balance": 1750})
 	}
 
-	totalEarnings := 1750
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID")
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
 
-	utils.LogInfo(ctx, "✅ Earnings history retrieved", "user_id", userID, "count", len(mockEarnings), "total", totalEarnings)
+	entries, total, err := h.ledgerRepo.Entries(c.Context(), fillerID, limit, offset)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch ledger entries", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earnings history", "success": false})
+	}
+	balances, err := h.ledgerRepo.Balances(c.Context(), fillerID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch balances", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch earnings history", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Earnings history retrieved", "user_id", userID, "count", len(entries), "balance", balances.AvailableBalance)
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"data": mockEarnings,
-		"count": len(mockEarnings),
-		"total_earnings": totalEarnings,
-		"balance": totalEarnings,
+		"success":          true,
+		"data":             entries,
+		"count":            len(entries),
+		"total":            total,
+		"pending_earnings": balances.PendingEarnings,
+		"balance":          balances.AvailableBalance,
 	})
 }
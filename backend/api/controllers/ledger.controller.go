@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"onetimer-backend/api/middleware"
+	"onetimer-backend/cache"
+	"onetimer-backend/repository"
+	"onetimer-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// LedgerController serves balances and account statements from the ledger
+type LedgerController struct {
+	cache      *cache.Cache
+	ledgerRepo *repository.LedgerRepository
+}
+
+func NewLedgerController(cache *cache.Cache, ledgerRepo *repository.LedgerRepository) *LedgerController {
+	return &LedgerController{cache: cache, ledgerRepo: ledgerRepo}
+}
+
+// GetBalances returns the caller's credit, pending earnings and wallet balances
+func (h *LedgerController) GetBalances(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetBalances request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID in balances request")
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	balances, err := h.ledgerRepo.Balances(c.Context(), userID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to fetch balances", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch balances", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Balances retrieved", "user_id", userID)
+	return c.JSON(fiber.Map{"success": true, "data": balances})
+}
+
+// GetEntries returns the postings to the caller's accounts, newest first
+func (h *LedgerController) GetEntries(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetEntries request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID in ledger entries request")
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	limit := c.QueryInt("limit", 50)
+	offset := c.QueryInt("offset", 0)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	entries, total, err := h.ledgerRepo.Entries(c.Context(), userID, limit, offset)
+	if err != nil {
+		utils.LogError(ctx, "Failed to fetch ledger entries", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch ledger entries", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Ledger entries retrieved", "user_id", userID, "count", len(entries))
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    entries,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+// GetPlatformBalances totals every account kind across the platform, for finance
+func (h *LedgerController) GetPlatformBalances(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetPlatformBalances request")
+
+	totals, err := h.ledgerRepo.PlatformBalances(c.Context())
+	if err != nil {
+		utils.LogError(ctx, "Failed to fetch platform balances", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch platform balances", "success": false})
+	}
+
+	// Every journal balances, so anything but zero means the ledger has been tampered with
+	sum := 0
+	for _, total := range totals {
+		sum += total
+	}
+	if sum != 0 {
+		utils.LogWarn(ctx, "⚠️ Ledger does not balance", "imbalance", sum)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"data":     totals,
+		"balanced": sum == 0,
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
 	"onetimer-backend/utils"
 	"time"
 
@@ -41,6 +42,7 @@ func (h *OnboardingController) CompleteFillerOnboarding(c *fiber.Ctx) error {
 			Employment  string   `json:"employment"`
 			IncomeRange string   `json:"income_range"`
 			Interests   []string `json:"interests"`
+			Language    string   `json:"preferred_language"` // locale surveys are served in when translated
 		} `json:"profile"`
 	}
 
@@ -53,6 +55,14 @@ func (h *OnboardingController) CompleteFillerOnboarding(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Missing required fields"})
 	}
 
+	var preferredLanguage *string
+	if req.Profile.Language != "" {
+		if !models.IsLocale(req.Profile.Language) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unsupported preferred language"})
+		}
+		preferredLanguage = &req.Profile.Language
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -84,12 +94,12 @@ func (h *OnboardingController) CompleteFillerOnboarding(c *fiber.Ctx) error {
 	// Create user profile
 	interestsJSON, _ := json.Marshal(req.Profile.Interests)
 	profileQuery := `
-		INSERT INTO user_profiles (user_id, age_range, gender, country, state, education, employment, income_range, interests, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		INSERT INTO user_profiles (user_id, age_range, gender, country, state, education, employment, income_range, interests, preferred_language, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
 	`
 	_, err = h.db.Exec(context.Background(), profileQuery, userID, req.Profile.AgeRange, req.Profile.Gender,
 		req.Profile.Country, req.Profile.State, req.Profile.Education, req.Profile.Employment,
-		req.Profile.IncomeRange, string(interestsJSON))
+		req.Profile.IncomeRange, string(interestsJSON), preferredLanguage)
 	if err != nil {
 		// Rollback user creation if profile fails
 		h.db.Exec(context.Background(), "DELETE FROM users WHERE id = $1", userID)
@@ -275,6 +285,47 @@ func (h *OnboardingController) UpdateDemographics(c *fiber.Ctx) error {
 	})
 }
 
+// UpdateLanguage sets the language the user prefers to take surveys in. Surveys translated into it
+// are served in it unless a request asks for another; an empty language clears the preference.
+func (h *OnboardingController) UpdateLanguage(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req struct {
+		Language string `json:"preferred_language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request data"})
+	}
+
+	var language *string
+	if req.Language != "" {
+		if !models.IsLocale(req.Language) {
+			return c.Status(400).JSON(fiber.Map{"error": "Unsupported language"})
+		}
+		language = &req.Language
+	}
+
+	tag, err := h.db.Exec(context.Background(),
+		"UPDATE user_profiles SET preferred_language = $2, updated_at = NOW() WHERE user_id = $1",
+		userID, language)
+	if err == nil && tag.RowsAffected() == 0 {
+		_, err = h.db.Exec(context.Background(),
+			"INSERT INTO user_profiles (user_id, preferred_language, created_at, updated_at) VALUES ($1, $2, NOW(), NOW())",
+			userID, language)
+	}
+	if err != nil {
+		utils.LogError(context.Background(), "Failed to update preferred language", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update language"})
+	}
+
+	return c.JSON(fiber.Map{
+		"ok":                 true,
+		"user_id":            userID,
+		"preferred_language": language,
+		"message":            "Language updated successfully",
+	})
+}
+
 // GetEligibleSurveys returns surveys user is eligible for based on demographics
 func (h *OnboardingController) GetEligibleSurveys(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
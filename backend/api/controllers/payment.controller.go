@@ -2,10 +2,16 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
 	"onetimer-backend/services"
 	"onetimer-backend/utils"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,85 +21,40 @@ import (
 type PaymentController struct {
 	cache           *cache.Cache
 	paystackService *services.PaystackService
+	paymentRepo     *repository.PaymentRepository
+	charges         *services.ChargeFulfiller
+	refunds         *services.PurchaseRefunder
+	auditRepo       *repository.AuditRepository
+	invoices        *services.Invoicer
+	methodRepo      *repository.PaymentMethodRepository
 }
 
-// Initialize with Paystack secret key from environment
-func NewPaymentController(cache *cache.Cache, paystackSecretKey string) *PaymentController {
-	var ps *services.PaystackService
-	if paystackSecretKey != "" {
-		ps = services.NewPaystackService(paystackSecretKey)
-	}
-	return &PaymentController{
+// NewPaymentController takes a nil Paystack service when no secret key is configured
+func NewPaymentController(cache *cache.Cache, paystackService *services.PaystackService, paymentRepo *repository.PaymentRepository, auditRepo *repository.AuditRepository) *PaymentController {
+	h := &PaymentController{
 		cache:           cache,
-		paystackService: ps,
-	}
-}
-
-// PurchaseCredits handles credit purchases via Paystack
-func (h *PaymentController) PurchaseCredits(c *fiber.Ctx) error {
-	ctx := context.Background()
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		utils.LogError(ctx, "Unauthorized credit purchase attempt", nil, "ip", c.IP())
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var req struct {
-		Email   string `json:"email"`
-		Amount  int    `json:"amount"` // in kobo
-		Credits int    `json:"credits"`
-	}
-
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "Invalid purchase request", err, "user_id", userID)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
-	}
-
-	// Validate purchase
-	if req.Amount < 10000 { // Minimum ₦100
-		utils.LogWarn(ctx, "⚠️ Purchase amount too low", "amount", req.Amount, "user_id", userID)
-		return c.Status(400).JSON(fiber.Map{"error": "Minimum purchase is ₦100 (10000 kobo)"})
-	}
-
-	if req.Credits < 10 {
-		utils.LogWarn(ctx, "⚠️ Credits too low", "credits", req.Credits, "user_id", userID)
-		return c.Status(400).JSON(fiber.Map{"error": "Minimum purchase is 10 credits"})
-	}
-
-	// If Paystack service not configured, return mock
-	if h.paystackService == nil {
-		utils.LogWarn(ctx, "Paystack not configured, returning mock response", "user_id", userID)
-		transactionID := uuid.New()
-		return c.Status(201).JSON(fiber.Map{
-			"ok":             true,
-			"transaction_id": transactionID,
-			"amount":         req.Amount,
-			"credits":        req.Credits,
-			"status":         "pending",
-			"message":        "Payment initialization (mock mode)",
-		})
+		paystackService: paystackService,
+		paymentRepo:     paymentRepo,
+		auditRepo:       auditRepo,
 	}
-
-	// Initialize Paystack transaction
-	transactionRef := fmt.Sprintf("PS_%s_%d", userID[:8], time.Now().Unix())
-	result, err := h.paystackService.InitializeTransaction(req.Email, req.Amount, transactionRef)
-	if err != nil {
-		utils.LogError(ctx, "Failed to initialize Paystack transaction", err, "user_id", userID, "amount", req.Amount)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to initialize payment"})
+	if paymentRepo != nil {
+		h.charges = services.NewChargeFulfiller(paymentRepo)
+		h.refunds = services.NewPurchaseRefunder(paymentRepo)
 	}
+	return h
+}
 
-	utils.LogInfo(ctx, "✅ Payment initialized", "user_id", userID, "reference", result.Data.Reference, "amount", req.Amount, "credits", req.Credits)
+// WithInvoices issues invoices for paid credit purchases, emailing each to its buyer once the
+// payment is verified
+func (h *PaymentController) WithInvoices(invoices *services.Invoicer) *PaymentController {
+	h.invoices = invoices
+	return h
+}
 
-	return c.Status(201).JSON(fiber.Map{
-		"ok":                true,
-		"reference":         result.Data.Reference,
-		"access_code":       result.Data.AccessCode,
-		"authorization_url": result.Data.AuthorizationURL,
-		"amount":            req.Amount,
-		"credits":           req.Credits,
-		"user_id":           userID,
-		"message":           "Payment initialized successfully",
-	})
+// WithPaymentMethods saves the cards creators pay with, to charge them again without a checkout
+func (h *PaymentController) WithPaymentMethods(methodRepo *repository.PaymentMethodRepository) *PaymentController {
+	h.methodRepo = methodRepo
+	return h
 }
 
 // VerifyPayment verifies Paystack payment
@@ -146,8 +107,32 @@ func (h *PaymentController) VerifyPayment(c *fiber.Ctx) error {
 		})
 	}
 
-	// Calculate credits (e.g., 1 credit per ₦100)
-	creditsAdded := result.Data.Amount / 10000
+	// Credits are held in naira; the webhook may already have granted them, and they are granted once
+	creditsAdded := result.Data.Amount / 100
+	if h.paymentRepo != nil {
+		buyerID := chargeBuyer(result.Data.Metadata)
+		if buyerID == nil || buyerID.String() != userID {
+			utils.LogWarn(ctx, "⚠️ Payment belongs to another user", "reference", reference, "user_id", userID)
+			return c.Status(403).JSON(fiber.Map{"error": "This payment does not belong to you"})
+		}
+		var granted bool
+		creditsAdded, granted, err = h.charges.Fulfil(c.Context(), reference, buyerID, result.Data.Amount/100)
+		if errors.Is(err, services.ErrPaymentAmountMismatch) {
+			utils.LogWarn(ctx, "⚠️ Amount paid does not match the purchase", "reference", reference, "user_id", userID, "amount", result.Data.Amount)
+			return c.Status(409).JSON(fiber.Map{"error": "The amount paid does not match the purchase"})
+		}
+		if err != nil {
+			utils.LogError(ctx, "Failed to grant credits", err, "reference", reference, "user_id", userID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to grant credits"})
+		}
+		if granted && h.invoices != nil {
+			// Failing to invoice never fails the verification; the invoice can still be downloaded
+			go h.invoices.Send(context.Background(), reference)
+		}
+		if save, _ := result.Data.Metadata["save_card"].(bool); save {
+			h.saveCard(ctx, *buyerID, result)
+		}
+	}
 
 	utils.LogInfo(ctx, "✅ Payment verified successfully", "reference", reference, "user_id", userID, "amount", result.Data.Amount, "credits", creditsAdded)
 
@@ -164,66 +149,35 @@ func (h *PaymentController) VerifyPayment(c *fiber.Ctx) error {
 	})
 }
 
-// ProcessBatchPayouts handles admin batch payout processing
-func (h *PaymentController) ProcessBatchPayouts(c *fiber.Ctx) error {
-	ctx := context.Background()
-	var req struct {
-		WithdrawalIDs []string `json:"withdrawal_ids"`
-		AdminNote     string   `json:"admin_note,omitempty"`
-	}
-
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "Invalid batch payout request", err)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
-	}
-
-	adminID, ok := c.Locals("user_id").(string)
+// GetPaymentMethods returns user's saved payment methods
+func (h *PaymentController) GetPaymentMethods(c *fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(string)
 	if !ok {
-		utils.LogError(ctx, "Unauthorized batch payout attempt", nil, "ip", c.IP())
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
-	processed := 0
-	failed := 0
-	totalAmount := 0
-
-	// Process each withdrawal via Paystack
-	for _, withdrawalID := range req.WithdrawalIDs {
-		if len(withdrawalID) > 0 {
-			if h.paystackService != nil {
-				// Process via Paystack API
-				processed++
-				totalAmount += 15000
-			} else {
-				// Mock processing when Paystack not configured
-				processed++
-				totalAmount += 15000
-			}
-		} else {
-			failed++
+	if h.methodRepo != nil {
+		ctx := middleware.GetContextWithTrace(c)
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		methods, err := h.methodRepo.ListPaymentMethods(c.Context(), id)
+		if err != nil {
+			utils.LogError(ctx, "Failed to list payment methods", err, "user_id", userID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to get payment methods"})
 		}
+		return c.JSON(fiber.Map{
+			"payment_methods": methods,
+			"user_id":         userID,
+		})
 	}
-
-	utils.LogInfo(ctx, "✅ Batch payouts processed", "admin_id", adminID, "processed", processed, "failed", failed, "total_amount", totalAmount)
-
-	return c.JSON(fiber.Map{
-		"ok":           true,
-		"processed":    processed,
-		"failed":       failed,
-		"total_amount": totalAmount,
-		"admin_id":     adminID,
-		"message":      fmt.Sprintf("Processed %d payouts, %d failed", processed, failed),
-	})
-}
-
-// GetPaymentMethods returns user's saved payment methods
-func (h *PaymentController) GetPaymentMethods(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	if h.paymentRepo != nil {
+		// Without an encryption key no card can have been saved
+		return c.Status(503).JSON(fiber.Map{"error": "Saved payment methods unavailable"})
 	}
 
-	// Mock payment methods
+	// Mock payment methods when running without a database
 	methods := []fiber.Map{
 		{
 			"id":         "pm_001",
@@ -242,43 +196,226 @@ func (h *PaymentController) GetPaymentMethods(c *fiber.Ctx) error {
 	})
 }
 
-// AddPaymentMethod adds new payment method
+// POST /api/payment/methods
+// Saves the card the caller paid with in a successful Paystack payment, given its reference, so it
+// can be charged again without a checkout. Paystack must report the card reusable.
 func (h *PaymentController) AddPaymentMethod(c *fiber.Ctx) error {
-	userID, ok := c.Locals("user_id").(string)
-	if !ok {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ AddPaymentMethod request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
-
 	var req struct {
-		CardToken  string `json:"card_token"`
+		Reference  string `json:"reference"`
 		SetDefault bool   `json:"set_default"`
 	}
-
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	if err := c.BodyParser(&req); err != nil || req.Reference == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "reference is required"})
 	}
 
-	methodID := uuid.New()
-
-	// Save payment method via Paystack
-	if h.paystackService == nil {
-		// Mock when Paystack not configured
+	if h.paystackService == nil || h.methodRepo == nil {
+		if h.paymentRepo != nil {
+			return c.Status(503).JSON(fiber.Map{"error": "Saved payment methods unavailable"})
+		}
+		// Mock when running without a database
 		return c.Status(201).JSON(fiber.Map{
 			"ok":        true,
-			"method_id": methodID,
+			"method_id": uuid.New(),
 			"user_id":   userID,
 			"message":   "Payment method added (mock mode)",
 		})
 	}
 
+	result, err := h.paystackService.VerifyTransaction(req.Reference)
+	if err != nil {
+		utils.LogError(ctx, "Paystack verification failed", err, "reference", req.Reference, "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Payment verification failed"})
+	}
+	buyerID := chargeBuyer(result.Data.Metadata)
+	if buyerID == nil || *buyerID != userID {
+		utils.LogWarn(ctx, "⚠️ Card of another user's payment", "reference", req.Reference, "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "This payment does not belong to you"})
+	}
+	if result.Data.Status != "success" || !result.Data.Authorization.Reusable {
+		utils.LogWarn(ctx, "⚠️ Card cannot be saved", "reference", req.Reference, "status", result.Data.Status)
+		return c.Status(422).JSON(fiber.Map{"error": "This payment's card cannot be charged again"})
+	}
+
+	method := savedCard(userID, result.Data.Customer.Email, result.Data.Authorization)
+	method.IsDefault = req.SetDefault
+	if err := h.methodRepo.SavePaymentMethod(c.Context(), method); err != nil {
+		utils.LogError(ctx, "Failed to save payment method", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to add payment method"})
+	}
+
+	utils.LogInfo(ctx, "✅ Payment method saved", "user_id", userID, "payment_method_id", method.ID, "last4", method.Last4)
 	return c.Status(201).JSON(fiber.Map{
-		"ok":        true,
-		"method_id": methodID,
-		"user_id":   userID,
-		"message":   "Payment method added successfully",
+		"ok":             true,
+		"method_id":      method.ID,
+		"payment_method": method,
+		"user_id":        userID,
+		"message":        "Payment method added successfully",
 	})
 }
 
+// PUT /api/payment/methods/:id/default
+// Makes one of the caller's saved cards the one top-ups are charged to.
+func (h *PaymentController) SetDefaultPaymentMethod(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ SetDefaultPaymentMethod request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	methodID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payment method ID"})
+	}
+	if h.methodRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Saved payment methods unavailable"})
+	}
+
+	err = h.methodRepo.SetDefaultPaymentMethod(c.Context(), userID, methodID)
+	if errors.Is(err, repository.ErrPaymentMethodNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment method not found"})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to set default payment method", err, "payment_method_id", methodID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to set default payment method"})
+	}
+
+	utils.LogInfo(ctx, "✅ Default payment method set", "user_id", userID, "payment_method_id", methodID)
+	return c.JSON(fiber.Map{"ok": true, "message": "Default payment method updated"})
+}
+
+// DELETE /api/payment/methods/:id
+// Forgets one of the caller's saved cards.
+func (h *PaymentController) DeletePaymentMethod(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ DeletePaymentMethod request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	methodID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payment method ID"})
+	}
+	if h.methodRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Saved payment methods unavailable"})
+	}
+
+	err = h.methodRepo.DeletePaymentMethod(c.Context(), userID, methodID)
+	if errors.Is(err, repository.ErrPaymentMethodNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Payment method not found"})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to delete payment method", err, "payment_method_id", methodID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete payment method"})
+	}
+
+	utils.LogInfo(ctx, "✅ Payment method deleted", "user_id", userID, "payment_method_id", methodID)
+	return c.JSON(fiber.Map{"ok": true, "message": "Payment method deleted"})
+}
+
+// GET /api/payment/auto-top-up
+// Returns the caller's auto top-up settings, switched off when they never set any.
+func (h *PaymentController) GetAutoTopUp(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetAutoTopUp request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	if h.methodRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Auto top-up unavailable"})
+	}
+
+	settings, err := h.methodRepo.AutoTopUp(c.Context(), userID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to get auto top-up", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get auto top-up"})
+	}
+	if settings == nil {
+		settings = &models.AutoTopUp{UserID: userID, Amount: models.MinCustomPurchase}
+	}
+	return c.JSON(fiber.Map{"auto_top_up": settings})
+}
+
+// PUT /api/payment/auto-top-up
+// Sets the caller's auto top-up: buying amount credits with their default card whenever their
+// credits fall below threshold. Switching it on needs a saved card.
+func (h *PaymentController) SaveAutoTopUp(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ SaveAutoTopUp request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	var req struct {
+		Enabled   bool `json:"enabled"`
+		Threshold int  `json:"threshold"`
+		Amount    int  `json:"amount"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	settings := &models.AutoTopUp{UserID: userID, Enabled: req.Enabled, Threshold: req.Threshold, Amount: req.Amount}
+	if err := settings.Validate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if h.methodRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Auto top-up unavailable"})
+	}
+
+	if settings.Enabled {
+		method, err := h.methodRepo.PaymentMethod(c.Context(), userID, uuid.Nil)
+		if err != nil {
+			utils.LogError(ctx, "Failed to load default payment method", err, "user_id", userID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to save auto top-up"})
+		}
+		if method == nil {
+			return c.Status(422).JSON(fiber.Map{"error": "Save a card before switching auto top-up on"})
+		}
+	}
+	if err := h.methodRepo.SaveAutoTopUp(c.Context(), settings); err != nil {
+		utils.LogError(ctx, "Failed to save auto top-up", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save auto top-up"})
+	}
+
+	utils.LogInfo(ctx, "✅ Auto top-up saved", "user_id", userID, "enabled", settings.Enabled,
+		"threshold", settings.Threshold, "amount", settings.Amount)
+	return c.JSON(fiber.Map{"ok": true, "auto_top_up": settings})
+}
+
+// savedCard is the payment method for a card Paystack reported on a charge. Cards without a
+// signature are told apart by their number and expiry.
+func savedCard(userID uuid.UUID, email string, authorization services.PaystackAuthorization) *models.PaymentMethod {
+	signature := authorization.Signature
+	if signature == "" {
+		signature = authorization.Bin + authorization.Last4 + authorization.ExpMonth + authorization.ExpYear
+	}
+	return &models.PaymentMethod{
+		UserID:            userID,
+		AuthorizationCode: authorization.AuthorizationCode,
+		Signature:         signature,
+		Email:             email,
+		Bin:               authorization.Bin,
+		Last4:             authorization.Last4,
+		ExpMonth:          authorization.ExpMonth,
+		ExpYear:           authorization.ExpYear,
+		Brand:             authorization.Brand,
+		CardType:          authorization.CardType,
+		Bank:              authorization.Bank,
+	}
+}
+
 // GetTransactionHistory returns user's transaction history
 func (h *PaymentController) GetTransactionHistory(c *fiber.Ctx) error {
 	userID, ok := c.Locals("user_id").(string)
@@ -286,6 +423,23 @@ func (h *PaymentController) GetTransactionHistory(c *fiber.Ctx) error {
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
 
+	if h.paymentRepo != nil {
+		ctx := middleware.GetContextWithTrace(c)
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		transactions, err := h.paymentRepo.ListPayments(c.Context(), id, c.QueryInt("limit", 50))
+		if err != nil {
+			utils.LogError(ctx, "Failed to list payments", err, "user_id", userID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to get transaction history"})
+		}
+		return c.JSON(fiber.Map{
+			"transactions": transactions,
+			"user_id":      userID,
+		})
+	}
+
 	// Mock transaction history
 	transactions := []fiber.Map{
 		{
@@ -316,39 +470,184 @@ func (h *PaymentController) GetTransactionHistory(c *fiber.Ctx) error {
 	})
 }
 
-// RefundTransaction handles transaction refunds
-func (h *PaymentController) RefundTransaction(c *fiber.Ctx) error {
-	transactionID := c.Params("id")
-	adminID, ok := c.Locals("user_id").(string)
-	if !ok {
+// GET /api/payment/history/:id/invoice
+// Downloads the PDF invoice for one of the caller's paid credit purchases, issuing it if it has
+// not been yet.
+func (h *PaymentController) GetInvoice(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetInvoice request")
+
+	userID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
+	paymentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid transaction ID"})
+	}
+	if h.invoices == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Invoices unavailable"})
+	}
+
+	invoice, err := h.invoices.Invoice(c.Context(), userID, paymentID)
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Transaction not found"})
+	case errors.Is(err, services.ErrPaymentNotPaid):
+		return c.Status(409).JSON(fiber.Map{"error": "Only paid credit purchases have an invoice"})
+	case err != nil:
+		utils.LogError(ctx, "Failed to issue invoice", err, "payment_id", paymentID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get invoice"})
+	}
+
+	pdf, err := services.RenderInvoice(invoice)
+	if err != nil {
+		utils.LogError(ctx, "Failed to render invoice", err, "invoice", invoice.Number)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to get invoice"})
+	}
+
+	utils.LogInfo(ctx, "✅ Invoice downloaded", "invoice", invoice.Number, "user_id", userID)
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=invoice-%s.pdf", invoice.Number))
+	return c.Send(pdf)
+}
+
+// saveCard saves the card a verified purchase was paid with when the buyer asked for it at checkout.
+// Failing to save it never fails the verification.
+func (h *PaymentController) saveCard(ctx context.Context, userID uuid.UUID, result *services.PaystackVerifyResponse) {
+	if h.methodRepo == nil || !result.Data.Authorization.Reusable {
+		return
+	}
+	method := savedCard(userID, result.Data.Customer.Email, result.Data.Authorization)
+	if err := h.methodRepo.SavePaymentMethod(ctx, method); err != nil {
+		utils.LogError(ctx, "Failed to save payment method", err, "user_id", userID, "reference", result.Data.Reference)
+		return
+	}
+	utils.LogInfo(ctx, "✅ Payment method saved", "user_id", userID, "payment_method_id", method.ID, "last4", method.Last4)
+}
+
+// RefundTransaction refunds all or part of a credit purchase through Paystack. The refunded
+// credits are taken back from the creator first, so a refund is refused once they are spent.
+func (h *PaymentController) RefundTransaction(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ RefundTransaction request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+	paymentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid transaction ID", "success": false})
+	}
 
 	var req struct {
 		Reason string `json:"reason"`
-		Amount int    `json:"amount,omitempty"` // partial refund amount
+		Amount int    `json:"amount,omitempty"` // partial refund amount in naira; everything left when omitted
 	}
-
 	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request"})
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required", "success": false})
+	}
+	if req.Amount < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid amount", "success": false})
+	}
+	if h.paymentRepo == nil || h.paystackService == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Refunds unavailable", "success": false})
 	}
 
-	// Process refund via Paystack
-	if h.paystackService != nil {
-		// Process actual refund via Paystack API
-		// For now, return success
-	} else {
-		// Mock refund processing
+	refund := &models.Refund{
+		ID:        uuid.New(),
+		PaymentID: paymentID,
+		Amount:    req.Amount,
+		Reason:    req.Reason,
+		IssuedBy:  &adminID,
+	}
+	err = h.refunds.Issue(c.Context(), refund)
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Transaction not found", "success": false})
+	case errors.Is(err, services.ErrPaymentNotRefundable), errors.Is(err, services.ErrRefundExceedsPayment),
+		errors.Is(err, services.ErrCreditsSpent):
+		utils.LogWarn(ctx, "⚠️ Refund refused", "payment_id", paymentID, "amount", req.Amount, "reason", err.Error())
+		return c.Status(409).JSON(fiber.Map{"error": err.Error(), "success": false})
+	case err != nil:
+		utils.LogError(ctx, "Failed to issue refund", err, "payment_id", paymentID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to issue refund", "success": false})
 	}
 
-	refundID := uuid.New()
+	result, err := h.paystackService.CreateRefund(refund.PaystackReference, refund.Amount*100, refund.Reason)
+	if err != nil {
+		if failErr := h.refunds.Fail(c.Context(), refund.ID, err.Error()); failErr != nil {
+			utils.LogError(ctx, "Failed to restore credits of a refused refund", failErr, "refund_id", refund.ID)
+		}
+		h.auditRefund(c, adminID, "refund_failed", refund, err.Error())
+		return c.Status(502).JSON(fiber.Map{"error": "Paystack refused the refund: " + err.Error(), "success": false})
+	}
+	refund.Status = models.RefundStatusProcessing
+	paystackRefundID := fmt.Sprintf("%d", result.ID)
+	refund.PaystackRefundID = &paystackRefundID
+	if err := h.paymentRepo.MarkRefundSubmitted(c.Context(), refund.ID, paystackRefundID); err != nil {
+		utils.LogError(ctx, "Failed to record Paystack refund", err, "refund_id", refund.ID, "paystack_refund_id", paystackRefundID)
+	}
+	h.auditRefund(c, adminID, "refund_issued", refund, "")
 
+	utils.LogInfo(ctx, "✅ Refund issued", "refund_id", refund.ID, "payment_id", paymentID, "amount", refund.Amount, "admin_id", adminID)
 	return c.JSON(fiber.Map{
-		"ok":             true,
-		"refund_id":      refundID,
-		"transaction_id": transactionID,
-		"admin_id":       adminID,
-		"reason":         req.Reason,
-		"message":        "Refund processed successfully",
+		"success": true,
+		"refund":  refund,
+		"message": "Refund submitted to Paystack",
 	})
 }
+
+// GetRefunds lists the refunds issued against a credit purchase
+func (h *PaymentController) GetRefunds(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	paymentID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid transaction ID", "success": false})
+	}
+	if h.paymentRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Refunds unavailable", "success": false})
+	}
+
+	refunds, err := h.paymentRepo.RefundsForPayment(c.Context(), paymentID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list refunds", err, "payment_id", paymentID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to list refunds", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true, "refunds": refunds})
+}
+
+// auditRefund records who issued a refund and why
+func (h *PaymentController) auditRefund(c *fiber.Ctx, adminID uuid.UUID, action string, refund *models.Refund, failure string) {
+	if h.auditRepo == nil {
+		return
+	}
+	details, _ := json.Marshal(map[string]interface{}{
+		"payment_id": refund.PaymentID,
+		"user_id":    refund.UserID,
+		"amount":     refund.Amount,
+		"reason":     refund.Reason,
+		"error":      failure,
+	})
+	resource := "refund:" + refund.ID.String()
+	ip := c.IP()
+	ua := string(c.Request().Header.UserAgent())
+	err := h.auditRepo.CreateAuditLog(c.Context(), &models.AuditLog{
+		ID:        uuid.New(),
+		UserID:    &adminID,
+		Action:    action,
+		Resource:  &resource,
+		Details:   details,
+		IPAddress: &ip,
+		UserAgent: &ua,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.LogError(middleware.GetContextWithTrace(c), "Failed to audit refund", err, "refund_id", refund.ID)
+	}
+}
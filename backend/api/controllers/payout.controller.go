@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"errors"
+	"onetimer-backend/api/middleware"
+	"onetimer-backend/repository"
+	"onetimer-backend/services"
+	"onetimer-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PayoutController lets admins approve withdrawals and drive their Paystack payouts
+type PayoutController struct {
+	withdrawalRepo *repository.WithdrawalRepository
+	worker         *services.PayoutWorker
+}
+
+// NewPayoutController takes a nil worker when Paystack is not configured; withdrawals can then be
+// approved but are only paid out once it is
+func NewPayoutController(withdrawalRepo *repository.WithdrawalRepository, worker *services.PayoutWorker) *PayoutController {
+	return &PayoutController{withdrawalRepo: withdrawalRepo, worker: worker}
+}
+
+// ApprovePayout approves a pending withdrawal for the payout worker's next run
+func (h *PayoutController) ApprovePayout(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ ApprovePayout request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	withdrawalID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid withdrawal ID", "success": false})
+	}
+	if h.withdrawalRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Payouts unavailable", "success": false})
+	}
+
+	withdrawal, err := h.withdrawalRepo.Approve(c.Context(), withdrawalID, adminID)
+	switch {
+	case errors.Is(err, repository.ErrWithdrawalNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Withdrawal not found", "success": false})
+	case errors.Is(err, repository.ErrWithdrawalNotPending):
+		return c.Status(409).JSON(fiber.Map{"error": "Withdrawal is not pending", "success": false})
+	case err != nil:
+		utils.LogError(ctx, "Failed to approve payout", err, "withdrawal_id", withdrawalID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to approve payout", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Payout approved", "withdrawal_id", withdrawalID, "admin_id", adminID, "amount", withdrawal.Amount)
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"withdrawal": withdrawal,
+		"message":    "Payout approved successfully",
+	})
+}
+
+// ProcessBatchPayouts approves the given pending withdrawals and pays them out straight away
+// instead of waiting for the scheduled payout run
+func (h *PayoutController) ProcessBatchPayouts(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ ProcessBatchPayouts request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	var req struct {
+		WithdrawalIDs []string `json:"withdrawal_ids"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.WithdrawalIDs) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "withdrawal_ids is required", "success": false})
+	}
+	ids := make([]uuid.UUID, 0, len(req.WithdrawalIDs))
+	for _, raw := range req.WithdrawalIDs {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid withdrawal ID: " + raw, "success": false})
+		}
+		ids = append(ids, id)
+	}
+	if h.withdrawalRepo == nil || h.worker == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Payouts unavailable", "success": false})
+	}
+
+	for _, id := range ids {
+		// Withdrawals approved earlier are paid out along with the rest
+		if _, err := h.withdrawalRepo.Approve(c.Context(), id, adminID); err != nil &&
+			!errors.Is(err, repository.ErrWithdrawalNotPending) && !errors.Is(err, repository.ErrWithdrawalNotFound) {
+			utils.LogError(ctx, "Failed to approve payout", err, "withdrawal_id", id)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to approve payouts", "success": false})
+		}
+	}
+
+	result, err := h.worker.Run(c.Context(), ids)
+	if result == nil {
+		utils.LogError(ctx, "Failed to process payouts", err, "admin_id", adminID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process payouts", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Payouts processed with errors", err, "admin_id", adminID)
+	}
+
+	utils.LogInfo(ctx, "✅ Batch payouts processed", "admin_id", adminID, "claimed", result.Claimed)
+	return c.JSON(fiber.Map{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// FinalizePayout submits the OTP Paystack sent for a withdrawal's transfer
+func (h *PayoutController) FinalizePayout(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ FinalizePayout request")
+
+	withdrawalID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid withdrawal ID", "success": false})
+	}
+	var req struct {
+		OTP string `json:"otp"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.OTP == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "otp is required", "success": false})
+	}
+	if h.withdrawalRepo == nil || h.worker == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Payouts unavailable", "success": false})
+	}
+
+	withdrawal, err := h.withdrawalRepo.GetByID(c.Context(), withdrawalID)
+	if errors.Is(err, repository.ErrWithdrawalNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Withdrawal not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to load withdrawal", err, "withdrawal_id", withdrawalID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to finalize payout", "success": false})
+	}
+
+	result, err := h.worker.Finalize(c.Context(), withdrawal, req.OTP)
+	if errors.Is(err, services.ErrPayoutNotAwaitingOTP) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Payout finalization failed", "withdrawal_id", withdrawalID, "error", err.Error())
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to finalize payout: " + err.Error(), "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Payout finalized", "withdrawal_id", withdrawalID)
+	return c.JSON(fiber.Map{
+		"success": true,
+		"result":  result,
+	})
+}
@@ -0,0 +1,392 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"onetimer-backend/api/middleware"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
+	"onetimer-backend/services"
+	"onetimer-backend/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PricingController lets super admins manage the pricing rules, promo codes and organization rates
+// surveys are priced on, and the credit packages creators buy. Every change is audited.
+type PricingController struct {
+	repo       *repository.PricingRepository
+	creditRepo *repository.CreditRepository
+	billing    *services.BillingService
+	auditRepo  *repository.AuditRepository
+}
+
+func NewPricingController(repo *repository.PricingRepository, creditRepo *repository.CreditRepository, billing *services.BillingService, auditRepo *repository.AuditRepository) *PricingController {
+	return &PricingController{repo: repo, creditRepo: creditRepo, billing: billing, auditRepo: auditRepo}
+}
+
+// GET /api/super-admin/pricing
+// Returns the pricing in force now and every stored version, including those yet to take effect.
+func (h *PricingController) GetPricing(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetPricing request")
+
+	active, err := h.billing.Pricing(c.Context())
+	if err != nil {
+		utils.LogError(ctx, "Failed to load active pricing", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load pricing", "success": false})
+	}
+	versions := []models.PricingVersion{}
+	if h.repo != nil {
+		if versions, err = h.repo.ListPricingVersions(c.Context()); err != nil {
+			utils.LogError(ctx, "Failed to list pricing versions", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load pricing", "success": false})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"active":   active,
+			"versions": versions,
+		},
+	})
+}
+
+// POST /api/super-admin/pricing
+// Stores new rules as the next pricing version, taking effect now or at effective_from.
+func (h *PricingController) CreatePricingVersion(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ CreatePricingVersion request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	var req struct {
+		Rules         models.PricingRules `json:"rules"`
+		EffectiveFrom *time.Time          `json:"effective_from"`
+		Note          string              `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	if err := services.ValidatePricingRules(req.Rules); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+
+	version := &models.PricingVersion{Rules: req.Rules, EffectiveFrom: time.Now(), CreatedBy: &adminID}
+	if req.EffectiveFrom != nil {
+		version.EffectiveFrom = *req.EffectiveFrom
+	}
+	if req.Note != "" {
+		version.Note = &req.Note
+	}
+	err = h.repo.CreatePricingVersion(c.Context(), version)
+	if errors.Is(err, repository.ErrPricingVersionInPast) {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to create pricing version", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create pricing version", "success": false})
+	}
+
+	h.audit(c, adminID, "pricing_version_created", "pricing_version:"+version.ID.String(), version)
+	utils.LogInfo(ctx, "✅ Pricing version created", "version", version.Version, "effective_from", version.EffectiveFrom, "admin_id", adminID)
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": version})
+}
+
+// GET /api/super-admin/pricing/promo-codes
+func (h *PricingController) GetPromoCodes(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetPromoCodes request")
+
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+	promos, err := h.repo.ListPromoCodes(c.Context())
+	if err != nil {
+		utils.LogError(ctx, "Failed to list promo codes", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load promo codes", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": promos})
+}
+
+// POST /api/super-admin/pricing/promo-codes
+func (h *PricingController) CreatePromoCode(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ CreatePromoCode request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	var req struct {
+		Code           string     `json:"code"`
+		Description    string     `json:"description"`
+		PercentOff     int        `json:"percent_off"`
+		AmountOff      int        `json:"amount_off"`
+		MaxRedemptions *int       `json:"max_redemptions"`
+		StartsAt       *time.Time `json:"starts_at"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	switch {
+	case repository.NormalizePromoCode(req.Code) == "":
+		return c.Status(400).JSON(fiber.Map{"error": "code is required", "success": false})
+	case req.PercentOff < 0 || req.PercentOff > 100 || req.AmountOff < 0 || req.PercentOff+req.AmountOff == 0:
+		return c.Status(400).JSON(fiber.Map{"error": "A promo code takes off a percent_off between 1 and 100, an amount_off, or both", "success": false})
+	case req.MaxRedemptions != nil && *req.MaxRedemptions <= 0:
+		return c.Status(400).JSON(fiber.Map{"error": "max_redemptions must be greater than 0", "success": false})
+	case req.StartsAt != nil && req.ExpiresAt != nil && !req.ExpiresAt.After(*req.StartsAt):
+		return c.Status(400).JSON(fiber.Map{"error": "expires_at must be after starts_at", "success": false})
+	}
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+
+	promo := &models.PromoCode{
+		Code:           req.Code,
+		PercentOff:     req.PercentOff,
+		AmountOff:      req.AmountOff,
+		MaxRedemptions: req.MaxRedemptions,
+		StartsAt:       req.StartsAt,
+		ExpiresAt:      req.ExpiresAt,
+		Active:         true,
+		CreatedBy:      &adminID,
+	}
+	if req.Description != "" {
+		promo.Description = &req.Description
+	}
+	err = h.repo.CreatePromoCode(c.Context(), promo)
+	if errors.Is(err, repository.ErrPromoCodeExists) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to create promo code", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create promo code", "success": false})
+	}
+
+	h.audit(c, adminID, "promo_code_created", "promo_code:"+promo.ID.String(), promo)
+	utils.LogInfo(ctx, "✅ Promo code created", "code", promo.Code, "admin_id", adminID)
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": promo})
+}
+
+// PATCH /api/super-admin/pricing/promo-codes/:id
+// Switches a promo code on or off; surveys already funded with it keep their discount.
+func (h *PricingController) UpdatePromoCode(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ UpdatePromoCode request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid promo code ID", "success": false})
+	}
+	var req struct {
+		Active *bool `json:"active"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Active == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "active is required", "success": false})
+	}
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+
+	promo, err := h.repo.SetPromoCodeActive(c.Context(), id, *req.Active)
+	if errors.Is(err, repository.ErrPromoCodeNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Promo code not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to update promo code", err, "promo_code_id", id)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update promo code", "success": false})
+	}
+
+	h.audit(c, adminID, "promo_code_updated", "promo_code:"+id.String(), fiber.Map{"active": *req.Active})
+	utils.LogInfo(ctx, "✅ Promo code updated", "promo_code_id", id, "active", *req.Active)
+	return c.JSON(fiber.Map{"success": true, "data": promo})
+}
+
+// GET /api/super-admin/pricing/organizations
+func (h *PricingController) GetOrganizationRates(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetOrganizationRates request")
+
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+	rates, err := h.repo.ListOrganizationRates(c.Context())
+	if err != nil {
+		utils.LogError(ctx, "Failed to list organization rates", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load organization rates", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": rates})
+}
+
+// PUT /api/super-admin/pricing/organizations/:creator_id
+// Sets the custom rate of the organization behind a creator account, replacing any it had.
+func (h *PricingController) SaveOrganizationRate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ SaveOrganizationRate request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	creatorID, err := uuid.Parse(c.Params("creator_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid creator ID", "success": false})
+	}
+	var req struct {
+		PlatformFee        *int       `json:"platform_fee"`
+		FeeDiscountPercent int        `json:"fee_discount_percent"`
+		ExpiresAt          *time.Time `json:"expires_at"`
+		Note               string     `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	switch {
+	case req.PlatformFee != nil && *req.PlatformFee < 0:
+		return c.Status(400).JSON(fiber.Map{"error": "platform_fee cannot be negative", "success": false})
+	case req.FeeDiscountPercent < 0 || req.FeeDiscountPercent > 100:
+		return c.Status(400).JSON(fiber.Map{"error": "fee_discount_percent must be between 0 and 100", "success": false})
+	case req.PlatformFee == nil && req.FeeDiscountPercent == 0:
+		return c.Status(400).JSON(fiber.Map{"error": "A rate needs a platform_fee, a fee_discount_percent or both", "success": false})
+	}
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+
+	rate := &models.OrganizationRate{
+		CreatorID:          creatorID,
+		PlatformFee:        req.PlatformFee,
+		FeeDiscountPercent: req.FeeDiscountPercent,
+		ExpiresAt:          req.ExpiresAt,
+		UpdatedBy:          &adminID,
+	}
+	if req.Note != "" {
+		rate.Note = &req.Note
+	}
+	if err := h.repo.SaveOrganizationRate(c.Context(), rate); err != nil {
+		utils.LogError(ctx, "Failed to save organization rate", err, "creator_id", creatorID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save organization rate", "success": false})
+	}
+
+	h.audit(c, adminID, "organization_rate_saved", "creator:"+creatorID.String(), rate)
+	utils.LogInfo(ctx, "✅ Organization rate saved", "creator_id", creatorID, "admin_id", adminID)
+	return c.JSON(fiber.Map{"success": true, "data": rate})
+}
+
+// DELETE /api/super-admin/pricing/organizations/:creator_id
+func (h *PricingController) DeleteOrganizationRate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ DeleteOrganizationRate request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	creatorID, err := uuid.Parse(c.Params("creator_id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid creator ID", "success": false})
+	}
+	if h.repo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Pricing unavailable", "success": false})
+	}
+
+	err = h.repo.DeleteOrganizationRate(c.Context(), creatorID)
+	if errors.Is(err, repository.ErrOrganizationRateNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization rate not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to delete organization rate", err, "creator_id", creatorID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete organization rate", "success": false})
+	}
+
+	h.audit(c, adminID, "organization_rate_deleted", "creator:"+creatorID.String(), nil)
+	utils.LogInfo(ctx, "✅ Organization rate deleted", "creator_id", creatorID, "admin_id", adminID)
+	return c.JSON(fiber.Map{"success": true, "message": "Organization rate deleted"})
+}
+
+// audit records a pricing change in the audit log
+// GET /api/super-admin/pricing/credit-packages
+// Returns every credit package, including those taken off sale.
+func (h *PricingController) GetCreditPackages(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ GetCreditPackages request")
+
+	if h.creditRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Credit packages unavailable", "success": false})
+	}
+	packages, err := h.creditRepo.ListPackages(c.Context(), true)
+	if err != nil {
+		utils.LogError(ctx, "Failed to list credit packages", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load credit packages", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": packages})
+}
+
+// PUT /api/super-admin/pricing/credit-packages/:id
+// Creates or replaces a credit package. Purchases already initialized keep their price and credits.
+func (h *PricingController) SaveCreditPackage(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ SaveCreditPackage request")
+
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	pkg := &models.CreditPackage{Active: true}
+	if err := c.BodyParser(pkg); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	pkg.ID = c.Params("id")
+	if err := pkg.Validate(); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+	if h.creditRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Credit packages unavailable", "success": false})
+	}
+
+	if err := h.creditRepo.SavePackage(c.Context(), pkg); err != nil {
+		utils.LogError(ctx, "Failed to save credit package", err, "package_id", pkg.ID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save credit package", "success": false})
+	}
+
+	h.audit(c, adminID, "credit_package_saved", "credit_package:"+pkg.ID, pkg)
+	utils.LogInfo(ctx, "✅ Credit package saved", "package_id", pkg.ID, "admin_id", adminID)
+	return c.JSON(fiber.Map{"success": true, "data": pkg})
+}
+
+func (h *PricingController) audit(c *fiber.Ctx, adminID uuid.UUID, action, resource string, details interface{}) {
+	if h.auditRepo == nil {
+		return
+	}
+	raw, _ := json.Marshal(details)
+	ip := c.IP()
+	ua := string(c.Request().Header.UserAgent())
+	err := h.auditRepo.CreateAuditLog(c.Context(), &models.AuditLog{
+		ID:        uuid.New(),
+		UserID:    &adminID,
+		Action:    action,
+		Resource:  &resource,
+		Details:   raw,
+		IPAddress: &ip,
+		UserAgent: &ua,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		utils.LogError(middleware.GetContextWithTrace(c), "Failed to audit pricing change", err, "action", action)
+	}
+}
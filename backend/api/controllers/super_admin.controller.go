@@ -4,6 +4,7 @@ import (
 	"context"
 	"onetimer-backend/cache"
 	"onetimer-backend/models"
+	"onetimer-backend/services"
 	"onetimer-backend/utils"
 	"time"
 
@@ -13,12 +14,13 @@ import (
 )
 
 type SuperAdminController struct {
-	cache *cache.Cache
-	db    *pgxpool.Pool
+	cache   *cache.Cache
+	db      *pgxpool.Pool
+	billing *services.BillingService
 }
 
-func NewSuperAdminController(cache *cache.Cache, db *pgxpool.Pool) *SuperAdminController {
-	return &SuperAdminController{cache: cache, db: db}
+func NewSuperAdminController(cache *cache.Cache, db *pgxpool.Pool, billing *services.BillingService) *SuperAdminController {
+	return &SuperAdminController{cache: cache, db: db, billing: billing}
 }
 
 func (h *SuperAdminController) GetAllUsers(c *fiber.Ctx) error {
@@ -225,8 +227,16 @@ func (h *SuperAdminController) GetAuditLogs(c *fiber.Ctx) error {
 func (h *SuperAdminController) GetSystemSettings(c *fiber.Ctx) error {
 	ctx := context.Background()
 
+	// The platform fee is part of the pricing rules, managed under /pricing
+	pricing, err := h.billing.Pricing(c.Context())
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to load pricing", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load settings"})
+	}
+
 	settings := fiber.Map{
-		"platform_fee_percentage": 15.0,
+		"platform_fee_percentage": pricing.Rules.PlatformFeePercentage,
+		"pricing_version":         pricing.Version,
 		"min_withdrawal_amount":   1000,
 		"max_survey_reward":       5000,
 		"kyc_required":            true,
@@ -178,8 +178,8 @@ func (h *SuperAdminAnalyticsController) GetSurveyStats(c *fiber.Ctx) error {
 
 	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys").Scan(&total)
 	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE status = 'active'").Scan(&active)
-	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE status = 'pending'").Scan(&pending)
-	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE status = 'suspended'").Scan(&suspended)
+	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE status = 'pending_review'").Scan(&pending)
+	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE status = 'paused'").Scan(&suspended)
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -48,7 +48,7 @@ func (h *SuperAdminDashboardController) GetDashboardStats(c *fiber.Ctx) error {
 
 	// Total revenue (sum of all survey rewards paid)
 	var totalRevenue float64
-	err = h.db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE status = 'completed'").Scan(&totalRevenue)
+	err = h.db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE status = 'available'").Scan(&totalRevenue)
 	if err != nil {
 		utils.LogError(ctx, "Failed to get total revenue", err)
 		totalRevenue = 0
@@ -60,7 +60,7 @@ func (h *SuperAdminDashboardController) GetDashboardStats(c *fiber.Ctx) error {
 	
 	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM users WHERE created_at < NOW() - INTERVAL '1 month'").Scan(&prevMonthUsers)
 	h.db.QueryRow(ctx, "SELECT COUNT(*) FROM surveys WHERE created_at < NOW() - INTERVAL '1 month'").Scan(&prevMonthSurveys)
-	h.db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE status = 'completed' AND created_at < NOW() - INTERVAL '1 month'").Scan(&prevMonthRevenue)
+	h.db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE status = 'available' AND created_at < NOW() - INTERVAL '1 month'").Scan(&prevMonthRevenue)
 
 	// Calculate percentage changes
 	userChange := calculatePercentageChange(prevMonthUsers, totalUsers)
@@ -2,21 +2,33 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
+	"onetimer-backend/services"
 	"onetimer-backend/utils"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type SuperAdminFinanceController struct {
-	cache *cache.Cache
-	db    *pgxpool.Pool
+	cache              *cache.Cache
+	db                 *pgxpool.Pool
+	reconciliationRepo *repository.ReconciliationRepository
+	reconciler         *services.Reconciler
 }
 
-func NewSuperAdminFinanceController(cache *cache.Cache, db *pgxpool.Pool) *SuperAdminFinanceController {
-	return &SuperAdminFinanceController{cache: cache, db: db}
+func NewSuperAdminFinanceController(cache *cache.Cache, db *pgxpool.Pool, reconciliationRepo *repository.ReconciliationRepository, reconciler *services.Reconciler) *SuperAdminFinanceController {
+	return &SuperAdminFinanceController{cache: cache, db: db, reconciliationRepo: reconciliationRepo, reconciler: reconciler}
 }
 
 // GET /api/super-admin/financials/metrics
@@ -76,18 +88,18 @@ func (h *SuperAdminFinanceController) GetPayoutQueue(c *fiber.Ctx) error {
 
 	rows, err := h.db.Query(ctx, `
 		SELECT 
-			e.id,
-			SUM(e.amount) as total_amount,
-			COUNT(DISTINCT e.user_id) as user_count,
-			e.status,
-			u.name as submitted_by,
-			e.created_at
-		FROM earnings e
-		LEFT JOIN users u ON e.user_id = u.id
-		WHERE e.status IN ('pending', 'processing')
-		GROUP BY e.id, e.status, u.name, e.created_at
-		ORDER BY e.created_at DESC
-		LIMIT 20
+			w.id,
+			w.amount,
+			w.status,
+			w.attempts,
+			COALESCE(w.last_error, ''),
+			COALESCE(u.name, ''),
+			w.created_at
+		FROM withdrawals w
+		LEFT JOIN users u ON w.user_id = u.id
+		WHERE w.status IN ('pending', 'approved', 'processing', 'awaiting_otp')
+		ORDER BY w.created_at
+		LIMIT 50
 	`)
 	if err != nil {
 		utils.LogError(ctx, "Failed to get payout queue", err)
@@ -97,12 +109,14 @@ func (h *SuperAdminFinanceController) GetPayoutQueue(c *fiber.Ctx) error {
 
 	payouts := []fiber.Map{}
 	for rows.Next() {
-		var id, status, submittedBy string
-		var amount float64
-		var userCount int
+		var id uuid.UUID
+		var status, lastError, submittedBy string
+		var amount, attempts int
 		var createdAt time.Time
 
-		rows.Scan(&id, &amount, &userCount, &status, &submittedBy, &createdAt)
+		if err := rows.Scan(&id, &amount, &status, &attempts, &lastError, &submittedBy, &createdAt); err != nil {
+			continue
+		}
 
 		priority := "low"
 		if amount > 100000 {
@@ -114,10 +128,13 @@ func (h *SuperAdminFinanceController) GetPayoutQueue(c *fiber.Ctx) error {
 		payouts = append(payouts, fiber.Map{
 			"id":          id,
 			"amount":      amount,
-			"users":       userCount,
+			"users":       1,
 			"status":      status,
+			"attempts":    attempts,
+			"lastError":   lastError,
 			"priority":    priority,
 			"submittedBy": submittedBy,
+			"createdAt":   createdAt,
 		})
 	}
 
@@ -129,49 +146,42 @@ func (h *SuperAdminFinanceController) GetPayoutQueue(c *fiber.Ctx) error {
 
 // GET /api/super-admin/financials/reconciliation
 func (h *SuperAdminFinanceController) GetReconciliation(c *fiber.Ctx) error {
-	ctx := context.Background()
+	ctx := middleware.GetContextWithTrace(c)
+	if h.reconciliationRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Reconciliation unavailable", "success": false})
+	}
 
-	rows, err := h.db.Query(ctx, `
-		SELECT 
-			DATE(created_at) as date,
-			SUM(CASE WHEN status = 'pending' THEN amount ELSE 0 END) as expected,
-			SUM(CASE WHEN status = 'completed' THEN amount ELSE 0 END) as processed
-		FROM earnings
-		WHERE created_at >= NOW() - INTERVAL '7 days'
-		GROUP BY DATE(created_at)
-		ORDER BY DATE(created_at) DESC
-	`)
+	reports, err := h.reconciliationRepo.ListReconciliations(c.Context(), c.QueryInt("limit", 30))
 	if err != nil {
 		utils.LogError(ctx, "Failed to get reconciliation", err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch reconciliation"})
 	}
-	defer rows.Close()
 
 	reconciliation := []fiber.Map{}
-	for rows.Next() {
-		var date time.Time
-		var expected, processed float64
-
-		rows.Scan(&date, &expected, &processed)
-
-		variance := processed - expected
-		status := "matched"
-		if variance != 0 {
-			status = "review"
-		}
-
+	for _, report := range reports {
+		variance := report.PaystackTotal - report.InternalTotal
 		varianceStr := "₦0"
 		if variance > 0 {
-			varianceStr = "+₦" + formatMoney(variance)
+			varianceStr = "+₦" + formatMoney(float64(variance))
 		} else if variance < 0 {
-			varianceStr = "-₦" + formatMoney(-variance)
+			varianceStr = "-₦" + formatMoney(float64(-variance))
+		}
+
+		status := "matched"
+		if report.Status == models.ReconciliationReportNeedsReview {
+			status = "review"
 		}
 
 		reconciliation = append(reconciliation, fiber.Map{
-			"date":      date.Format("2006-01-02"),
-			"expected":  "₦" + formatMoney(expected),
-			"processed": "₦" + formatMoney(processed),
+			"id":        report.ID,
+			"date":      report.PeriodStart.Format("2006-01-02"),
+			"periodEnd": report.PeriodEnd,
+			"source":    report.Source,
+			"expected":  "₦" + formatMoney(float64(report.InternalTotal)),
+			"processed": "₦" + formatMoney(float64(report.PaystackTotal)),
 			"variance":  varianceStr,
+			"matched":   report.Matched,
+			"issues":    report.Issues,
 			"status":    status,
 		})
 	}
@@ -182,28 +192,155 @@ func (h *SuperAdminFinanceController) GetReconciliation(c *fiber.Ctx) error {
 	})
 }
 
-// POST /api/super-admin/financials/approve-payout/:id
-func (h *SuperAdminFinanceController) ApprovePayout(c *fiber.Ctx) error {
-	ctx := context.Background()
-	payoutID := c.Params("id")
+// GET /api/super-admin/financials/reconciliation/:id
+func (h *SuperAdminFinanceController) GetReconciliationReport(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid report ID", "success": false})
+	}
+	if h.reconciliationRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Reconciliation unavailable", "success": false})
+	}
+
+	report, err := h.reconciliationRepo.GetReconciliation(c.Context(), id)
+	if errors.Is(err, repository.ErrReconciliationNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Report not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to get reconciliation report", err, "report_id", id)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch report", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true, "data": report})
+}
 
-	_, err := h.db.Exec(ctx, `
-		UPDATE earnings 
-		SET status = 'processing', updated_at = NOW()
-		WHERE id = $1
-	`, payoutID)
+// POST /api/super-admin/financials/reconciliation/run
+// Reconciles a period against the Paystack API; the body's from and to default to yesterday.
+// A settlement CSV uploaded as "file" is reconciled instead.
+func (h *SuperAdminFinanceController) RunReconciliation(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ RunReconciliation request")
 
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
 	if err != nil {
-		utils.LogError(ctx, "Failed to approve payout", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to approve payout"})
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	if h.reconciler == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Reconciliation unavailable", "success": false})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Payout approved successfully",
-	})
+	var req struct {
+		From string `json:"from" form:"from"`
+		To   string `json:"to" form:"to"`
+	}
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -1), today
+	if req.From != "" {
+		if from, err = time.Parse("2006-01-02", req.From); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "from must be a YYYY-MM-DD date", "success": false})
+		}
+		to = from.AddDate(0, 0, 1)
+	}
+	if req.To != "" {
+		end, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "to must be a YYYY-MM-DD date", "success": false})
+		}
+		to = end.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return c.Status(400).JSON(fiber.Map{"error": "to must not be before from", "success": false})
+	}
+
+	source := models.ReconciliationSourcePaystack
+	var records []models.SettlementRecord
+	if file, ferr := c.FormFile("file"); ferr == nil {
+		source = models.ReconciliationSourceCSV
+		f, err := file.Open()
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Could not read settlement file", "success": false})
+		}
+		defer f.Close()
+		if records, err = services.ParseSettlementCSV(f); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+		}
+	} else if records, err = h.reconciler.FetchPaystack(from, to); err != nil {
+		if errors.Is(err, services.ErrPaystackUnavailable) {
+			return c.Status(503).JSON(fiber.Map{"error": "Paystack is not configured; upload a settlement file instead", "success": false})
+		}
+		utils.LogError(ctx, "Failed to fetch Paystack records", err, "from", from, "to", to)
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to fetch Paystack records", "success": false})
+	}
+
+	report, err := h.reconciler.Run(c.Context(), from, to, source, records, &adminID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to reconcile", err, "from", from, "to", to, "source", source)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to reconcile", "success": false})
+	}
+	return c.Status(201).JSON(fiber.Map{"success": true, "data": report})
 }
 
+// POST /api/super-admin/financials/reconciliation/items/:id/resolve
+func (h *SuperAdminFinanceController) ResolveReconciliationItem(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	adminID, err := uuid.Parse(c.Locals("user_id").(string))
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+	itemID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid item ID", "success": false})
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A resolution note is required", "success": false})
+	}
+	if h.reconciliationRepo == nil {
+		return c.Status(503).JSON(fiber.Map{"error": "Reconciliation unavailable", "success": false})
+	}
+
+	item, err := h.reconciliationRepo.ResolveItem(c.Context(), itemID, adminID, strings.TrimSpace(req.Note))
+	if errors.Is(err, repository.ErrReconciliationItemNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "Item not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "Failed to resolve reconciliation item", err, "item_id", itemID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to resolve item", "success": false})
+	}
+	utils.LogInfo(ctx, "✅ Reconciliation item resolved", "item_id", itemID, "admin_id", adminID)
+	return c.JSON(fiber.Map{"success": true, "data": item})
+}
+
+// formatMoney renders an amount with thousands separators and kobo only when there are any
 func formatMoney(amount float64) string {
-	return fiber.Map{"amount": amount}["amount"].(string)
+	negative := amount < 0
+	if negative {
+		amount = -amount
+	}
+	whole := int64(amount)
+	kobo := int64(math.Round((amount - float64(whole)) * 100))
+	if kobo == 100 {
+		whole, kobo = whole+1, 0
+	}
+
+	digits := strconv.FormatInt(whole, 10)
+	var b strings.Builder
+	if negative {
+		b.WriteByte('-')
+	}
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if kobo > 0 {
+		fmt.Fprintf(&b, ".%02d", kobo)
+	}
+	return b.String()
 }
@@ -3,11 +3,17 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
 	"onetimer-backend/models"
 	"onetimer-backend/repository"
+	"onetimer-backend/services"
 	"onetimer-backend/utils"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,15 +21,280 @@ import (
 )
 
 type SurveyController struct {
-	cache *cache.Cache
-	repo  *repository.SurveyRepository
+	cache        *cache.Cache
+	repo         *repository.SurveyRepository
+	notifier     *services.NotificationService
+	logic        *services.SurveyLogicService
+	answers      *services.AnswerValidator
+	quality      *services.QualityScoringService
+	targets      *services.TargetingService
+	billing      *services.BillingService
+	definitions  *services.SurveyDefinitionService
+	types        *services.QuestionTypeRegistry
+	delivery     *services.SurveyDeliveryService
+	translations *services.TranslationService
+	templates    *repository.TemplateRepository
+	submissions  *services.ResponseSubmitter
+	reviews      *services.ResponseReviewer
 }
 
-func NewSurveyController(cache *cache.Cache, repo *repository.SurveyRepository) *SurveyController {
-	return &SurveyController{
-		cache: cache,
-		repo:  repo,
+func NewSurveyController(cache *cache.Cache, repo *repository.SurveyRepository, templates *repository.TemplateRepository, notifier *services.NotificationService, billing *services.BillingService) *SurveyController {
+	h := &SurveyController{
+		cache:        cache,
+		repo:         repo,
+		notifier:     notifier,
+		logic:        services.NewSurveyLogicService(),
+		answers:      services.NewAnswerValidator(),
+		quality:      services.NewQualityScoringService(),
+		targets:      services.NewTargetingService(),
+		billing:      billing,
+		definitions:  services.NewSurveyDefinitionService(),
+		types:        services.NewQuestionTypeRegistry(),
+		delivery:     services.NewSurveyDeliveryService(),
+		translations: services.NewTranslationService(),
+		templates:    templates,
 	}
+	if repo != nil {
+		h.submissions = services.NewResponseSubmitter(repo)
+		h.reviews = services.NewResponseReviewer(repo)
+	}
+	return h
+}
+
+// peerAnswerSample is how many recent responses are compared against for duplicate open text
+const peerAnswerSample = 200
+
+// buildQuestions converts request questions into models ordered by their position, assigning
+// IDs and rewriting display rule, consistency and answer piping references from client-side
+// question IDs to the generated ones
+func buildQuestions(surveyID uuid.UUID, reqs []models.QuestionRequest) []models.Question {
+	ids := make(map[string]string, len(reqs))
+	for _, q := range reqs {
+		if q.ID != "" {
+			ids[q.ID] = uuid.New().String()
+		}
+	}
+
+	questions := make([]models.Question, 0, len(reqs))
+	for _, q := range reqs {
+		id := uuid.New()
+		if mapped, ok := ids[q.ID]; ok {
+			id = uuid.MustParse(mapped)
+		}
+
+		var logic []byte
+		if len(q.Logic) > 0 {
+			rules := make([]models.DisplayRule, len(q.Logic))
+			for i, rule := range q.Logic {
+				rules[i] = rule
+				rules[i].Conditions = make([]models.RuleCondition, len(rule.Conditions))
+				for j, cond := range rule.Conditions {
+					if mapped, ok := ids[cond.QuestionID]; ok {
+						cond.QuestionID = mapped
+					}
+					rules[i].Conditions[j] = cond
+				}
+				if mapped, ok := ids[rule.Target]; ok {
+					rules[i].Target = mapped
+				}
+			}
+			logic, _ = json.Marshal(rules)
+		}
+
+		consistencyWith := q.ConsistencyWith
+		if mapped, ok := ids[consistencyWith]; ok {
+			consistencyWith = mapped
+		}
+
+		pipe := func(ref string) string {
+			if mapped, ok := ids[ref]; ok {
+				return mapped
+			}
+			return ref
+		}
+		title := services.RewritePipes(q.Title, pipe)
+		description := services.RewritePipes(q.Description, pipe)
+		translations := make(map[string]models.QuestionTranslation, len(q.Translations))
+		for locale, t := range q.Translations {
+			t.Title = services.RewritePipes(t.Title, pipe)
+			t.Description = services.RewritePipes(t.Description, pipe)
+			translations[locale] = t
+		}
+		translated, _ := json.Marshal(translations)
+
+		options, _ := json.Marshal(q.Options)
+		settings, _ := json.Marshal(models.QuestionSettings{
+			Scale:            q.Scale,
+			Rows:             q.Rows,
+			Cols:             q.Cols,
+			Min:              q.Min,
+			Max:              q.Max,
+			Step:             q.Step,
+			DateMode:         q.DateMode,
+			MinDate:          q.MinDate,
+			MaxDate:          q.MaxDate,
+			AllowedTypes:     q.AllowedTypes,
+			MaxFiles:         q.MaxFiles,
+			MaxSizeMB:        q.MaxSizeMB,
+			RandomizeOptions: q.RandomizeOptions,
+			PinnedOptions:    q.PinnedOptions,
+			Block:            q.Block,
+			ExpectedAnswer:   q.ExpectedAnswer,
+			ConsistencyWith:  consistencyWith,
+			ConsistencyMode:  q.ConsistencyMode,
+		})
+		questions = append(questions, models.Question{
+			ID:           id,
+			SurveyID:     surveyID,
+			Type:         q.Type,
+			Title:        title,
+			Description:  &description,
+			Required:     q.Required,
+			Options:      options,
+			OrderIndex:   q.Order,
+			Logic:        logic,
+			Settings:     settings,
+			Translations: translated,
+		})
+	}
+
+	sort.SliceStable(questions, func(i, j int) bool {
+		return questions[i].OrderIndex < questions[j].OrderIndex
+	})
+	return questions
+}
+
+// questionErrorResponse renders questions whose type or type-specific settings are invalid
+func questionErrorResponse(c *fiber.Ctx, errs []services.DefinitionError) error {
+	return c.Status(400).JSON(fiber.Map{"error": "Invalid questions", "errors": errs, "success": false})
+}
+
+// translationErrorResponse renders invalid survey and question translations
+func translationErrorResponse(c *fiber.Ctx, errs []services.DefinitionError) error {
+	return c.Status(400).JSON(fiber.Map{"error": "Invalid translations", "errors": errs, "success": false})
+}
+
+// logicErrorResponse renders why a survey's display rules failed validation
+func logicErrorResponse(c *fiber.Ctx, err error) error {
+	var logicErr *services.LogicValidationError
+	if errors.As(err, &logicErr) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid question logic", "errors": logicErr.Errors, "success": false})
+	}
+	return c.Status(400).JSON(fiber.Map{"error": "Invalid question logic: " + err.Error(), "success": false})
+}
+
+// applyTargeting validates the request's targeting and quotas and stores them on the survey
+func (h *SurveyController) applyTargeting(survey *models.Survey, req *models.SurveyRequest) error {
+	if err := h.targets.ValidateQuotas(req.Quotas); err != nil {
+		return err
+	}
+	quotas := req.Quotas
+	if quotas == nil {
+		quotas = []models.SegmentQuota{}
+	}
+	survey.Targeting, _ = json.Marshal(req.Demographics)
+	survey.Quotas, _ = json.Marshal(quotas)
+	return nil
+}
+
+// applyTranslations validates the request's language and translations and stores them on the survey
+func (h *SurveyController) applyTranslations(survey *models.Survey, req *models.SurveyRequest) []services.DefinitionError {
+	if errs := h.translations.Validate(req); len(errs) > 0 {
+		return errs
+	}
+	translations := req.Translations
+	if translations == nil {
+		translations = map[string]models.SurveyTranslation{}
+	}
+	survey.Locale = services.SurveyLocale(req.Locale)
+	survey.Translations, _ = json.Marshal(translations)
+	return nil
+}
+
+// negotiateLocale picks the language to serve a survey in to the caller: the ?lang query, then the
+// language set on their profile, then the request's Accept-Language, falling back to the survey's own
+func (h *SurveyController) negotiateLocale(c *fiber.Ctx, survey *models.Survey) string {
+	if len(survey.Locales()) == 1 {
+		return survey.Locale
+	}
+	preferences := []string{c.Query("lang")}
+	userID, _ := c.Locals("user_id").(string)
+	if userID, err := uuid.Parse(userID); err == nil {
+		if preferred, err := h.repo.GetPreferredLanguage(c.Context(), userID); err == nil {
+			preferences = append(preferences, preferred)
+		}
+	}
+	preferences = append(preferences, services.ParseAcceptLanguage(c.Get("Accept-Language"))...)
+	return h.translations.NegotiateLocale(survey, preferences...)
+}
+
+// fillerTargeting loads the filler's profile for a targeted survey and returns the criteria they
+// fail plus the segment quotas their response would count toward
+func (h *SurveyController) fillerTargeting(ctx context.Context, survey *models.Survey, fillerID uuid.UUID) ([]string, []models.SegmentQuota, error) {
+	criteria := survey.TargetingCriteria()
+	quotas := survey.SegmentQuotas()
+	if len(quotas) == 0 && criteria.Empty() {
+		return nil, nil, nil
+	}
+
+	profile, err := h.repo.GetFillerProfile(ctx, fillerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return h.targets.Mismatches(criteria, profile), h.targets.ApplicableQuotas(quotas, profile), nil
+}
+
+// questionsFor hides attention-check answers from everyone but the survey's creator
+func questionsFor(c *fiber.Ctx, survey *models.Survey, questions []models.Question) []models.Question {
+	if userID, ok := c.Locals("user_id").(string); ok && survey.CreatorID.String() == userID {
+		return questions
+	}
+	public := make([]models.Question, len(questions))
+	for i, q := range questions {
+		public[i] = q.ForFiller()
+	}
+	return public
+}
+
+// deliverQuestions lays questions out for the calling filler: in the order drawn for their session,
+// in locale, with answers they have saved so far piped into question text. Creators see questions
+// as written, translated when they ask for a locale.
+func (h *SurveyController) deliverQuestions(c *fiber.Ctx, survey *models.Survey, questions []models.Question, locale string) []models.Question {
+	userID, _ := c.Locals("user_id").(string)
+	if survey.CreatorID.String() == userID {
+		return h.translations.LocalizeQuestions(questions, locale)
+	}
+
+	answers := map[string]interface{}{}
+	if fillerID, err := uuid.Parse(userID); err == nil {
+		if session, err := h.repo.GetFillerResponse(c.Context(), survey.ID, fillerID); err == nil {
+			json.Unmarshal(session.Answers, &answers)
+			if order := session.ParsedServedOrder(); order != nil && order.Version == survey.Version {
+				questions = h.delivery.Arrange(questions, order)
+			}
+		}
+	}
+	answers = h.translations.LocalizeAnswers(questions, locale, answers)
+	return h.delivery.Pipe(h.translations.LocalizeQuestions(questions, locale), answers)
+}
+
+// sessionOrder returns the order a filler's session is served in, drawing and storing one when the
+// session has none yet or its order was drawn for an earlier version of the survey
+func (h *SurveyController) sessionOrder(ctx context.Context, survey *models.Survey, session *models.Response) (*models.ServedOrder, error) {
+	if order := session.ParsedServedOrder(); order != nil && order.Version == survey.Version {
+		return order, nil
+	}
+
+	questions, err := h.repo.GetVersionQuestions(ctx, survey.ID, survey.Version)
+	if err != nil {
+		return nil, err
+	}
+	order := h.delivery.ServeOrder(questions, survey.Version, services.SessionSeed(session.ID))
+	raw, _ := json.Marshal(order)
+	if err := h.repo.SetServedOrder(ctx, session.ID, survey.Version, raw); err != nil {
+		return nil, err
+	}
+	return &order, nil
 }
 
 func (h *SurveyController) CreateSurvey(c *fiber.Ctx) error {
@@ -50,6 +321,16 @@ func (h *SurveyController) CreateSurvey(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Title and description are required"})
 	}
 
+	if req.MinQualityScore < 0 || req.MinQualityScore > 10 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: min quality score out of range", "min_quality_score", req.MinQualityScore)
+		return c.Status(400).JSON(fiber.Map{"error": "Minimum quality score must be between 0 and 10"})
+	}
+
+	if req.StartsAt != nil && req.StartsAt.Before(time.Now()) {
+		utils.LogWarn(ctx, "⚠️ Validation failed: start time in the past", "starts_at", req.StartsAt)
+		return c.Status(400).JSON(fiber.Map{"error": "Scheduled start time must be in the future"})
+	}
+
 	// Parse creator ID
 	creatorID, err := uuid.Parse(userID)
 	if err != nil {
@@ -68,34 +349,57 @@ func (h *SurveyController) CreateSurvey(c *fiber.Ctx) error {
 		RewardAmount:      req.RewardAmount,
 		TargetResponses:   req.TargetCount,
 		EstimatedDuration: req.Duration,
-		Status:            "pending",
+		MinQualityScore:   req.MinQualityScore,
+		StartsAt:          req.StartsAt,
+		RunDays:           h.billing.SurveyRunDays(req.ExtraDays),
+		PriorityPlacement: req.PriorityPlacement,
+		DataExport:        req.DataExport,
+		Status:            models.SurveyStatusPendingReview,
 		CreatedAt:         time.Now(),
 		UpdatedAt:         time.Now(),
 	}
 
-	var questions []models.Question
-	for _, q := range req.Questions {
-		options, _ := json.Marshal(q.Options)
-		questions = append(questions, models.Question{
-			ID:          uuid.New(),
-			SurveyID:    surveyID,
-			Type:        q.Type,
-			Title:       q.Title,
-			Description: &q.Description,
-			Required:    q.Required,
-			Options:     options,
-			OrderIndex:  q.Order,
-		})
+	if err := h.applyTargeting(&survey, &req); err != nil {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid targeting", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if errs := h.types.ValidateQuestions(req.Questions); len(errs) > 0 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid questions", "errors", len(errs))
+		return questionErrorResponse(c, errs)
+	}
+
+	if errs := h.applyTranslations(&survey, &req); len(errs) > 0 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid translations", "errors", len(errs))
+		return translationErrorResponse(c, errs)
+	}
+
+	questions := buildQuestions(surveyID, req.Questions)
+	if err := h.logic.Validate(questions); err != nil {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid question logic", "error", err.Error())
+		return logicErrorResponse(c, err)
+	}
+
+	quote, err := h.billing.QuoteSurvey(c.Context(), &survey, len(questions), req.PromoCode)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Validation failed: survey cannot be priced", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	utils.LogInfo(ctx, "Creating survey in database", "survey_id", surveyID, "question_count", len(questions), "reward", req.RewardAmount)
+	utils.LogInfo(ctx, "Creating survey in database", "survey_id", surveyID, "question_count", len(questions), "reward", req.RewardAmount, "total_cost", quote.TotalCost)
 
-	if err := h.repo.CreateSurvey(c.Context(), &survey, questions, 0); err != nil {
+	if err := h.repo.CreateSurvey(c.Context(), &survey, questions, quote); err != nil {
+		if errors.Is(err, repository.ErrInsufficientFunds) {
+			return insufficientCreditsResponse(c, survey.ID, quote)
+		}
+		if errors.Is(err, repository.ErrPromoCodeUnavailable) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
 		utils.LogError(ctx, "⚠️ Database error: failed to create survey", err, "survey_id", surveyID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to save survey to database"})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey created successfully", "survey_id", surveyID, "user_id", userID, "status", "pending")
+	utils.LogInfo(ctx, "✅ Survey created successfully", "survey_id", surveyID, "user_id", userID, "status", survey.Status)
 
 	// Return success response with survey details
 	return c.Status(201).JSON(fiber.Map{
@@ -110,8 +414,11 @@ func (h *SurveyController) CreateSurvey(c *fiber.Ctx) error {
 			"target_count":   survey.TargetResponses,
 			"estimated_time": survey.EstimatedDuration,
 			"status":         survey.Status,
+			"starts_at":      survey.StartsAt,
+			"run_days":       survey.RunDays,
 			"created_at":     survey.CreatedAt,
 			"question_count": len(req.Questions),
+			"cost":           quote,
 		},
 		"message": "Survey created successfully and submitted for review",
 	})
@@ -135,6 +442,11 @@ func (h *SurveyController) GetSurveys(c *fiber.Ctx) error {
 
 	utils.LogInfo(ctx, "Cache miss, fetching from database")
 
+	if h.repo == nil {
+		utils.LogWarn(ctx, "Survey repository unavailable, returning no surveys")
+		return c.JSON(fiber.Map{"data": []models.Survey{}, "success": true, "error": "Temporary service issue"})
+	}
+
 	// Get only active surveys with limit
 	surveys, err := h.repo.GetAll(dbCtx, 50, 0, "active")
 	if err != nil {
@@ -180,9 +492,12 @@ func (h *SurveyController) GetSurvey(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey retrieved successfully", "survey_id", id, "question_count", len(questions))
+	locale := h.negotiateLocale(c, survey)
+	utils.LogInfo(ctx, "✅ Survey retrieved successfully", "survey_id", id, "question_count", len(questions), "locale", locale)
 
-	return c.JSON(fiber.Map{"data": fiber.Map{"survey": survey, "questions": questions}, "questions": questions, "reward": survey.RewardAmount})
+	questions = questionsFor(c, survey, h.translations.LocalizeQuestions(questions, locale))
+	survey = h.translations.LocalizeSurvey(survey, locale)
+	return c.JSON(fiber.Map{"data": fiber.Map{"survey": survey, "questions": questions}, "questions": questions, "reward": survey.RewardAmount, "locale": locale, "locales": survey.Locales()})
 }
 
 func (h *SurveyController) UpdateSurvey(c *fiber.Ctx) error {
@@ -219,6 +534,16 @@ func (h *SurveyController) UpdateSurvey(c *fiber.Ctx) error {
 		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to update this survey"})
 	}
 
+	if !models.IsEditableSurveyStatus(survey.Status) {
+		utils.LogWarn(ctx, "⚠️ Survey is not editable in its current status", "survey_id", surveyID, "status", survey.Status)
+		return c.Status(409).JSON(fiber.Map{"error": "A " + survey.Status + " survey can no longer be edited", "status": survey.Status})
+	}
+
+	if req.MinQualityScore < 0 || req.MinQualityScore > 10 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: min quality score out of range", "min_quality_score", req.MinQualityScore)
+		return c.Status(400).JSON(fiber.Map{"error": "Minimum quality score must be between 0 and 10"})
+	}
+
 	utils.LogInfo(ctx, "Updating survey", "survey_id", surveyID, "title", req.Title)
 
 	survey.Title = req.Title
@@ -227,20 +552,92 @@ func (h *SurveyController) UpdateSurvey(c *fiber.Ctx) error {
 	survey.RewardAmount = req.RewardAmount
 	survey.TargetResponses = req.TargetCount
 	survey.EstimatedDuration = req.Duration
+	survey.MinQualityScore = req.MinQualityScore
 	survey.UpdatedAt = time.Now()
 
-	if err := h.repo.Update(c.Context(), survey); err != nil {
+	// The schedule and add-ons are fixed once the survey has been published
+	if survey.PublishedAt == nil {
+		if req.StartsAt != nil && req.StartsAt.Before(time.Now()) {
+			utils.LogWarn(ctx, "⚠️ Validation failed: start time in the past", "starts_at", req.StartsAt)
+			return c.Status(400).JSON(fiber.Map{"error": "Scheduled start time must be in the future"})
+		}
+		survey.StartsAt = req.StartsAt
+		survey.RunDays = h.billing.SurveyRunDays(req.ExtraDays)
+		survey.PriorityPlacement = req.PriorityPlacement
+		survey.DataExport = req.DataExport
+	}
+	if err := h.applyTargeting(survey, &req); err != nil {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid targeting", "survey_id", surveyID, "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if errs := h.applyTranslations(survey, &req); len(errs) > 0 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid translations", "survey_id", surveyID, "errors", len(errs))
+		return translationErrorResponse(c, errs)
+	}
+
+	var questions []models.Question
+	if len(req.Questions) > 0 {
+		if errs := h.types.ValidateQuestions(req.Questions); len(errs) > 0 {
+			utils.LogWarn(ctx, "⚠️ Validation failed: invalid questions", "survey_id", surveyID, "errors", len(errs))
+			return questionErrorResponse(c, errs)
+		}
+		questions = buildQuestions(surveyUUID, req.Questions)
+		if err := h.logic.Validate(questions); err != nil {
+			utils.LogWarn(ctx, "⚠️ Validation failed: invalid question logic", "survey_id", surveyID, "error", err.Error())
+			return logicErrorResponse(c, err)
+		}
+	} else if models.EditedVersion(survey.Version, survey.PublishedAt) != survey.Version {
+		// Editing a published survey starts a new version, which carries its own copy of the questions
+		current, err := h.repo.GetQuestions(c.Context(), surveyUUID)
+		if err != nil {
+			utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+		}
+		questions = buildQuestions(surveyUUID, h.definitions.FromSurvey(survey, current).Questions)
+	}
+
+	// A funded survey is repriced, so the edit may need more credits or free some
+	var quote *models.SurveyQuote
+	if survey.FundedAt != nil {
+		questionCount := len(questions)
+		if questions == nil {
+			current, err := h.repo.GetQuestions(c.Context(), surveyUUID)
+			if err != nil {
+				utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+			}
+			questionCount = len(current)
+		}
+		if quote, err = h.billing.QuoteSurvey(c.Context(), survey, questionCount, ""); err != nil {
+			utils.LogWarn(ctx, "⚠️ Validation failed: survey cannot be priced", "survey_id", surveyID, "error", err.Error())
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	previousVersion := survey.Version
+	version, err := h.repo.UpdateSurvey(c.Context(), survey, questions, &survey.CreatorID, quote)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		return insufficientCreditsResponse(c, survey.ID, quote)
+	}
+	if errors.Is(err, repository.ErrSurveyNotEditable) {
+		utils.LogWarn(ctx, "⚠️ Survey closed before the edit was saved", "survey_id", surveyID)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey can no longer be edited"})
+	}
+	if err != nil {
 		utils.LogError(ctx, "⚠️ Database error: failed to update survey", err, "survey_id", surveyID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to update survey"})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey updated successfully", "survey_id", surveyID, "user_id", userID)
+	utils.LogInfo(ctx, "✅ Survey updated successfully", "survey_id", surveyID, "user_id", userID, "version", version)
 
 	return c.JSON(fiber.Map{
-		"ok":        true,
-		"survey_id": surveyID,
-		"user_id":   userID,
-		"message":   "Survey updated successfully",
+		"ok":          true,
+		"survey_id":   surveyID,
+		"user_id":     userID,
+		"version":     version,
+		"new_version": version != previousVersion,
+		"message":     "Survey updated successfully",
 	})
 }
 
@@ -316,71 +713,233 @@ func (h *SurveyController) SubmitResponse(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID"})
 	}
 
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+
 	survey, err := h.repo.GetByID(c.Context(), surveyUUID)
 	if err != nil {
 		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
 		return c.Status(404).JSON(fiber.Map{"error": "Survey not found"})
 	}
 
-	answers, _ := json.Marshal(req.Answers)
+	// A retried submission carrying the same Idempotency-Key gets the original result back
+	idempotencyKey := c.Get("Idempotency-Key")
+	replay := func() (bool, error) {
+		if idempotencyKey == "" {
+			return false, nil
+		}
+		existing, err := h.repo.GetFillerResponse(c.Context(), surveyUUID, fillerID)
+		if err != nil || existing.IdempotencyKey == nil || *existing.IdempotencyKey != idempotencyKey {
+			return false, nil
+		}
+		utils.LogInfo(ctx, "Replaying idempotent survey submission", "survey_id", surveyID, "response_id", existing.ID)
+		return true, c.JSON(fiber.Map{
+			"ok":          true,
+			"success":     true,
+			"replayed":    true,
+			"message":     "Survey already submitted",
+			"survey_id":   surveyID,
+			"response_id": existing.ID,
+			"reward":      survey.RewardAmount,
+		})
+	}
+	if replayed, err := replay(); replayed {
+		return err
+	}
 
-	response := models.Response{
-		ID:          uuid.New(),
-		SurveyID:    surveyUUID,
-		FillerID:    uuid.MustParse(userID),
-		Answers:     answers,
-		Status:      "completed",
-		StartedAt:   time.Now(),
-		CompletedAt: &time.Time{},
+	mismatches, quotas, err := h.fillerTargeting(c.Context(), survey, fillerID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to load filler profile", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check survey eligibility"})
+	}
+	if len(mismatches) > 0 {
+		utils.LogWarn(ctx, "⚠️ Filler outside survey targeting", "survey_id", surveyID, "user_id", userID, "criteria", mismatches)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not in this survey's target audience", "criteria": mismatches, "success": false})
 	}
 
-	if err := h.repo.CreateResponse(c.Context(), &response); err != nil {
-		utils.LogError(ctx, "⚠️ Database error: failed to create response", err, "survey_id", surveyID)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to submit response"})
+	questions, err := h.repo.GetVersionQuestions(c.Context(), surveyUUID, survey.Version)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
+
+	// Answers given in a translation are stored against the survey's own labels
+	locale := h.negotiateLocale(c, survey)
+	req.Answers = h.translations.CanonicalAnswers(questions, locale, req.Answers)
+
+	// Only questions the filler was actually shown count; answers to hidden ones are dropped
+	visible := h.logic.VisibleQuestions(questions, req.Answers)
+	var shown []models.Question
+	for _, q := range questions {
+		if !visible[q.ID] {
+			delete(req.Answers, q.ID.String())
+			continue
+		}
+		shown = append(shown, q)
+	}
+
+	if answerErrs := h.answers.Validate(shown, req.Answers); len(answerErrs) > 0 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid answers", "survey_id", surveyID, "error_count", len(answerErrs))
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid answers", "errors": answerErrs, "success": false})
+	}
+
+	answers, _ := json.Marshal(req.Answers)
+	now := time.Now()
+
+	// Record the layout the filler was served; without a session they got the survey unrandomized
+	servedOrder := h.delivery.CanonicalOrder(questions, survey.Version)
+	if session, err := h.repo.GetFillerResponse(c.Context(), surveyUUID, fillerID); err == nil {
+		if order := session.ParsedServedOrder(); order != nil && order.Version == survey.Version {
+			servedOrder = *order
+		}
 	}
+	served, _ := json.Marshal(servedOrder)
 
-	utils.LogInfo(ctx, "Response saved, updating count", "response_id", response.ID)
+	quality := h.scoreResponse(c.Context(), survey, fillerID, shown, req.Answers, now)
+	flags, _ := json.Marshal(quality.Flags)
 
-	if err := h.repo.IncrementResponseCount(c.Context(), surveyUUID); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to update response count", err, "survey_id", surveyID)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update response count"})
+	response := models.Response{
+		ID:            uuid.New(),
+		SurveyID:      surveyUUID,
+		FillerID:      fillerID,
+		Answers:       answers,
+		Status:        models.ResponseStatusCompleted,
+		StartedAt:     now,
+		CompletedAt:   &now,
+		QualityScore:  quality.Score,
+		QualityFlags:  flags,
+		SurveyVersion: survey.Version,
+		ServedOrder:   served,
+		Locale:        locale,
 	}
+	if idempotencyKey != "" {
+		response.IdempotencyKey = &idempotencyKey
+	}
+	reviewStatus := models.ReviewStatusPending
+	if quality.Score < survey.MinQualityScore {
+		reviewStatus = models.ReviewStatusRejected
+		note := fmt.Sprintf("Quality score %d is below the survey minimum of %d", quality.Score, survey.MinQualityScore)
+		response.Status = models.ResponseStatusRejected
+		response.ReviewNote = &note
+	}
+	response.ReviewStatus = &reviewStatus
 
 	earning := models.Earning{
 		ID:        uuid.New(),
-		UserID:    uuid.MustParse(userID),
+		UserID:    fillerID,
 		SurveyID:  &surveyUUID,
 		Amount:    survey.RewardAmount,
 		Type:      "survey_completion",
-		Status:    "completed",
-		CreatedAt: time.Now(),
+		Status:    models.EarningStatusPending,
+		CreatedAt: now,
+	}
+
+	var answered []uuid.UUID
+	for _, q := range shown {
+		if services.IsAnswered(req.Answers[q.ID.String()]) {
+			answered = append(answered, q.ID)
+		}
+	}
+
+	err = h.submissions.Submit(c.Context(), &response, &earning, answered, quotas)
+	switch {
+	case errors.Is(err, repository.ErrAlreadyResponded):
+		if replayed, err := replay(); replayed {
+			return err
+		}
+		utils.LogWarn(ctx, "⚠️ Duplicate survey submission", "survey_id", surveyID, "user_id", userID)
+		return c.Status(409).JSON(fiber.Map{"error": "You have already completed this survey", "success": false})
+	case errors.Is(err, services.ErrSurveyClosed):
+		utils.LogWarn(ctx, "⚠️ Survey closed to new responses", "survey_id", surveyID)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey is no longer accepting responses", "success": false})
+	case errors.Is(err, services.ErrSegmentFull):
+		utils.LogWarn(ctx, "⚠️ Segment quota full", "survey_id", surveyID, "user_id", userID)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey has enough responses from your demographic group", "success": false})
+	case errors.Is(err, repository.ErrInsufficientFunds):
+		utils.LogWarn(ctx, "⚠️ Survey escrow cannot cover the reward", "survey_id", surveyID, "error", err.Error())
+		return c.Status(409).JSON(fiber.Map{"error": "This survey's budget has been used up", "success": false})
+	case errors.Is(err, services.ErrVersionChanged):
+		utils.LogWarn(ctx, "⚠️ Survey edited during submission", "survey_id", surveyID, "version", survey.Version)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey was just updated. Please reload it and submit again", "success": false})
+	case err != nil:
+		utils.LogError(ctx, "⚠️ Database error: failed to submit response", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to submit response"})
 	}
 
-	if err := h.repo.CreateEarning(c.Context(), &earning); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to create earning", err, "survey_id", surveyID, "amount", survey.RewardAmount)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create earning"})
+	if response.Status == models.ResponseStatusRejected {
+		utils.LogWarn(ctx, "⚠️ Response rejected for low quality", "survey_id", surveyID, "user_id", userID, "quality_score", quality.Score, "min_quality_score", survey.MinQualityScore)
+		return c.Status(422).JSON(fiber.Map{
+			"error":         "Your response did not meet this survey's quality requirements and was not rewarded",
+			"success":       false,
+			"survey_id":     surveyID,
+			"response_id":   response.ID,
+			"quality_score": quality.Score,
+		})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey response submitted successfully", "survey_id", surveyID, "user_id", userID, "reward", survey.RewardAmount, "earning_id", earning.ID)
+	utils.LogInfo(ctx, "✅ Survey response submitted successfully", "survey_id", surveyID, "user_id", userID, "reward", survey.RewardAmount, "earning_id", earning.ID, "quality_score", quality.Score)
 
 	return c.JSON(fiber.Map{
 		"ok":              true,
 		"success":         true,
-		"message":         "Survey submitted successfully! Your reward has been added to your account.",
+		"message":         "Survey submitted successfully! Your reward is pending the creator's review.",
 		"survey_id":       surveyID,
+		"response_id":     response.ID,
 		"reward":          survey.RewardAmount,
+		"review_status":   reviewStatus,
 		"responses_count": len(req.Answers),
 	})
 }
 
+// scoreResponse runs the quality pipeline for a submission, timing it from the filler's session
+// start and comparing open text against other fillers' recent answers
+func (h *SurveyController) scoreResponse(ctx context.Context, survey *models.Survey, fillerID uuid.UUID, shown []models.Question, answers map[string]interface{}, now time.Time) services.QualityResult {
+	input := services.QualityInput{
+		Survey:          survey,
+		Questions:       shown,
+		Answers:         answers,
+		PeerTextAnswers: map[string][]string{},
+	}
+
+	if session, err := h.repo.GetFillerResponse(ctx, survey.ID, fillerID); err == nil {
+		input.Duration = now.Sub(session.StartedAt)
+	}
+
+	if recent, err := h.repo.GetRecentAnswers(ctx, survey.ID, peerAnswerSample); err == nil {
+		for _, raw := range recent {
+			var peer map[string]interface{}
+			if json.Unmarshal(raw, &peer) != nil {
+				continue
+			}
+			for id, answer := range peer {
+				if text, ok := answer.(string); ok {
+					input.PeerTextAnswers[id] = append(input.PeerTextAnswers[id], text)
+				}
+			}
+		}
+	}
+
+	return h.quality.Score(input)
+}
+
 func (h *SurveyController) GetSurveyResponses(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	surveyID := c.Params("id")
 	limit := c.QueryInt("limit", 10)
 	offset := c.QueryInt("offset", 0)
-	utils.LogInfo(ctx, "→ GetSurveyResponses request", "survey_id", surveyID, "limit", limit, "offset", offset)
+	reviewStatus := c.Query("review_status")
+	version := c.QueryInt("version", 0)
+	utils.LogInfo(ctx, "→ GetSurveyResponses request", "survey_id", surveyID, "limit", limit, "offset", offset, "review_status", reviewStatus, "version", version)
+
+	survey, resp := h.reviewableSurvey(c, surveyID)
+	if survey == nil {
+		return resp
+	}
 
-	responses, total, err := h.repo.GetSurveyResponses(c.Context(), uuid.MustParse(surveyID), limit, offset)
+	responses, total, err := h.repo.GetSurveyResponses(c.Context(), survey.ID, reviewStatus, version, limit, offset)
 	if err != nil {
 		utils.LogError(ctx, "⚠️ Failed to fetch survey responses", err, "survey_id", surveyID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch survey responses"})
@@ -393,6 +952,7 @@ func (h *SurveyController) GetSurveyResponses(c *fiber.Ctx) error {
 		"total":     total,
 		"limit":     limit,
 		"offset":    offset,
+		"version":   version,
 	})
 }
 
@@ -402,7 +962,22 @@ func (h *SurveyController) GetResponseDetails(c *fiber.Ctx) error {
 	responseID := c.Params("response_id")
 	utils.LogInfo(ctx, "→ GetResponseDetails request", "survey_id", surveyID, "response_id", responseID)
 
-	response, err := h.repo.GetResponseDetails(c.Context(), uuid.MustParse(surveyID), uuid.MustParse(responseID))
+	responseUUID, err := uuid.Parse(responseID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid response ID format", "id", responseID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid response ID", "success": false})
+	}
+
+	survey, resp := h.reviewableSurvey(c, surveyID)
+	if survey == nil {
+		return resp
+	}
+
+	response, err := h.repo.GetResponseDetails(c.Context(), survey.ID, responseUUID)
+	if errors.Is(err, repository.ErrResponseNotFound) {
+		utils.LogWarn(ctx, "⚠️ Response not found", "survey_id", surveyID, "response_id", responseID)
+		return c.Status(404).JSON(fiber.Map{"error": "Response not found", "success": false})
+	}
 	if err != nil {
 		utils.LogError(ctx, "⚠️ Failed to fetch response details", err, "survey_id", surveyID, "response_id", responseID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch response details"})
@@ -415,110 +990,101 @@ func (h *SurveyController) GetResponseDetails(c *fiber.Ctx) error {
 	})
 }
 
-func (h *SurveyController) GetSurveyQuestions(c *fiber.Ctx) error {
-	ctx := middleware.GetContextWithTrace(c)
-	surveyID := c.Params("id")
-	utils.LogInfo(ctx, "→ GetSurveyQuestions request", "survey_id", surveyID)
-
-	id, err := uuid.Parse(surveyID)
-	if err != nil {
-		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
-	}
-
-	questions, err := h.repo.GetQuestions(c.Context(), id)
-	if err != nil {
-		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
-	}
-
-	utils.LogInfo(ctx, "✅ Questions retrieved", "survey_id", surveyID, "count", len(questions))
+func (h *SurveyController) ApproveResponse(c *fiber.Ctx) error {
+	return h.reviewResponse(c, models.ReviewStatusApproved)
+}
 
-	return c.JSON(fiber.Map{"data": questions, "success": true})
+func (h *SurveyController) RejectResponse(c *fiber.Ctx) error {
+	return h.reviewResponse(c, models.ReviewStatusRejected)
 }
 
-func (h *SurveyController) StartSurvey(c *fiber.Ctx) error {
+// reviewResponse records the survey creator's decision on a pending_review response and notifies the filler
+func (h *SurveyController) reviewResponse(c *fiber.Ctx, decision string) error {
 	ctx := middleware.GetContextWithTrace(c)
 	surveyID := c.Params("id")
-	userID := "mock-user-id"
-	if c.Locals("user_id") != nil {
-		if uid, ok := c.Locals("user_id").(string); ok {
-			userID = uid
-		}
-	}
-
-	utils.LogInfo(ctx, "→ StartSurvey request", "survey_id", surveyID, "user_id", userID)
+	responseID := c.Params("response_id")
+	utils.LogInfo(ctx, "→ ReviewResponse request", "survey_id", surveyID, "response_id", responseID, "decision", decision)
 
-	sessionKey := "survey_session:" + surveyID + ":" + userID
-	sessionData := fiber.Map{
-		"survey_id":  surveyID,
-		"user_id":    userID,
-		"started_at": time.Now(),
-		"progress":   0,
-		"answers":    make(map[string]interface{}),
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized review attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
 	}
 
-	h.cache.Set(c.Context(), sessionKey, sessionData)
-
-	utils.LogInfo(ctx, "✅ Survey session created", "session_key", sessionKey)
-
-	return c.JSON(fiber.Map{
-		"ok":         true,
-		"session_id": sessionKey,
-		"started_at": time.Now(),
-		"success":    true,
-	})
-}
-
-func (h *SurveyController) SaveProgress(c *fiber.Ctx) error {
-	ctx := middleware.GetContextWithTrace(c)
-	surveyID := c.Params("id")
-	userID := "mock-user-id"
-	if c.Locals("user_id") != nil {
-		if uid, ok := c.Locals("user_id").(string); ok {
-			userID = uid
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.LogError(ctx, "⚠️ Failed to parse review request", err)
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
 		}
 	}
+	if decision == models.ReviewStatusRejected && req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required to reject a response", "success": false})
+	}
 
-	utils.LogInfo(ctx, "→ SaveProgress request", "survey_id", surveyID, "user_id", userID)
-
-	var req struct {
-		Progress int                    `json:"progress"`
-		Answers  map[string]interface{} `json:"answers"`
+	surveyUUID, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+	responseUUID, err := uuid.Parse(responseID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid response ID format", "id", responseID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid response ID", "success": false})
 	}
 
-	if err := c.BodyParser(&req); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to parse progress request", err)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	survey, err := h.repo.GetByID(c.Context(), surveyUUID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	if survey.CreatorID.String() != userID {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "creator_id", survey.CreatorID.String(), "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to review responses to this survey", "success": false})
 	}
 
-	sessionKey := "survey_session:" + surveyID + ":" + userID
-	var sessionData fiber.Map
-	if err := h.cache.Get(c.Context(), sessionKey, &sessionData); err != nil {
-		utils.LogWarn(ctx, "⚠️ Session not found", "session_key", sessionKey)
-		return c.Status(404).JSON(fiber.Map{"error": "Session not found", "success": false})
+	var note *string
+	if req.Reason != "" {
+		note = &req.Reason
 	}
 
-	sessionData["progress"] = req.Progress
-	sessionData["answers"] = req.Answers
-	sessionData["updated_at"] = time.Now()
+	reviewed, err := h.reviews.Review(c.Context(), surveyUUID, responseUUID, decision, note)
+	switch {
+	case errors.Is(err, repository.ErrResponseNotFound):
+		utils.LogWarn(ctx, "⚠️ Response not found", "survey_id", surveyID, "response_id", responseID)
+		return c.Status(404).JSON(fiber.Map{"error": "Response not found", "success": false})
+	case errors.Is(err, services.ErrAlreadyReviewed):
+		utils.LogWarn(ctx, "⚠️ Response already reviewed", "survey_id", surveyID, "response_id", responseID)
+		return c.Status(409).JSON(fiber.Map{"error": "Response has already been reviewed", "success": false})
+	case err != nil:
+		utils.LogError(ctx, "⚠️ Database error: failed to review response", err, "survey_id", surveyID, "response_id", responseID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to review response", "success": false})
+	}
 
-	h.cache.Set(c.Context(), sessionKey, sessionData)
+	if h.notifier != nil {
+		if decision == models.ReviewStatusApproved {
+			h.notifier.NotifyFillerResponseApproved(reviewed.FillerID, reviewed.SurveyTitle, reviewed.Amount)
+		} else {
+			h.notifier.NotifyFillerResponseRejected(reviewed.FillerID, reviewed.SurveyTitle, req.Reason)
+		}
+	}
 
-	utils.LogInfo(ctx, "✅ Progress saved", "session_key", sessionKey, "progress", req.Progress)
+	utils.LogInfo(ctx, "✅ Response reviewed", "survey_id", surveyID, "response_id", responseID, "decision", decision, "amount", reviewed.Amount)
 
 	return c.JSON(fiber.Map{
-		"ok":       true,
-		"progress": req.Progress,
-		"saved_at": time.Now(),
-		"success":  true,
+		"ok":            true,
+		"response_id":   responseID,
+		"review_status": decision,
+		"success":       true,
 	})
 }
 
-func (h *SurveyController) PauseSurvey(c *fiber.Ctx) error {
+func (h *SurveyController) GetSurveyQuestions(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	surveyID := c.Params("id")
-	utils.LogInfo(ctx, "→ PauseSurvey request", "survey_id", surveyID)
+	utils.LogInfo(ctx, "→ GetSurveyQuestions request", "survey_id", surveyID)
 
 	id, err := uuid.Parse(surveyID)
 	if err != nil {
@@ -526,45 +1092,621 @@ func (h *SurveyController) PauseSurvey(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
 	}
 
-	if err = h.repo.UpdateStatus(c.Context(), id, "paused"); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to pause survey", err, "survey_id", surveyID)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to pause survey", "success": false})
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+
+	questions, err := h.repo.GetQuestions(c.Context(), id)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey paused", "survey_id", surveyID)
+	locale := h.negotiateLocale(c, survey)
+	utils.LogInfo(ctx, "✅ Questions retrieved", "survey_id", surveyID, "count", len(questions), "locale", locale)
 
-	return c.JSON(fiber.Map{
-		"ok":      true,
-		"status":  "paused",
-		"success": true,
-	})
+	return c.JSON(fiber.Map{"data": questionsFor(c, survey, h.deliverQuestions(c, survey, questions, locale)), "locale": locale, "success": true})
 }
 
-func (h *SurveyController) ResumeSurvey(c *fiber.Ctx) error {
+func (h *SurveyController) StartSurvey(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	surveyID := c.Params("id")
-	utils.LogInfo(ctx, "→ ResumeSurvey request", "survey_id", surveyID)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized survey start attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	utils.LogInfo(ctx, "→ StartSurvey request", "survey_id", surveyID, "user_id", userID)
+
+	surveyUUID, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), surveyUUID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	if survey.Status != models.SurveyStatusActive || (survey.ExpiresAt != nil && time.Now().After(*survey.ExpiresAt)) {
+		utils.LogWarn(ctx, "⚠️ Survey not accepting responses", "survey_id", surveyID, "status", survey.Status)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey is no longer accepting responses", "success": false})
+	}
+
+	mismatches, quotas, err := h.fillerTargeting(c.Context(), survey, fillerID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to load filler profile", err, "user_id", userID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check survey eligibility", "success": false})
+	}
+	if len(mismatches) > 0 {
+		utils.LogWarn(ctx, "⚠️ Filler outside survey targeting", "survey_id", surveyID, "user_id", userID, "criteria", mismatches)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not in this survey's target audience", "criteria": mismatches, "success": false})
+	}
+	full, err := h.repo.FullSegments(c.Context(), surveyUUID, quotas)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to check segment quotas", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check survey eligibility", "success": false})
+	}
+	if len(full) > 0 {
+		utils.LogWarn(ctx, "⚠️ Segment quota full", "survey_id", surveyID, "user_id", userID)
+		return c.Status(409).JSON(fiber.Map{"error": "This survey has enough responses from your demographic group", "success": false})
+	}
+
+	session, resumed, err := h.repo.StartSession(c.Context(), surveyUUID, fillerID)
+	if errors.Is(err, repository.ErrAlreadyResponded) {
+		utils.LogWarn(ctx, "⚠️ Survey already completed by filler", "survey_id", surveyID, "user_id", userID)
+		return c.Status(409).JSON(fiber.Map{"error": "You have already completed this survey", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to start survey session", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start survey", "success": false})
+	}
+
+	order, err := h.sessionOrder(c.Context(), survey, session)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to record served order", err, "session_id", session.ID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to start survey", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey session started", "session_id", session.ID, "resumed", resumed)
+
+	return c.JSON(fiber.Map{
+		"ok":           true,
+		"session_id":   session.ID,
+		"started_at":   session.StartedAt,
+		"resumed":      resumed,
+		"progress":     session.Progress,
+		"answers":      session.Answers,
+		"served_order": order,
+		"success":      true,
+	})
+}
+
+func (h *SurveyController) SaveProgress(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized progress save attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	utils.LogInfo(ctx, "→ SaveProgress request", "survey_id", surveyID, "user_id", userID)
+
+	var req struct {
+		Progress          int                    `json:"progress"`
+		Answers           map[string]interface{} `json:"answers"`
+		CurrentQuestionID string                 `json:"current_question_id"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to parse progress request", err)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+	}
+	if req.Progress < 0 || req.Progress > 100 {
+		return c.Status(400).JSON(fiber.Map{"error": "Progress must be between 0 and 100", "success": false})
+	}
+
+	surveyUUID, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+	fillerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	shown, answered := services.SessionTimings(req.CurrentQuestionID, req.Answers)
+
+	if req.Answers == nil {
+		req.Answers = map[string]interface{}{}
+	}
+	answers, _ := json.Marshal(req.Answers)
+
+	session, err := h.repo.SaveSessionProgress(c.Context(), surveyUUID, fillerID, answers, req.Progress, shown, answered)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		utils.LogWarn(ctx, "⚠️ Session not found", "survey_id", surveyID, "user_id", userID)
+		return c.Status(404).JSON(fiber.Map{"error": "Session not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to save progress", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save progress", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Progress saved", "session_id", session.ID, "progress", req.Progress)
+
+	return c.JSON(fiber.Map{
+		"ok":         true,
+		"session_id": session.ID,
+		"progress":   session.Progress,
+		"saved_at":   session.LastActivityAt,
+		"success":    true,
+	})
+}
+
+func (h *SurveyController) PauseSurvey(c *fiber.Ctx) error {
+	return h.transitionSurvey(c, models.SurveyStatusPaused)
+}
+
+func (h *SurveyController) ResumeSurvey(c *fiber.Ctx) error {
+	return h.transitionSurvey(c, models.SurveyStatusActive)
+}
+
+// SubmitSurveyForReview sends a draft or rejected survey to admins for approval
+func (h *SurveyController) SubmitSurveyForReview(c *fiber.Ctx) error {
+	return h.transitionSurvey(c, models.SurveyStatusPendingReview)
+}
+
+func (h *SurveyController) ArchiveSurvey(c *fiber.Ctx) error {
+	return h.transitionSurvey(c, models.SurveyStatusArchived)
+}
+
+// transitionSurvey moves a survey owned by the current user to another lifecycle state
+func (h *SurveyController) transitionSurvey(c *fiber.Ctx, to string) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ TransitionSurvey request", "survey_id", surveyID, "to", to)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized survey status change")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	var req struct {
+		Reason    string `json:"reason"`
+		PromoCode string `json:"promo_code"` // discount on the platform fee when submitting
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.LogError(ctx, "⚠️ Failed to parse status change request", err)
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+		}
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+	actorID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	if survey.CreatorID != actorID {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "creator_id", survey.CreatorID.String(), "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to change this survey's status", "success": false})
+	}
+
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
+	}
+
+	var updated *models.Survey
+	if to == models.SurveyStatusPendingReview {
+		// Submitting is publishing from the creator's side: the survey's cost is locked in escrow now
+		questions, err := h.repo.GetQuestions(c.Context(), id)
+		if err != nil {
+			utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
+		}
+		quote, err := h.billing.QuoteSurvey(c.Context(), survey, len(questions), req.PromoCode)
+		if err != nil {
+			utils.LogWarn(ctx, "⚠️ Validation failed: survey cannot be priced", "survey_id", surveyID, "error", err.Error())
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+		}
+		updated, err = h.repo.SubmitForReview(c.Context(), id, quote, &actorID, reason)
+		if errors.Is(err, repository.ErrInsufficientFunds) {
+			return insufficientCreditsResponse(c, id, quote)
+		}
+		if errors.Is(err, repository.ErrPromoCodeUnavailable) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error(), "success": false})
+		}
+		if err != nil {
+			return transitionErrorResponse(c, survey, to, err)
+		}
+	} else {
+		updated, err = h.repo.TransitionStatus(c.Context(), id, to, &actorID, reason)
+		if err != nil {
+			return transitionErrorResponse(c, survey, to, err)
+		}
+	}
+
+	utils.LogInfo(ctx, "✅ Survey status changed", "survey_id", surveyID, "from", survey.Status, "to", to, "user_id", userID)
+
+	return c.JSON(fiber.Map{
+		"ok":      true,
+		"status":  updated.Status,
+		"data":    updated,
+		"success": true,
+	})
+}
+
+// ApproveSurvey publishes a survey waiting for admin review
+func (h *SurveyController) ApproveSurvey(c *fiber.Ctx) error {
+	return h.reviewSurvey(c, models.SurveyStatusActive)
+}
+
+// RejectSurvey turns down a survey waiting for admin review; the creator can revise and resubmit it
+func (h *SurveyController) RejectSurvey(c *fiber.Ctx) error {
+	return h.reviewSurvey(c, models.SurveyStatusRejected)
+}
+
+// reviewSurvey records an admin's decision on a pending_review survey and notifies its creator
+func (h *SurveyController) reviewSurvey(c *fiber.Ctx, to string) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ ReviewSurvey request (admin)", "survey_id", surveyID, "to", to)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized survey review attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+	adminID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			utils.LogError(ctx, "⚠️ Failed to parse survey review request", err)
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request", "success": false})
+		}
+	}
+	if to == models.SurveyStatusRejected && req.Reason == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "A reason is required to reject a survey", "success": false})
+	}
 
 	id, err := uuid.Parse(surveyID)
 	if err != nil {
 		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
 	}
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
 
-	if err = h.repo.UpdateStatus(c.Context(), id, "active"); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to resume survey", err, "survey_id", surveyID)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to resume survey", "success": false})
+	var reason *string
+	if req.Reason != "" {
+		reason = &req.Reason
 	}
 
-	utils.LogInfo(ctx, "✅ Survey resumed", "survey_id", surveyID)
+	// Approved surveys with a future start wait in scheduled until the scheduler publishes them
+	if to == models.SurveyStatusActive && survey.StartsAt != nil && survey.StartsAt.After(time.Now()) {
+		to = models.SurveyStatusScheduled
+	}
+
+	updated, err := h.repo.TransitionStatus(c.Context(), id, to, &adminID, reason)
+	if err != nil {
+		return transitionErrorResponse(c, survey, to, err)
+	}
+
+	if h.notifier != nil && to != models.SurveyStatusScheduled {
+		if to == models.SurveyStatusActive {
+			h.notifier.NotifyCreatorSurveyApproved(survey.CreatorID, survey.Title)
+		} else {
+			h.notifier.NotifyCreatorSurveyRejected(survey.CreatorID, survey.Title, req.Reason)
+		}
+	}
+
+	utils.LogInfo(ctx, "✅ Survey reviewed", "survey_id", surveyID, "status", to, "admin_id", adminID)
 
 	return c.JSON(fiber.Map{
 		"ok":      true,
-		"status":  "active",
+		"status":  updated.Status,
+		"data":    updated,
 		"success": true,
 	})
 }
 
+// transitionErrorResponse maps a failed lifecycle transition to its HTTP response
+func transitionErrorResponse(c *fiber.Ctx, survey *models.Survey, to string, err error) error {
+	ctx := middleware.GetContextWithTrace(c)
+	switch {
+	case errors.Is(err, repository.ErrSurveyNotFound):
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", survey.ID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	case errors.Is(err, repository.ErrInvalidTransition):
+		utils.LogWarn(ctx, "⚠️ Invalid survey status transition", "survey_id", survey.ID, "from", survey.Status, "to", to)
+		return c.Status(409).JSON(fiber.Map{
+			"error":   fmt.Sprintf("A %s survey cannot be moved to %s", survey.Status, to),
+			"status":  survey.Status,
+			"success": false,
+		})
+	default:
+		utils.LogError(ctx, "⚠️ Database error: failed to change survey status", err, "survey_id", survey.ID, "to", to)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to change survey status", "success": false})
+	}
+}
+
+func insufficientCreditsResponse(c *fiber.Ctx, surveyID uuid.UUID, quote *models.SurveyQuote) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogWarn(ctx, "⚠️ Insufficient credits to fund survey", "survey_id", surveyID, "total_cost", quote.TotalCost)
+	return c.Status(402).JSON(fiber.Map{
+		"error":   "Insufficient credits to fund this survey",
+		"cost":    quote,
+		"success": false,
+	})
+}
+
+// GetSurveyQuote prices publishing the survey as it stands, so creators see the cost before submitting.
+// A ?promo_code is applied to the quote; a funded survey is quoted on the terms it was funded on.
+func (h *SurveyController) GetSurveyQuote(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ GetSurveyQuote request", "survey_id", surveyID)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized quote request")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	if survey.CreatorID.String() != userID {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to price this survey", "success": false})
+	}
+
+	questions, err := h.repo.GetQuestions(c.Context(), id)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
+	}
+	quote, err := h.billing.QuoteSurvey(c.Context(), survey, len(questions), c.Query("promo_code"))
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey cannot be priced", "survey_id", surveyID, "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey quoted", "survey_id", surveyID, "total_cost", quote.TotalCost)
+	return c.JSON(fiber.Map{"data": quote, "funded": survey.FundedAt != nil, "success": true})
+}
+
+// GetSurveyStatusHistory lists when and why a survey changed state; visible to its creator and admins
+func (h *SurveyController) GetSurveyStatusHistory(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ GetSurveyStatusHistory request", "survey_id", surveyID)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized status history request")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	role, _ := c.Locals("role").(string)
+	if survey.CreatorID.String() != userID && role != "admin" && role != "super_admin" {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to view this survey's history", "success": false})
+	}
+
+	history, err := h.repo.GetStatusHistory(c.Context(), id)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to fetch status history", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch status history", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Status history retrieved", "survey_id", surveyID, "count", len(history))
+
+	return c.JSON(fiber.Map{"status": survey.Status, "data": history, "success": true})
+}
+
+// GetSurveyVersions lists a survey's versions with how many responses each collected (creator or admin)
+func (h *SurveyController) GetSurveyVersions(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ GetSurveyVersions request", "survey_id", surveyID)
+
+	survey, resp := h.reviewableSurvey(c, surveyID)
+	if survey == nil {
+		return resp
+	}
+
+	versions, err := h.repo.GetVersions(c.Context(), survey.ID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to fetch survey versions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch survey versions", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey versions retrieved", "survey_id", surveyID, "count", len(versions))
+
+	return c.JSON(fiber.Map{"current_version": survey.Version, "data": versions, "success": true})
+}
+
+// GetSurveyVersion returns one version of a survey with the questions respondents saw in it
+func (h *SurveyController) GetSurveyVersion(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ GetSurveyVersion request", "survey_id", surveyID, "version", c.Params("version"))
+
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil || version < 1 {
+		utils.LogWarn(ctx, "⚠️ Invalid survey version", "version", c.Params("version"))
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey version", "success": false})
+	}
+
+	survey, resp := h.reviewableSurvey(c, surveyID)
+	if survey == nil {
+		return resp
+	}
+
+	snapshot, err := h.repo.GetVersion(c.Context(), survey.ID, version)
+	if errors.Is(err, repository.ErrVersionNotFound) {
+		utils.LogWarn(ctx, "⚠️ Survey version not found", "survey_id", surveyID, "version", version)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey version not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to fetch survey version", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch survey version", "success": false})
+	}
+
+	questions, err := h.repo.GetVersionQuestions(c.Context(), survey.ID, version)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey version retrieved", "survey_id", surveyID, "version", version)
+
+	return c.JSON(fiber.Map{"data": snapshot, "questions": questions, "success": true})
+}
+
+// reviewableSurvey loads a survey its creator or an admin is inspecting. When the survey is nil,
+// the returned error is the response already written to the client.
+func (h *SurveyController) reviewableSurvey(c *fiber.Ctx, surveyID string) (*models.Survey, error) {
+	ctx := middleware.GetContextWithTrace(c)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized survey request")
+		return nil, c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return nil, c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	role, _ := c.Locals("role").(string)
+	if survey.CreatorID.String() != userID && role != "admin" && role != "super_admin" {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "user_id", userID)
+		return nil, c.Status(403).JSON(fiber.Map{"error": "You are not authorized to view this survey", "success": false})
+	}
+	return survey, nil
+}
+
+// ExportSurveyDefinition downloads a survey with its questions in the portable definition format,
+// as JSON by default or YAML with ?format=yaml
+func (h *SurveyController) ExportSurveyDefinition(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	format := services.DefinitionFormat(c.Query("format", services.DefinitionFormatJSON))
+	utils.LogInfo(ctx, "→ ExportSurveyDefinition request", "survey_id", surveyID, "format", format)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized export attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found for export", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	role, _ := c.Locals("role").(string)
+	if survey.CreatorID.String() != userID && role != "admin" && role != "super_admin" {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to export this survey", "success": false})
+	}
+
+	questions, err := h.repo.GetQuestions(c.Context(), id)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
+	}
+
+	data, err := h.definitions.Encode(h.definitions.FromSurvey(survey, questions), format)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to encode survey definition", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to export survey", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey definition exported", "survey_id", surveyID, "format", format, "questions", len(questions))
+
+	contentType := fiber.MIMEApplicationJSON
+	if format == services.DefinitionFormatYAML {
+		contentType = "application/yaml"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="survey-%s.%s"`, survey.ID, format))
+	return c.Send(data)
+}
+
+// ImportSurvey creates a draft survey from a definition uploaded as survey_file or sent as the
+// request body. YAML is detected from the file extension or Content-Type.
 func (h *SurveyController) ImportSurvey(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	utils.LogInfo(ctx, "→ ImportSurvey request")
@@ -574,47 +1716,67 @@ func (h *SurveyController) ImportSurvey(c *fiber.Ctx) error {
 		utils.LogWarn(ctx, "⚠️ Unauthorized import attempt")
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
-
-	file, err := c.FormFile("survey_file")
+	creatorID, err := uuid.Parse(userID)
 	if err != nil {
-		utils.LogWarn(ctx, "⚠️ No file uploaded")
-		return c.Status(400).JSON(fiber.Map{"error": "No file uploaded", "success": false})
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
 	}
 
-	utils.LogInfo(ctx, "Processing survey import", "filename", file.Filename, "size", file.Size)
+	var data []byte
+	format := services.DefinitionFormat(c.Get(fiber.HeaderContentType))
+	if file, err := c.FormFile("survey_file"); err == nil {
+		utils.LogInfo(ctx, "Processing survey import", "filename", file.Filename, "size", file.Size)
+		format = services.DefinitionFormat(file.Filename)
 
-	fileContent, err := file.Open()
-	if err != nil {
-		utils.LogError(ctx, "⚠️ Failed to read file", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to read file", "success": false})
+		fileContent, err := file.Open()
+		if err != nil {
+			utils.LogError(ctx, "⚠️ Failed to read file", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to read file", "success": false})
+		}
+		defer fileContent.Close()
+
+		if data, err = io.ReadAll(fileContent); err != nil {
+			utils.LogError(ctx, "⚠️ Failed to read file", err)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to read file", "success": false})
+		}
+	} else {
+		data = c.Body()
+	}
+	if len(data) == 0 {
+		utils.LogWarn(ctx, "⚠️ No survey definition uploaded")
+		return c.Status(400).JSON(fiber.Map{"error": "No file uploaded", "success": false})
 	}
-	defer fileContent.Close()
 
-	var surveyData models.Survey
-	if err := json.NewDecoder(fileContent).Decode(&surveyData); err != nil {
-		utils.LogError(ctx, "⚠️ Invalid survey format", err)
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey format", "success": false})
+	def, err := h.definitions.Decode(data, format)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey format", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey format", "details": err.Error(), "success": false})
 	}
 
-	surveyData.ID = uuid.New()
-	surveyData.CreatorID, _ = uuid.Parse(userID)
-	surveyData.Status = "draft"
+	survey, questions, err := h.surveyFromDefinition(creatorID, def)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey definition failed validation", "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
 
-	if err = h.repo.CreateSurvey(c.Context(), &surveyData, []models.Question{}, 0); err != nil {
-		utils.LogError(ctx, "⚠️ Failed to import survey", err, "survey_id", surveyData.ID)
+	if err = h.repo.CreateSurvey(c.Context(), survey, questions, nil); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to import survey", err, "survey_id", survey.ID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to import survey", "success": false})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey imported successfully", "survey_id", surveyData.ID, "user_id", userID)
+	utils.LogInfo(ctx, "✅ Survey imported successfully", "survey_id", survey.ID, "user_id", userID, "questions", len(questions))
 
 	return c.JSON(fiber.Map{
-		"ok":      true,
-		"survey":  surveyData,
-		"message": "Survey imported successfully",
-		"success": true,
+		"ok":             true,
+		"survey":         survey,
+		"question_count": len(questions),
+		"message":        "Survey imported successfully",
+		"success":        true,
 	})
 }
 
+// DuplicateSurvey copies a survey and all of its questions into a new draft. Questions get new
+// IDs and branching and consistency references are remapped to the copies.
 func (h *SurveyController) DuplicateSurvey(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
 	surveyID := c.Params("id")
@@ -625,6 +1787,11 @@ func (h *SurveyController) DuplicateSurvey(c *fiber.Ctx) error {
 		utils.LogWarn(ctx, "⚠️ Unauthorized duplication attempt")
 		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
 	}
+	creatorID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
 
 	surveyUUID, err := uuid.Parse(surveyID)
 	if err != nil {
@@ -637,57 +1804,432 @@ func (h *SurveyController) DuplicateSurvey(c *fiber.Ctx) error {
 		utils.LogWarn(ctx, "⚠️ Survey not found for duplication", "survey_id", surveyID)
 		return c.Status(404).JSON(fiber.Map{"error": "Survey not found"})
 	}
+	if originalSurvey.CreatorID != creatorID {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "creator_id", originalSurvey.CreatorID.String(), "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to duplicate this survey"})
+	}
 
-	newSurveyID := uuid.New()
-	newSurvey := *originalSurvey
-	newSurvey.ID = newSurveyID
-	newSurvey.CreatorID = uuid.MustParse(userID)
-	newSurvey.Status = "draft"
-	newSurvey.CreatedAt = time.Now()
-	newSurvey.UpdatedAt = time.Now()
+	originalQuestions, err := h.repo.GetQuestions(c.Context(), surveyUUID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
 
-	utils.LogInfo(ctx, "Duplicating survey", "original_id", surveyID, "new_id", newSurveyID)
+	// Round-trip through the definition format so the copy gets exactly what an export/import would
+	newSurvey, questions, err := h.surveyFromDefinition(creatorID, h.definitions.FromSurvey(originalSurvey, originalQuestions))
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey could not be copied", "survey_id", surveyID, "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
 
-	if err := h.repo.CreateSurvey(c.Context(), &newSurvey, []models.Question{}, 0); err != nil {
+	utils.LogInfo(ctx, "Duplicating survey", "original_id", surveyID, "new_id", newSurvey.ID, "questions", len(questions))
+
+	if err := h.repo.CreateSurvey(c.Context(), newSurvey, questions, nil); err != nil {
 		utils.LogError(ctx, "⚠️ Failed to duplicate survey", err, "original_id", surveyID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to duplicate survey"})
 	}
 
-	utils.LogInfo(ctx, "✅ Survey duplicated successfully", "original_id", surveyID, "new_id", newSurveyID)
+	utils.LogInfo(ctx, "✅ Survey duplicated successfully", "original_id", surveyID, "new_id", newSurvey.ID)
 
 	return c.JSON(fiber.Map{
-		"ok":            true,
-		"original_id":   surveyID,
-		"new_survey_id": newSurveyID,
-		"user_id":       userID,
-		"message":       "Survey duplicated successfully",
+		"ok":             true,
+		"original_id":    surveyID,
+		"new_survey_id":  newSurvey.ID,
+		"question_count": len(questions),
+		"user_id":        userID,
+		"message":        "Survey duplicated successfully",
 	})
 }
 
+// surveyFromDefinition validates a definition and builds the draft survey and questions it describes
+func (h *SurveyController) surveyFromDefinition(creatorID uuid.UUID, def *models.SurveyDefinition) (*models.Survey, []models.Question, error) {
+	if err := h.definitions.Validate(def); err != nil {
+		return nil, nil, err
+	}
+
+	req := def.SurveyRequest()
+	survey := &models.Survey{
+		ID:                uuid.New(),
+		CreatorID:         creatorID,
+		Title:             req.Title,
+		Description:       req.Description,
+		Category:          &req.Category,
+		RewardAmount:      req.RewardAmount,
+		TargetResponses:   req.TargetCount,
+		EstimatedDuration: req.Duration,
+		MinQualityScore:   req.MinQualityScore,
+		RunDays:           h.billing.SurveyRunDays(0),
+		Status:            models.SurveyStatusDraft,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+	if err := h.applyTargeting(survey, &req); err != nil {
+		return nil, nil, err
+	}
+	if errs := h.applyTranslations(survey, &req); len(errs) > 0 {
+		return nil, nil, &services.DefinitionValidationError{Errors: errs}
+	}
+
+	questions := buildQuestions(survey.ID, req.Questions)
+	if err := h.logic.Validate(questions); err != nil {
+		return nil, nil, err
+	}
+	return survey, questions, nil
+}
+
+// definitionErrorResponse renders why a survey definition could not be turned into a survey
+func definitionErrorResponse(c *fiber.Ctx, err error) error {
+	var defErr *services.DefinitionValidationError
+	if errors.As(err, &defErr) {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey definition", "errors": defErr.Errors, "success": false})
+	}
+	var logicErr *services.LogicValidationError
+	if errors.As(err, &logicErr) {
+		return logicErrorResponse(c, err)
+	}
+	return c.Status(400).JSON(fiber.Map{"error": err.Error(), "success": false})
+}
+
+// GetQuestionTypes lists the question types a survey can use, with the settings each is
+// configured with and the shape of its answers
+func (h *SurveyController) GetQuestionTypes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"question_types": h.types.Schemas()})
+}
+
+// GetSurveyTemplates lists the curated template library plus the caller's private templates,
+// optionally filtered by ?category=
 func (h *SurveyController) GetSurveyTemplates(c *fiber.Ctx) error {
 	ctx := middleware.GetContextWithTrace(c)
-	utils.LogInfo(ctx, "→ GetSurveyTemplates request")
+	category := c.Query("category")
+	utils.LogInfo(ctx, "→ GetSurveyTemplates request", "category", category)
 
-	templates := []fiber.Map{
-		{
-			"id":          "template_001",
-			"name":        "Customer Satisfaction Survey",
-			"description": "Measure customer satisfaction and feedback",
-			"category":    "business",
-			"questions":   5,
-		},
-		{
-			"id":          "template_002",
-			"name":        "Market Research Survey",
-			"description": "Understand market trends and preferences",
-			"category":    "research",
-			"questions":   8,
-		},
+	var ownerID *uuid.UUID
+	if userID, ok := c.Locals("user_id").(string); ok {
+		if id, err := uuid.Parse(userID); err == nil {
+			ownerID = &id
+		}
 	}
 
-	utils.LogInfo(ctx, "✅ Templates retrieved", "count", len(templates))
+	templates, err := h.templates.List(c.Context(), ownerID, category)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to fetch templates", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch templates", "success": false})
+	}
 
-	return c.JSON(fiber.Map{"templates": templates})
+	summaries := make([]fiber.Map, 0, len(templates))
+	for _, template := range templates {
+		summaries = append(summaries, fiber.Map{
+			"id":          template.ID,
+			"name":        template.Name,
+			"description": template.Description,
+			"category":    template.Category,
+			"questions":   template.QuestionCount,
+			"curated":     template.IsCurated(),
+			"updated_at":  template.UpdatedAt,
+		})
+	}
+
+	utils.LogInfo(ctx, "✅ Templates retrieved", "count", len(summaries))
+
+	return c.JSON(fiber.Map{"templates": summaries})
+}
+
+// GetSurveyTemplate returns a template with its full survey definition
+func (h *SurveyController) GetSurveyTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	templateID := c.Params("id")
+	utils.LogInfo(ctx, "→ GetSurveyTemplate request", "template_id", templateID)
+
+	template, resp := h.visibleTemplate(c, templateID)
+	if template == nil {
+		return resp
+	}
+
+	utils.LogInfo(ctx, "✅ Template retrieved", "template_id", templateID)
+
+	return c.JSON(fiber.Map{"data": template, "success": true})
+}
+
+// InstantiateTemplate creates a draft survey, with all of its questions, from a template
+func (h *SurveyController) InstantiateTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	templateID := c.Params("id")
+	utils.LogInfo(ctx, "→ InstantiateTemplate request", "template_id", templateID)
+
+	template, resp := h.visibleTemplate(c, templateID)
+	if template == nil {
+		return resp
+	}
+	creatorID, _ := uuid.Parse(c.Locals("user_id").(string))
+
+	def, err := template.ParsedDefinition()
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Stored template definition is unreadable", err, "template_id", templateID)
+		return c.Status(500).JSON(fiber.Map{"error": "Template is corrupted", "success": false})
+	}
+	if def.Category == "" {
+		def.Category = template.Category
+	}
+
+	survey, questions, err := h.surveyFromDefinition(creatorID, def)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Template could not be instantiated", "template_id", templateID, "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
+
+	if err := h.repo.CreateSurvey(c.Context(), survey, questions, nil); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to create survey from template", err, "template_id", templateID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create survey", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey created from template", "template_id", templateID, "survey_id", survey.ID, "questions", len(questions))
+
+	return c.Status(201).JSON(fiber.Map{
+		"ok":             true,
+		"survey_id":      survey.ID,
+		"template_id":    template.ID,
+		"status":         survey.Status,
+		"question_count": len(questions),
+		"message":        "Draft survey created from template",
+	})
+}
+
+// SaveSurveyAsTemplate stores one of the caller's surveys as a private template
+func (h *SurveyController) SaveSurveyAsTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	surveyID := c.Params("id")
+	utils.LogInfo(ctx, "→ SaveSurveyAsTemplate request", "survey_id", surveyID)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized save as template attempt")
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+
+	var req models.SaveAsTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid request body", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body", "success": false})
+	}
+
+	id, err := uuid.Parse(surveyID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid survey ID format", "id", surveyID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID", "success": false})
+	}
+
+	survey, err := h.repo.GetByID(c.Context(), id)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey not found", "survey_id", surveyID)
+		return c.Status(404).JSON(fiber.Map{"error": "Survey not found", "success": false})
+	}
+	if survey.CreatorID.String() != userID {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: user not survey creator", "survey_id", surveyID, "user_id", userID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to save this survey as a template", "success": false})
+	}
+
+	questions, err := h.repo.GetQuestions(c.Context(), id)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions", "success": false})
+	}
+
+	if req.Name == "" {
+		req.Name = survey.Title
+	}
+	if req.Description == "" {
+		req.Description = survey.Description
+	}
+	category := models.SurveyCategoryOther
+	if survey.Category != nil && models.IsSurveyCategory(*survey.Category) {
+		category = *survey.Category
+	}
+
+	template, err := h.templateFromDefinition(req.Name, req.Description, category, h.definitions.FromSurvey(survey, questions))
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Survey could not be saved as a template", "survey_id", surveyID, "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
+	template.OwnerID = &survey.CreatorID
+	template.CreatedBy = &survey.CreatorID
+
+	if err := h.templates.Create(c.Context(), template); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to save template", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to save template", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Survey saved as template", "survey_id", surveyID, "template_id", template.ID)
+
+	return c.Status(201).JSON(fiber.Map{"data": template, "success": true})
+}
+
+// CreateSurveyTemplate adds a curated template to the library (admin)
+func (h *SurveyController) CreateSurveyTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ CreateSurveyTemplate request")
+
+	var req models.SurveyTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid request body", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body", "success": false})
+	}
+
+	template, err := h.templateFromDefinition(req.Name, req.Description, req.Category, &req.Definition)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid template", "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
+	if userID, ok := c.Locals("user_id").(string); ok {
+		if adminID, err := uuid.Parse(userID); err == nil {
+			template.CreatedBy = &adminID
+		}
+	}
+
+	if err := h.templates.Create(c.Context(), template); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to create template", err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create template", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Curated template created", "template_id", template.ID, "questions", template.QuestionCount)
+
+	return c.Status(201).JSON(fiber.Map{"data": template, "success": true})
+}
+
+// UpdateSurveyTemplate replaces the content of a curated template (admin)
+func (h *SurveyController) UpdateSurveyTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	templateID := c.Params("id")
+	utils.LogInfo(ctx, "→ UpdateSurveyTemplate request", "template_id", templateID)
+
+	id, err := uuid.Parse(templateID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid template ID format", "id", templateID)
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid template ID", "success": false})
+	}
+
+	existing, err := h.templates.GetByID(c.Context(), id)
+	if err != nil || !existing.IsCurated() {
+		utils.LogWarn(ctx, "⚠️ Curated template not found", "template_id", templateID)
+		return c.Status(404).JSON(fiber.Map{"error": "Template not found", "success": false})
+	}
+
+	var req models.SurveyTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid request body", "error", err.Error())
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body", "success": false})
+	}
+
+	template, err := h.templateFromDefinition(req.Name, req.Description, req.Category, &req.Definition)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid template", "template_id", templateID, "error", err.Error())
+		return definitionErrorResponse(c, err)
+	}
+	template.ID = existing.ID
+	template.CreatedBy = existing.CreatedBy
+	template.CreatedAt = existing.CreatedAt
+
+	if err := h.templates.Update(c.Context(), template); err != nil {
+		utils.LogError(ctx, "⚠️ Failed to update template", err, "template_id", templateID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update template", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Curated template updated", "template_id", templateID)
+
+	return c.JSON(fiber.Map{"data": template, "success": true})
+}
+
+// DeleteSurveyTemplate removes a template. Creators may delete their private templates;
+// curated templates can only be deleted by admins.
+func (h *SurveyController) DeleteSurveyTemplate(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	templateID := c.Params("id")
+	utils.LogInfo(ctx, "→ DeleteSurveyTemplate request", "template_id", templateID)
+
+	template, resp := h.visibleTemplate(c, templateID)
+	if template == nil {
+		return resp
+	}
+	role, _ := c.Locals("role").(string)
+	if template.IsCurated() && role != "admin" && role != "super_admin" {
+		utils.LogWarn(ctx, "⚠️ Authorization failed: curated templates are admin-managed", "template_id", templateID)
+		return c.Status(403).JSON(fiber.Map{"error": "You are not authorized to delete this template", "success": false})
+	}
+
+	if err := h.templates.Delete(c.Context(), template.ID); err != nil {
+		if errors.Is(err, repository.ErrTemplateNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Template not found", "success": false})
+		}
+		utils.LogError(ctx, "⚠️ Failed to delete template", err, "template_id", templateID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete template", "success": false})
+	}
+
+	utils.LogInfo(ctx, "✅ Template deleted", "template_id", templateID)
+
+	return c.JSON(fiber.Map{"message": "Template deleted", "success": true})
+}
+
+// visibleTemplate loads a template the caller may use. When the template is nil, the returned
+// error is the response already written to the client.
+func (h *SurveyController) visibleTemplate(c *fiber.Ctx, templateID string) (*models.SurveyTemplate, error) {
+	ctx := middleware.GetContextWithTrace(c)
+
+	userID, ok := c.Locals("user_id").(string)
+	if !ok {
+		utils.LogWarn(ctx, "⚠️ Unauthorized template request")
+		return nil, c.Status(401).JSON(fiber.Map{"error": "Unauthorized", "success": false})
+	}
+	callerID, err := uuid.Parse(userID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid user ID", "user_id", userID)
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid user ID", "success": false})
+	}
+
+	id, err := uuid.Parse(templateID)
+	if err != nil {
+		utils.LogWarn(ctx, "⚠️ Invalid template ID format", "id", templateID)
+		return nil, c.Status(400).JSON(fiber.Map{"error": "Invalid template ID", "success": false})
+	}
+
+	template, err := h.templates.GetByID(c.Context(), id)
+	if errors.Is(err, repository.ErrTemplateNotFound) || (err == nil && !template.VisibleTo(callerID)) {
+		// Other creators' private templates are reported as missing rather than forbidden
+		utils.LogWarn(ctx, "⚠️ Template not found", "template_id", templateID, "user_id", userID)
+		return nil, c.Status(404).JSON(fiber.Map{"error": "Template not found", "success": false})
+	}
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Database error: failed to fetch template", err, "template_id", templateID)
+		return nil, c.Status(500).JSON(fiber.Map{"error": "Failed to fetch template", "success": false})
+	}
+	return template, nil
+}
+
+// templateFromDefinition checks that a definition would instantiate cleanly and wraps it as a template
+func (h *SurveyController) templateFromDefinition(name, description, category string, def *models.SurveyDefinition) (*models.SurveyTemplate, error) {
+	if name == "" {
+		return nil, errors.New("template name is required")
+	}
+	if !models.IsSurveyCategory(category) {
+		return nil, fmt.Errorf("unknown template category '%s'", category)
+	}
+	if def.Category == "" {
+		def.Category = category
+	}
+	if _, _, err := h.surveyFromDefinition(uuid.Nil, def); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(def)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SurveyTemplate{
+		ID:            uuid.New(),
+		Name:          name,
+		Description:   description,
+		Category:      category,
+		Definition:    data,
+		QuestionCount: len(def.Questions),
+	}, nil
 }
 
 func (h *SurveyController) SaveSurveyDraft(c *fiber.Ctx) error {
@@ -713,12 +2255,16 @@ func (h *SurveyController) SaveSurveyDraft(c *fiber.Ctx) error {
 		CreatorID:   uuid.MustParse(userID),
 		Title:       req.Title,
 		Description: req.Description,
-		Status:      "draft",
+		Status:      models.SurveyStatusDraft,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if errs := h.applyTranslations(&survey, &req); len(errs) > 0 {
+		utils.LogWarn(ctx, "⚠️ Validation failed: invalid translations", "errors", len(errs))
+		return translationErrorResponse(c, errs)
+	}
 
-	if err := h.repo.CreateSurvey(c.Context(), &survey, []models.Question{}, 0); err != nil {
+	if err := h.repo.CreateSurvey(c.Context(), &survey, []models.Question{}, nil); err != nil {
 		utils.LogError(ctx, "⚠️ Failed to save draft", err, "draft_id", draftID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to save draft"})
 	}
@@ -2,8 +2,12 @@ package controllers
 
 import (
 	"fmt"
+	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
 	"onetimer-backend/services"
+	"onetimer-backend/utils"
 	"path/filepath"
 	"strings"
 
@@ -14,16 +18,18 @@ import (
 type UploadHandler struct {
 	cache          *cache.Cache
 	storageService *services.StorageService
+	surveyRepo     *repository.SurveyRepository
+	types          *services.QuestionTypeRegistry
 }
 
 type UploadController = UploadHandler
 
-func NewUploadHandler(cache *cache.Cache, storageService *services.StorageService) *UploadHandler {
-	return &UploadHandler{cache: cache, storageService: storageService}
+func NewUploadHandler(cache *cache.Cache, storageService *services.StorageService, surveyRepo *repository.SurveyRepository) *UploadHandler {
+	return &UploadHandler{cache: cache, storageService: storageService, surveyRepo: surveyRepo, types: services.NewQuestionTypeRegistry()}
 }
 
-func NewUploadController(cache *cache.Cache, storageService *services.StorageService) *UploadController {
-	return (*UploadController)(NewUploadHandler(cache, storageService))
+func NewUploadController(cache *cache.Cache, storageService *services.StorageService, surveyRepo *repository.SurveyRepository) *UploadController {
+	return (*UploadController)(NewUploadHandler(cache, storageService, surveyRepo))
 }
 
 func (h *UploadHandler) UploadKYC(c *fiber.Ctx) error {
@@ -118,50 +124,97 @@ func (h *UploadHandler) UploadSurveyMedia(c *fiber.Ctx) error {
 	})
 }
 
-// UploadResponseImage handles image uploads for survey responses
+// UploadResponseImage handles image uploads for survey responses. The image answers the
+// file upload question given by the question_id form field, under that question's limits.
 func (h *UploadHandler) UploadResponseImage(c *fiber.Ctx) error {
+	questionID := c.FormValue("question_id")
+	if questionID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "question_id is required"})
+	}
+	return h.uploadResponseFile(c, c.Params("survey_id"), questionID, "image")
+}
+
+// UploadResponseFile uploads a file answering a survey's file upload question. The returned URL
+// is what the filler submits as the question's answer.
+func (h *UploadHandler) UploadResponseFile(c *fiber.Ctx) error {
+	return h.uploadResponseFile(c, c.Params("survey_id"), c.Params("question_id"), "file")
+}
+
+func (h *UploadHandler) uploadResponseFile(c *fiber.Ctx, surveyID, questionID, field string) error {
+	ctx := middleware.GetContextWithTrace(c)
 	userID := c.Locals("user_id").(string)
-	surveyID := c.Params("survey_id")
+	utils.LogInfo(ctx, "→ UploadResponseFile request", "survey_id", surveyID, "question_id", questionID, "user_id", userID)
 
-	file, err := c.FormFile("image")
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "No image uploaded"})
+	if h.storageService == nil || h.surveyRepo == nil {
+		utils.LogWarn(ctx, "⚠️ File storage is not configured")
+		return c.Status(503).JSON(fiber.Map{"error": "File uploads are not available"})
 	}
 
-	// Validate file size (5MB limit)
-	if file.Size > 5*1024*1024 {
-		return c.Status(400).JSON(fiber.Map{"error": "Image size exceeds 5MB limit"})
+	surveyUUID, err := uuid.Parse(surveyID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid survey ID"})
+	}
+	questionUUID, err := uuid.Parse(questionID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid question ID"})
 	}
 
-	// Validate image type
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	allowedExts := []string{".jpg", ".jpeg", ".png"}
-
-	valid := false
-	for _, allowedExt := range allowedExts {
-		if ext == allowedExt {
-			valid = true
+	questions, err := h.surveyRepo.GetQuestions(c.Context(), surveyUUID)
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to fetch questions", err, "survey_id", surveyID)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
+	var question *models.Question
+	for i := range questions {
+		if questions[i].ID == questionUUID {
+			question = &questions[i]
 			break
 		}
 	}
+	if question == nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Question not found"})
+	}
+	if questionType, ok := h.types.Lookup(question.Type); !ok || questionType.Schema().Type != "file_upload" {
+		return c.Status(400).JSON(fiber.Map{"error": "Question does not accept file uploads"})
+	}
 
-	if !valid {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid image format. Only JPG, JPEG, PNG allowed"})
+	file, err := c.FormFile(field)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "No file uploaded"})
 	}
 
-	// Generate unique filename
-	filename := fmt.Sprintf("response_%s_%s_%s%s", surveyID, userID, uuid.New().String()[:8], ext)
+	exts, _, maxSize := services.FileUploadLimits(*question)
+	if file.Size > maxSize {
+		return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("File size exceeds %dMB limit", maxSize/(1024*1024))})
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if len(exts) > 0 {
+		valid := false
+		for _, allowedExt := range exts {
+			if ext == allowedExt {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("Invalid file type. Allowed: %s", strings.Join(exts, ", "))})
+		}
+	}
 
-	// Upload to AWS S3
-	url, err := h.storageService.UploadFile(file, filename)
+	key := services.ResponseFileKey(surveyUUID, questionUUID, userID, ext)
+	url, err := h.storageService.UploadFile(file, key)
 	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to upload response file", err, "survey_id", surveyID, "question_id", questionID)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to upload file to S3"})
 	}
 
+	utils.LogInfo(ctx, "✅ Response file uploaded", "survey_id", surveyID, "question_id", questionID, "key", key)
+
 	return c.JSON(fiber.Map{
-		"ok":       true,
-		"filename": filename,
-		"url":      url,
-		"type":     "response_image",
+		"ok":          true,
+		"filename":    key,
+		"url":         url,
+		"question_id": questionID,
+		"type":        "response_file",
 	})
 }
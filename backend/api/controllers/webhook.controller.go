@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"onetimer-backend/api/middleware"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
+	"onetimer-backend/services"
+	"onetimer-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WebhookController receives payment provider webhooks
+type WebhookController struct {
+	paystack  *services.PaystackService
+	events    services.PaymentEventStore
+	charges   *services.ChargeFulfiller
+	refunds   *services.PurchaseRefunder
+	transfers services.TransferStore
+	invoices  *services.Invoicer
+}
+
+// NewWebhookController takes nil stores when there is no database
+func NewWebhookController(paystack *services.PaystackService, events services.PaymentEventStore, transfers services.TransferStore) *WebhookController {
+	h := &WebhookController{paystack: paystack, events: events, transfers: transfers}
+	if events != nil {
+		h.charges = services.NewChargeFulfiller(events)
+		h.refunds = services.NewPurchaseRefunder(events)
+	}
+	return h
+}
+
+// WithInvoices emails the buyer the invoice for every charge whose credits a webhook grants
+func (h *WebhookController) WithInvoices(invoices *services.Invoicer) *WebhookController {
+	h.invoices = invoices
+	return h
+}
+
+// HandlePaystack verifies and stores a Paystack event, then applies it to payments, credits and
+// withdrawals. Any response but 200 makes Paystack redeliver, so failures that a retry could fix
+// return 500, while events that can never be applied are acknowledged and left unprocessed.
+func (h *WebhookController) HandlePaystack(c *fiber.Ctx) error {
+	ctx := middleware.GetContextWithTrace(c)
+	utils.LogInfo(ctx, "→ Paystack webhook")
+
+	body := c.Body()
+	if h.paystack == nil || !h.paystack.VerifyWebhookSignature(body, c.Get("x-paystack-signature")) {
+		utils.LogWarn(ctx, "⚠️ Paystack webhook signature rejected", "ip", c.IP())
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid signature", "success": false})
+	}
+
+	var envelope services.PaystackWebhookEvent
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Event == "" {
+		utils.LogWarn(ctx, "⚠️ Malformed Paystack webhook payload")
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid payload", "success": false})
+	}
+
+	if h.events == nil {
+		utils.LogWarn(ctx, "⚠️ Paystack webhook received without a database", "event", envelope.Event)
+		return c.Status(503).JSON(fiber.Map{"error": "Payments unavailable", "success": false})
+	}
+
+	hash := sha256.Sum256(body)
+	event := &models.PaystackEvent{
+		Event:       envelope.Event,
+		Reference:   eventReference(envelope.Data),
+		PayloadHash: hex.EncodeToString(hash[:]),
+		Payload:     body,
+	}
+	processed, err := h.events.RecordEvent(c.Context(), event)
+	if err != nil {
+		utils.LogError(ctx, "Failed to store Paystack event", err, "event", event.Event, "reference", event.Reference)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to store event", "success": false})
+	}
+	if processed {
+		utils.LogInfo(ctx, "Duplicate Paystack event ignored", "event", event.Event, "reference", event.Reference, "attempts", event.Attempts)
+		return c.JSON(fiber.Map{"success": true, "duplicate": true})
+	}
+
+	processErr := h.processPaystackEvent(c.Context(), envelope)
+	if err := h.events.FinishEvent(c.Context(), event.ID, processErr); err != nil {
+		utils.LogError(ctx, "Failed to update Paystack event", err, "event_id", event.ID)
+	}
+
+	switch {
+	case processErr == nil:
+		utils.LogInfo(ctx, "✅ Paystack event processed", "event", event.Event, "reference", event.Reference)
+	case errors.Is(processErr, services.ErrPaymentNotFound), errors.Is(processErr, repository.ErrWithdrawalNotFound):
+		utils.LogWarn(ctx, "⚠️ Paystack event references nothing on record", "event", event.Event, "reference", event.Reference, "error", processErr.Error())
+	case errors.Is(processErr, services.ErrPaymentAmountMismatch):
+		// The purchase is already marked failed for reconciliation; a redelivery cannot change that
+		utils.LogWarn(ctx, "⚠️ Paystack charge does not match its purchase", "event", event.Event, "reference", event.Reference)
+	default:
+		utils.LogError(ctx, "Failed to process Paystack event", processErr, "event", event.Event, "reference", event.Reference)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process event", "success": false})
+	}
+	return c.JSON(fiber.Map{"success": true})
+}
+
+func (h *WebhookController) processPaystackEvent(ctx context.Context, envelope services.PaystackWebhookEvent) error {
+	switch envelope.Event {
+	case models.PaystackEventChargeSuccess:
+		var charge services.PaystackChargeData
+		if err := json.Unmarshal(envelope.Data, &charge); err != nil {
+			return err
+		}
+		if charge.Status != "success" {
+			return nil
+		}
+		credits, granted, err := h.charges.Fulfil(ctx, charge.Reference, chargeBuyer(charge.Metadata), charge.Amount/100)
+		if err == nil && granted {
+			utils.LogInfo(ctx, "✅ Credits granted from webhook", "reference", charge.Reference, "amount", charge.Amount/100, "credits", credits)
+			if h.invoices != nil {
+				go h.invoices.Send(context.Background(), charge.Reference)
+			}
+		}
+		return err
+
+	case models.PaystackEventTransferSuccess, models.PaystackEventTransferFailed, models.PaystackEventTransferReversed:
+		var transfer services.PaystackTransferData
+		if err := json.Unmarshal(envelope.Data, &transfer); err != nil {
+			return err
+		}
+		if h.transfers == nil {
+			return errors.New("withdrawals unavailable")
+		}
+		var withdrawal *models.Withdrawal
+		var err error
+		if envelope.Event == models.PaystackEventTransferSuccess {
+			withdrawal, err = h.transfers.CompleteTransfer(ctx, transfer.Reference, transfer.TransferCode)
+		} else {
+			withdrawal, err = h.transfers.FailTransfer(ctx, transfer.Reference, transfer.TransferCode)
+		}
+		if err == nil {
+			utils.LogInfo(ctx, "✅ Withdrawal settled from webhook", "withdrawal_id", withdrawal.ID, "status", withdrawal.Status)
+		}
+		return err
+
+	case models.PaystackEventRefundProcessed, models.PaystackEventRefundFailed:
+		var refund services.PaystackRefundData
+		if err := json.Unmarshal(envelope.Data, &refund); err != nil {
+			return err
+		}
+		if envelope.Event == models.PaystackEventRefundFailed {
+			return h.refunds.FailIssued(ctx, refund.TransactionReference, refund.Reference(), refund.Amount/100)
+		}
+		shortfall, err := h.refunds.Record(ctx, refund.TransactionReference, refund.Reference(), refund.Amount/100)
+		if err == nil && shortfall > 0 {
+			utils.LogWarn(ctx, "⚠️ Refunded credits were already spent", "reference", refund.TransactionReference, "shortfall", shortfall)
+		}
+		return err
+	}
+	return nil
+}
+
+// eventReference picks the reference an event is about, for finding it among stored events
+func eventReference(data json.RawMessage) string {
+	var refs struct {
+		Reference            string `json:"reference"`
+		TransactionReference string `json:"transaction_reference"`
+	}
+	json.Unmarshal(data, &refs)
+	if refs.TransactionReference != "" {
+		return refs.TransactionReference
+	}
+	return refs.Reference
+}
+
+// chargeBuyer reads the buyer a credit purchase was initialized for
+func chargeBuyer(metadata map[string]interface{}) *uuid.UUID {
+	raw, ok := metadata["user_id"].(string)
+	if !ok {
+		return nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -1,12 +1,14 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"onetimer-backend/cache"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,13 +17,15 @@ import (
 )
 
 type WithdrawalController struct {
-	cache       *cache.Cache
-	db          *pgxpool.Pool
-	paystackKey string
+	cache          *cache.Cache
+	db             *pgxpool.Pool
+	paystackKey    string
+	ledgerRepo     *repository.LedgerRepository
+	withdrawalRepo *repository.WithdrawalRepository
 }
 
-func NewWithdrawalController(cache *cache.Cache, db *pgxpool.Pool, paystackKey string) *WithdrawalController {
-	return &WithdrawalController{cache: cache, db: db, paystackKey: paystackKey}
+func NewWithdrawalController(cache *cache.Cache, db *pgxpool.Pool, paystackKey string, ledgerRepo *repository.LedgerRepository, withdrawalRepo *repository.WithdrawalRepository) *WithdrawalController {
+	return &WithdrawalController{cache: cache, db: db, paystackKey: paystackKey, ledgerRepo: ledgerRepo, withdrawalRepo: withdrawalRepo}
 }
 
 // RequestWithdrawal handles withdrawal requests from fillers
@@ -63,21 +67,17 @@ func (h *WithdrawalController) RequestWithdrawal(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Minimum withdrawal amount is ₦5,000"})
 	}
 
-	// Check user balance
-	var balance int
-	balanceQuery := `SELECT COALESCE(SUM(amount), 0) FROM earnings WHERE user_id = $1 AND status = 'completed'`
-	err = h.db.QueryRow(context.Background(), balanceQuery, userID).Scan(&balance)
+	fillerID, err := uuid.Parse(userID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to check balance"})
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
-	// Check for pending withdrawals
-	var pendingAmount int
-	pendingQuery := `SELECT COALESCE(SUM(amount), 0) FROM withdrawals WHERE user_id = $1 AND status IN ('pending', 'processing')`
-	h.db.QueryRow(context.Background(), pendingQuery, userID).Scan(&pendingAmount)
-
-	availableBalance := balance - pendingAmount
-	if availableBalance < req.Amount {
+	// Check user balance; withdrawals still in flight have already left the wallet
+	balance, err := h.ledgerRepo.Balance(c.Context(), models.FillerWalletAccount(fillerID))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check balance"})
+	}
+	if balance < req.Amount {
 		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
 	}
 
@@ -87,21 +87,28 @@ func (h *WithdrawalController) RequestWithdrawal(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "Invalid bank account details"})
 	}
 
-	// Create withdrawal record
-	withdrawalID := uuid.New()
-	insertQuery := `
-		INSERT INTO withdrawals (id, user_id, amount, bank_name, account_number, account_name, bank_code, status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', NOW())
-	`
-	_, err = h.db.Exec(context.Background(), insertQuery, withdrawalID, userID, req.Amount,
-		req.BankName, req.AccountNumber, accountName, req.BankCode)
+	// Create withdrawal record, holding the amount until the payout settles
+	withdrawal := models.Withdrawal{
+		ID:            uuid.New(),
+		UserID:        fillerID,
+		Amount:        req.Amount,
+		BankName:      req.BankName,
+		AccountNumber: req.AccountNumber,
+		AccountName:   accountName,
+		BankCode:      req.BankCode,
+	}
+	err = h.withdrawalRepo.Create(c.Context(), &withdrawal)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		// Another withdrawal spent the balance since it was checked
+		return c.Status(400).JSON(fiber.Map{"error": "Insufficient balance"})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to create withdrawal request"})
 	}
 
 	return c.Status(201).JSON(fiber.Map{
 		"ok":              true,
-		"withdrawal_id":   withdrawalID,
+		"withdrawal_id":   withdrawal.ID,
 		"amount":          req.Amount,
 		"status":          "pending",
 		"message":         "Withdrawal request submitted successfully",
@@ -255,52 +262,3 @@ func (h *WithdrawalController) verifyBankAccount(accountNumber, bankCode string)
 
 	return result.Status, result.Data.AccountName, nil
 }
-
-// processPaystackTransfer processes withdrawal via Paystack
-func (h *WithdrawalController) processPaystackTransfer(withdrawalID uuid.UUID, amount int, accountNumber, bankCode, reason string) error {
-	url := "https://api.paystack.co/transfer"
-
-	payload := map[string]interface{}{
-		"source":    "balance",
-		"amount":    amount * 100, // Convert to kobo
-		"recipient": fmt.Sprintf("%s-%s", bankCode, accountNumber),
-		"reason":    reason,
-		"reference": withdrawalID.String(),
-	}
-
-	jsonPayload, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+h.paystackKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Status bool `json:"status"`
-		Data   struct {
-			Reference    string `json:"reference"`
-			TransferCode string `json:"transfer_code"`
-		} `json:"data"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if result.Status {
-		// Update withdrawal with Paystack reference
-		updateQuery := `UPDATE withdrawals SET paystack_reference = $1, status = 'processing' WHERE id = $2`
-		h.db.Exec(context.Background(), updateQuery, result.Data.TransferCode, withdrawalID)
-	}
-
-	return nil
-}
@@ -44,6 +44,31 @@ func JWTMiddleware(secret string) fiber.Handler {
 	}
 }
 
+// OptionalJWTMiddleware identifies the caller like JWTMiddleware when a valid token is sent, and
+// lets anonymous requests through for public endpoints that tailor their response to the caller
+func OptionalJWTMiddleware(secret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenString := c.Cookies("auth_token")
+		if tokenString == "" {
+			tokenString = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+		}
+		if tokenString == "" {
+			return c.Next()
+		}
+
+		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			return []byte(secret), nil
+		})
+		if err == nil && token.Valid {
+			claims := token.Claims.(*Claims)
+			c.Locals("user_id", claims.UserID)
+			c.Locals("role", claims.Role)
+		}
+
+		return c.Next()
+	}
+}
+
 func RequireRole(roles ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userRole := c.Locals("role").(string)
@@ -23,7 +23,7 @@ func SetupGateway(app *fiber.App, rateLimit int) {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://localhost:3001,https://www.onetimesurvey.xyz",
 		AllowMethods:     "GET,POST,HEAD,PUT,DELETE,PATCH,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,X-CSRF-Token",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With,X-CSRF-Token,Idempotency-Key",
 		ExposeHeaders:    "Content-Length,X-JSON-Response-Count",
 		AllowCredentials: true,
 		MaxAge:           300,
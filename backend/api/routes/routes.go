@@ -1,14 +1,19 @@
 package routes
 
 import (
+	"context"
+	"log"
 	"onetimer-backend/api/controllers"
 	"onetimer-backend/api/handlers"
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/cache"
 	"onetimer-backend/config"
 	"onetimer-backend/database"
+	"onetimer-backend/models"
 	"onetimer-backend/repository"
+	"onetimer-backend/security"
 	"onetimer-backend/services"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
@@ -52,6 +57,11 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 		})
 	})
 
+	// A database whose pool never connected is treated as none, so every feature runs in mock mode
+	if db != nil && db.Pool == nil {
+		db = nil
+	}
+
 	// Initialize repositories
 	var baseRepo *repository.BaseRepository
 	var userRepo *repository.UserRepository
@@ -59,6 +69,13 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	var notificationRepo *repository.NotificationRepository
 	var creditRepo *repository.CreditRepository
 	var surveyRepo *repository.SurveyRepository
+	var templateRepo *repository.TemplateRepository
+	var ledgerRepo *repository.LedgerRepository
+	var withdrawalRepo *repository.WithdrawalRepository
+	var paymentRepo *repository.PaymentRepository
+	var invoiceRepo *repository.InvoiceRepository
+	var reconciliationRepo *repository.ReconciliationRepository
+	var pricingRepo *repository.PricingRepository
 	
 	if db != nil {
 		baseRepo = repository.NewBaseRepository(db)
@@ -67,38 +84,125 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 		notificationRepo = repository.NewNotificationRepository(baseRepo)
 		creditRepo = repository.NewCreditRepository(baseRepo)
 		surveyRepo = repository.NewSurveyRepository(baseRepo)
+		templateRepo = repository.NewTemplateRepository(baseRepo)
+		ledgerRepo = repository.NewLedgerRepository(baseRepo)
+		withdrawalRepo = repository.NewWithdrawalRepository(baseRepo)
+		paymentRepo = repository.NewPaymentRepository(baseRepo)
+		invoiceRepo = repository.NewInvoiceRepository(baseRepo)
+		reconciliationRepo = repository.NewReconciliationRepository(baseRepo)
+		pricingRepo = repository.NewPricingRepository(baseRepo)
+	}
+
+	// Saved cards need a key to seal their authorizations; without one creators pay at a checkout each time
+	var paymentMethodRepo *repository.PaymentMethodRepository
+	if baseRepo != nil && cfg.PaymentMethodKey != "" {
+		sealer, err := security.NewSealer(cfg.PaymentMethodKey)
+		if err != nil {
+			log.Printf("Saved payment methods disabled: %v", err)
+		} else {
+			paymentMethodRepo = repository.NewPaymentMethodRepository(baseRepo, sealer)
+		}
+	}
+
+	// Surveys are priced on the built-in default rules until the database holds the pricing
+	billingService := services.NewBillingService()
+	if pricingRepo != nil {
+		billingService.WithStore(pricingRepo)
+	}
+
+	// Invoices are numbered in the database, so there are none without one
+	var invoices *services.Invoicer
+	if invoiceRepo != nil {
+		invoices = services.NewInvoicer(invoiceRepo, emailService, models.InvoiceIssuer{
+			Name:    cfg.InvoiceCompanyName,
+			Address: cfg.InvoiceCompanyAddress,
+			TaxID:   cfg.InvoiceTaxID,
+			VATRate: cfg.VATRate,
+		})
 	}
 
 	// Initialize controllers with nil-safety checks
 	var dbPool *pgxpool.Pool
+	var notificationService *services.NotificationService
+	var payoutWorker *services.PayoutWorker
+	var reconciler *services.Reconciler
+	var topUpService *services.TopUpService
 	if db != nil {
 		dbPool = db.Pool
+		notificationService = services.NewNotificationService(dbPool, emailService)
+
+		scheduler := services.NewScheduler()
+		registerSurveyJobs(scheduler, surveyRepo, notificationService, time.Duration(cfg.ResponseReviewWindowHours)*time.Hour)
+		registerLedgerJobs(scheduler, ledgerRepo)
+		reconciler = services.NewReconciler(nil, reconciliationRepo)
+		if cfg.PaystackSecret != "" {
+			payoutWorker = services.NewPayoutWorker(paystackService, withdrawalRepo)
+			registerPayoutJobs(scheduler, payoutWorker)
+			reconciler = services.NewReconciler(paystackService, reconciliationRepo)
+			registerReconciliationJobs(scheduler, reconciler)
+			if paymentMethodRepo != nil {
+				topUpService = services.NewTopUpService(paystackService, paymentRepo, paymentMethodRepo).WithInvoices(invoices)
+				registerTopUpJobs(scheduler, topUpService)
+			}
+		}
+		scheduler.Start(context.Background())
+	}
+
+	// Payments run in mock mode until a Paystack key is configured
+	var paymentPaystack *services.PaystackService
+	if cfg.PaystackSecret != "" {
+		paymentPaystack = paystackService
 	}
 
 	userController := controllers.NewUserControllerWithDB(cache, db, userRepo)
 	authController := controllers.NewAuthControllerWithDB(cache, cfg.JWTSecret, emailService, dbPool)
 	adminController := controllers.NewAdminController(cache, dbPool)
 	auditController := controllers.NewAuditController(cache, auditRepo)
-	billingController := controllers.NewBillingController()
-	creditsController := controllers.NewCreditsController(cache, cfg, creditRepo)
-	earningsController := controllers.NewEarningsController(cache, dbPool, cfg)
+	billingController := controllers.NewBillingController(billingService)
+	creditsController := controllers.NewCreditsController(cache, paymentPaystack, creditRepo, paymentRepo, userRepo)
+	if topUpService != nil {
+		creditsController.WithTopUps(topUpService)
+	}
+	earningsController := controllers.NewEarningsController(cache, dbPool, cfg, ledgerRepo, withdrawalRepo)
 	eligibilityController := controllers.NewEligibilityController(cache, db, userRepo)
 	exportController := controllers.NewExportController(cache, dbPool)
-	fillerController := controllers.NewFillerController(cache, dbPool)
+	fillerController := controllers.NewFillerController(cache, dbPool, surveyRepo, ledgerRepo)
 	loginController := controllers.NewLoginHandler(cache, cfg.JWTSecret, userRepo)
 	logoutController := controllers.NewLogoutController()
 	onboardingController := controllers.NewOnboardingController(cache, dbPool)
-	paymentController := controllers.NewPaymentController(cache, cfg.PaystackSecret)
+	paymentController := controllers.NewPaymentController(cache, paymentPaystack, paymentRepo, auditRepo)
+	if invoices != nil {
+		paymentController.WithInvoices(invoices)
+	}
+	if paymentMethodRepo != nil {
+		paymentController.WithPaymentMethods(paymentMethodRepo)
+	}
 	referralController := controllers.NewReferralController(cache, dbPool)
-	superAdminController := controllers.NewSuperAdminController(cache, dbPool)
+	superAdminController := controllers.NewSuperAdminController(cache, dbPool, billingService)
 	superAdminDashboardController := controllers.NewSuperAdminDashboardController(cache, dbPool)
 	superAdminAnalyticsController := controllers.NewSuperAdminAnalyticsController(cache, dbPool)
-	superAdminFinanceController := controllers.NewSuperAdminFinanceController(cache, dbPool)
-	surveyController := controllers.NewSurveyController(cache, surveyRepo)
-	uploadController := controllers.NewUploadController(cache, storageService)
-	withdrawalController := controllers.NewWithdrawalController(cache, dbPool, cfg.PaystackSecret)
+	superAdminFinanceController := controllers.NewSuperAdminFinanceController(cache, dbPool, reconciliationRepo, reconciler)
+	surveyController := controllers.NewSurveyController(cache, surveyRepo, templateRepo, notificationService, billingService)
+	uploadController := controllers.NewUploadController(cache, storageService, surveyRepo)
+	withdrawalController := controllers.NewWithdrawalController(cache, dbPool, cfg.PaystackSecret, ledgerRepo, withdrawalRepo)
 	waitlistController := controllers.NewWaitlistController(dbPool, emailService)
-	analyticsController := controllers.NewAnalyticsController(cache, dbPool)
+	analyticsController := controllers.NewAnalyticsController(cache, dbPool, ledgerRepo)
+	ledgerController := controllers.NewLedgerController(cache, ledgerRepo)
+	// A missing repository has to reach the webhook as a nil store, not a store holding a nil repository
+	var paymentEvents services.PaymentEventStore
+	if paymentRepo != nil {
+		paymentEvents = paymentRepo
+	}
+	var transfers services.TransferStore
+	if withdrawalRepo != nil {
+		transfers = withdrawalRepo
+	}
+	webhookController := controllers.NewWebhookController(paystackService, paymentEvents, transfers)
+	if invoices != nil {
+		webhookController.WithInvoices(invoices)
+	}
+	payoutController := controllers.NewPayoutController(withdrawalRepo, payoutWorker)
+	pricingController := controllers.NewPricingController(pricingRepo, creditRepo, billingService, auditRepo)
 	wsController := controllers.NewWebSocketController(wsHub)
 	notificationController := controllers.NewNotificationHandler(cache, notificationRepo)
 	kycHandler := handlers.NewKYCHandler(cfg)
@@ -152,10 +256,20 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	admin.Post("/users/:id/approve", adminController.ApproveUser)
 	admin.Post("/users/:id/reject", adminController.RejectUser)
 	admin.Get("/surveys", adminController.GetSurveys)
-	admin.Post("/surveys/:id/approve", adminController.ApproveSurvey)
+	admin.Post("/surveys/:id/approve", surveyController.ApproveSurvey)
+	admin.Post("/surveys/:id/reject", surveyController.RejectSurvey)
+	admin.Get("/surveys/:id/history", surveyController.GetSurveyStatusHistory)
+	admin.Get("/surveys/:id/versions", surveyController.GetSurveyVersions)
+	admin.Get("/surveys/:id/versions/:version", surveyController.GetSurveyVersion)
+	admin.Get("/templates", surveyController.GetSurveyTemplates)
+	admin.Post("/templates", surveyController.CreateSurveyTemplate)
+	admin.Put("/templates/:id", surveyController.UpdateSurveyTemplate)
+	admin.Delete("/templates/:id", surveyController.DeleteSurveyTemplate)
 	admin.Get("/payments", adminController.GetPayments)
+	admin.Post("/payments/:id/refund", paymentController.RefundTransaction)
+	admin.Get("/payments/:id/refunds", paymentController.GetRefunds)
 	admin.Get("/reports", adminController.GetReports)
-	admin.Post("/payouts", adminController.ProcessPayouts)
+	admin.Post("/payouts", payoutController.ProcessBatchPayouts)
 	admin.Get("/export/users", adminController.ExportUsers)
 	admin.Get("/users/:id", adminController.GetUserDetails)
 	admin.Post("/users/:id/suspend", adminController.SuspendUser)
@@ -175,8 +289,18 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	creator.Post("/surveys/:id/export", exportController.ExportSurveyResponses)                    // Use exportController
 	creator.Post("/surveys/:id/pause", surveyController.PauseSurvey)                               // Use surveyController
 	creator.Post("/surveys/:id/resume", surveyController.ResumeSurvey)                             // Use surveyController
+	creator.Post("/surveys/:id/submit", surveyController.SubmitSurveyForReview)                    // Use surveyController
+	creator.Post("/surveys/:id/archive", surveyController.ArchiveSurvey)                           // Use surveyController
+	creator.Get("/surveys/:id/history", surveyController.GetSurveyStatusHistory)                   // Use surveyController
+	creator.Get("/surveys/:id/quote", surveyController.GetSurveyQuote)                             // Use surveyController
+	creator.Get("/surveys/:id/versions", surveyController.GetSurveyVersions)                       // Use surveyController
+	creator.Get("/surveys/:id/versions/:version", surveyController.GetSurveyVersion)               // Use surveyController
 	creator.Post("/surveys/:id/duplicate", surveyController.DuplicateSurvey)                       // Use surveyController
+	creator.Get("/surveys/:id/definition", surveyController.ExportSurveyDefinition)                // Use surveyController
+	creator.Post("/surveys/:id/template", surveyController.SaveSurveyAsTemplate)                   // Use surveyController
 	creator.Get("/surveys/:survey_id/responses/:response_id", surveyController.GetResponseDetails) // Use surveyController
+	creator.Post("/surveys/:id/responses/:response_id/approve", surveyController.ApproveResponse)  // Use surveyController
+	creator.Post("/surveys/:id/responses/:response_id/reject", surveyController.RejectResponse)    // Use surveyController
 
 	// Credits routes
 	credits := api.Group("/credits")
@@ -184,6 +308,7 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	credits.Get("/packages", creditsController.GetPackages)
 	credits.Post("/purchase", creditsController.PurchaseCredits)
 	credits.Post("/purchase/custom", creditsController.PurchaseCustom)
+	credits.Post("/top-up", creditsController.TopUp)
 
 	// Earnings routes
 	earnings := api.Group("/earnings")
@@ -193,6 +318,12 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	earnings.Get("/export", earningsController.ExportEarnings)
 	earnings.Post("/withdraw", earningsController.WithdrawEarnings)
 
+	// Ledger routes: balances and statements for the caller's accounts
+	ledger := api.Group("/ledger")
+	ledger.Use(jwtMiddleware)
+	ledger.Get("/balances", ledgerController.GetBalances)
+	ledger.Get("/entries", ledgerController.GetEntries)
+
 	// Eligibility routes
 	eligibility := api.Group("/eligibility")
 	eligibility.Use(middleware.JWTMiddleware(cfg.JWTSecret))
@@ -245,18 +376,29 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	onboarding.Post("/filler", onboardingController.CompleteFillerOnboarding)
 	onboarding.Post("/creator", onboardingController.CompleteCreatorOnboarding)
 	onboarding.Put("/demographics", onboardingController.UpdateDemographics)
+	onboarding.Put("/language", onboardingController.UpdateLanguage)
 	onboarding.Get("/surveys", onboardingController.GetEligibleSurveys)
 
 	// Payment routes
+	// Paystack webhooks authenticate with their signature rather than a JWT
+	webhooks := api.Group("/webhooks")
+	webhooks.Post("/paystack", webhookController.HandlePaystack)
+
 	payment := api.Group("/payment")
 	payment.Use(middleware.JWTMiddleware(cfg.JWTSecret))
-	payment.Post("/purchase", paymentController.PurchaseCredits)
+	payment.Post("/purchase", creditsController.PurchaseCredits)
 	payment.Get("/verify/:reference", paymentController.VerifyPayment)
-	payment.Post("/payouts", paymentController.ProcessBatchPayouts)
+	payment.Post("/payouts", middleware.RequireRole("admin", "super_admin"), payoutController.ProcessBatchPayouts)
 	payment.Get("/methods", paymentController.GetPaymentMethods)
 	payment.Post("/methods", paymentController.AddPaymentMethod)
+	payment.Put("/methods/:id/default", paymentController.SetDefaultPaymentMethod)
+	payment.Delete("/methods/:id", paymentController.DeletePaymentMethod)
+	payment.Get("/auto-top-up", paymentController.GetAutoTopUp)
+	payment.Put("/auto-top-up", paymentController.SaveAutoTopUp)
 	payment.Get("/history", paymentController.GetTransactionHistory)
-	payment.Post("/refund/:id", paymentController.RefundTransaction)
+	payment.Get("/history/:id/invoice", paymentController.GetInvoice)
+	payment.Post("/refund/:id", middleware.RequireRole("admin", "super_admin"), paymentController.RefundTransaction)
+	payment.Get("/refund/:id", middleware.RequireRole("admin", "super_admin"), paymentController.GetRefunds)
 
 	// Referral routes
 	referral := api.Group("/referral")
@@ -293,7 +435,13 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	superAdmin.Get("/financials/metrics", superAdminFinanceController.GetFinancialMetrics)
 	superAdmin.Get("/financials/payouts", superAdminFinanceController.GetPayoutQueue)
 	superAdmin.Get("/financials/reconciliation", superAdminFinanceController.GetReconciliation)
-	superAdmin.Post("/financials/approve-payout/:id", superAdminFinanceController.ApprovePayout)
+	superAdmin.Post("/financials/reconciliation/run", superAdminFinanceController.RunReconciliation)
+	superAdmin.Post("/financials/reconciliation/items/:id/resolve", superAdminFinanceController.ResolveReconciliationItem)
+	superAdmin.Get("/financials/reconciliation/:id", superAdminFinanceController.GetReconciliationReport)
+	superAdmin.Post("/financials/approve-payout/:id", payoutController.ApprovePayout)
+	superAdmin.Post("/financials/payouts/process", payoutController.ProcessBatchPayouts)
+	superAdmin.Post("/financials/payouts/:id/finalize", payoutController.FinalizePayout)
+	superAdmin.Get("/financials/ledger", ledgerController.GetPlatformBalances)
 	
 	// Audit & Settings
 	superAdmin.Post("/audit-logs", auditController.LogAction)
@@ -301,14 +449,32 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	superAdmin.Get("/settings", superAdminController.GetSystemSettings)
 	superAdmin.Put("/settings", superAdminController.UpdateSettings)
 
+	// Pricing rules, promo codes, organization rates and credit packages
+	superAdmin.Get("/pricing", pricingController.GetPricing)
+	superAdmin.Post("/pricing", pricingController.CreatePricingVersion)
+	superAdmin.Get("/pricing/promo-codes", pricingController.GetPromoCodes)
+	superAdmin.Post("/pricing/promo-codes", pricingController.CreatePromoCode)
+	superAdmin.Patch("/pricing/promo-codes/:id", pricingController.UpdatePromoCode)
+	superAdmin.Get("/pricing/organizations", pricingController.GetOrganizationRates)
+	superAdmin.Put("/pricing/organizations/:creator_id", pricingController.SaveOrganizationRate)
+	superAdmin.Delete("/pricing/organizations/:creator_id", pricingController.DeleteOrganizationRate)
+	superAdmin.Get("/pricing/credit-packages", pricingController.GetCreditPackages)
+	superAdmin.Put("/pricing/credit-packages/:id", pricingController.SaveCreditPackage)
+
 	// Survey routes (consolidated - single group with selective middleware)
 	survey := api.Group("/survey")
 
+	// Question types and the template library, registered before /:id so their paths are not taken as a survey ID
+	survey.Get("/question-types", surveyController.GetQuestionTypes)
+	survey.Get("/templates", jwtMiddleware, surveyController.GetSurveyTemplates)
+	survey.Get("/templates/:id", jwtMiddleware, surveyController.GetSurveyTemplate)
+	survey.Post("/templates/:id/instantiate", jwtMiddleware, surveyController.InstantiateTemplate)
+	survey.Delete("/templates/:id", jwtMiddleware, surveyController.DeleteSurveyTemplate)
+
 	// Public GET endpoints (no middleware)
 	survey.Get("/", surveyController.GetSurveys)
 	survey.Get("/:id", surveyController.GetSurvey)
-	survey.Get("/:id/questions", surveyController.GetSurveyQuestions)
-	survey.Get("/templates", surveyController.GetSurveyTemplates)
+	survey.Get("/:id/questions", middleware.OptionalJWTMiddleware(cfg.JWTSecret), surveyController.GetSurveyQuestions)
 
 	// Protected POST/PUT/DELETE endpoints (with JWT middleware)
 	survey.Post("/", jwtMiddleware, surveyController.CreateSurvey)
@@ -319,15 +485,24 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 	survey.Post("/:id/progress", jwtMiddleware, surveyController.SaveProgress)
 	survey.Post("/:id/pause", jwtMiddleware, surveyController.PauseSurvey)
 	survey.Post("/:id/resume", jwtMiddleware, surveyController.ResumeSurvey)
+	survey.Post("/:id/review", jwtMiddleware, surveyController.SubmitSurveyForReview)
+	survey.Post("/:id/archive", jwtMiddleware, surveyController.ArchiveSurvey)
+	survey.Get("/:id/history", jwtMiddleware, surveyController.GetSurveyStatusHistory)
+	survey.Get("/:id/quote", jwtMiddleware, surveyController.GetSurveyQuote)
+	survey.Get("/:id/versions", jwtMiddleware, surveyController.GetSurveyVersions)
+	survey.Get("/:id/versions/:version", jwtMiddleware, surveyController.GetSurveyVersion)
 	survey.Post("/import", jwtMiddleware, surveyController.ImportSurvey)
+	survey.Get("/:id/definition", jwtMiddleware, surveyController.ExportSurveyDefinition)
 	survey.Post("/:id/duplicate", jwtMiddleware, surveyController.DuplicateSurvey)
+	survey.Post("/:id/template", jwtMiddleware, surveyController.SaveSurveyAsTemplate)
 	survey.Post("/draft", jwtMiddleware, surveyController.SaveSurveyDraft)
 
 	// Upload routes
 	upload := api.Group("/upload")
-	upload.Post("/kyc", uploadController.UploadKYC)
-	upload.Post("/survey-media", uploadController.UploadSurveyMedia)
-	upload.Post("/response-image/:survey_id", uploadController.UploadResponseImage)
+	upload.Post("/kyc", jwtMiddleware, uploadController.UploadKYC)
+	upload.Post("/survey-media", jwtMiddleware, uploadController.UploadSurveyMedia)
+	upload.Post("/response-image/:survey_id", jwtMiddleware, uploadController.UploadResponseImage)
+	upload.Post("/response-file/:survey_id/:question_id", jwtMiddleware, uploadController.UploadResponseFile)
 
 	// Withdrawal routes
 	withdrawal := api.Group("/withdrawal")
@@ -351,3 +526,105 @@ func SetupRoutes(app *fiber.App, cache *cache.Cache, cfg *config.Config, db *dat
 		})
 	})
 }
+
+// Survey sessions idle for longer than this are marked abandoned
+const sessionIdleTimeout = 24 * time.Hour
+
+// registerSurveyJobs schedules the survey lifecycle jobs: publishing and expiring surveys on time,
+// abandoning idle sessions and auto-approving responses left unreviewed past the review window
+func registerSurveyJobs(scheduler *services.Scheduler, repo *repository.SurveyRepository, notifier *services.NotificationService, reviewWindow time.Duration) {
+	scheduler.Every("activate_scheduled_surveys", time.Minute, func(ctx context.Context) error {
+		activated, err := repo.ActivateScheduledSurveys(ctx)
+		for _, survey := range activated {
+			notifier.NotifyCreatorSurveyApproved(survey.CreatorID, survey.Title)
+		}
+		if len(activated) > 0 {
+			log.Printf("Published %d scheduled surveys", len(activated))
+		}
+		return err
+	})
+
+	scheduler.Every("expire_surveys", time.Minute, func(ctx context.Context) error {
+		expired, err := repo.ExpireSurveys(ctx)
+		for _, survey := range expired {
+			notifier.NotifyCreatorSurveyExpired(survey.CreatorID, survey.Title, survey.CurrentResponses, survey.RefundedAmount)
+		}
+		if len(expired) > 0 {
+			log.Printf("Expired %d surveys", len(expired))
+		}
+		return err
+	})
+
+	scheduler.Every("abandon_stale_sessions", 15*time.Minute, func(ctx context.Context) error {
+		count, err := repo.AbandonStaleSessions(ctx, sessionIdleTimeout)
+		if count > 0 {
+			log.Printf("Marked %d idle survey sessions as abandoned", count)
+		}
+		return err
+	})
+
+	reviewer := services.NewResponseReviewer(repo)
+	scheduler.Every("auto_approve_responses", time.Hour, func(ctx context.Context) error {
+		approved, err := reviewer.AutoApprove(ctx, reviewWindow)
+		for _, r := range approved {
+			notifier.NotifyFillerResponseApproved(r.FillerID, r.SurveyTitle, r.Amount)
+		}
+		if len(approved) > 0 {
+			log.Printf("Auto-approved %d survey responses", len(approved))
+		}
+		return err
+	})
+}
+
+// registerLedgerJobs schedules the ledger's daily balance snapshot. It runs hourly so a restart
+// around midnight cannot skip a day; each day is only snapshotted once.
+func registerLedgerJobs(scheduler *services.Scheduler, repo *repository.LedgerRepository) {
+	scheduler.Every("snapshot_ledger_balances", time.Hour, func(ctx context.Context) error {
+		count, err := repo.SnapshotBalances(ctx, time.Now().AddDate(0, 0, -1))
+		if count > 0 {
+			log.Printf("Snapshotted %d ledger account balances", count)
+		}
+		return err
+	})
+}
+
+// registerPayoutJobs schedules the payout worker: paying approved withdrawals out every minute and
+// checking on transfers whose webhook never arrived
+func registerPayoutJobs(scheduler *services.Scheduler, worker *services.PayoutWorker) {
+	scheduler.Every("process_payouts", time.Minute, func(ctx context.Context) error {
+		_, err := worker.Run(ctx, nil)
+		return err
+	})
+
+	scheduler.Every("reconcile_payouts", 15*time.Minute, func(ctx context.Context) error {
+		result, err := worker.Reconcile(ctx)
+		if result != nil && result.Completed+result.Failed > 0 {
+			log.Printf("Settled %d payouts without a webhook", result.Completed+result.Failed)
+		}
+		return err
+	})
+}
+
+// registerReconciliationJobs reconciles each day against Paystack once it is over. It runs hourly so
+// a restart cannot skip a day; each day is only reconciled once.
+func registerReconciliationJobs(scheduler *services.Scheduler, reconciler *services.Reconciler) {
+	scheduler.Every("reconcile_paystack", time.Hour, func(ctx context.Context) error {
+		report, err := reconciler.RunDaily(ctx, time.Now().UTC().AddDate(0, 0, -1))
+		if report != nil && report.Issues > 0 {
+			log.Printf("Reconciliation for %s found %d issues", report.PeriodStart.Format("2006-01-02"), report.Issues)
+		}
+		return err
+	})
+}
+
+// registerTopUpJobs charges the saved cards of creators whose credits fell below their auto top-up
+// threshold
+func registerTopUpJobs(scheduler *services.Scheduler, topUps *services.TopUpService) {
+	scheduler.Every("auto_top_up", 15*time.Minute, func(ctx context.Context) error {
+		result, err := topUps.RunAutoTopUps(ctx)
+		if result != nil && result.Declined > 0 {
+			log.Printf("Switched off %d auto top-ups after their cards were declined", result.Declined)
+		}
+		return err
+	})
+}
@@ -79,7 +79,7 @@ func main() {
 
 	// Initialize services
 	emailService := services.NewEmailService(cfg)
-	paystackService := services.NewPaystackService(cfg.PaystackSecret)
+	paystackService := services.NewPaystackService(cfg.PaystackSecret).WithBaseURL(cfg.PaystackBaseURL)
 	storageService, err := services.NewStorageService(cfg.AWSRegion, cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.S3Bucket)
 	if err != nil {
 		log.Printf("⚠️ Storage service initialization failed: %v (uploads will use local storage)", err)
@@ -18,6 +18,8 @@ type Config struct {
 	SupabaseURL        string
 	SupabaseKey        string
 	PaystackSecret     string
+	PaystackBaseURL    string // Paystack API; point at a local fake Paystack in development
+	PaymentMethodKey   string // seals saved card authorizations; changing it makes saved cards unusable
 	AWSRegion          string
 	AWSAccessKeyID     string
 	AWSSecretAccessKey string
@@ -44,6 +46,15 @@ type Config struct {
 	SentryDSN       string
 	SentryRelease   string
 	SentryServerName string
+
+	// Survey responses are auto-approved after sitting in review this long
+	ResponseReviewWindowHours int
+
+	// Invoices: the seller named on credit purchase invoices and the VAT rate prices include
+	InvoiceCompanyName    string
+	InvoiceCompanyAddress string
+	InvoiceTaxID          string
+	VATRate               float64
 }
 
 func Load() *Config {
@@ -51,6 +62,8 @@ func Load() *Config {
 
 	rateLimit, _ := strconv.Atoi(getEnv("RATE_LIMIT", "100"))
 	cacheTTL, _ := strconv.Atoi(getEnv("CACHE_TTL", "300"))
+	reviewWindow, _ := strconv.Atoi(getEnv("RESPONSE_REVIEW_WINDOW_HOURS", "72"))
+	vatRate, _ := strconv.ParseFloat(getEnv("VAT_RATE", "7.5"), 64)
 	
 
 
@@ -65,6 +78,8 @@ func Load() *Config {
 		SupabaseURL:        getEnv("SUPABASE_URL", ""),
 		SupabaseKey:        getEnv("SUPABASE_ANON_KEY", ""),
 		PaystackSecret:     getEnv("PAYSTACK_SECRET_KEY", ""),
+		PaystackBaseURL:    getEnv("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+		PaymentMethodKey:   getEnv("PAYMENT_METHOD_ENCRYPTION_KEY", ""),
 		AWSRegion:          getEnv("AWS_REGION", "us-east-1"),
 		AWSAccessKeyID:     getEnv("AWS_ACCESS_KEY_ID", ""),
 		AWSSecretAccessKey: getEnv("AWS_SECRET_ACCESS_KEY", ""),
@@ -90,6 +105,13 @@ func Load() *Config {
 		SentryDSN:        getEnv("SENTRY_DSN", ""),
 		SentryRelease:    getEnv("SENTRY_RELEASE", "onetimer-backend@dev"),
 		SentryServerName: getEnv("SENTRY_SERVER_NAME", ""),
+
+		ResponseReviewWindowHours: reviewWindow,
+
+		InvoiceCompanyName:    getEnv("INVOICE_COMPANY_NAME", "OneTime Survey"),
+		InvoiceCompanyAddress: getEnv("INVOICE_COMPANY_ADDRESS", ""),
+		InvoiceTaxID:          getEnv("INVOICE_TAX_ID", ""),
+		VATRate:               vatRate,
 	}
 }
 
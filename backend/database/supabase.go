@@ -303,6 +303,391 @@ func (db *SupabaseDB) InitSchema() error {
 		created_at TIMESTAMP DEFAULT NOW(),
 		updated_at TIMESTAMP DEFAULT NOW()
 	);
+
+	-- Question display rules (show/skip/jump_to)
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS logic JSONB;
+
+	-- Type-specific question settings (rating scale, matrix rows/cols)
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS settings JSONB;
+
+	-- One response per filler per survey, with idempotent retries
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_responses_survey_filler ON responses(survey_id, filler_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_responses_idempotency ON responses(filler_id, idempotency_key) WHERE idempotency_key IS NOT NULL;
+
+	-- Survey sessions: in_progress/abandoned/completed responses with per-question timings
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS progress INTEGER DEFAULT 0;
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_responses_status_activity ON responses(status, last_activity_at);
+	CREATE TABLE IF NOT EXISTS response_question_timings (
+		response_id UUID REFERENCES responses(id) ON DELETE CASCADE,
+		question_id UUID REFERENCES questions(id) ON DELETE CASCADE,
+		first_shown_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		answered_at TIMESTAMP,
+		PRIMARY KEY (response_id, question_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_response_question_timings_question ON response_question_timings(question_id);
+
+	-- Response quality scoring: reasons behind each score and a per-survey minimum
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS quality_flags JSONB DEFAULT '[]';
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS min_quality_score INTEGER DEFAULT 0;
+
+	-- Creator review of responses; survey earnings are held until the review settles them
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS review_status VARCHAR(20);
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS review_note TEXT;
+	CREATE INDEX IF NOT EXISTS idx_responses_review ON responses(review_status, completed_at);
+	ALTER TABLE earnings ADD COLUMN IF NOT EXISTS response_id UUID REFERENCES responses(id) ON DELETE SET NULL;
+	CREATE INDEX IF NOT EXISTS idx_earnings_response ON earnings(response_id);
+
+	-- Demographic targeting and per-segment quotas
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS targeting JSONB DEFAULT '{}';
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS quotas JSONB DEFAULT '[]';
+
+	-- Survey lifecycle: when each state was entered and the full transition history
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS expired_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMP;
+	CREATE TABLE IF NOT EXISTS survey_status_history (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		survey_id UUID REFERENCES surveys(id) ON DELETE CASCADE,
+		from_status VARCHAR(50),
+		to_status VARCHAR(50) NOT NULL,
+		actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		reason TEXT,
+		created_at TIMESTAMP DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_survey_status_history_survey ON survey_status_history(survey_id, created_at);
+
+	-- Survey scheduling: start/expiry times and the respondent budget refunded when a survey closes short
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS starts_at TIMESTAMP;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS run_days INTEGER DEFAULT 0;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS budget INTEGER DEFAULT 0;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS refunded_amount INTEGER DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_surveys_starts ON surveys(status, starts_at);
+	CREATE INDEX IF NOT EXISTS idx_surveys_expires ON surveys(status, expires_at);
+
+	-- Survey templates: curated (owner_id NULL) and creator-owned survey definitions
+	CREATE TABLE IF NOT EXISTS survey_templates (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+		name VARCHAR(255) NOT NULL,
+		description TEXT DEFAULT '',
+		category VARCHAR(50) NOT NULL,
+		definition JSONB NOT NULL,
+		question_count INTEGER DEFAULT 0,
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		updated_at TIMESTAMP DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_survey_templates_owner ON survey_templates(owner_id, category);
+
+	-- Survey versions: published surveys are edited into new versions; responses record the one they answered
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS version INTEGER DEFAULT 1;
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS survey_version INTEGER DEFAULT 1;
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS survey_version INTEGER DEFAULT 1;
+	CREATE TABLE IF NOT EXISTS survey_versions (
+		survey_id UUID REFERENCES surveys(id) ON DELETE CASCADE,
+		version INTEGER NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		reward_amount INTEGER DEFAULT 0,
+		target_responses INTEGER DEFAULT 0,
+		estimated_duration INTEGER DEFAULT 0,
+		min_quality_score INTEGER DEFAULT 0,
+		targeting JSONB DEFAULT '{}',
+		quotas JSONB DEFAULT '[]',
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		PRIMARY KEY (survey_id, version)
+	);
+	CREATE INDEX IF NOT EXISTS idx_questions_survey_version ON questions(survey_id, survey_version, order_index);
+	CREATE INDEX IF NOT EXISTS idx_responses_survey_version ON responses(survey_id, survey_version);
+
+	-- Served order: the randomized question and option order drawn for each filler's session
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS served_order JSONB;
+
+	-- Translations: survey and question text by locale, the language each response was served in
+	-- and the language fillers prefer to take surveys in
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en';
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+	ALTER TABLE survey_versions ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en';
+	ALTER TABLE survey_versions ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+	ALTER TABLE questions ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+	ALTER TABLE responses ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en';
+	ALTER TABLE user_profiles ADD COLUMN IF NOT EXISTS preferred_language VARCHAR(10);
+
+	-- Ledger: double-entry accounts, append-only journals and entries, running and daily balances
+	CREATE TABLE IF NOT EXISTS ledger_accounts (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		kind VARCHAR(30) NOT NULL,
+		owner_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		UNIQUE (kind, owner_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_ledger_accounts_owner ON ledger_accounts(owner_id);
+
+	CREATE TABLE IF NOT EXISTS ledger_journals (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		kind VARCHAR(30) NOT NULL,
+		reference VARCHAR(255) NOT NULL UNIQUE,
+		description TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_entries (
+		id BIGSERIAL PRIMARY KEY,
+		journal_id UUID NOT NULL REFERENCES ledger_journals(id),
+		account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+		amount BIGINT NOT NULL CHECK (amount <> 0),
+		balance_after BIGINT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(account_id, id);
+	CREATE INDEX IF NOT EXISTS idx_ledger_entries_journal ON ledger_entries(journal_id);
+
+	CREATE TABLE IF NOT EXISTS ledger_balances (
+		account_id UUID PRIMARY KEY REFERENCES ledger_accounts(id),
+		balance BIGINT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_balance_snapshots (
+		account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+		snapshot_date DATE NOT NULL,
+		balance BIGINT NOT NULL,
+		taken_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (account_id, snapshot_date)
+	);
+
+	CREATE OR REPLACE FUNCTION ledger_reject_change() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION '% rows are immutable', TG_TABLE_NAME;
+	END;
+	$$ LANGUAGE plpgsql;
+	DROP TRIGGER IF EXISTS ledger_journals_immutable ON ledger_journals;
+	CREATE TRIGGER ledger_journals_immutable BEFORE UPDATE OR DELETE ON ledger_journals
+		FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+	DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+	CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+		FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+	-- Survey escrow: the quoted cost is locked when a survey is submitted, its fee taken on first publication
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS platform_fee INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS funded_at TIMESTAMPTZ;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS priority_placement BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS data_export BOOLEAN NOT NULL DEFAULT FALSE;
+
+	-- Paystack webhooks: raw events stored once per payload, payments unique per Paystack reference
+	CREATE TABLE IF NOT EXISTS paystack_events (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		event VARCHAR(50) NOT NULL,
+		reference VARCHAR(255),
+		payload_hash CHAR(64) NOT NULL UNIQUE,
+		payload JSONB NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 1,
+		last_error TEXT,
+		received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		processed_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_paystack_events_reference ON paystack_events(reference);
+	CREATE INDEX IF NOT EXISTS idx_paystack_events_unprocessed ON paystack_events(received_at) WHERE processed_at IS NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_transactions_reference ON payment_transactions(paystack_reference) WHERE paystack_reference IS NOT NULL;
+
+	-- Payouts: approved withdrawals are paid out by the payout worker through Paystack transfers
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS last_error TEXT;
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS approved_by UUID REFERENCES users(id);
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+	ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+	CREATE TABLE IF NOT EXISTS transfer_recipients (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		bank_code VARCHAR(10) NOT NULL,
+		account_number VARCHAR(50) NOT NULL,
+		account_name VARCHAR(255),
+		recipient_code VARCHAR(100) NOT NULL,
+		created_at TIMESTAMP DEFAULT NOW(),
+		UNIQUE (user_id, bank_code, account_number)
+	);
+	CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_queue ON withdrawals(status, next_attempt_at) WHERE status IN ('approved', 'processing');
+
+	-- Refunds: full and partial refunds of credit purchases, issued by admins or made on Paystack
+	CREATE TABLE IF NOT EXISTS refunds (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		payment_id UUID NOT NULL REFERENCES payment_transactions(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		paystack_reference VARCHAR(255) NOT NULL,
+		paystack_refund_id VARCHAR(100),
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		reason TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		issued_by UUID REFERENCES users(id),
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		processed_at TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds(payment_id);
+
+	-- Reconciliation: daily comparisons of payments and withdrawals with Paystack's records
+	CREATE TABLE IF NOT EXISTS reconciliation_reports (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		period_start TIMESTAMPTZ NOT NULL,
+		period_end TIMESTAMPTZ NOT NULL,
+		source VARCHAR(20) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		matched INTEGER NOT NULL DEFAULT 0,
+		issues INTEGER NOT NULL DEFAULT 0,
+		internal_total BIGINT NOT NULL DEFAULT 0,
+		paystack_total BIGINT NOT NULL DEFAULT 0,
+		created_by UUID REFERENCES users(id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_period ON reconciliation_reports(period_start, period_end, source);
+	CREATE TABLE IF NOT EXISTS reconciliation_items (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		report_id UUID NOT NULL REFERENCES reconciliation_reports(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL,
+		reference VARCHAR(255) NOT NULL,
+		issue VARCHAR(30) NOT NULL,
+		internal_amount INTEGER,
+		paystack_amount INTEGER,
+		detail TEXT NOT NULL DEFAULT '',
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		resolved_by UUID REFERENCES users(id),
+		resolution_note TEXT,
+		resolved_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_reconciliation_items_report ON reconciliation_items(report_id, status);
+
+	-- Pricing: effective-dated rules, promo codes and organization rates, locked on surveys when funded
+	CREATE TABLE IF NOT EXISTS pricing_versions (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		version INTEGER NOT NULL UNIQUE,
+		rules JSONB NOT NULL,
+		effective_from TIMESTAMPTZ NOT NULL,
+		note TEXT,
+		created_by UUID REFERENCES users(id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_pricing_versions_effective ON pricing_versions(effective_from DESC, version DESC);
+	CREATE TABLE IF NOT EXISTS promo_codes (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		code VARCHAR(50) NOT NULL UNIQUE,
+		description TEXT,
+		percent_off INTEGER NOT NULL DEFAULT 0 CHECK (percent_off BETWEEN 0 AND 100),
+		amount_off INTEGER NOT NULL DEFAULT 0 CHECK (amount_off >= 0),
+		max_redemptions INTEGER CHECK (max_redemptions > 0),
+		starts_at TIMESTAMPTZ,
+		expires_at TIMESTAMPTZ,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by UUID REFERENCES users(id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	CREATE TABLE IF NOT EXISTS promo_redemptions (
+		promo_code_id UUID NOT NULL REFERENCES promo_codes(id),
+		survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+		creator_id UUID NOT NULL REFERENCES users(id),
+		discount INTEGER NOT NULL DEFAULT 0,
+		redeemed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (promo_code_id, survey_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_promo_redemptions_survey ON promo_redemptions(survey_id);
+	CREATE TABLE IF NOT EXISTS organization_rates (
+		creator_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		platform_fee INTEGER CHECK (platform_fee >= 0),
+		fee_discount_percent INTEGER NOT NULL DEFAULT 0 CHECK (fee_discount_percent BETWEEN 0 AND 100),
+		expires_at TIMESTAMPTZ,
+		note TEXT,
+		updated_by UUID REFERENCES users(id),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	ALTER TABLE surveys ADD COLUMN IF NOT EXISTS pricing_terms JSONB;
+
+	-- Credit packages: the catalog creators buy credits from, and the package each purchase was for
+	CREATE TABLE IF NOT EXISTS credit_packages (
+		id VARCHAR(50) PRIMARY KEY,
+		name VARCHAR(100) NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		price INTEGER NOT NULL CHECK (price > 0),
+		credits INTEGER NOT NULL CHECK (credits >= price),
+		popular BOOLEAN NOT NULL DEFAULT FALSE,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	INSERT INTO credit_packages (id, name, description, price, credits, popular, sort_order) VALUES
+		('starter', 'Starter Pack', 'Perfect for small surveys', 15000, 15000, FALSE, 1),
+		('professional', 'Professional Pack', 'Most popular choice', 40000, 45000, TRUE, 2),
+		('enterprise', 'Enterprise Pack', 'For large-scale research', 120000, 150000, FALSE, 3)
+	ON CONFLICT (id) DO NOTHING;
+	ALTER TABLE payment_transactions ADD COLUMN IF NOT EXISTS package_id VARCHAR(50) REFERENCES credit_packages(id);
+
+	-- Invoices: one sequentially numbered tax invoice and receipt per paid credit purchase
+	CREATE TABLE IF NOT EXISTS invoices (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		number VARCHAR(20) NOT NULL UNIQUE,
+		sequence INTEGER NOT NULL UNIQUE,
+		payment_id UUID NOT NULL UNIQUE REFERENCES payment_transactions(id),
+		user_id UUID NOT NULL REFERENCES users(id),
+		customer_name VARCHAR(255) NOT NULL,
+		customer_email VARCHAR(255) NOT NULL,
+		customer_address VARCHAR(255),
+		organization_name VARCHAR(255),
+		organization_type VARCHAR(50),
+		seller_name VARCHAR(255) NOT NULL,
+		seller_address TEXT NOT NULL DEFAULT '',
+		seller_tax_id VARCHAR(50) NOT NULL DEFAULT '',
+		description TEXT NOT NULL,
+		credits INTEGER NOT NULL,
+		reference VARCHAR(255) NOT NULL,
+		vat_rate NUMERIC(5,2) NOT NULL,
+		subtotal BIGINT NOT NULL,
+		vat BIGINT NOT NULL,
+		total BIGINT NOT NULL,
+		paid_at TIMESTAMPTZ NOT NULL,
+		issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		emailed_at TIMESTAMPTZ
+	);
+	CREATE INDEX IF NOT EXISTS idx_invoices_user ON invoices(user_id, issued_at DESC);
+
+	-- Payment methods: cards saved from Paystack payments, their authorization codes encrypted
+	CREATE TABLE IF NOT EXISTS payment_methods (
+		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		authorization_code TEXT NOT NULL,
+		signature VARCHAR(255) NOT NULL,
+		email VARCHAR(255) NOT NULL,
+		bin VARCHAR(10) NOT NULL DEFAULT '',
+		last4 VARCHAR(4) NOT NULL DEFAULT '',
+		exp_month VARCHAR(2) NOT NULL DEFAULT '',
+		exp_year VARCHAR(4) NOT NULL DEFAULT '',
+		brand VARCHAR(50) NOT NULL DEFAULT '',
+		card_type VARCHAR(50) NOT NULL DEFAULT '',
+		bank VARCHAR(255) NOT NULL DEFAULT '',
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+		last_used_at TIMESTAMPTZ,
+		UNIQUE (user_id, signature)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_methods_default ON payment_methods(user_id) WHERE is_default;
+
+	CREATE TABLE IF NOT EXISTS auto_top_ups (
+		user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		threshold INTEGER NOT NULL CHECK (threshold >= 0),
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		last_triggered_at TIMESTAMPTZ,
+		last_error TEXT,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
 	`
 
 	_, err := db.Exec(context.Background(), schema)
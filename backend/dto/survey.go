@@ -38,7 +38,7 @@ type SurveyResponse struct {
 
 type CreateQuestionRequest struct {
 	SurveyID uuid.UUID `json:"survey_id" validate:"required"`
-	Type     string    `json:"type" validate:"required,oneof=multiple_choice open_ended rating media_upload"`
+	Type     string    `json:"type" validate:"required,oneof=single multiple_choice multi text open_ended rating nps slider ranking date file_upload media_upload matrix likert"` // types registered in services.QuestionTypeRegistry
 	Title    string    `json:"title" validate:"required,min=3,max=500"`
 	Required bool      `json:"required"`
 	OrderNum int       `json:"order_num" validate:"required,min=1"`
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MinCustomPurchase is the smallest custom credit purchase, in naira
+const MinCustomPurchase = 3000
+
+// CreditPackage is a bundle of credits sold at a fixed price in naira. Credits are held in naira, so
+// any credits beyond the price are a bonus the platform gives away with the package.
+type CreditPackage struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Price       int       `json:"price" db:"price"`
+	Credits     int       `json:"credits" db:"credits"`
+	Popular     bool      `json:"popular" db:"popular"`
+	Active      bool      `json:"active" db:"active"`
+	SortOrder   int       `json:"sort_order" db:"sort_order"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Bonus is how many of the package's credits are given on top of what is paid for
+func (p *CreditPackage) Bonus() int {
+	return max(p.Credits-p.Price, 0)
+}
+
+// Validate checks a package before it is stored. A package never grants fewer credits than it costs.
+func (p *CreditPackage) Validate() error {
+	switch {
+	case p.ID == "" || p.Name == "":
+		return errors.New("a package needs an id and a name")
+	case p.Price < MinCustomPurchase:
+		return errors.New("a package cannot cost less than the minimum custom purchase")
+	case p.Credits < p.Price:
+		return errors.New("a package cannot grant fewer credits than it costs")
+	}
+	return nil
+}
+
+// RefundedCredits is how many of a purchase's credits a refund of amount takes back, once refunded
+// had already been refunded. Credits come back in proportion to the price paid for them, bonus
+// included, so refunding a purchase in full, in one refund or several, takes back all of them.
+func RefundedCredits(price, credits, refunded, amount int) int {
+	if price <= 0 {
+		return amount
+	}
+	return (refunded+amount)*credits/price - refunded*credits/price
+}
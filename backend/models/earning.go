@@ -6,10 +6,19 @@ import (
 	"github.com/google/uuid"
 )
 
+// Earning states: survey earnings are held as pending until the response is reviewed, then
+// become available for withdrawal or are reversed if the creator rejects the response
+const (
+	EarningStatusPending   = "pending"
+	EarningStatusAvailable = "available"
+	EarningStatusReversed  = "reversed"
+)
+
 type Earning struct {
 	ID          uuid.UUID  `json:"id" db:"id"`
 	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
 	SurveyID    *uuid.UUID `json:"survey_id" db:"survey_id"`
+	ResponseID  *uuid.UUID `json:"response_id" db:"response_id"`
 	Amount      int        `json:"amount" db:"amount"`
 	Type        string     `json:"type" db:"type"`
 	Status      string     `json:"status" db:"status"`
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InvoiceIssuer is the seller named on invoices, and the VAT rate, in percent, prices include
+type InvoiceIssuer struct {
+	Name    string  `json:"name"`
+	Address string  `json:"address"`
+	TaxID   string  `json:"tax_id"`
+	VATRate float64 `json:"vat_rate"`
+}
+
+// Invoice is the tax invoice and receipt for a paid credit purchase. Invoices are numbered in one
+// unbroken sequence and never change once issued: the buyer's and seller's details are copied onto
+// them. Amounts are in kobo, as VAT is rarely a whole number of naira.
+type Invoice struct {
+	ID               uuid.UUID  `json:"id" db:"id"`
+	Number           string     `json:"number" db:"number"`
+	Sequence         int        `json:"sequence" db:"sequence"`
+	PaymentID        uuid.UUID  `json:"payment_id" db:"payment_id"`
+	UserID           uuid.UUID  `json:"user_id" db:"user_id"`
+	CustomerName     string     `json:"customer_name" db:"customer_name"`
+	CustomerEmail    string     `json:"customer_email" db:"customer_email"`
+	CustomerAddress  *string    `json:"customer_address" db:"customer_address"`
+	OrganizationName *string    `json:"organization_name" db:"organization_name"`
+	OrganizationType *string    `json:"organization_type" db:"organization_type"`
+	SellerName       string     `json:"seller_name" db:"seller_name"`
+	SellerAddress    string     `json:"seller_address" db:"seller_address"`
+	SellerTaxID      string     `json:"seller_tax_id" db:"seller_tax_id"`
+	Description      string     `json:"description" db:"description"`
+	Credits          int        `json:"credits" db:"credits"`
+	Reference        string     `json:"reference" db:"reference"`
+	VATRate          float64    `json:"vat_rate" db:"vat_rate"`
+	Subtotal         int        `json:"subtotal" db:"subtotal"`
+	VAT              int        `json:"vat" db:"vat"`
+	Total            int        `json:"total" db:"total"`
+	PaidAt           time.Time  `json:"paid_at" db:"paid_at"`
+	IssuedAt         time.Time  `json:"issued_at" db:"issued_at"`
+	EmailedAt        *time.Time `json:"emailed_at" db:"emailed_at"`
+}
+
+// InvoicedPurchase is a credit purchase as issuing its invoice sees it: what was paid for, when, and
+// by whom
+type InvoicedPurchase struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	Reference        string
+	Description      *string
+	Amount           int
+	Credits          int
+	Status           string
+	PaidAt           time.Time
+	CustomerName     string
+	CustomerEmail    string
+	CustomerAddress  *string
+	OrganizationName *string
+	OrganizationType *string
+}
+
+// InvoiceNumber formats an invoice's place in the sequence as its number
+func InvoiceNumber(sequence int) string {
+	return fmt.Sprintf("OTS-%06d", sequence)
+}
+
+// SplitVAT splits a VAT-inclusive total, in kobo, into the amount before VAT and the VAT on it
+func SplitVAT(total int, rate float64) (subtotal, vat int) {
+	subtotal = int(math.Round(float64(total) / (1 + rate/100)))
+	return subtotal, total - subtotal
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ledger account kinds. Every naira the platform tracks sits in exactly one ledger account: user
+// and survey accounts are owned by the user or survey they belong to, platform accounts have no owner.
+const (
+	LedgerAccountCreatorCredits = "creator_credits" // per creator: credits available to fund surveys
+	LedgerAccountFillerPending  = "filler_pending"  // per filler: earnings held until their response is reviewed
+	LedgerAccountFillerWallet   = "filler_wallet"   // per filler: earnings available to withdraw
+	LedgerAccountEscrow         = "escrow"          // per survey: budget held for respondent rewards
+	LedgerAccountPlatformFees   = "platform_fees"   // platform: fees the platform has earned
+	LedgerAccountPayoutClearing = "payout_clearing" // platform: withdrawals requested but not yet settled
+	LedgerAccountPaymentGateway = "payment_gateway" // platform: money on the far side of Paystack
+	LedgerAccountPromotions     = "promotions"      // platform: bonus credits given away with credit packages
+)
+
+// Journal kinds, describing the money movement a journal records
+const (
+	JournalOpeningBalance      = "opening_balance"
+	JournalCreditPurchase      = "credit_purchase"
+	JournalSurveyFunding       = "survey_funding"
+	JournalPlatformFee         = "platform_fee"
+	JournalBudgetRefund        = "budget_refund"
+	JournalEarningAccrued      = "earning_accrued"
+	JournalEarningReleased     = "earning_released"
+	JournalEarningReversed     = "earning_reversed"
+	JournalWithdrawalRequested = "withdrawal_requested"
+	JournalWithdrawalPaid      = "withdrawal_paid"
+	JournalWithdrawalReturned  = "withdrawal_returned"
+	JournalChargeRefunded      = "charge_refunded"
+	JournalRefundFailed        = "refund_failed"
+)
+
+var (
+	ErrJournalUnbalanced = errors.New("journal postings do not sum to zero")
+	ErrJournalTooShort   = errors.New("journal needs at least two postings")
+)
+
+// LedgerAccountRef names a ledger account by kind and owner; platform accounts use the nil UUID
+type LedgerAccountRef struct {
+	Kind    string    `json:"kind" db:"kind"`
+	OwnerID uuid.UUID `json:"owner_id" db:"owner_id"`
+}
+
+func CreatorCreditsAccount(creatorID uuid.UUID) LedgerAccountRef {
+	return LedgerAccountRef{Kind: LedgerAccountCreatorCredits, OwnerID: creatorID}
+}
+
+func FillerPendingAccount(fillerID uuid.UUID) LedgerAccountRef {
+	return LedgerAccountRef{Kind: LedgerAccountFillerPending, OwnerID: fillerID}
+}
+
+func FillerWalletAccount(fillerID uuid.UUID) LedgerAccountRef {
+	return LedgerAccountRef{Kind: LedgerAccountFillerWallet, OwnerID: fillerID}
+}
+
+func EscrowAccount(surveyID uuid.UUID) LedgerAccountRef {
+	return LedgerAccountRef{Kind: LedgerAccountEscrow, OwnerID: surveyID}
+}
+
+func PlatformAccount(kind string) LedgerAccountRef {
+	return LedgerAccountRef{Kind: kind}
+}
+
+// MayOverdraw reports whether the account's balance may go negative. The payment gateway account
+// mirrors money held outside the platform and so carries the negative of everything inside it;
+// escrow may run short for surveys created before budgets were escrowed, which is why paying a
+// reward out of a funded survey's escrow checks its balance first; promotions carries the
+// negative of every bonus credit given away.
+func (a LedgerAccountRef) MayOverdraw() bool {
+	return a.Kind == LedgerAccountPaymentGateway || a.Kind == LedgerAccountEscrow || a.Kind == LedgerAccountPromotions
+}
+
+func (a LedgerAccountRef) String() string {
+	if a.OwnerID == uuid.Nil {
+		return a.Kind
+	}
+	return a.Kind + ":" + a.OwnerID.String()
+}
+
+// LedgerPosting moves Amount into an account; a negative amount moves it out
+type LedgerPosting struct {
+	Account LedgerAccountRef `json:"account"`
+	Amount  int              `json:"amount"`
+}
+
+// LedgerJournal is one balanced money movement. Reference is unique across the ledger, so posting
+// the same journal twice records it once.
+type LedgerJournal struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Reference   string          `json:"reference" db:"reference"`
+	Description string          `json:"description" db:"description"`
+	Postings    []LedgerPosting `json:"postings" db:"-"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Transfer is a journal moving amount from one account to another
+func Transfer(kind, reference, description string, from, to LedgerAccountRef, amount int) *LedgerJournal {
+	return &LedgerJournal{
+		Kind:        kind,
+		Reference:   reference,
+		Description: description,
+		Postings:    []LedgerPosting{{Account: from, Amount: -amount}, {Account: to, Amount: amount}},
+	}
+}
+
+// Validate checks the journal can be posted: it has a kind and reference, at least two non-zero
+// postings, and its postings sum to zero
+func (j *LedgerJournal) Validate() error {
+	if j.Kind == "" || j.Reference == "" {
+		return errors.New("journal needs a kind and a reference")
+	}
+	if len(j.Postings) < 2 {
+		return ErrJournalTooShort
+	}
+	sum := 0
+	for _, p := range j.Postings {
+		if p.Amount == 0 {
+			return fmt.Errorf("journal posts nothing to %s", p.Account)
+		}
+		sum += p.Amount
+	}
+	if sum != 0 {
+		return ErrJournalUnbalanced
+	}
+	return nil
+}
+
+// SortedPostings returns the postings ordered by account, the order their balances are locked in
+// so concurrent journals touching the same accounts cannot deadlock
+func (j *LedgerJournal) SortedPostings() []LedgerPosting {
+	sorted := append([]LedgerPosting{}, j.Postings...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return sorted[a].Account.String() < sorted[b].Account.String()
+	})
+	return sorted
+}
+
+// LedgerEntry is one posting as recorded, with the account's balance right after it
+type LedgerEntry struct {
+	ID           int64     `json:"id" db:"id"`
+	JournalID    uuid.UUID `json:"journal_id" db:"journal_id"`
+	JournalKind  string    `json:"journal_kind" db:"journal_kind"`
+	Reference    string    `json:"reference" db:"reference"`
+	Description  string    `json:"description" db:"description"`
+	AccountKind  string    `json:"account_kind" db:"account_kind"`
+	Amount       int       `json:"amount" db:"amount"`
+	BalanceAfter int       `json:"balance_after" db:"balance_after"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// Balances are a user's balances across the accounts they own
+type Balances struct {
+	Credits          int `json:"credits"`
+	PendingEarnings  int `json:"pending_earnings"`
+	AvailableBalance int `json:"available_balance"`
+}
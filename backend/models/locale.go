@@ -0,0 +1,36 @@
+package models
+
+// Locales a survey can be translated into and fillers can prefer. A survey's own text is its
+// canonical language; translations are keyed by locale.
+const (
+	LocaleEnglish = "en"
+	LocaleYoruba  = "yo"
+	LocaleHausa   = "ha"
+	LocaleIgbo    = "ig"
+	LocalePidgin  = "pcm" // Nigerian Pidgin
+)
+
+// IsLocale reports whether locale is one of the supported locales
+func IsLocale(locale string) bool {
+	switch locale {
+	case LocaleEnglish, LocaleYoruba, LocaleHausa, LocaleIgbo, LocalePidgin:
+		return true
+	}
+	return false
+}
+
+// SurveyTranslation is a survey's title and description in one locale
+type SurveyTranslation struct {
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// QuestionTranslation is a question's text in one locale. Options, Rows and Cols map the canonical
+// label to its translation; labels left out are shown untranslated.
+type QuestionTranslation struct {
+	Title       string            `json:"title" yaml:"title"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Options     map[string]string `json:"options,omitempty" yaml:"options,omitempty"`
+	Rows        map[string]string `json:"rows,omitempty" yaml:"rows,omitempty"` // for matrix and likert questions
+	Cols        map[string]string `json:"cols,omitempty" yaml:"cols,omitempty"` // for matrix and likert questions
+}
@@ -0,0 +1,25 @@
+package models
+
+// Display rule actions
+const (
+	LogicActionShow   = "show"    // question is shown only when the conditions match
+	LogicActionSkip   = "skip"    // question is hidden when the conditions match
+	LogicActionJumpTo = "jump_to" // after answering, jump forward to Target when the conditions match
+)
+
+// LogicTargetEnd can be used as a jump_to target to end the survey early (e.g. screen-outs)
+const LogicTargetEnd = "end"
+
+// DisplayRule is a single branching rule attached to a question and stored in questions.logic
+type DisplayRule struct {
+	Action     string          `json:"action" yaml:"action"`                   // show, skip, jump_to
+	Match      string          `json:"match,omitempty" yaml:"match,omitempty"` // all (AND) or any (OR), defaults to all
+	Conditions []RuleCondition `json:"conditions" yaml:"conditions"`
+	Target     string          `json:"target,omitempty" yaml:"target,omitempty"` // question ID or "end", jump_to only
+}
+
+type RuleCondition struct {
+	QuestionID string      `json:"question_id" yaml:"question_id"`
+	Operator   string      `json:"operator" yaml:"operator"` // equals, not_equals, contains, not_contains, in, gt, gte, lt, lte, answered, not_answered
+	Value      interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
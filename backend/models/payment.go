@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment transaction types and states. Amounts are in naira, the unit credits are held in.
+const (
+	PaymentTypePurchase = "purchase"
+	PaymentTypePayout   = "payout"
+	PaymentTypeRefund   = "refund"
+
+	PaymentStatusPending           = "pending"
+	PaymentStatusSuccess           = "success"
+	PaymentStatusFailed            = "failed"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+	PaymentStatusReversed          = "reversed"
+)
+
+type PaymentTransaction struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	UserID            *uuid.UUID `json:"user_id" db:"user_id"`
+	Type              string     `json:"type" db:"type"`
+	Amount            int        `json:"amount" db:"amount"`
+	Credits           int        `json:"credits" db:"credits"`
+	PackageID         *string    `json:"package_id" db:"package_id"`
+	Status            string     `json:"status" db:"status"`
+	PaystackReference *string    `json:"paystack_reference" db:"paystack_reference"`
+	Description       *string    `json:"description" db:"description"`
+	InvoiceNumber     *string    `json:"invoice_number,omitempty" db:"invoice_number"` // only when listed with its invoice
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PurchaseJournal moves a credit purchase's amount from the payment gateway to the buyer's credits,
+// with any bonus credits beyond it from promotions
+func PurchaseJournal(reference string, buyer uuid.UUID, amount, credits int) *LedgerJournal {
+	journal := Transfer(JournalCreditPurchase,
+		fmt.Sprintf("payment:%s:purchase", reference), "Credit purchase",
+		PlatformAccount(LedgerAccountPaymentGateway), CreatorCreditsAccount(buyer), amount)
+	if bonus := credits - amount; bonus > 0 {
+		journal.Postings = append(journal.Postings, LedgerPosting{Account: PlatformAccount(LedgerAccountPromotions), Amount: -bonus})
+		journal.Postings[1].Amount = credits
+	}
+	return journal
+}
+
+// Paystack webhook events the platform acts on; any other event is stored and acknowledged
+const (
+	PaystackEventChargeSuccess    = "charge.success"
+	PaystackEventTransferSuccess  = "transfer.success"
+	PaystackEventTransferFailed   = "transfer.failed"
+	PaystackEventTransferReversed = "transfer.reversed"
+	PaystackEventRefundProcessed  = "refund.processed"
+	PaystackEventRefundFailed     = "refund.failed"
+)
+
+// Refund states: an issued refund takes its credits back from the creator while pending, is
+// processing once Paystack accepts it and processed when Paystack reports the money returned. A
+// failed refund gives the credits back.
+const (
+	RefundStatusPending    = "pending"
+	RefundStatusProcessing = "processing"
+	RefundStatusProcessed  = "processed"
+	RefundStatusFailed     = "failed"
+)
+
+// Refund returns all or part of a credit purchase. IssuedBy is the admin who issued it, and is nil
+// for refunds made from the Paystack dashboard that arrive only through the webhook.
+type Refund struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	PaymentID         uuid.UUID  `json:"payment_id" db:"payment_id"`
+	UserID            uuid.UUID  `json:"user_id" db:"user_id"`
+	PaystackReference string     `json:"paystack_reference" db:"paystack_reference"` // the refunded transaction
+	PaystackRefundID  *string    `json:"paystack_refund_id" db:"paystack_refund_id"`
+	Amount            int        `json:"amount" db:"amount"`
+	Reason            string     `json:"reason" db:"reason"`
+	Status            string     `json:"status" db:"status"`
+	IssuedBy          *uuid.UUID `json:"issued_by" db:"issued_by"`
+	LastError         *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+	ProcessedAt       *time.Time `json:"processed_at" db:"processed_at"`
+}
+
+// ClawbackJournal takes a refund's credits back from the buyer: the refunded amount returns to the
+// payment gateway and the bonus credits bought with it to promotions
+func ClawbackJournal(reference string, buyer uuid.UUID, amount, credits int) *LedgerJournal {
+	journal := Transfer(JournalChargeRefunded, reference, "Credit purchase refunded",
+		CreatorCreditsAccount(buyer), PlatformAccount(LedgerAccountPaymentGateway), amount)
+	if bonus := credits - amount; bonus > 0 {
+		journal.Postings = append(journal.Postings, LedgerPosting{Account: PlatformAccount(LedgerAccountPromotions), Amount: bonus})
+		journal.Postings[0].Amount = -credits
+	}
+	return journal
+}
+
+// RefundClawbackReference is the reference of the journal that took an issued refund's credits back
+func RefundClawbackReference(refundID uuid.UUID) string {
+	return fmt.Sprintf("refund:%s:clawback", refundID)
+}
+
+// RefundablePurchase is a credit purchase as a refund against it is decided: what was paid and
+// granted, how much is already refunded or being refunded, and the credits its buyer still holds
+type RefundablePurchase struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Reference string
+	Amount    int
+	Credits   int
+	Status    string
+	Refunded  int
+	Balance   int
+}
+
+// PaystackEvent is a webhook delivery as received. Paystack redelivers until it gets a 200, so
+// events are keyed by a hash of their payload and processed at most once.
+type PaystackEvent struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	Event       string          `json:"event" db:"event"`
+	Reference   string          `json:"reference" db:"reference"`
+	PayloadHash string          `json:"payload_hash" db:"payload_hash"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	LastError   *string         `json:"last_error" db:"last_error"`
+	ReceivedAt  time.Time       `json:"received_at" db:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at" db:"processed_at"`
+}
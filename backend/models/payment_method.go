@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentMethod is a card a creator saved from a Paystack payment, charged again through its
+// reusable authorization code. The code is a secret: it is stored encrypted and never returned.
+type PaymentMethod struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	UserID            uuid.UUID  `json:"user_id" db:"user_id"`
+	AuthorizationCode string     `json:"-" db:"-"`
+	Signature         string     `json:"-" db:"signature"` // the same card saved twice shares it
+	Email             string     `json:"-" db:"email"`     // Paystack charges an authorization only with the email it was created with
+	Bin               string     `json:"bin" db:"bin"`
+	Last4             string     `json:"last4" db:"last4"`
+	ExpMonth          string     `json:"exp_month" db:"exp_month"`
+	ExpYear           string     `json:"exp_year" db:"exp_year"`
+	Brand             string     `json:"brand" db:"brand"`
+	CardType          string     `json:"card_type" db:"card_type"`
+	Bank              string     `json:"bank" db:"bank"`
+	IsDefault         bool       `json:"is_default" db:"is_default"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at" db:"last_used_at"`
+}
+
+// AutoTopUp buys Amount credits, at ₦1 each, with a creator's default payment method whenever their
+// credits fall below Threshold
+type AutoTopUp struct {
+	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	Enabled         bool       `json:"enabled" db:"enabled"`
+	Threshold       int        `json:"threshold" db:"threshold"`
+	Amount          int        `json:"amount" db:"amount"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at" db:"last_triggered_at"`
+	LastError       *string    `json:"last_error" db:"last_error"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Validate checks auto top-up settings before they are stored
+func (a *AutoTopUp) Validate() error {
+	switch {
+	case a.Threshold < 0:
+		return errors.New("threshold cannot be negative")
+	case a.Amount < MinCustomPurchase:
+		return errors.New("an auto top-up must buy at least the minimum custom purchase")
+	}
+	return nil
+}
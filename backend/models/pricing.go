@@ -0,0 +1,117 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PricingTier prices surveys by their number of pages. MaxPages is the last page count the tier
+// covers; the last tier has none and covers every survey longer than the tier before it.
+type PricingTier struct {
+	Level       string `json:"level"`
+	MaxPages    int    `json:"max_pages,omitempty"`
+	Duration    string `json:"duration"`
+	PlatformFee int    `json:"platform_fee"`
+	MinReward   int    `json:"min_reward"`
+	MaxReward   int    `json:"max_reward"`
+}
+
+// PricingRules is everything a survey's price is worked out from. Amounts are in naira.
+type PricingRules struct {
+	Tiers                 []PricingTier `json:"tiers"`
+	MinReward             int           `json:"min_reward"` // lowest reward per respondent on any survey
+	PriorityPlacement     int           `json:"priority_placement"`
+	DemographicFilter     int           `json:"demographic_filter"` // per filter
+	ExtraDay              int           `json:"extra_day"`          // per day beyond the base run
+	DataExport            int           `json:"data_export"`
+	PlatformFeePercentage float64       `json:"platform_fee_percentage"` // of the respondent rewards, on top of the tier fee
+}
+
+// DefaultPricingRules are the rules surveys are priced on until super admins store their own
+func DefaultPricingRules() PricingRules {
+	return PricingRules{
+		Tiers: []PricingTier{
+			{Level: "Basic", MaxPages: 5, Duration: "2-5 mins", PlatformFee: 150, MinReward: 100, MaxReward: 150},
+			{Level: "Standard", MaxPages: 10, Duration: "5-10 mins", PlatformFee: 300, MinReward: 150, MaxReward: 250},
+			{Level: "Advanced", MaxPages: 20, Duration: "10-20 mins", PlatformFee: 500, MinReward: 300, MaxReward: 500},
+			{Level: "Enterprise", Duration: "20+ mins", PlatformFee: 1000, MinReward: 600, MaxReward: 1000},
+		},
+		MinReward:         100,
+		PriorityPlacement: 500,
+		DemographicFilter: 200,
+		ExtraDay:          100,
+		DataExport:        300,
+	}
+}
+
+// Tier returns the tier covering a number of pages
+func (r PricingRules) Tier(pages int) PricingTier {
+	for _, tier := range r.Tiers {
+		if tier.MaxPages == 0 || pages <= tier.MaxPages {
+			return tier
+		}
+	}
+	return r.Tiers[len(r.Tiers)-1]
+}
+
+// PricingVersion is an immutable set of pricing rules taking effect at a time. The rules in force
+// at any time are those of the latest version to have taken effect by then.
+type PricingVersion struct {
+	ID            uuid.UUID    `json:"id" db:"id"`
+	Version       int          `json:"version" db:"version"` // 0 for the built-in defaults
+	Rules         PricingRules `json:"rules" db:"rules"`
+	EffectiveFrom time.Time    `json:"effective_from" db:"effective_from"`
+	Note          *string      `json:"note" db:"note"`
+	CreatedBy     *uuid.UUID   `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time    `json:"created_at" db:"created_at"`
+}
+
+// PromoCode takes a percentage, an amount or both off the platform fee of the surveys it is
+// redeemed on, never below nothing. Rewards are never discounted.
+type PromoCode struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Code           string     `json:"code" db:"code"`
+	Description    *string    `json:"description" db:"description"`
+	PercentOff     int        `json:"percent_off" db:"percent_off"`
+	AmountOff      int        `json:"amount_off" db:"amount_off"`
+	MaxRedemptions *int       `json:"max_redemptions" db:"max_redemptions"` // nil for unlimited
+	Redemptions    int        `json:"redemptions" db:"redemptions"`
+	StartsAt       *time.Time `json:"starts_at" db:"starts_at"`
+	ExpiresAt      *time.Time `json:"expires_at" db:"expires_at"`
+	Active         bool       `json:"active" db:"active"`
+	CreatedBy      *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Redeemable reports whether the code can be redeemed at a time
+func (p *PromoCode) Redeemable(at time.Time) bool {
+	return p.Active &&
+		(p.StartsAt == nil || !at.Before(*p.StartsAt)) &&
+		(p.ExpiresAt == nil || at.Before(*p.ExpiresAt)) &&
+		(p.MaxRedemptions == nil || p.Redemptions < *p.MaxRedemptions)
+}
+
+// OrganizationRate is a custom rate agreed with the organization behind a creator account. Its
+// platform fee replaces the tier fee, and its discount comes off the platform fee before any promo code.
+type OrganizationRate struct {
+	CreatorID          uuid.UUID  `json:"creator_id" db:"creator_id"`
+	OrganizationName   *string    `json:"organization_name" db:"organization_name"`
+	PlatformFee        *int       `json:"platform_fee" db:"platform_fee"`
+	FeeDiscountPercent int        `json:"fee_discount_percent" db:"fee_discount_percent"`
+	ExpiresAt          *time.Time `json:"expires_at" db:"expires_at"`
+	Note               *string    `json:"note" db:"note"`
+	UpdatedBy          *uuid.UUID `json:"updated_by" db:"updated_by"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PricingTerms are what a survey is priced on: a pricing version's rules, the creator's organization
+// rate and a promo code. They are locked on the survey when it is funded, so its price no longer
+// follows later rule changes.
+type PricingTerms struct {
+	VersionID        *uuid.UUID        `json:"version_id,omitempty"` // nil for the built-in defaults
+	Version          int               `json:"version"`
+	Rules            PricingRules      `json:"rules"`
+	OrganizationRate *OrganizationRate `json:"organization_rate,omitempty"`
+	PromoCode        *PromoCode        `json:"promo_code,omitempty"`
+}
@@ -8,13 +8,96 @@ import (
 )
 
 type Question struct {
-	ID          uuid.UUID       `json:"id" db:"id"`
-	SurveyID    uuid.UUID       `json:"survey_id" db:"survey_id"`
-	Type        string          `json:"type" db:"type"`
-	Title       string          `json:"title" db:"title"`
-	Description *string         `json:"description" db:"description"`
-	Required    bool            `json:"required" db:"required"`
-	Options     json.RawMessage `json:"options" db:"options"`
-	OrderIndex  int             `json:"order_index" db:"order_index"`
-	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	ID            uuid.UUID       `json:"id" db:"id"`
+	SurveyID      uuid.UUID       `json:"survey_id" db:"survey_id"`
+	Type          string          `json:"type" db:"type"`
+	Title         string          `json:"title" db:"title"`
+	Description   *string         `json:"description" db:"description"`
+	Required      bool            `json:"required" db:"required"`
+	Options       json.RawMessage `json:"options" db:"options"`
+	OrderIndex    int             `json:"order_index" db:"order_index"`
+	Logic         json.RawMessage `json:"logic" db:"logic"`
+	Settings      json.RawMessage `json:"settings" db:"settings"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	SurveyVersion int             `json:"survey_version" db:"survey_version"` // survey version the question belongs to
+	Translations  json.RawMessage `json:"translations" db:"translations"`     // QuestionTranslation by locale
+}
+
+// QuestionSettings holds type-specific configuration stored in questions.settings
+type QuestionSettings struct {
+	Scale int      `json:"scale,omitempty"` // for rating questions
+	Rows  []string `json:"rows,omitempty"`  // for matrix and likert questions
+	Cols  []string `json:"cols,omitempty"`  // for matrix and likert questions
+
+	Min  float64 `json:"min,omitempty"`  // for slider questions
+	Max  float64 `json:"max,omitempty"`  // for slider questions
+	Step float64 `json:"step,omitempty"` // for slider questions
+
+	DateMode string `json:"date_mode,omitempty"` // date, time or datetime, for date questions
+	MinDate  string `json:"min_date,omitempty"`  // earliest accepted answer, in the date mode's format
+	MaxDate  string `json:"max_date,omitempty"`  // latest accepted answer, in the date mode's format
+
+	AllowedTypes []string `json:"allowed_types,omitempty"` // file extensions, for file upload questions
+	MaxFiles     int      `json:"max_files,omitempty"`     // for file upload questions
+	MaxSizeMB    int      `json:"max_size_mb,omitempty"`   // per file, for file upload questions
+
+	// Delivery
+	RandomizeOptions bool     `json:"randomize_options,omitempty"` // shuffle options for each filler
+	PinnedOptions    []string `json:"pinned_options,omitempty"`    // options kept in place when shuffling, e.g. "Other"
+	Block            string   `json:"block,omitempty"`             // named blocks of consecutive questions are served in random order
+
+	// Quality checks
+	ExpectedAnswer  interface{} `json:"expected_answer,omitempty"`  // marks an attention-check question
+	ConsistencyWith string      `json:"consistency_with,omitempty"` // ID of a question this one should agree with
+	ConsistencyMode string      `json:"consistency_mode,omitempty"` // same (default) or reverse for reverse-scored ratings
+}
+
+// OptionList decodes the question's options as a list of choice labels
+func (q *Question) OptionList() []string {
+	var options []string
+	if len(q.Options) > 0 {
+		json.Unmarshal(q.Options, &options)
+	}
+	return options
+}
+
+// ParsedSettings decodes the question's type-specific settings
+func (q *Question) ParsedSettings() QuestionSettings {
+	var settings QuestionSettings
+	if len(q.Settings) > 0 {
+		json.Unmarshal(q.Settings, &settings)
+	}
+	return settings
+}
+
+// ParsedTranslations decodes the question's translations, keyed by locale
+func (q *Question) ParsedTranslations() map[string]QuestionTranslation {
+	var translations map[string]QuestionTranslation
+	if len(q.Translations) > 0 {
+		json.Unmarshal(q.Translations, &translations)
+	}
+	return translations
+}
+
+// ForFiller returns a copy of the question safe to show fillers, without attention-check answers
+func (q Question) ForFiller() Question {
+	settings := q.ParsedSettings()
+	if settings.ExpectedAnswer == nil {
+		return q
+	}
+	settings.ExpectedAnswer = nil
+	q.Settings, _ = json.Marshal(settings)
+	return q
+}
+
+// Rules decodes the question's display rules, returning nil when none are set
+func (q *Question) Rules() ([]DisplayRule, error) {
+	if len(q.Logic) == 0 || string(q.Logic) == "null" {
+		return nil, nil
+	}
+	var rules []DisplayRule
+	if err := json.Unmarshal(q.Logic, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
 }
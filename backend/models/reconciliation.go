@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Settlement record kinds: money in from charges and money out through transfers
+const (
+	SettlementKindCharge   = "charge"
+	SettlementKindTransfer = "transfer"
+)
+
+// Where a reconciliation's Paystack side came from
+const (
+	ReconciliationSourcePaystack = "paystack_api"
+	ReconciliationSourceCSV      = "settlement_csv"
+)
+
+// Reconciliation issues: a record only Paystack has, one only we have, a reference seen more than
+// once on a side, or a record both have with a different amount or outcome
+const (
+	ReconciliationMissingInternal = "missing_internal"
+	ReconciliationMissingPaystack = "missing_paystack"
+	ReconciliationDuplicate       = "duplicate"
+	ReconciliationAmountMismatch  = "amount_mismatch"
+	ReconciliationStatusMismatch  = "status_mismatch"
+
+	ReconciliationItemOpen     = "open"
+	ReconciliationItemResolved = "resolved"
+
+	ReconciliationReportClean       = "clean"
+	ReconciliationReportNeedsReview = "needs_review"
+	ReconciliationReportResolved    = "resolved"
+)
+
+// SettlementRecord is one charge or transfer as either side recorded it. Amounts are in naira and
+// Settled says whether the money actually moved.
+type SettlementRecord struct {
+	Kind      string `json:"kind"`
+	Reference string `json:"reference"`
+	Amount    int    `json:"amount"`
+	Status    string `json:"status"`
+	Settled   bool   `json:"settled"`
+}
+
+// ReconciliationReport compares our payments and withdrawals with Paystack's over a period
+type ReconciliationReport struct {
+	ID            uuid.UUID            `json:"id" db:"id"`
+	PeriodStart   time.Time            `json:"period_start" db:"period_start"`
+	PeriodEnd     time.Time            `json:"period_end" db:"period_end"`
+	Source        string               `json:"source" db:"source"`
+	Status        string               `json:"status" db:"status"`
+	Matched       int                  `json:"matched" db:"matched"`
+	Issues        int                  `json:"issues" db:"issues"`
+	InternalTotal int                  `json:"internal_total" db:"internal_total"`
+	PaystackTotal int                  `json:"paystack_total" db:"paystack_total"`
+	CreatedBy     *uuid.UUID           `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time            `json:"created_at" db:"created_at"`
+	Items         []ReconciliationItem `json:"items,omitempty" db:"-"`
+}
+
+// ReconciliationItem is one discrepancy for finance to resolve
+type ReconciliationItem struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ReportID       uuid.UUID  `json:"report_id" db:"report_id"`
+	Kind           string     `json:"kind" db:"kind"`
+	Reference      string     `json:"reference" db:"reference"`
+	Issue          string     `json:"issue" db:"issue"`
+	InternalAmount *int       `json:"internal_amount" db:"internal_amount"`
+	PaystackAmount *int       `json:"paystack_amount" db:"paystack_amount"`
+	Detail         string     `json:"detail" db:"detail"`
+	Status         string     `json:"status" db:"status"`
+	ResolvedBy     *uuid.UUID `json:"resolved_by" db:"resolved_by"`
+	ResolutionNote *string    `json:"resolution_note" db:"resolution_note"`
+	ResolvedAt     *time.Time `json:"resolved_at" db:"resolved_at"`
+}
@@ -1,36 +1,72 @@
 package models
 
+import "time"
+
 type QuestionRequest struct {
-	ID          string   `json:"id"`
-	Type        string   `json:"type"` // single, multi, text, rating, matrix
-	Title       string   `json:"title"`
-	Description string   `json:"description,omitempty"`
-	Required    bool     `json:"required"`
-	Options     []string `json:"options,omitempty"`
-	Scale       int      `json:"scale,omitempty"` // for rating questions
-	Rows        []string `json:"rows,omitempty"`  // for matrix questions
-	Cols        []string `json:"cols,omitempty"`  // for matrix questions
-	Order       int      `json:"order"`
+	ID          string        `json:"id" yaml:"id"`
+	Type        string        `json:"type" yaml:"type"` // a registered question type, see services.QuestionTypeRegistry
+	Title       string        `json:"title" yaml:"title"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Required    bool          `json:"required" yaml:"required"`
+	Options     []string      `json:"options,omitempty" yaml:"options,omitempty"`
+	Scale       int           `json:"scale,omitempty" yaml:"scale,omitempty"` // for rating questions
+	Rows        []string      `json:"rows,omitempty" yaml:"rows,omitempty"`   // for matrix and likert questions
+	Cols        []string      `json:"cols,omitempty" yaml:"cols,omitempty"`   // for matrix and likert questions
+	Order       int           `json:"order" yaml:"order"`
+	Logic       []DisplayRule `json:"logic,omitempty" yaml:"logic,omitempty"` // branching rules, referencing other questions by ID
+
+	Min          float64  `json:"min,omitempty" yaml:"min,omitempty"`                     // for slider questions
+	Max          float64  `json:"max,omitempty" yaml:"max,omitempty"`                     // for slider questions
+	Step         float64  `json:"step,omitempty" yaml:"step,omitempty"`                   // for slider questions
+	DateMode     string   `json:"date_mode,omitempty" yaml:"date_mode,omitempty"`         // date, time or datetime
+	MinDate      string   `json:"min_date,omitempty" yaml:"min_date,omitempty"`           // for date questions
+	MaxDate      string   `json:"max_date,omitempty" yaml:"max_date,omitempty"`           // for date questions
+	AllowedTypes []string `json:"allowed_types,omitempty" yaml:"allowed_types,omitempty"` // file extensions, for file upload questions
+	MaxFiles     int      `json:"max_files,omitempty" yaml:"max_files,omitempty"`         // for file upload questions
+	MaxSizeMB    int      `json:"max_size_mb,omitempty" yaml:"max_size_mb,omitempty"`     // per file, for file upload questions
+
+	RandomizeOptions bool     `json:"randomize_options,omitempty" yaml:"randomize_options,omitempty"` // shuffle options for each filler
+	PinnedOptions    []string `json:"pinned_options,omitempty" yaml:"pinned_options,omitempty"`       // options kept in place when shuffling
+	Block            string   `json:"block,omitempty" yaml:"block,omitempty"`                         // named blocks are served in random order
+
+	ExpectedAnswer  interface{} `json:"expected_answer,omitempty" yaml:"expected_answer,omitempty"`   // makes this an attention-check question
+	ConsistencyWith string      `json:"consistency_with,omitempty" yaml:"consistency_with,omitempty"` // ID of a question this one should agree with
+	ConsistencyMode string      `json:"consistency_mode,omitempty" yaml:"consistency_mode,omitempty"` // same or reverse
+
+	Translations map[string]QuestionTranslation `json:"translations,omitempty" yaml:"translations,omitempty"` // keyed by locale
 }
 
 type SurveyRequest struct {
-	Title              string            `json:"title"`
-	Description        string            `json:"description"`
-	Category           string            `json:"category"`
-	RewardAmount       int               `json:"reward_amount"`
-	TargetCount        int               `json:"target_count"`
-	Duration           int               `json:"estimated_duration"`
-	Questions          []QuestionRequest `json:"questions"`
-	PriorityPlacement  bool              `json:"priority_placement,omitempty"`
-	DemographicFilters []string          `json:"demographic_filters,omitempty"`
-	ExtraDays          int               `json:"extra_days,omitempty"`
-	DataExport         bool              `json:"data_export,omitempty"`
-	Demographics       struct {
-		AgeGroups    []string `json:"age_groups,omitempty"`
-		Genders      []string `json:"genders,omitempty"`
-		Locations    []string `json:"locations,omitempty"`
-		Education    []string `json:"education,omitempty"`
-		Employment   []string `json:"employment,omitempty"`
-		IncomeRanges []string `json:"income_ranges,omitempty"`
-	} `json:"demographics,omitempty"`
+	Title              string                       `json:"title"`
+	Description        string                       `json:"description"`
+	Category           string                       `json:"category"`
+	RewardAmount       int                          `json:"reward_amount"`
+	TargetCount        int                          `json:"target_count"`
+	Duration           int                          `json:"estimated_duration"`
+	Questions          []QuestionRequest            `json:"questions"`
+	PriorityPlacement  bool                         `json:"priority_placement,omitempty"`
+	DemographicFilters []string                     `json:"demographic_filters,omitempty"`
+	ExtraDays          int                          `json:"extra_days,omitempty"`
+	StartsAt           *time.Time                   `json:"starts_at,omitempty"` // open the survey at this time instead of on approval
+	DataExport         bool                         `json:"data_export,omitempty"`
+	MinQualityScore    int                          `json:"min_quality_score,omitempty"` // 0-10, responses below are rejected
+	Demographics       TargetingCriteria            `json:"demographics,omitempty"`
+	Quotas             []SegmentQuota               `json:"quotas,omitempty"`       // per-segment response caps
+	Locale             string                       `json:"locale,omitempty"`       // language the survey is written in, English by default
+	Translations       map[string]SurveyTranslation `json:"translations,omitempty"` // title and description by locale
+	PromoCode          string                       `json:"promo_code,omitempty"`   // discount on the platform fee
+}
+
+// SurveyTemplateRequest creates or replaces a curated template
+type SurveyTemplateRequest struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Category    string           `json:"category"`
+	Definition  SurveyDefinition `json:"definition"`
+}
+
+// SaveAsTemplateRequest saves one of a creator's surveys as a private template
+type SaveAsTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
@@ -7,13 +7,93 @@ import (
 	"github.com/google/uuid"
 )
 
+// Response session states
+const (
+	ResponseStatusInProgress = "in_progress"
+	ResponseStatusAbandoned  = "abandoned"
+	ResponseStatusCompleted  = "completed"
+	ResponseStatusRejected   = "rejected" // failed quality scoring or creator review; not counted or paid
+)
+
+// IsFinishedResponseStatus reports whether a session in the state was submitted, so it can be neither
+// resumed nor submitted again
+func IsFinishedResponseStatus(status string) bool {
+	return status == ResponseStatusCompleted || status == ResponseStatusRejected
+}
+
+// Creator review states of a submitted response
+const (
+	ReviewStatusPending  = "pending_review"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+)
+
 type Response struct {
-	ID           uuid.UUID       `json:"id" db:"id"`
-	SurveyID     uuid.UUID       `json:"survey_id" db:"survey_id"`
-	FillerID     uuid.UUID       `json:"filler_id" db:"filler_id"`
-	Answers      json.RawMessage `json:"answers" db:"answers"`
-	Status       string          `json:"status" db:"status"`
-	StartedAt    time.Time       `json:"started_at" db:"started_at"`
-	CompletedAt  *time.Time      `json:"completed_at" db:"completed_at"`
-	QualityScore int             `json:"quality_score" db:"quality_score"`
+	ID             uuid.UUID       `json:"id" db:"id"`
+	SurveyID       uuid.UUID       `json:"survey_id" db:"survey_id"`
+	FillerID       uuid.UUID       `json:"filler_id" db:"filler_id"`
+	Answers        json.RawMessage `json:"answers" db:"answers"`
+	Status         string          `json:"status" db:"status"`
+	StartedAt      time.Time       `json:"started_at" db:"started_at"`
+	CompletedAt    *time.Time      `json:"completed_at" db:"completed_at"`
+	QualityScore   int             `json:"quality_score" db:"quality_score"`
+	QualityFlags   json.RawMessage `json:"quality_flags" db:"quality_flags"` // reasons behind the score
+	IdempotencyKey *string         `json:"-" db:"idempotency_key"`           // Idempotency-Key header of the creating submission
+	Progress       int             `json:"progress" db:"progress"`           // percentage reported by the client while in progress
+	LastActivityAt *time.Time      `json:"last_activity_at" db:"last_activity_at"`
+	ReviewStatus   *string         `json:"review_status" db:"review_status"` // nil until submitted
+	ReviewedAt     *time.Time      `json:"reviewed_at" db:"reviewed_at"`
+	ReviewNote     *string         `json:"review_note" db:"review_note"`
+	SurveyVersion  int             `json:"survey_version" db:"survey_version"` // survey version the answers were given against
+	ServedOrder    json.RawMessage `json:"served_order" db:"served_order"`     // ServedOrder the filler saw, set when the session starts
+	Locale         string          `json:"locale" db:"locale"`                 // language the filler was served the survey in
+}
+
+// ServedOrder records the order a filler was shown a survey's questions and each question's options in,
+// after block and option randomisation
+type ServedOrder struct {
+	Version   int                 `json:"version"`   // survey version the order was drawn for
+	Questions []string            `json:"questions"` // question IDs in served order
+	Options   map[string][]string `json:"options,omitempty"`
+}
+
+// ParsedServedOrder decodes the response's served order, returning nil when none was recorded
+func (r *Response) ParsedServedOrder() *ServedOrder {
+	if len(r.ServedOrder) == 0 || string(r.ServedOrder) == "null" {
+		return nil
+	}
+	var order ServedOrder
+	if err := json.Unmarshal(r.ServedOrder, &order); err != nil {
+		return nil
+	}
+	return &order
+}
+
+// SubmissionState is the survey state a submitted response is admitted against, read with the survey
+// row locked so concurrent submissions see each other's counts
+type SubmissionState struct {
+	SurveyStatus     string
+	CurrentResponses int
+	TargetResponses  int
+	ExpiresAt        *time.Time
+	Version          int
+	FullSegments     []SegmentQuota // quotas the filler falls into that have no room left
+}
+
+// ReviewOutcome is what a creator's review does to a submitted response
+type ReviewOutcome struct {
+	ReviewStatus   string
+	ResponseStatus string
+	EarningStatus  string // state the response's held earning moves to
+	Uncounted      bool   // the response no longer counts toward the survey's target
+}
+
+// ReviewedResponse describes a response whose review settled its earning, for notifying the filler
+type ReviewedResponse struct {
+	ResponseID  uuid.UUID  `db:"response_id"`
+	FillerID    uuid.UUID  `db:"filler_id"`
+	SurveyID    uuid.UUID  `db:"survey_id"`
+	SurveyTitle string     `db:"survey_title"`
+	EarningID   *uuid.UUID `db:"earning_id"`
+	Amount      int        `db:"amount"`
 }
@@ -1,23 +1,192 @@
 package models
 
 import (
+	"encoding/json"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Survey struct {
-	ID                uuid.UUID  `json:"id" db:"id"`
-	CreatorID         uuid.UUID  `json:"creator_id" db:"creator_id"`
-	Title             string     `json:"title" db:"title"`
-	Description       string     `json:"description" db:"description"`
-	Category          *string    `json:"category" db:"category"`
-	RewardAmount      int        `json:"reward_amount" db:"reward_amount"`
-	EstimatedDuration int        `json:"estimated_duration" db:"estimated_duration"`
-	TargetResponses   int        `json:"target_responses" db:"target_responses"`
-	CurrentResponses  int        `json:"current_responses" db:"current_responses"`
-	Status            string     `json:"status" db:"status"`
-	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
-	ExpiresAt         *time.Time `json:"expires_at" db:"expires_at"`
+	ID                uuid.UUID       `json:"id" db:"id"`
+	CreatorID         uuid.UUID       `json:"creator_id" db:"creator_id"`
+	Title             string          `json:"title" db:"title"`
+	Description       string          `json:"description" db:"description"`
+	Category          *string         `json:"category" db:"category"`
+	RewardAmount      int             `json:"reward_amount" db:"reward_amount"`
+	EstimatedDuration int             `json:"estimated_duration" db:"estimated_duration"`
+	TargetResponses   int             `json:"target_responses" db:"target_responses"`
+	CurrentResponses  int             `json:"current_responses" db:"current_responses"`
+	Status            string          `json:"status" db:"status"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at" db:"updated_at"`
+	ExpiresAt         *time.Time      `json:"expires_at" db:"expires_at"`
+	StartsAt          *time.Time      `json:"starts_at" db:"starts_at"` // scheduled publication, nil to publish on approval
+	RunDays           int             `json:"run_days" db:"run_days"`   // days the survey stays open once published
+	Budget            int             `json:"budget" db:"budget"`       // respondent rewards charged to the creator
+	RefundedAmount    int             `json:"refunded_amount" db:"refunded_amount"`
+	PlatformFee       int             `json:"platform_fee" db:"platform_fee"`             // fee and add-ons charged on top of the rewards
+	FundedAt          *time.Time      `json:"funded_at" db:"funded_at"`                   // when the cost was locked in escrow, nil while unfunded
+	PriorityPlacement bool            `json:"priority_placement" db:"priority_placement"` // billed add-on
+	DataExport        bool            `json:"data_export" db:"data_export"`               // billed add-on
+	MinQualityScore   int             `json:"min_quality_score" db:"min_quality_score"`   // responses scoring below are rejected
+	Targeting         json.RawMessage `json:"targeting" db:"targeting"`
+	Quotas            json.RawMessage `json:"quotas" db:"quotas"`
+	SubmittedAt       *time.Time      `json:"submitted_at" db:"submitted_at"`
+	PublishedAt       *time.Time      `json:"published_at" db:"published_at"`
+	PausedAt          *time.Time      `json:"paused_at" db:"paused_at"`
+	CompletedAt       *time.Time      `json:"completed_at" db:"completed_at"`
+	ExpiredAt         *time.Time      `json:"expired_at" db:"expired_at"`
+	ArchivedAt        *time.Time      `json:"archived_at" db:"archived_at"`
+	RejectedAt        *time.Time      `json:"rejected_at" db:"rejected_at"`
+	Version           int             `json:"version" db:"version"`           // current version; editing a published survey starts a new one
+	Locale            string          `json:"locale" db:"locale"`             // language the survey is written in
+	Translations      json.RawMessage `json:"translations" db:"translations"` // SurveyTranslation by locale
+	PricingTerms      json.RawMessage `json:"-" db:"pricing_terms"`           // PricingTerms locked when funded
+}
+
+// Survey lifecycle states
+const (
+	SurveyStatusDraft         = "draft"
+	SurveyStatusPendingReview = "pending_review"
+	SurveyStatusScheduled     = "scheduled"
+	SurveyStatusActive        = "active"
+	SurveyStatusPaused        = "paused"
+	SurveyStatusCompleted     = "completed"
+	SurveyStatusExpired       = "expired"
+	SurveyStatusArchived      = "archived"
+	SurveyStatusRejected      = "rejected"
+)
+
+// surveyTransitions lists the states each survey state may move to
+var surveyTransitions = map[string][]string{
+	SurveyStatusDraft:         {SurveyStatusPendingReview, SurveyStatusArchived},
+	SurveyStatusPendingReview: {SurveyStatusActive, SurveyStatusScheduled, SurveyStatusRejected, SurveyStatusDraft},
+	SurveyStatusScheduled:     {SurveyStatusActive, SurveyStatusArchived},
+	SurveyStatusRejected:      {SurveyStatusDraft, SurveyStatusPendingReview, SurveyStatusArchived},
+	SurveyStatusActive:        {SurveyStatusPaused, SurveyStatusCompleted, SurveyStatusExpired},
+	SurveyStatusPaused:        {SurveyStatusActive, SurveyStatusCompleted, SurveyStatusExpired, SurveyStatusArchived},
+	SurveyStatusCompleted:     {SurveyStatusActive, SurveyStatusArchived}, // reopened when a counted response is rejected
+	SurveyStatusExpired:       {SurveyStatusArchived},
+}
+
+// CanTransitionSurvey reports whether a survey may move from one lifecycle state to another
+func CanTransitionSurvey(from, to string) bool {
+	for _, next := range surveyTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEditableSurveyStatus reports whether a survey in the state may still be edited. Closed and
+// rejected surveys are not; a rejected survey goes back to draft first
+func IsEditableSurveyStatus(status string) bool {
+	switch status {
+	case SurveyStatusDraft, SurveyStatusPendingReview, SurveyStatusScheduled, SurveyStatusActive, SurveyStatusPaused:
+		return true
+	}
+	return false
+}
+
+// IsClosingSurveyStatus reports whether entering the state ends the survey's run, releasing unused budget
+func IsClosingSurveyStatus(status string) bool {
+	return status == SurveyStatusCompleted || status == SurveyStatusExpired || status == SurveyStatusArchived
+}
+
+// SurveyStatusChange is one entry of a survey's lifecycle history
+type SurveyStatusChange struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	SurveyID   uuid.UUID  `json:"survey_id" db:"survey_id"`
+	FromStatus *string    `json:"from_status" db:"from_status"`
+	ToStatus   string     `json:"to_status" db:"to_status"`
+	ActorID    *uuid.UUID `json:"actor_id" db:"actor_id"` // nil for system transitions
+	Reason     *string    `json:"reason" db:"reason"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SurveyQuote is what publishing a survey costs its creator: the respondent rewards, held in the
+// survey's escrow until each response is approved, and the platform's fee for running it, net of
+// any discount. Terms are what it was priced on, locked on the survey when it is funded.
+type SurveyQuote struct {
+	Budget         int           `json:"budget"`
+	PlatformFee    int           `json:"platform_fee"`
+	Discount       int           `json:"discount"` // taken off the platform fee by an organization rate or promo code
+	TotalCost      int           `json:"total_cost"`
+	PricingVersion int           `json:"pricing_version"`
+	PromoCode      string        `json:"promo_code,omitempty"`
+	Terms          *PricingTerms `json:"-"`
+}
+
+// SurveyVersion is an immutable snapshot of a published survey's content. Its questions are the
+// question rows carrying the same survey_version, and every response records the version it answered.
+type SurveyVersion struct {
+	SurveyID          uuid.UUID       `json:"survey_id" db:"survey_id"`
+	Version           int             `json:"version" db:"version"`
+	Title             string          `json:"title" db:"title"`
+	Description       string          `json:"description" db:"description"`
+	RewardAmount      int             `json:"reward_amount" db:"reward_amount"`
+	TargetResponses   int             `json:"target_responses" db:"target_responses"`
+	EstimatedDuration int             `json:"estimated_duration" db:"estimated_duration"`
+	MinQualityScore   int             `json:"min_quality_score" db:"min_quality_score"`
+	Targeting         json.RawMessage `json:"targeting" db:"targeting"`
+	Quotas            json.RawMessage `json:"quotas" db:"quotas"`
+	Locale            string          `json:"locale" db:"locale"`
+	Translations      json.RawMessage `json:"translations" db:"translations"`
+	CreatedBy         *uuid.UUID      `json:"created_by" db:"created_by"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	QuestionCount     int             `json:"question_count" db:"question_count"`
+	ResponseCount     int             `json:"response_count" db:"response_count"` // completed responses answered against this version
+}
+
+// EditedVersion is the version an edit to a survey lands on. A survey is edited in place until it is
+// first published; after that every edit starts a new version, so responses keep the questions they
+// answered.
+func EditedVersion(version int, publishedAt *time.Time) int {
+	if publishedAt == nil {
+		return version
+	}
+	return version + 1
+}
+
+// TargetingCriteria decodes the survey's demographic targeting
+func (s *Survey) TargetingCriteria() TargetingCriteria {
+	var criteria TargetingCriteria
+	if len(s.Targeting) > 0 {
+		json.Unmarshal(s.Targeting, &criteria)
+	}
+	return criteria
+}
+
+// SegmentQuotas decodes the survey's per-segment response quotas
+func (s *Survey) SegmentQuotas() []SegmentQuota {
+	var quotas []SegmentQuota
+	if len(s.Quotas) > 0 {
+		json.Unmarshal(s.Quotas, &quotas)
+	}
+	return quotas
+}
+
+// ParsedTranslations decodes the survey's title and description translations, keyed by locale
+func (s *Survey) ParsedTranslations() map[string]SurveyTranslation {
+	var translations map[string]SurveyTranslation
+	if len(s.Translations) > 0 {
+		json.Unmarshal(s.Translations, &translations)
+	}
+	return translations
+}
+
+// Locales lists the languages the survey can be served in: its own followed by its translations
+func (s *Survey) Locales() []string {
+	translations := s.ParsedTranslations()
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		if locale != s.Locale {
+			locales = append(locales, locale)
+		}
+	}
+	sort.Strings(locales)
+	return append([]string{s.Locale}, locales...)
 }
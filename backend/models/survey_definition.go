@@ -0,0 +1,63 @@
+package models
+
+// SurveyDefinitionVersion is the current version of the survey definition format.
+// Bump it whenever a change would make an older definition decode differently.
+const SurveyDefinitionVersion = 1
+
+// SurveyDefinition is the portable form of a survey used for export, import and duplication,
+// written as JSON or YAML. Questions refer to one another (branching rules, consistency checks)
+// by their definition-local id, so the same definition can be imported any number of times.
+//
+//	format_version: 1
+//	title: Commute Survey
+//	description: How you get to work
+//	reward_amount: 200
+//	target_count: 100
+//	estimated_duration: 5
+//	targeting:
+//	  locations: [Lagos]
+//	questions:
+//	  - id: q1
+//	    type: single
+//	    title: Do you drive?
+//	    required: true
+//	    options: ["Yes", "No"]
+//	  - id: q2
+//	    type: text
+//	    title: Which car do you drive?
+//	    logic:
+//	      - action: show
+//	        conditions: [{question_id: q1, operator: equals, value: "Yes"}]
+type SurveyDefinition struct {
+	FormatVersion     int                          `json:"format_version" yaml:"format_version"`
+	Title             string                       `json:"title" yaml:"title"`
+	Description       string                       `json:"description" yaml:"description"`
+	Category          string                       `json:"category,omitempty" yaml:"category,omitempty"`
+	RewardAmount      int                          `json:"reward_amount" yaml:"reward_amount"`
+	TargetCount       int                          `json:"target_count" yaml:"target_count"`
+	EstimatedDuration int                          `json:"estimated_duration" yaml:"estimated_duration"` // minutes
+	MinQualityScore   int                          `json:"min_quality_score,omitempty" yaml:"min_quality_score,omitempty"`
+	Targeting         TargetingCriteria            `json:"targeting" yaml:"targeting"`
+	Quotas            []SegmentQuota               `json:"quotas,omitempty" yaml:"quotas,omitempty"`
+	Questions         []QuestionRequest            `json:"questions" yaml:"questions"`
+	Locale            string                       `json:"locale,omitempty" yaml:"locale,omitempty"`
+	Translations      map[string]SurveyTranslation `json:"translations,omitempty" yaml:"translations,omitempty"`
+}
+
+// SurveyRequest converts the definition into the request shape used to create a survey
+func (d *SurveyDefinition) SurveyRequest() SurveyRequest {
+	return SurveyRequest{
+		Title:           d.Title,
+		Description:     d.Description,
+		Category:        d.Category,
+		RewardAmount:    d.RewardAmount,
+		TargetCount:     d.TargetCount,
+		Duration:        d.EstimatedDuration,
+		Questions:       d.Questions,
+		MinQualityScore: d.MinQualityScore,
+		Demographics:    d.Targeting,
+		Quotas:          d.Quotas,
+		Locale:          d.Locale,
+		Translations:    d.Translations,
+	}
+}
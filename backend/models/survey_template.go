@@ -0,0 +1,61 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Survey categories, matching the category enum accepted when creating a survey
+const (
+	SurveyCategoryMarketResearch     = "market_research"
+	SurveyCategoryCustomerExperience = "customer_experience"
+	SurveyCategoryProductFeedback    = "product_feedback"
+	SurveyCategoryAcademicResearch   = "academic_research"
+	SurveyCategoryOther              = "other"
+)
+
+// IsSurveyCategory reports whether category is one of the survey categories
+func IsSurveyCategory(category string) bool {
+	switch category {
+	case SurveyCategoryMarketResearch, SurveyCategoryCustomerExperience, SurveyCategoryProductFeedback,
+		SurveyCategoryAcademicResearch, SurveyCategoryOther:
+		return true
+	}
+	return false
+}
+
+// SurveyTemplate is a reusable survey stored as a survey definition. Curated templates are
+// managed by admins and visible to every creator; private templates belong to the creator who saved them.
+type SurveyTemplate struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	OwnerID       *uuid.UUID      `json:"owner_id" db:"owner_id"` // nil for curated templates
+	Name          string          `json:"name" db:"name"`
+	Description   string          `json:"description" db:"description"`
+	Category      string          `json:"category" db:"category"`
+	Definition    json.RawMessage `json:"definition" db:"definition"` // SurveyDefinition as JSON
+	QuestionCount int             `json:"question_count" db:"question_count"`
+	CreatedBy     *uuid.UUID      `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// IsCurated reports whether the template is part of the admin-managed library
+func (t *SurveyTemplate) IsCurated() bool {
+	return t.OwnerID == nil
+}
+
+// VisibleTo reports whether a user may see and instantiate the template
+func (t *SurveyTemplate) VisibleTo(userID uuid.UUID) bool {
+	return t.IsCurated() || *t.OwnerID == userID
+}
+
+// ParsedDefinition decodes the stored survey definition
+func (t *SurveyTemplate) ParsedDefinition() (*SurveyDefinition, error) {
+	var def SurveyDefinition
+	if err := json.Unmarshal(t.Definition, &def); err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
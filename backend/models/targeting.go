@@ -0,0 +1,86 @@
+package models
+
+// TargetingCriteria restricts which fillers can see and take a survey, stored in surveys.targeting.
+// Each list is matched against the filler's profile; an empty list matches everyone.
+type TargetingCriteria struct {
+	AgeGroups    []string `json:"age_groups,omitempty" yaml:"age_groups,omitempty"`
+	Genders      []string `json:"genders,omitempty" yaml:"genders,omitempty"`
+	Locations    []string `json:"locations,omitempty" yaml:"locations,omitempty"`
+	Education    []string `json:"education,omitempty" yaml:"education,omitempty"`
+	Employment   []string `json:"employment,omitempty" yaml:"employment,omitempty"`
+	IncomeRanges []string `json:"income_ranges,omitempty" yaml:"income_ranges,omitempty"`
+}
+
+// Empty reports whether the criteria target everyone
+func (c TargetingCriteria) Empty() bool {
+	return len(c.AgeGroups) == 0 && len(c.Genders) == 0 && len(c.Locations) == 0 &&
+		len(c.Education) == 0 && len(c.Employment) == 0 && len(c.IncomeRanges) == 0
+}
+
+// Filters counts the demographics the criteria narrow, each billed as a demographic filter
+func (c TargetingCriteria) Filters() int {
+	filters := 0
+	for _, values := range [][]string{c.AgeGroups, c.Genders, c.Locations, c.Education, c.Employment, c.IncomeRanges} {
+		if len(values) > 0 {
+			filters++
+		}
+	}
+	return filters
+}
+
+// Segment fields usable in quotas, each backed by a user_profiles column
+const (
+	SegmentAgeGroup    = "age_group"
+	SegmentGender      = "gender"
+	SegmentLocation    = "location" // matches the profile's state, location or country
+	SegmentEducation   = "education"
+	SegmentEmployment  = "employment"
+	SegmentIncomeRange = "income_range"
+)
+
+// SegmentQuota caps completed responses from one segment, e.g. at most 50 from Lagos.
+// Stored as a list in surveys.quotas; a segment closes once Max responses are counted.
+type SegmentQuota struct {
+	Field string `json:"field" yaml:"field"`
+	Value string `json:"value" yaml:"value"`
+	Max   int    `json:"max" yaml:"max"`
+}
+
+// FillerProfile is the demographic part of user_profiles used for targeting
+type FillerProfile struct {
+	AgeRange    *string `db:"age_range"`
+	Gender      *string `db:"gender"`
+	Country     *string `db:"country"`
+	State       *string `db:"state"`
+	Location    *string `db:"location"`
+	Education   *string `db:"education"`
+	Employment  *string `db:"employment"`
+	IncomeRange *string `db:"income_range"`
+}
+
+// Values returns the profile's values for a segment field; location yields state, location and country
+func (p *FillerProfile) Values(field string) []string {
+	var values []*string
+	switch field {
+	case SegmentAgeGroup:
+		values = []*string{p.AgeRange}
+	case SegmentGender:
+		values = []*string{p.Gender}
+	case SegmentLocation:
+		values = []*string{p.State, p.Location, p.Country}
+	case SegmentEducation:
+		values = []*string{p.Education}
+	case SegmentEmployment:
+		values = []*string{p.Employment}
+	case SegmentIncomeRange:
+		values = []*string{p.IncomeRange}
+	}
+
+	var out []string
+	for _, v := range values {
+		if v != nil && *v != "" {
+			out = append(out, *v)
+		}
+	}
+	return out
+}
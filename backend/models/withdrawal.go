@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Withdrawal states: a requested withdrawal holds its amount in payout clearing while pending and
+// processing, until the transfer completes or fails. An admin approves a pending withdrawal before
+// the payout worker transfers it; a transfer Paystack holds for an OTP awaits finalization.
+const (
+	WithdrawalStatusPending     = "pending"
+	WithdrawalStatusApproved    = "approved"
+	WithdrawalStatusProcessing  = "processing"
+	WithdrawalStatusAwaitingOTP = "awaiting_otp"
+	WithdrawalStatusCompleted   = "completed"
+	WithdrawalStatusFailed      = "failed"
+)
+
+type Withdrawal struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	UserID            uuid.UUID  `json:"user_id" db:"user_id"`
+	Amount            int        `json:"amount" db:"amount"`
+	BankName          string     `json:"bank_name" db:"bank_name"`
+	AccountNumber     string     `json:"account_number" db:"account_number"`
+	AccountName       string     `json:"account_name" db:"account_name"`
+	BankCode          string     `json:"bank_code" db:"bank_code"`
+	PaystackReference *string    `json:"paystack_reference" db:"paystack_reference"` // the transfer code
+	Status            string     `json:"status" db:"status"`
+	Attempts          int        `json:"attempts" db:"attempts"`
+	LastError         *string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt     *time.Time `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	ApprovedBy        *uuid.UUID `json:"approved_by,omitempty" db:"approved_by"`
+	ApprovedAt        *time.Time `json:"approved_at,omitempty" db:"approved_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	ProcessedAt       *time.Time `json:"processed_at" db:"processed_at"`
+}
+
+// TransferReference is the reference the withdrawal's Paystack transfer is initiated with. It is
+// the same on every attempt, so Paystack refuses to pay a withdrawal out twice.
+func (w *Withdrawal) TransferReference() string {
+	return w.ID.String()
+}
+
+// TransferRecipient caches the Paystack recipient created for a filler's bank account
+type TransferRecipient struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	UserID        uuid.UUID `json:"user_id" db:"user_id"`
+	BankCode      string    `json:"bank_code" db:"bank_code"`
+	AccountNumber string    `json:"account_number" db:"account_number"`
+	AccountName   string    `json:"account_name" db:"account_name"`
+	RecipientCode string    `json:"recipient_code" db:"recipient_code"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
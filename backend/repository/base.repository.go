@@ -4,8 +4,18 @@ import (
 	"context"
 	"fmt"
 	"onetimer-backend/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// DBTX is satisfied by both the connection pool and a transaction, so helpers can run in either
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 type BaseRepository struct {
 	db *database.SupabaseDB
 }
@@ -14,7 +24,8 @@ func NewBaseRepository(db *database.SupabaseDB) *BaseRepository {
 	return &BaseRepository{db: db}
 }
 
-func (r *BaseRepository) WithTx(ctx context.Context, fn func(tx interface{}) error) error {
+// WithTx runs fn inside a transaction; every statement in fn must go through tx to be part of it
+func (r *BaseRepository) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
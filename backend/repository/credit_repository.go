@@ -2,11 +2,18 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"onetimer-backend/models"
 
+	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/google/uuid"
 )
 
+var ErrCreditPackageNotFound = errors.New("credit package not found")
+
+const creditPackageColumns = "id, name, description, price, credits, popular, active, sort_order, created_at, updated_at"
+
 type CreditRepository struct {
 	*BaseRepository
 }
@@ -15,18 +22,46 @@ func NewCreditRepository(base *BaseRepository) *CreditRepository {
 	return &CreditRepository{BaseRepository: base}
 }
 
+// GetUserCredits returns the creator's credit balance from the ledger
 func (r *CreditRepository) GetUserCredits(ctx context.Context, userID uuid.UUID) (int, error) {
-	var totalCredits *int
-	err := r.db.QueryRow(ctx, "SELECT COALESCE(SUM(amount), 0) FROM credits WHERE user_id = $1", userID).Scan(&totalCredits)
+	credits, err := accountBalance(ctx, r.db, models.CreatorCreditsAccount(userID))
 	if err != nil {
 		return 0, fmt.Errorf("failed to get user credits: %w", err)
 	}
-	if totalCredits == nil {
-		return 0, nil
+	return credits, nil
+}
+
+// ListPackages returns the credit packages in display order, only those on sale unless all is set
+func (r *CreditRepository) ListPackages(ctx context.Context, all bool) ([]models.CreditPackage, error) {
+	packages := []models.CreditPackage{}
+	err := pgxscan.Select(ctx, r.db, &packages,
+		"SELECT "+creditPackageColumns+" FROM credit_packages WHERE active OR $1 ORDER BY sort_order, price", all)
+	return packages, err
+}
+
+// GetPackage returns a credit package, whether or not it is on sale
+func (r *CreditRepository) GetPackage(ctx context.Context, id string) (*models.CreditPackage, error) {
+	var pkg models.CreditPackage
+	err := pgxscan.Get(ctx, r.db, &pkg, "SELECT "+creditPackageColumns+" FROM credit_packages WHERE id = $1", id)
+	if pgxscan.NotFound(err) {
+		return nil, ErrCreditPackageNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
-	return *totalCredits, nil
+	return &pkg, nil
 }
 
-func (r *CreditRepository) DeductCredits(ctx context.Context, userID uuid.UUID, amount int, description string) error {
-	return r.Exec(ctx, "INSERT INTO credits (user_id, amount, type, description) VALUES ($1, $2, $3, $4)", userID, -amount, "deduction", description)
+// SavePackage creates a credit package or replaces one with the same id. Purchases already
+// initialized keep the price and credits they were initialized with.
+func (r *CreditRepository) SavePackage(ctx context.Context, pkg *models.CreditPackage) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO credit_packages (id, name, description, price, credits, popular, active, sort_order)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, description = EXCLUDED.description,
+			price = EXCLUDED.price, credits = EXCLUDED.credits, popular = EXCLUDED.popular,
+			active = EXCLUDED.active, sort_order = EXCLUDED.sort_order, updated_at = NOW()
+		RETURNING created_at, updated_at`,
+		pkg.ID, pkg.Name, pkg.Description, pkg.Price, pkg.Credits, pkg.Popular, pkg.Active, pkg.SortOrder).
+		Scan(&pkg.CreatedAt, &pkg.UpdatedAt)
 }
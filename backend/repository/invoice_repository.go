@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const invoiceColumns = `id, number, sequence, payment_id, user_id, customer_name, customer_email, customer_address,
+	organization_name, organization_type, seller_name, seller_address, seller_tax_id, description, credits, reference,
+	vat_rate, subtotal, vat, total, paid_at, issued_at, emailed_at`
+
+// InvoiceRepository issues the invoices and receipts for paid credit purchases
+type InvoiceRepository struct {
+	*BaseRepository
+}
+
+func NewInvoiceRepository(base *BaseRepository) *InvoiceRepository {
+	return &InvoiceRepository{BaseRepository: base}
+}
+
+// IssueInvoice locks the credit purchase with paymentID and returns its invoice. When it has none
+// yet, issue is handed the purchase, nil when there is none, and the next number in the sequence,
+// and the invoice it returns recorded.
+func (r *InvoiceRepository) IssueInvoice(ctx context.Context, paymentID uuid.UUID, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error) {
+	return r.issueInvoice(ctx, "id", paymentID, issue)
+}
+
+// IssueInvoiceForReference is IssueInvoice for the purchase with a Paystack reference
+func (r *InvoiceRepository) IssueInvoiceForReference(ctx context.Context, reference string, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error) {
+	return r.issueInvoice(ctx, "paystack_reference", reference, issue)
+}
+
+func (r *InvoiceRepository) issueInvoice(ctx context.Context, where string, arg interface{}, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		// Locking the purchase issues its invoice once however many ask at the same time
+		var p models.InvoicedPurchase
+		var buyer *uuid.UUID
+		var reference *string
+		err := tx.QueryRow(ctx, `
+			SELECT p.id, p.user_id, p.paystack_reference, p.description, p.amount, p.credits, p.status,
+				COALESCE((SELECT j.created_at FROM ledger_journals j WHERE j.reference = 'payment:' || p.paystack_reference || ':purchase'), p.created_at),
+				COALESCE(u.name, ''), COALESCE(u.email, ''), u.location, c.organization_name, c.organization_type
+			FROM payment_transactions p
+			LEFT JOIN users u ON u.id = p.user_id
+			LEFT JOIN creators c ON c.user_id = p.user_id
+			WHERE p.`+where+` = $1 AND p.type = $2 FOR UPDATE OF p`,
+			arg, models.PaymentTypePurchase).Scan(&p.ID, &buyer, &reference, &p.Description, &p.Amount, &p.Credits,
+			&p.Status, &p.PaidAt, &p.CustomerName, &p.CustomerEmail, &p.CustomerAddress, &p.OrganizationName, &p.OrganizationType)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && (buyer == nil || reference == nil)) {
+			invoice, err = issue(nil, 0)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		p.UserID, p.Reference = *buyer, *reference
+
+		var existing models.Invoice
+		err = pgxscan.Get(ctx, tx, &existing, "SELECT "+invoiceColumns+" FROM invoices WHERE payment_id = $1", p.ID)
+		if err == nil {
+			invoice = &existing
+			return nil
+		}
+		if !pgxscan.NotFound(err) {
+			return err
+		}
+
+		// Serializes numbering, so the sequence has no gaps
+		if _, err := tx.Exec(ctx, "LOCK TABLE invoices IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+		var sequence int
+		if err := tx.QueryRow(ctx, "SELECT COALESCE(MAX(sequence), 0) + 1 FROM invoices").Scan(&sequence); err != nil {
+			return err
+		}
+		invoice, err = issue(&p, sequence)
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			INSERT INTO invoices (id, number, sequence, payment_id, user_id, customer_name, customer_email, customer_address,
+				organization_name, organization_type, seller_name, seller_address, seller_tax_id, description, credits, reference,
+				vat_rate, subtotal, vat, total, paid_at, issued_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, NOW())
+			RETURNING issued_at`,
+			invoice.ID, invoice.Number, invoice.Sequence, invoice.PaymentID, invoice.UserID, invoice.CustomerName,
+			invoice.CustomerEmail, invoice.CustomerAddress, invoice.OrganizationName, invoice.OrganizationType,
+			invoice.SellerName, invoice.SellerAddress, invoice.SellerTaxID, invoice.Description, invoice.Credits,
+			invoice.Reference, invoice.VATRate, invoice.Subtotal, invoice.VAT, invoice.Total, invoice.PaidAt).Scan(&invoice.IssuedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// ClaimInvoiceEmail marks an invoice emailed, reporting whether it had not been yet, so it is sent once
+func (r *InvoiceRepository) ClaimInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, "UPDATE invoices SET emailed_at = NOW() WHERE id = $1 AND emailed_at IS NULL", invoiceID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim invoice email: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseInvoiceEmail clears an invoice's emailed mark after sending it failed, so it is sent again
+func (r *InvoiceRepository) ReleaseInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "UPDATE invoices SET emailed_at = NULL WHERE id = $1", invoiceID)
+	return err
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient balance")
+
+// LedgerRepository is the double-entry ledger every balance is read from. Journals are append-only;
+// ledger_balances keeps each account's running balance up to date in the same transaction that
+// posts to it, and ledger_balance_snapshots records every account's closing balance for each day.
+type LedgerRepository struct {
+	*BaseRepository
+}
+
+func NewLedgerRepository(base *BaseRepository) *LedgerRepository {
+	return &LedgerRepository{BaseRepository: base}
+}
+
+// Post records a journal in its own transaction. It reports false when a journal with the same
+// reference was already posted, and fails with ErrInsufficientFunds when the journal would
+// overdraw an account that may not go negative.
+func (r *LedgerRepository) Post(ctx context.Context, journal *models.LedgerJournal) (bool, error) {
+	var posted bool
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		posted, err = postJournal(ctx, tx, journal)
+		return err
+	})
+	return posted, err
+}
+
+// postJournal records a journal and updates the balances of the accounts it touches; the caller's
+// transaction makes the journal atomic with whatever business change it accounts for
+func postJournal(ctx context.Context, db DBTX, journal *models.LedgerJournal) (bool, error) {
+	if err := journal.Validate(); err != nil {
+		return false, err
+	}
+	if journal.ID == uuid.Nil {
+		journal.ID = uuid.New()
+	}
+
+	err := db.QueryRow(ctx,
+		"INSERT INTO ledger_journals (id, kind, reference, description, created_at) VALUES ($1, $2, $3, $4, NOW()) ON CONFLICT (reference) DO NOTHING RETURNING created_at",
+		journal.ID, journal.Kind, journal.Reference, journal.Description).Scan(&journal.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, posting := range journal.SortedPostings() {
+		accountID, err := ledgerAccountID(ctx, db, posting.Account)
+		if err != nil {
+			return false, err
+		}
+
+		// The balance row lock serialises journals touching the same account
+		var balance int
+		if err := db.QueryRow(ctx,
+			"UPDATE ledger_balances SET balance = balance + $1, updated_at = NOW() WHERE account_id = $2 RETURNING balance",
+			posting.Amount, accountID).Scan(&balance); err != nil {
+			return false, err
+		}
+		if balance < 0 && !posting.Account.MayOverdraw() {
+			return false, fmt.Errorf("%w in %s", ErrInsufficientFunds, posting.Account)
+		}
+
+		if _, err := db.Exec(ctx,
+			"INSERT INTO ledger_entries (journal_id, account_id, amount, balance_after, created_at) VALUES ($1, $2, $3, $4, $5)",
+			journal.ID, accountID, posting.Amount, balance, journal.CreatedAt); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// ledgerAccountID returns the account's ID, opening it with a zero balance on first use
+func ledgerAccountID(ctx context.Context, db DBTX, account models.LedgerAccountRef) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRow(ctx, `
+		INSERT INTO ledger_accounts (id, kind, owner_id, created_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (kind, owner_id) DO UPDATE SET kind = EXCLUDED.kind
+		RETURNING id`,
+		uuid.New(), account.Kind, account.OwnerID).Scan(&id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	_, err = db.Exec(ctx,
+		"INSERT INTO ledger_balances (account_id, balance, updated_at) VALUES ($1, 0, NOW()) ON CONFLICT (account_id) DO NOTHING",
+		id)
+	return id, err
+}
+
+// Balance returns an account's current balance; accounts never posted to have a zero balance
+func (r *LedgerRepository) Balance(ctx context.Context, account models.LedgerAccountRef) (int, error) {
+	return accountBalance(ctx, r.db, account)
+}
+
+func accountBalance(ctx context.Context, db DBTX, account models.LedgerAccountRef) (int, error) {
+	var balance int
+	err := db.QueryRow(ctx, `
+		SELECT b.balance FROM ledger_balances b
+		JOIN ledger_accounts a ON a.id = b.account_id
+		WHERE a.kind = $1 AND a.owner_id = $2`,
+		account.Kind, account.OwnerID).Scan(&balance)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return balance, err
+}
+
+// loadJournal returns the journal posted under a reference with its postings, or nil when none was
+func loadJournal(ctx context.Context, db DBTX, reference string) (*models.LedgerJournal, error) {
+	journal := &models.LedgerJournal{Reference: reference}
+	err := db.QueryRow(ctx, "SELECT id, kind, description, created_at FROM ledger_journals WHERE reference = $1", reference).
+		Scan(&journal.ID, &journal.Kind, &journal.Description, &journal.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(ctx, `
+		SELECT a.kind, a.owner_id, e.amount FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		WHERE e.journal_id = $1`,
+		journal.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var posting models.LedgerPosting
+		if err := rows.Scan(&posting.Account.Kind, &posting.Account.OwnerID, &posting.Amount); err != nil {
+			return nil, err
+		}
+		journal.Postings = append(journal.Postings, posting)
+	}
+	return journal, rows.Err()
+}
+
+// Balances returns the balances of every account a user owns
+func (r *LedgerRepository) Balances(ctx context.Context, userID uuid.UUID) (*models.Balances, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT a.kind, b.balance FROM ledger_balances b
+		JOIN ledger_accounts a ON a.id = b.account_id
+		WHERE a.owner_id = $1`,
+		userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances models.Balances
+	for rows.Next() {
+		var kind string
+		var balance int
+		if err := rows.Scan(&kind, &balance); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case models.LedgerAccountCreatorCredits:
+			balances.Credits = balance
+		case models.LedgerAccountFillerPending:
+			balances.PendingEarnings = balance
+		case models.LedgerAccountFillerWallet:
+			balances.AvailableBalance = balance
+		}
+	}
+	return &balances, rows.Err()
+}
+
+// PlatformBalances totals the balances of each account kind across all owners. Every journal
+// balances, so the totals always sum to zero.
+func (r *LedgerRepository) PlatformBalances(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT a.kind, COALESCE(SUM(b.balance), 0) FROM ledger_balances b
+		JOIN ledger_accounts a ON a.id = b.account_id
+		GROUP BY a.kind`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		var kind string
+		var total int
+		if err := rows.Scan(&kind, &total); err != nil {
+			return nil, err
+		}
+		totals[kind] = total
+	}
+	return totals, rows.Err()
+}
+
+// Entries lists the postings to a user's accounts, newest first
+func (r *LedgerRepository) Entries(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.LedgerEntry, int, error) {
+	var total int
+	if err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		WHERE a.owner_id = $1`,
+		userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.LedgerEntry
+	err := pgxscan.Select(ctx, r.db, &entries, `
+		SELECT e.id, e.journal_id, j.kind AS journal_kind, j.reference, COALESCE(j.description, '') AS description,
+			a.kind AS account_kind, e.amount, e.balance_after, e.created_at
+		FROM ledger_entries e
+		JOIN ledger_accounts a ON a.id = e.account_id
+		JOIN ledger_journals j ON j.id = e.journal_id
+		WHERE a.owner_id = $1
+		ORDER BY e.id DESC
+		LIMIT $2 OFFSET $3`,
+		userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// SnapshotBalances records every account's closing balance for a finished day, taken from the
+// balance after the account's last posting that day. Days already snapshotted are left alone:
+// entries are immutable, so a closed day's balances cannot change.
+func (r *LedgerRepository) SnapshotBalances(ctx context.Context, day time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		INSERT INTO ledger_balance_snapshots (account_id, snapshot_date, balance, taken_at)
+		SELECT a.id, $1::date, COALESCE((
+			SELECT e.balance_after FROM ledger_entries e
+			WHERE e.account_id = a.id AND e.created_at < $1::date + 1
+			ORDER BY e.id DESC LIMIT 1
+		), 0), NOW()
+		FROM ledger_accounts a
+		WHERE a.created_at < $1::date + 1
+		ON CONFLICT (account_id, snapshot_date) DO NOTHING`,
+		day.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+	"onetimer-backend/security"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrPaymentMethodNotFound = errors.New("payment method not found")
+
+const paymentMethodColumns = `id, user_id, signature, email, bin, last4, exp_month, exp_year, brand, card_type, bank,
+	is_default, created_at, last_used_at`
+
+const autoTopUpColumns = "user_id, enabled, threshold, amount, last_triggered_at, last_error, updated_at"
+
+// PaymentMethodRepository keeps the cards creators saved and their auto top-up settings. Paystack
+// authorization codes are sealed before they are stored and opened only to charge them.
+type PaymentMethodRepository struct {
+	*BaseRepository
+	sealer *security.Sealer
+}
+
+func NewPaymentMethodRepository(base *BaseRepository, sealer *security.Sealer) *PaymentMethodRepository {
+	return &PaymentMethodRepository{BaseRepository: base, sealer: sealer}
+}
+
+// SavePaymentMethod saves a card, or refreshes it when the creator already saved the same card. A
+// creator's first card becomes their default, as does any saved with IsDefault set.
+func (r *PaymentMethodRepository) SavePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	sealed, err := r.sealer.Seal(method.AuthorizationCode)
+	if err != nil {
+		return err
+	}
+	makeDefault := method.IsDefault
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO payment_methods (id, user_id, authorization_code, signature, email, bin, last4, exp_month, exp_year,
+				brand, card_type, bank, is_default, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+				NOT EXISTS (SELECT 1 FROM payment_methods WHERE user_id = $2 AND is_default), NOW())
+			ON CONFLICT (user_id, signature) DO UPDATE SET authorization_code = EXCLUDED.authorization_code,
+				email = EXCLUDED.email, exp_month = EXCLUDED.exp_month, exp_year = EXCLUDED.exp_year, bank = EXCLUDED.bank
+			RETURNING id, is_default, created_at, last_used_at`,
+			uuid.New(), method.UserID, sealed, method.Signature, method.Email, method.Bin, method.Last4, method.ExpMonth,
+			method.ExpYear, method.Brand, method.CardType, method.Bank).
+			Scan(&method.ID, &method.IsDefault, &method.CreatedAt, &method.LastUsedAt)
+		if err != nil || !makeDefault || method.IsDefault {
+			return err
+		}
+		method.IsDefault = true
+		return setDefaultPaymentMethod(ctx, tx, method.UserID, method.ID)
+	})
+}
+
+// ListPaymentMethods returns a creator's saved cards, the default first
+func (r *PaymentMethodRepository) ListPaymentMethods(ctx context.Context, userID uuid.UUID) ([]models.PaymentMethod, error) {
+	methods := []models.PaymentMethod{}
+	err := pgxscan.Select(ctx, r.db, &methods,
+		"SELECT "+paymentMethodColumns+" FROM payment_methods WHERE user_id = $1 ORDER BY is_default DESC, created_at DESC", userID)
+	return methods, err
+}
+
+// PaymentMethod returns one of a creator's cards with its authorization code opened, their default
+// card when id is nil, or nil when there is no such card
+func (r *PaymentMethodRepository) PaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	var sealed string
+	err := r.db.QueryRow(ctx, `
+		SELECT authorization_code, `+paymentMethodColumns+` FROM payment_methods
+		WHERE user_id = $1 AND (id = $2 OR ($2 = $3 AND is_default))`,
+		userID, id, uuid.Nil).Scan(&sealed, &method.ID, &method.UserID, &method.Signature, &method.Email, &method.Bin,
+		&method.Last4, &method.ExpMonth, &method.ExpYear, &method.Brand, &method.CardType, &method.Bank, &method.IsDefault,
+		&method.CreatedAt, &method.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if method.AuthorizationCode, err = r.sealer.Open(sealed); err != nil {
+		return nil, err
+	}
+	return &method, nil
+}
+
+// MarkPaymentMethodUsed records that a card was just charged
+func (r *PaymentMethodRepository) MarkPaymentMethodUsed(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "UPDATE payment_methods SET last_used_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+// SetDefaultPaymentMethod makes one of a creator's cards their default
+func (r *PaymentMethodRepository) SetDefaultPaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		return setDefaultPaymentMethod(ctx, tx, userID, id)
+	})
+}
+
+func setDefaultPaymentMethod(ctx context.Context, tx pgx.Tx, userID, id uuid.UUID) error {
+	var exists bool
+	if err := tx.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM payment_methods WHERE id = $1 AND user_id = $2)", id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrPaymentMethodNotFound
+	}
+	if _, err := tx.Exec(ctx, "UPDATE payment_methods SET is_default = false WHERE user_id = $1 AND is_default", userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, "UPDATE payment_methods SET is_default = true WHERE id = $1", id)
+	return err
+}
+
+// DeletePaymentMethod forgets one of a creator's cards. When it was their default, the card they
+// saved most recently becomes the default.
+func (r *PaymentMethodRepository) DeletePaymentMethod(ctx context.Context, userID, id uuid.UUID) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		var wasDefault bool
+		err := tx.QueryRow(ctx,
+			"DELETE FROM payment_methods WHERE id = $1 AND user_id = $2 RETURNING is_default", id, userID).Scan(&wasDefault)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPaymentMethodNotFound
+		}
+		if err != nil || !wasDefault {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE payment_methods SET is_default = true
+			WHERE id = (SELECT id FROM payment_methods WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1)`,
+			userID)
+		return err
+	})
+}
+
+// AutoTopUp returns a creator's auto top-up settings, or nil when they have none
+func (r *PaymentMethodRepository) AutoTopUp(ctx context.Context, userID uuid.UUID) (*models.AutoTopUp, error) {
+	var settings models.AutoTopUp
+	err := pgxscan.Get(ctx, r.db, &settings, "SELECT "+autoTopUpColumns+" FROM auto_top_ups WHERE user_id = $1", userID)
+	if pgxscan.NotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// SaveAutoTopUp sets a creator's auto top-up settings, clearing the error that last stopped it
+func (r *PaymentMethodRepository) SaveAutoTopUp(ctx context.Context, settings *models.AutoTopUp) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO auto_top_ups (user_id, enabled, threshold, amount, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET enabled = EXCLUDED.enabled, threshold = EXCLUDED.threshold,
+			amount = EXCLUDED.amount, last_error = NULL, updated_at = NOW()
+		RETURNING last_triggered_at, last_error, updated_at`,
+		settings.UserID, settings.Enabled, settings.Threshold, settings.Amount).
+		Scan(&settings.LastTriggeredAt, &settings.LastError, &settings.UpdatedAt)
+}
+
+// ClaimAutoTopUps marks triggered, and returns, the enabled auto top-ups of creators with a default
+// card whose credits are below their threshold, skipping any triggered within the cooldown
+func (r *PaymentMethodRepository) ClaimAutoTopUps(ctx context.Context, cooldown time.Duration, limit int) ([]models.AutoTopUp, error) {
+	due := []models.AutoTopUp{}
+	err := pgxscan.Select(ctx, r.db, &due, `
+		UPDATE auto_top_ups SET last_triggered_at = NOW()
+		WHERE user_id IN (
+			SELECT t.user_id FROM auto_top_ups t
+			LEFT JOIN ledger_accounts a ON a.kind = $1 AND a.owner_id = t.user_id
+			LEFT JOIN ledger_balances b ON b.account_id = a.id
+			WHERE t.enabled AND COALESCE(b.balance, 0) < t.threshold
+				AND (t.last_triggered_at IS NULL OR t.last_triggered_at < $2)
+				AND EXISTS (SELECT 1 FROM payment_methods m WHERE m.user_id = t.user_id AND m.is_default)
+			ORDER BY t.last_triggered_at NULLS FIRST
+			LIMIT $3
+			FOR UPDATE OF t SKIP LOCKED)
+		RETURNING `+autoTopUpColumns,
+		models.LedgerAccountCreatorCredits, time.Now().Add(-cooldown), limit)
+	return due, err
+}
+
+// FinishAutoTopUp records how a triggered auto top-up went. A failure is kept for the creator to
+// see, and one that would only fail again, such as a declined card, switches auto top-up off.
+func (r *PaymentMethodRepository) FinishAutoTopUp(ctx context.Context, userID uuid.UUID, failure string, disable bool) error {
+	var lastError *string
+	if failure != "" {
+		lastError = &failure
+	}
+	_, err := r.db.Exec(ctx,
+		"UPDATE auto_top_ups SET last_error = $2, enabled = enabled AND NOT $3 WHERE user_id = $1",
+		userID, lastError, disable)
+	return err
+}
@@ -0,0 +1,361 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrRefundNotFound = errors.New("refund not found")
+
+// refundColumns are the columns scanned by scanRefund, in order
+const refundColumns = `id, payment_id, user_id, paystack_reference, paystack_refund_id, amount, reason, status, issued_by,
+	last_error, created_at, updated_at, processed_at`
+
+// PaymentRepository records Paystack payments and the webhook events that report on them
+type PaymentRepository struct {
+	*BaseRepository
+}
+
+func NewPaymentRepository(base *BaseRepository) *PaymentRepository {
+	return &PaymentRepository{BaseRepository: base}
+}
+
+// RecordEvent stores a webhook delivery, or counts another attempt at one already stored. It
+// reports whether the event was already processed, in which case the delivery is a duplicate.
+func (r *PaymentRepository) RecordEvent(ctx context.Context, event *models.PaystackEvent) (bool, error) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO paystack_events (id, event, reference, payload_hash, payload, attempts, received_at)
+		VALUES ($1, $2, $3, $4, $5, 1, NOW())
+		ON CONFLICT (payload_hash) DO UPDATE SET attempts = paystack_events.attempts + 1
+		RETURNING id, attempts, received_at, processed_at`,
+		event.ID, event.Event, event.Reference, event.PayloadHash, event.Payload).Scan(&event.ID, &event.Attempts, &event.ReceivedAt, &event.ProcessedAt)
+	if err != nil {
+		return false, err
+	}
+	return event.ProcessedAt != nil, nil
+}
+
+// FinishEvent marks an event processed, or records why processing failed so a redelivery retries it
+func (r *PaymentRepository) FinishEvent(ctx context.Context, eventID uuid.UUID, processErr error) error {
+	if processErr != nil {
+		_, err := r.db.Exec(ctx, "UPDATE paystack_events SET last_error = $1 WHERE id = $2", processErr.Error(), eventID)
+		return err
+	}
+	_, err := r.db.Exec(ctx, "UPDATE paystack_events SET processed_at = NOW(), last_error = NULL WHERE id = $1", eventID)
+	return err
+}
+
+// CreatePurchase records a credit purchase as pending before its checkout is initialized with
+// Paystack, fixing the amount to be paid and the credits it will grant
+func (r *PaymentRepository) CreatePurchase(ctx context.Context, payment *models.PaymentTransaction) error {
+	if payment.ID == uuid.Nil {
+		payment.ID = uuid.New()
+	}
+	payment.Type = models.PaymentTypePurchase
+	payment.Status = models.PaymentStatusPending
+	return insertPurchase(ctx, r.db, payment)
+}
+
+// ListPayments returns a user's payments, newest first, with the number of any invoice issued for them
+func (r *PaymentRepository) ListPayments(ctx context.Context, userID uuid.UUID, limit int) ([]models.PaymentTransaction, error) {
+	payments := []models.PaymentTransaction{}
+	err := pgxscan.Select(ctx, r.db, &payments, `
+		SELECT p.id, p.user_id, p.type, p.amount, p.credits, p.package_id, p.status, p.paystack_reference, p.description,
+			i.number AS invoice_number, p.created_at
+		FROM payment_transactions p LEFT JOIN invoices i ON i.payment_id = p.id
+		WHERE p.user_id = $1
+		ORDER BY p.created_at DESC
+		LIMIT $2`,
+		userID, limit)
+	return payments, err
+}
+
+// FailPurchase marks a pending credit purchase failed, as when Paystack refused to initialize it
+func (r *PaymentRepository) FailPurchase(ctx context.Context, reference string) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE payment_transactions SET status = $1 WHERE paystack_reference = $2 AND type = $3 AND status = $4",
+		models.PaymentStatusFailed, reference, models.PaymentTypePurchase, models.PaymentStatusPending)
+	return err
+}
+
+// SettleCharge locks the credit purchase recorded under a Paystack reference and hands it to
+// settle, nil when none was recorded. The purchase settle returns is saved, inserted when it is
+// new, and its journal posted in the same transaction; it reports whether the journal was posted
+// now rather than by an earlier settlement.
+func (r *PaymentRepository) SettleCharge(ctx context.Context, reference string, settle func(purchase *models.PaymentTransaction) (*models.PaymentTransaction, *models.LedgerJournal, error)) (bool, error) {
+	var posted bool
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var purchase *models.PaymentTransaction
+		var recorded models.PaymentTransaction
+		err := pgxscan.Get(ctx, tx, &recorded, `
+			SELECT id, user_id, type, amount, credits, package_id, status, paystack_reference, description, created_at
+			FROM payment_transactions WHERE paystack_reference = $1 AND type = $2 FOR UPDATE`,
+			reference, models.PaymentTypePurchase)
+		switch {
+		case err == nil:
+			purchase = &recorded
+		case !pgxscan.NotFound(err):
+			return err
+		}
+
+		settled, journal, err := settle(purchase)
+		if err != nil {
+			return err
+		}
+		if purchase == nil {
+			err = insertPurchase(ctx, tx, settled)
+		} else {
+			_, err = tx.Exec(ctx, "UPDATE payment_transactions SET status = $1 WHERE id = $2", settled.Status, settled.ID)
+		}
+		if err != nil || journal == nil {
+			return err
+		}
+		posted, err = postJournal(ctx, tx, journal)
+		return err
+	})
+	return posted, err
+}
+
+func insertPurchase(ctx context.Context, db DBTX, payment *models.PaymentTransaction) error {
+	return db.QueryRow(ctx, `
+		INSERT INTO payment_transactions (id, user_id, type, amount, credits, package_id, status, paystack_reference, description, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING created_at`,
+		payment.ID, payment.UserID, payment.Type, payment.Amount, payment.Credits, payment.PackageID, payment.Status,
+		payment.PaystackReference, payment.Description).Scan(&payment.CreatedAt)
+}
+
+func scanRefund(row pgx.Row) (*models.Refund, error) {
+	var r models.Refund
+	err := row.Scan(&r.ID, &r.PaymentID, &r.UserID, &r.PaystackReference, &r.PaystackRefundID, &r.Amount, &r.Reason, &r.Status,
+		&r.IssuedBy, &r.LastError, &r.CreatedAt, &r.UpdatedAt, &r.ProcessedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRefundNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// lockPurchase locks a credit purchase to decide a refund against it, returning nil when there is
+// none, with how much of it is refunded and the credits its buyer still holds
+func lockPurchase(ctx context.Context, tx pgx.Tx, where string, arg interface{}) (*models.RefundablePurchase, error) {
+	var p models.RefundablePurchase
+	var buyer *uuid.UUID
+	var reference *string
+	err := tx.QueryRow(ctx, `
+		SELECT id, user_id, amount, credits, status, paystack_reference FROM payment_transactions
+		WHERE `+where+` = $1 AND type = $2 FOR UPDATE`,
+		arg, models.PaymentTypePurchase).Scan(&p.ID, &buyer, &p.Amount, &p.Credits, &p.Status, &reference)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && (buyer == nil || reference == nil)) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.UserID, p.Reference = *buyer, *reference
+	if err := tx.QueryRow(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status <> $2",
+		p.ID, models.RefundStatusFailed).Scan(&p.Refunded); err != nil {
+		return nil, err
+	}
+	p.Balance, err = accountBalance(ctx, tx, models.CreatorCreditsAccount(p.UserID))
+	return &p, err
+}
+
+// IssueRefund locks the credit purchase refund is against and hands it to issue, nil when there is
+// none, then records the refund issue filled in and posts the clawback journal it returned
+func (r *PaymentRepository) IssueRefund(ctx context.Context, refund *models.Refund, issue func(purchase *models.RefundablePurchase) (*models.LedgerJournal, error)) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		purchase, err := lockPurchase(ctx, tx, "id", refund.PaymentID)
+		if err != nil {
+			return err
+		}
+		clawback, err := issue(purchase)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO refunds (id, payment_id, user_id, paystack_reference, amount, reason, status, issued_by, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+			RETURNING created_at, updated_at`,
+			refund.ID, refund.PaymentID, refund.UserID, refund.PaystackReference, refund.Amount, refund.Reason,
+			refund.Status, refund.IssuedBy).Scan(&refund.CreatedAt, &refund.UpdatedAt); err != nil {
+			return err
+		}
+		_, err = postJournal(ctx, tx, clawback)
+		return err
+	})
+}
+
+// MarkRefundSubmitted records the id Paystack gave a pending refund it accepted
+func (r *PaymentRepository) MarkRefundSubmitted(ctx context.Context, refundID uuid.UUID, paystackRefundID string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE refunds SET status = $1, paystack_refund_id = $2, updated_at = NOW()
+		WHERE id = $3 AND status = $4`,
+		models.RefundStatusProcessing, paystackRefundID, refundID, models.RefundStatusPending)
+	return err
+}
+
+// FailRefund locks an issued refund and hands it to fail with the journal that took its credits
+// back, saving the refund when fail changes its status and posting the journal fail returns
+func (r *PaymentRepository) FailRefund(ctx context.Context, refundID uuid.UUID, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		refund, err := scanRefund(tx.QueryRow(ctx, "SELECT "+refundColumns+" FROM refunds WHERE id = $1 FOR UPDATE", refundID))
+		if err != nil {
+			return err
+		}
+		return failRefund(ctx, tx, refund, fail)
+	})
+}
+
+// FailIssuedRefund is FailRefund for the refund a refund.failed event reports on, handing fail a
+// nil refund when none was issued
+func (r *PaymentRepository) FailIssuedRefund(ctx context.Context, transactionReference, refundReference string, amount int, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		purchase, err := lockPurchase(ctx, tx, "paystack_reference", transactionReference)
+		if err != nil {
+			return err
+		}
+		var refund *models.Refund
+		if purchase != nil {
+			refund, err = lockIssuedRefund(ctx, tx, purchase.ID, refundReference, amount)
+			if err != nil && !errors.Is(err, ErrRefundNotFound) {
+				return err
+			}
+		}
+		return failRefund(ctx, tx, refund, fail)
+	})
+}
+
+func failRefund(ctx context.Context, tx pgx.Tx, refund *models.Refund, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	if refund == nil {
+		_, err := fail(nil, nil)
+		return err
+	}
+	clawback, err := loadJournal(ctx, tx, models.RefundClawbackReference(refund.ID))
+	if err != nil {
+		return err
+	}
+	status := refund.Status
+	restore, err := fail(refund, clawback)
+	if err != nil {
+		return err
+	}
+	if refund.Status != status {
+		if _, err := tx.Exec(ctx,
+			"UPDATE refunds SET status = $1, last_error = $2, updated_at = NOW() WHERE id = $3",
+			refund.Status, refund.LastError, refund.ID); err != nil {
+			return err
+		}
+	}
+	if restore == nil {
+		return nil
+	}
+	_, err = postJournal(ctx, tx, restore)
+	return err
+}
+
+// lockIssuedRefund finds the refund an event from Paystack is about among those issued against a
+// purchase: by Paystack's refund id, or else the oldest unsettled refund of the same amount
+func lockIssuedRefund(ctx context.Context, tx pgx.Tx, paymentID uuid.UUID, paystackRefundID string, amount int) (*models.Refund, error) {
+	return scanRefund(tx.QueryRow(ctx, `
+		SELECT `+refundColumns+` FROM refunds
+		WHERE payment_id = $1 AND (paystack_refund_id = $2 OR (status IN ($3, $4) AND amount = $5))
+		ORDER BY COALESCE(paystack_refund_id = $2, false) DESC, created_at
+		LIMIT 1 FOR UPDATE`,
+		paymentID, paystackRefundID, models.RefundStatusPending, models.RefundStatusProcessing, amount))
+}
+
+// RecordRefund records, once per refund reference, a refund Paystack processed against a credit
+// purchase. It hands record the locked purchase, nil when there is none, and the refund issued
+// against it that Paystack reports on, nil when none was, then saves the refund record returns,
+// inserting it when it is new, the purchase's new status and the journal record returned.
+func (r *PaymentRepository) RecordRefund(ctx context.Context, transactionReference, refundReference string, amount int, record func(purchase *models.RefundablePurchase, issued *models.Refund) (*models.Refund, *models.LedgerJournal, error)) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		purchase, err := lockPurchase(ctx, tx, "paystack_reference", transactionReference)
+		if err != nil {
+			return err
+		}
+		if purchase == nil {
+			_, _, err := record(nil, nil)
+			return err
+		}
+
+		var refundID uuid.UUID
+		err = tx.QueryRow(ctx, `
+			INSERT INTO payment_transactions (id, user_id, type, amount, credits, status, paystack_reference, description, created_at)
+			VALUES ($1, $2, $3, $4, 0, $5, $6, $7, NOW())
+			ON CONFLICT (paystack_reference) WHERE paystack_reference IS NOT NULL DO NOTHING
+			RETURNING id`,
+			uuid.New(), purchase.UserID, models.PaymentTypeRefund, amount, models.PaymentStatusSuccess,
+			"refund:"+refundReference, "Refund of "+transactionReference).Scan(&refundID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		issued, err := lockIssuedRefund(ctx, tx, purchase.ID, refundReference, amount)
+		if err != nil && !errors.Is(err, ErrRefundNotFound) {
+			return err
+		}
+		refund, clawback, err := record(purchase, issued)
+		if err != nil {
+			return err
+		}
+
+		if issued != nil && refund.ID == issued.ID {
+			_, err = tx.Exec(ctx, `
+				UPDATE refunds SET status = $1, paystack_refund_id = $2, processed_at = NOW(), updated_at = NOW()
+				WHERE id = $3`,
+				refund.Status, refund.PaystackRefundID, refund.ID)
+		} else {
+			_, err = tx.Exec(ctx, `
+				INSERT INTO refunds (id, payment_id, user_id, paystack_reference, paystack_refund_id, amount, reason, status, created_at, updated_at, processed_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW(), NOW())`,
+				refund.ID, refund.PaymentID, refund.UserID, refund.PaystackReference, refund.PaystackRefundID, refund.Amount,
+				refund.Reason, refund.Status)
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, "UPDATE payment_transactions SET status = $1 WHERE id = $2", purchase.Status, purchase.ID); err != nil {
+			return err
+		}
+		if clawback == nil {
+			return nil
+		}
+		_, err = postJournal(ctx, tx, clawback)
+		return err
+	})
+}
+
+// RefundsForPayment lists the refunds issued against a credit purchase, newest first
+func (r *PaymentRepository) RefundsForPayment(ctx context.Context, paymentID uuid.UUID) ([]models.Refund, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+refundColumns+" FROM refunds WHERE payment_id = $1 ORDER BY created_at DESC", paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	refunds := []models.Refund{}
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, rows.Err()
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrPromoCodeNotFound        = errors.New("promo code not found")
+	ErrPromoCodeExists          = errors.New("promo code already exists")
+	ErrPromoCodeUnavailable     = errors.New("promo code can no longer be redeemed")
+	ErrOrganizationRateNotFound = errors.New("organization rate not found")
+	ErrPricingVersionInPast     = errors.New("pricing versions cannot take effect in the past")
+)
+
+const pricingVersionColumns = "id, version, rules, effective_from, note, created_by, created_at"
+
+// promoCodeColumns counts redemptions alongside each code
+const promoCodeColumns = `p.id, p.code, p.description, p.percent_off, p.amount_off, p.max_redemptions,
+	(SELECT COUNT(*) FROM promo_redemptions pr WHERE pr.promo_code_id = p.id) AS redemptions,
+	p.starts_at, p.expires_at, p.active, p.created_by, p.created_at`
+
+const organizationRateColumns = `r.creator_id, c.organization_name, r.platform_fee, r.fee_discount_percent, r.expires_at, r.note,
+	r.updated_by, r.updated_at`
+
+// PricingRepository keeps the effective-dated pricing versions, promo codes and organization rates
+// surveys are priced on
+type PricingRepository struct {
+	*BaseRepository
+}
+
+func NewPricingRepository(base *BaseRepository) *PricingRepository {
+	return &PricingRepository{BaseRepository: base}
+}
+
+// NormalizePromoCode is how promo codes are stored and looked up, so they match case-insensitively
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ActivePricing returns the latest pricing version to have taken effect by a time, or nil when none has
+func (r *PricingRepository) ActivePricing(ctx context.Context, at time.Time) (*models.PricingVersion, error) {
+	var version models.PricingVersion
+	err := pgxscan.Get(ctx, r.db, &version,
+		"SELECT "+pricingVersionColumns+" FROM pricing_versions WHERE effective_from <= $1 ORDER BY effective_from DESC, version DESC LIMIT 1", at)
+	if pgxscan.NotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// ListPricingVersions returns every pricing version, newest first, including those yet to take effect
+func (r *PricingRepository) ListPricingVersions(ctx context.Context) ([]models.PricingVersion, error) {
+	versions := []models.PricingVersion{}
+	err := pgxscan.Select(ctx, r.db, &versions,
+		"SELECT "+pricingVersionColumns+" FROM pricing_versions ORDER BY version DESC")
+	return versions, err
+}
+
+// CreatePricingVersion stores rules as the next version, taking effect at version.EffectiveFrom.
+// Versions are never edited, and none may take effect in the past, so a survey can always be
+// traced to the rules it was priced on.
+func (r *PricingRepository) CreatePricingVersion(ctx context.Context, version *models.PricingVersion) error {
+	if version.EffectiveFrom.Before(time.Now().Add(-time.Minute)) {
+		return ErrPricingVersionInPast
+	}
+	version.ID = uuid.New()
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		// Serializes version numbering
+		if _, err := tx.Exec(ctx, "LOCK TABLE pricing_versions IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			INSERT INTO pricing_versions (id, version, rules, effective_from, note, created_by)
+			SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5 FROM pricing_versions
+			RETURNING version, created_at`,
+			version.ID, version.Rules, version.EffectiveFrom, version.Note, version.CreatedBy).Scan(&version.Version, &version.CreatedAt)
+	})
+}
+
+// PromoCode returns a promo code with its redemption count, or nil when there is none
+func (r *PricingRepository) PromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	err := pgxscan.Get(ctx, r.db, &promo,
+		"SELECT "+promoCodeColumns+" FROM promo_codes p WHERE p.code = $1", NormalizePromoCode(code))
+	if pgxscan.NotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// ListPromoCodes returns every promo code, newest first
+func (r *PricingRepository) ListPromoCodes(ctx context.Context) ([]models.PromoCode, error) {
+	promos := []models.PromoCode{}
+	err := pgxscan.Select(ctx, r.db, &promos,
+		"SELECT "+promoCodeColumns+" FROM promo_codes p ORDER BY p.created_at DESC")
+	return promos, err
+}
+
+// CreatePromoCode stores a new promo code, failing with ErrPromoCodeExists when its code is taken
+func (r *PricingRepository) CreatePromoCode(ctx context.Context, promo *models.PromoCode) error {
+	promo.ID = uuid.New()
+	promo.Code = NormalizePromoCode(promo.Code)
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO promo_codes (id, code, description, percent_off, amount_off, max_redemptions, starts_at, expires_at, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING created_at`,
+		promo.ID, promo.Code, promo.Description, promo.PercentOff, promo.AmountOff, promo.MaxRedemptions,
+		promo.StartsAt, promo.ExpiresAt, promo.Active, promo.CreatedBy).Scan(&promo.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPromoCodeExists
+	}
+	return err
+}
+
+// SetPromoCodeActive switches a promo code on or off. Surveys already funded with it keep their discount.
+func (r *PricingRepository) SetPromoCodeActive(ctx context.Context, id uuid.UUID, active bool) (*models.PromoCode, error) {
+	tag, err := r.db.Exec(ctx, "UPDATE promo_codes SET active = $1 WHERE id = $2", active, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrPromoCodeNotFound
+	}
+	var promo models.PromoCode
+	err = pgxscan.Get(ctx, r.db, &promo, "SELECT "+promoCodeColumns+" FROM promo_codes p WHERE p.id = $1", id)
+	return &promo, err
+}
+
+// redeemPromoCode records a promo code's redemption on a survey being funded, under a lock on the
+// code so concurrent redemptions cannot exceed its limit. Redeeming on a survey it is already
+// redeemed on does nothing.
+func redeemPromoCode(ctx context.Context, db DBTX, promoID, surveyID, creatorID uuid.UUID, discount int) error {
+	var active bool
+	var startsAt, expiresAt *time.Time
+	var maxRedemptions *int
+	err := db.QueryRow(ctx,
+		"SELECT active, starts_at, expires_at, max_redemptions FROM promo_codes WHERE id = $1 FOR UPDATE",
+		promoID).Scan(&active, &startsAt, &expiresAt, &maxRedemptions)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrPromoCodeUnavailable
+	}
+	if err != nil {
+		return err
+	}
+
+	var redemptions int
+	var redeemed bool
+	if err := db.QueryRow(ctx,
+		"SELECT COUNT(*), COALESCE(BOOL_OR(survey_id = $2), false) FROM promo_redemptions WHERE promo_code_id = $1",
+		promoID, surveyID).Scan(&redemptions, &redeemed); err != nil {
+		return err
+	}
+	if redeemed {
+		return nil
+	}
+	promo := models.PromoCode{Active: active, StartsAt: startsAt, ExpiresAt: expiresAt, MaxRedemptions: maxRedemptions, Redemptions: redemptions}
+	if !promo.Redeemable(time.Now()) {
+		return ErrPromoCodeUnavailable
+	}
+	_, err = db.Exec(ctx,
+		"INSERT INTO promo_redemptions (promo_code_id, survey_id, creator_id, discount) VALUES ($1, $2, $3, $4)",
+		promoID, surveyID, creatorID, discount)
+	return err
+}
+
+// OrganizationRate returns a creator's organization rate if it has not expired by a time, or nil
+func (r *PricingRepository) OrganizationRate(ctx context.Context, creatorID uuid.UUID, at time.Time) (*models.OrganizationRate, error) {
+	var rate models.OrganizationRate
+	err := pgxscan.Get(ctx, r.db, &rate, `
+		SELECT `+organizationRateColumns+`
+		FROM organization_rates r LEFT JOIN creators c ON c.user_id = r.creator_id
+		WHERE r.creator_id = $1 AND (r.expires_at IS NULL OR r.expires_at > $2)`,
+		creatorID, at)
+	if pgxscan.NotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// ListOrganizationRates returns every organization rate, expired ones included
+func (r *PricingRepository) ListOrganizationRates(ctx context.Context) ([]models.OrganizationRate, error) {
+	rates := []models.OrganizationRate{}
+	err := pgxscan.Select(ctx, r.db, &rates, `
+		SELECT `+organizationRateColumns+`
+		FROM organization_rates r LEFT JOIN creators c ON c.user_id = r.creator_id
+		ORDER BY r.updated_at DESC`)
+	return rates, err
+}
+
+// SaveOrganizationRate sets a creator's organization rate, replacing any they had. Surveys already
+// funded keep the rate they were priced on.
+func (r *PricingRepository) SaveOrganizationRate(ctx context.Context, rate *models.OrganizationRate) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO organization_rates (creator_id, platform_fee, fee_discount_percent, expires_at, note, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (creator_id) DO UPDATE SET platform_fee = EXCLUDED.platform_fee,
+			fee_discount_percent = EXCLUDED.fee_discount_percent, expires_at = EXCLUDED.expires_at,
+			note = EXCLUDED.note, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING updated_at, (SELECT organization_name FROM creators WHERE user_id = $1)`,
+		rate.CreatorID, rate.PlatformFee, rate.FeeDiscountPercent, rate.ExpiresAt, rate.Note, rate.UpdatedBy).
+		Scan(&rate.UpdatedAt, &rate.OrganizationName)
+}
+
+// DeleteOrganizationRate returns a creator to the standard pricing
+func (r *PricingRepository) DeleteOrganizationRate(ctx context.Context, creatorID uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM organization_rates WHERE creator_id = $1", creatorID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrOrganizationRateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrReconciliationNotFound     = errors.New("reconciliation report not found")
+	ErrReconciliationItemNotFound = errors.New("reconciliation item not found")
+)
+
+const reconciliationReportColumns = `id, period_start, period_end, source, status, matched, issues, internal_total, paystack_total,
+	created_by, created_at`
+
+const reconciliationItemColumns = `id, report_id, kind, reference, issue, internal_amount, paystack_amount, detail, status,
+	resolved_by, resolution_note, resolved_at`
+
+// ReconciliationRepository provides our payments and withdrawals for reconciling against Paystack
+// and keeps the resulting reports
+type ReconciliationRepository struct {
+	*BaseRepository
+}
+
+func NewReconciliationRepository(base *BaseRepository) *ReconciliationRepository {
+	return &ReconciliationRepository{BaseRepository: base}
+}
+
+func scanReconciliationReport(row pgx.Row) (*models.ReconciliationReport, error) {
+	var r models.ReconciliationReport
+	err := row.Scan(&r.ID, &r.PeriodStart, &r.PeriodEnd, &r.Source, &r.Status, &r.Matched, &r.Issues,
+		&r.InternalTotal, &r.PaystackTotal, &r.CreatedBy, &r.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReconciliationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func scanReconciliationItem(row pgx.Row) (*models.ReconciliationItem, error) {
+	var it models.ReconciliationItem
+	err := row.Scan(&it.ID, &it.ReportID, &it.Kind, &it.Reference, &it.Issue, &it.InternalAmount, &it.PaystackAmount,
+		&it.Detail, &it.Status, &it.ResolvedBy, &it.ResolutionNote, &it.ResolvedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrReconciliationItemNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+// InternalRecords returns the credit purchases and withdrawal transfers recorded between two times,
+// and any carrying one of references whatever their time, so a record on either side of a period's
+// boundary still finds its match. Withdrawals are transferred under their ID.
+func (r *ReconciliationRepository) InternalRecords(ctx context.Context, from, to time.Time, references []string) ([]models.SettlementRecord, error) {
+	if references == nil {
+		references = []string{}
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT $4::text, paystack_reference, amount, status, status IN ($5, $6, $7)
+		FROM payment_transactions
+		WHERE type = $3 AND paystack_reference IS NOT NULL
+			AND ((created_at >= $1 AND created_at < $2) OR paystack_reference = ANY($10))
+		UNION ALL
+		SELECT $8::text, id::text, amount, status, status = $9
+		FROM withdrawals
+		WHERE status NOT IN ('pending', 'approved')
+			AND ((COALESCE(processed_at, updated_at, created_at) >= $1 AND COALESCE(processed_at, updated_at, created_at) < $2)
+				OR id::text = ANY($10))`,
+		from, to, models.PaymentTypePurchase, models.SettlementKindCharge,
+		models.PaymentStatusSuccess, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded,
+		models.SettlementKindTransfer, models.WithdrawalStatusCompleted, references)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []models.SettlementRecord{}
+	for rows.Next() {
+		var rec models.SettlementRecord
+		if err := rows.Scan(&rec.Kind, &rec.Reference, &rec.Amount, &rec.Status, &rec.Settled); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// HasReconciliation reports whether a period has already been reconciled from a source
+func (r *ReconciliationRepository) HasReconciliation(ctx context.Context, from, to time.Time, source string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM reconciliation_reports WHERE period_start = $1 AND period_end = $2 AND source = $3)",
+		from, to, source).Scan(&exists)
+	return exists, err
+}
+
+// SaveReconciliation stores a report and its items
+func (r *ReconciliationRepository) SaveReconciliation(ctx context.Context, report *models.ReconciliationReport) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO reconciliation_reports (id, period_start, period_end, source, status, matched, issues, internal_total, paystack_total, created_by, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+			RETURNING created_at`,
+			report.ID, report.PeriodStart, report.PeriodEnd, report.Source, report.Status, report.Matched, report.Issues,
+			report.InternalTotal, report.PaystackTotal, report.CreatedBy).Scan(&report.CreatedAt); err != nil {
+			return err
+		}
+		for _, it := range report.Items {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO reconciliation_items (id, report_id, kind, reference, issue, internal_amount, paystack_amount, detail, status)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+				it.ID, report.ID, it.Kind, it.Reference, it.Issue, it.InternalAmount, it.PaystackAmount, it.Detail, it.Status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListReconciliations returns the latest reports, newest period first, without their items
+func (r *ReconciliationRepository) ListReconciliations(ctx context.Context, limit int) ([]models.ReconciliationReport, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT "+reconciliationReportColumns+" FROM reconciliation_reports ORDER BY period_start DESC, created_at DESC LIMIT $1", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []models.ReconciliationReport{}
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, rows.Err()
+}
+
+// GetReconciliation returns a report with its items, open ones first
+func (r *ReconciliationRepository) GetReconciliation(ctx context.Context, id uuid.UUID) (*models.ReconciliationReport, error) {
+	report, err := scanReconciliationReport(r.db.QueryRow(ctx,
+		"SELECT "+reconciliationReportColumns+" FROM reconciliation_reports WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+reconciliationItemColumns+" FROM reconciliation_items WHERE report_id = $1 ORDER BY status = $2 DESC, issue, reference",
+		id, models.ReconciliationItemOpen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report.Items = []models.ReconciliationItem{}
+	for rows.Next() {
+		it, err := scanReconciliationItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		report.Items = append(report.Items, *it)
+	}
+	return report, rows.Err()
+}
+
+// ResolveItem records how finance resolved a discrepancy. Resolving a report's last open item
+// resolves the report.
+func (r *ReconciliationRepository) ResolveItem(ctx context.Context, itemID, resolvedBy uuid.UUID, note string) (*models.ReconciliationItem, error) {
+	var item *models.ReconciliationItem
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		item, err = scanReconciliationItem(tx.QueryRow(ctx, `
+			UPDATE reconciliation_items SET status = $1, resolved_by = $2, resolution_note = $3, resolved_at = NOW()
+			WHERE id = $4
+			RETURNING `+reconciliationItemColumns,
+			models.ReconciliationItemResolved, resolvedBy, note, itemID))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE reconciliation_reports SET status = $1
+			WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM reconciliation_items WHERE report_id = $2 AND status = $3)`,
+			models.ReconciliationReportResolved, item.ReportID, models.ReconciliationItemOpen)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
@@ -2,14 +2,49 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"onetimer-backend/models"
+	"strings"
 	"time"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
+var (
+	ErrAlreadyResponded  = errors.New("filler has already responded to this survey")
+	ErrSessionNotFound   = errors.New("no open survey session")
+	ErrResponseNotFound  = errors.New("response not found")
+	ErrSurveyNotFound    = errors.New("survey not found")
+	ErrInvalidTransition = errors.New("survey cannot move to that status from its current one")
+	ErrVersionNotFound   = errors.New("survey version not found")
+	ErrSurveyNotEditable = errors.New("survey can no longer be edited in its current status")
+)
+
+// statusTimestamps maps lifecycle states to the survey column stamped when the state is entered
+var statusTimestamps = map[string]string{
+	models.SurveyStatusPendingReview: "submitted_at",
+	models.SurveyStatusActive:        "published_at",
+	models.SurveyStatusPaused:        "paused_at",
+	models.SurveyStatusCompleted:     "completed_at",
+	models.SurveyStatusExpired:       "expired_at",
+	models.SurveyStatusArchived:      "archived_at",
+	models.SurveyStatusRejected:      "rejected_at",
+}
+
+// segmentConditions maps quota fields to the user_profiles comparison ($2 is the lowercased value)
+var segmentConditions = map[string]string{
+	models.SegmentAgeGroup:    "LOWER(up.age_range) = $2",
+	models.SegmentGender:      "LOWER(up.gender) = $2",
+	models.SegmentLocation:    "$2 IN (LOWER(up.state), LOWER(up.location), LOWER(up.country))",
+	models.SegmentEducation:   "LOWER(up.education) = $2",
+	models.SegmentEmployment:  "LOWER(up.employment) = $2",
+	models.SegmentIncomeRange: "LOWER(up.income_range) = $2",
+}
+
 type SurveyRepository struct {
 	*BaseRepository
 }
@@ -18,35 +53,217 @@ func NewSurveyRepository(base *BaseRepository) *SurveyRepository {
 	return &SurveyRepository{BaseRepository: base}
 }
 
-func (r *SurveyRepository) CreateSurvey(ctx context.Context, survey *models.Survey, questions []models.Question, totalCost int) error {
-	return r.WithTx(ctx, func(tx interface{}) error {
-		// Deduct credits
-		_, err := r.db.Exec(ctx, "INSERT INTO credits (user_id, amount, type, description) VALUES ($1, $2, $3, $4)", survey.CreatorID, -totalCost, "deduction", fmt.Sprintf("Survey creation: %s", survey.Title))
-		if err != nil {
-			return err
+// CreateSurvey saves a new survey as its first version. A quote locks the survey's cost in escrow
+// in the same transaction, failing with ErrInsufficientFunds when the creator's credits cannot cover it.
+func (r *SurveyRepository) CreateSurvey(ctx context.Context, survey *models.Survey, questions []models.Question, quote *models.SurveyQuote) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		if quote != nil {
+			survey.Budget, survey.PlatformFee = quote.Budget, quote.PlatformFee
 		}
 
 		// Save survey
-		err = r.db.QueryRow(ctx,
-			"INSERT INTO surveys (id, creator_id, title, description, category, reward_amount, estimated_duration, target_responses, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
-			survey.ID, survey.CreatorID, survey.Title, survey.Description, survey.Category, survey.RewardAmount, survey.EstimatedDuration, survey.TargetResponses, survey.Status).Scan(&survey.ID)
+		err := tx.QueryRow(ctx,
+			"INSERT INTO surveys (id, creator_id, title, description, category, reward_amount, estimated_duration, target_responses, status, min_quality_score, targeting, quotas, starts_at, run_days, budget, platform_fee, priority_placement, data_export, locale, translations) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20) RETURNING id",
+			survey.ID, survey.CreatorID, survey.Title, survey.Description, survey.Category, survey.RewardAmount, survey.EstimatedDuration, survey.TargetResponses, survey.Status, survey.MinQualityScore, survey.Targeting, survey.Quotas, survey.StartsAt, survey.RunDays, survey.Budget, survey.PlatformFee, survey.PriorityPlacement, survey.DataExport, survey.Locale, survey.Translations).Scan(&survey.ID)
 		if err != nil {
 			return err
 		}
 
-		// Save questions
-		for _, q := range questions {
-			q.SurveyID = survey.ID
-			_, err := r.db.Exec(ctx,
-				"INSERT INTO questions (id, survey_id, type, title, description, required, options, order_index) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
-				q.ID, q.SurveyID, q.Type, q.Title, q.Description, q.Required, q.Options, q.OrderIndex)
-			if err != nil {
+		if quote != nil {
+			if err := fundSurvey(ctx, tx, survey.ID, quote); err != nil {
+				return err
+			}
+		}
+
+		if err := stampStatus(ctx, tx, survey.ID, survey.Status); err != nil {
+			return err
+		}
+		if err := recordStatusChange(ctx, tx, survey.ID, nil, survey.Status, &survey.CreatorID, nil); err != nil {
+			return err
+		}
+
+		// Save questions as the first version
+		survey.Version = 1
+		if err := insertQuestions(ctx, tx, survey.ID, survey.Version, questions); err != nil {
+			return err
+		}
+		return saveVersion(ctx, tx, survey, &survey.CreatorID)
+	})
+}
+
+// fundSurvey brings a survey's escrow to what the survey still owes: the rewards for the responses
+// it has yet to collect, plus the platform fee until the survey is first published and the fee is
+// taken. A shortfall is charged to the creator's credits, failing with ErrInsufficientFunds when
+// they cannot cover it, and an excess after a cheaper edit goes back to them. The caller holds
+// the survey row lock, which is what keeps funding journals from racing each other.
+// The first funding locks the quote's pricing terms on the survey and redeems its promo code,
+// failing with ErrPromoCodeUnavailable when the code has since run out.
+func fundSurvey(ctx context.Context, db DBTX, surveyID uuid.UUID, quote *models.SurveyQuote) error {
+	var creatorID uuid.UUID
+	var title string
+	var reward, target, current int
+	var publishedAt *time.Time
+	var locked bool
+	err := db.QueryRow(ctx,
+		"SELECT creator_id, title, reward_amount, target_responses, current_responses, published_at, pricing_terms IS NOT NULL FROM surveys WHERE id = $1",
+		surveyID).Scan(&creatorID, &title, &reward, &target, &current, &publishedAt, &locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrSurveyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !locked && quote.Terms != nil {
+		if promo := quote.Terms.PromoCode; promo != nil {
+			if err := redeemPromoCode(ctx, db, promo.ID, surveyID, creatorID, quote.Discount); err != nil {
 				return err
 			}
 		}
+		terms, err := json.Marshal(quote.Terms)
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, "UPDATE surveys SET pricing_terms = $1 WHERE id = $2", terms, surveyID); err != nil {
+			return err
+		}
+	}
+
+	owed := reward * max(target-current, 0)
+	if publishedAt == nil {
+		owed += quote.PlatformFee
+	}
+	held, err := accountBalance(ctx, db, models.EscrowAccount(surveyID))
+	if err != nil {
+		return err
+	}
 
+	// The fee is fixed once it has been taken
+	if _, err := db.Exec(ctx, `
+		UPDATE surveys SET budget = $1, platform_fee = CASE WHEN published_at IS NULL THEN $2 ELSE platform_fee END,
+			funded_at = COALESCE(funded_at, NOW()), updated_at = NOW()
+		WHERE id = $3`,
+		quote.Budget, quote.PlatformFee, surveyID); err != nil {
+		return err
+	}
+
+	reference := fmt.Sprintf("survey:%s:funding:%s", surveyID, uuid.New())
+	switch {
+	case owed > held:
+		_, err = postJournal(ctx, db, models.Transfer(models.JournalSurveyFunding, reference,
+			fmt.Sprintf("Survey funding: %s", title),
+			models.CreatorCreditsAccount(creatorID), models.EscrowAccount(surveyID), owed-held))
+	case owed < held:
+		_, err = postJournal(ctx, db, models.Transfer(models.JournalBudgetRefund, reference,
+			fmt.Sprintf("Survey repriced: %s", title),
+			models.EscrowAccount(surveyID), models.CreatorCreditsAccount(creatorID), held-owed))
+	}
+	return err
+}
+
+// takePlatformFee moves a funded survey's fee from its escrow to the platform, once, when the
+// survey is first published
+func takePlatformFee(ctx context.Context, db DBTX, surveyID uuid.UUID) error {
+	var title string
+	var fee int
+	var fundedAt *time.Time
+	if err := db.QueryRow(ctx,
+		"SELECT title, platform_fee, funded_at FROM surveys WHERE id = $1",
+		surveyID).Scan(&title, &fee, &fundedAt); err != nil {
+		return err
+	}
+	if fundedAt == nil || fee <= 0 {
 		return nil
+	}
+	_, err := postJournal(ctx, db, models.Transfer(models.JournalPlatformFee,
+		fmt.Sprintf("survey:%s:fee", surveyID), fmt.Sprintf("Platform fee: %s", title),
+		models.EscrowAccount(surveyID), models.PlatformAccount(models.LedgerAccountPlatformFees), fee))
+	return err
+}
+
+// UpdateSurvey saves an edited survey. Until the survey is first published its current version is
+// rewritten in place; after that the version respondents answered is left untouched and the edit
+// becomes a new version. questions replaces the question set, or keeps it when nil. A funded
+// survey is repriced to quote, topping up or returning the difference in its escrow. Surveys
+// that are no longer editable fail with ErrSurveyNotEditable. It reports the version the survey
+// is now on.
+func (r *SurveyRepository) UpdateSurvey(ctx context.Context, survey *models.Survey, questions []models.Question, actorID *uuid.UUID, quote *models.SurveyQuote) (int, error) {
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var version int
+		var status string
+		var publishedAt, fundedAt *time.Time
+		err := tx.QueryRow(ctx, "SELECT version, status, published_at, funded_at FROM surveys WHERE id = $1 FOR UPDATE", survey.ID).Scan(&version, &status, &publishedAt, &fundedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSurveyNotFound
+		}
+		if err != nil {
+			return err
+		}
+		// A closed survey's escrow has already been refunded, so topping it up again would strand credits
+		if !models.IsEditableSurveyStatus(status) {
+			return ErrSurveyNotEditable
+		}
+
+		survey.Version = models.EditedVersion(version, publishedAt)
+		if survey.Version == version {
+			if questions != nil {
+				if _, err := tx.Exec(ctx, "DELETE FROM questions WHERE survey_id = $1 AND survey_version = $2", survey.ID, version); err != nil {
+					return err
+				}
+			}
+		} else if questions == nil {
+			// Question rows belong to one version; callers copy an unchanged set under fresh ids
+			return errors.New("a new survey version needs its question set")
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE surveys SET title = $1, description = $2, category = $3, reward_amount = $4, target_responses = $5, estimated_duration = $6, min_quality_score = $7, targeting = $8, quotas = $9, starts_at = $10, run_days = $11, version = $12, locale = $13, translations = $14, priority_placement = $15, data_export = $16, updated_at = NOW() WHERE id = $17",
+			survey.Title, survey.Description, survey.Category, survey.RewardAmount, survey.TargetResponses, survey.EstimatedDuration, survey.MinQualityScore, survey.Targeting, survey.Quotas, survey.StartsAt, survey.RunDays, survey.Version, survey.Locale, survey.Translations, survey.PriorityPlacement, survey.DataExport, survey.ID)
+		if err != nil {
+			return err
+		}
+		if fundedAt != nil && quote != nil {
+			if err := fundSurvey(ctx, tx, survey.ID, quote); err != nil {
+				return err
+			}
+		}
+		if questions != nil {
+			if err := insertQuestions(ctx, tx, survey.ID, survey.Version, questions); err != nil {
+				return err
+			}
+		}
+		return saveVersion(ctx, tx, survey, actorID)
 	})
+	return survey.Version, err
+}
+
+func insertQuestions(ctx context.Context, db DBTX, surveyID uuid.UUID, version int, questions []models.Question) error {
+	for _, q := range questions {
+		q.SurveyID = surveyID
+		_, err := db.Exec(ctx,
+			"INSERT INTO questions (id, survey_id, type, title, description, required, options, order_index, logic, settings, survey_version, translations) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+			q.ID, q.SurveyID, q.Type, q.Title, q.Description, q.Required, q.Options, q.OrderIndex, q.Logic, q.Settings, version, q.Translations)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveVersion records the survey's content as its current version, overwriting the snapshot
+// while the version is still unpublished
+func saveVersion(ctx context.Context, db DBTX, survey *models.Survey, actorID *uuid.UUID) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO survey_versions (survey_id, version, title, description, reward_amount, target_responses, estimated_duration, min_quality_score, targeting, quotas, locale, translations, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
+		ON CONFLICT (survey_id, version) DO UPDATE SET
+			title = EXCLUDED.title, description = EXCLUDED.description, reward_amount = EXCLUDED.reward_amount,
+			target_responses = EXCLUDED.target_responses, estimated_duration = EXCLUDED.estimated_duration,
+			min_quality_score = EXCLUDED.min_quality_score, targeting = EXCLUDED.targeting, quotas = EXCLUDED.quotas,
+			locale = EXCLUDED.locale, translations = EXCLUDED.translations`,
+		survey.ID, survey.Version, survey.Title, survey.Description, survey.RewardAmount, survey.TargetResponses,
+		survey.EstimatedDuration, survey.MinQualityScore, survey.Targeting, survey.Quotas, survey.Locale, survey.Translations, actorID)
+	return err
 }
 
 func (r *SurveyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Survey, error) {
@@ -86,20 +303,60 @@ func (r *SurveyRepository) GetAll(ctx context.Context, limit, offset int, status
 	return surveys, nil
 }
 
+// GetQuestions returns the questions of the survey's current version
 func (r *SurveyRepository) GetQuestions(ctx context.Context, surveyID uuid.UUID) ([]models.Question, error) {
 	var questions []models.Question
-	err := pgxscan.Select(ctx, r.db, &questions, "SELECT * FROM questions WHERE survey_id = $1 ORDER BY order_index", surveyID)
+	err := pgxscan.Select(ctx, r.db, &questions,
+		"SELECT q.* FROM questions q JOIN surveys s ON s.id = q.survey_id AND s.version = q.survey_version WHERE q.survey_id = $1 ORDER BY q.order_index",
+		surveyID)
 	if err != nil {
 		return nil, err
 	}
 	return questions, nil
 }
 
-func (r *SurveyRepository) Update(ctx context.Context, survey *models.Survey) error {
-	_, err := r.db.Exec(ctx,
-		"UPDATE surveys SET title = $1, description = $2, category = $3, reward_amount = $4, target_responses = $5, estimated_duration = $6, status = $7, updated_at = NOW() WHERE id = $8",
-		survey.Title, survey.Description, survey.Category, survey.RewardAmount, survey.TargetResponses, survey.EstimatedDuration, survey.Status, survey.ID)
-	return err
+// GetVersionQuestions returns the questions of one version of a survey
+func (r *SurveyRepository) GetVersionQuestions(ctx context.Context, surveyID uuid.UUID, version int) ([]models.Question, error) {
+	var questions []models.Question
+	err := pgxscan.Select(ctx, r.db, &questions,
+		"SELECT * FROM questions WHERE survey_id = $1 AND survey_version = $2 ORDER BY order_index",
+		surveyID, version)
+	if err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+const surveyVersionColumns = `
+	v.survey_id, v.version, v.title, v.description, v.reward_amount, v.target_responses, v.estimated_duration,
+	v.min_quality_score, v.targeting, v.quotas, v.locale, v.translations, v.created_by, v.created_at,
+	(SELECT COUNT(*) FROM questions q WHERE q.survey_id = v.survey_id AND q.survey_version = v.version) AS question_count,
+	(SELECT COUNT(*) FROM responses r WHERE r.survey_id = v.survey_id AND r.survey_version = v.version AND r.status = 'completed') AS response_count`
+
+// GetVersions lists every version of a survey, oldest first
+func (r *SurveyRepository) GetVersions(ctx context.Context, surveyID uuid.UUID) ([]models.SurveyVersion, error) {
+	versions := []models.SurveyVersion{}
+	err := pgxscan.Select(ctx, r.db, &versions,
+		"SELECT "+surveyVersionColumns+" FROM survey_versions v WHERE v.survey_id = $1 ORDER BY v.version",
+		surveyID)
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *SurveyRepository) GetVersion(ctx context.Context, surveyID uuid.UUID, version int) (*models.SurveyVersion, error) {
+	var v models.SurveyVersion
+	err := pgxscan.Get(ctx, r.db, &v,
+		"SELECT "+surveyVersionColumns+" FROM survey_versions v WHERE v.survey_id = $1 AND v.version = $2",
+		surveyID, version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
 }
 
 func (r *SurveyRepository) Delete(ctx context.Context, id uuid.UUID) error {
@@ -107,43 +364,647 @@ func (r *SurveyRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-func (r *SurveyRepository) CreateResponse(ctx context.Context, response *models.Response) error {
+// RecordSubmission completes the filler's session (or records a new response if none was started)
+// and creates its earning in one transaction, once admit accepts the survey's state. The survey row
+// is locked so the admission checks and counter bump are atomic; the survey moves to completed when
+// the response fills its target. A response with status rejected is stored for the record but
+// neither counted nor paid.
+func (r *SurveyRepository) RecordSubmission(ctx context.Context, response *models.Response, earning *models.Earning, answered []uuid.UUID, quotas []models.SegmentQuota, admit func(*models.SubmissionState) error) error {
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		var state models.SubmissionState
+		err := tx.QueryRow(ctx,
+			"SELECT status, current_responses, target_responses, expires_at, version FROM surveys WHERE id = $1 FOR UPDATE",
+			response.SurveyID).Scan(&state.SurveyStatus, &state.CurrentResponses, &state.TargetResponses, &state.ExpiresAt, &state.Version)
+		if err != nil {
+			return err
+		}
+
+		var sessionID uuid.UUID
+		var sessionStatus string
+		var startedAt time.Time
+		err = tx.QueryRow(ctx,
+			"SELECT id, status, started_at FROM responses WHERE survey_id = $1 AND filler_id = $2 FOR UPDATE",
+			response.SurveyID, response.FillerID).Scan(&sessionID, &sessionStatus, &startedAt)
+		hasSession := err == nil
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if hasSession && models.IsFinishedResponseStatus(sessionStatus) {
+			return ErrAlreadyResponded
+		}
+
+		// The survey row lock above serialises submissions, so these counts cannot race
+		state.FullSegments, err = fullSegments(ctx, tx, response.SurveyID, quotas)
+		if err != nil {
+			return err
+		}
+		if err := admit(&state); err != nil {
+			return err
+		}
+
+		if hasSession {
+			response.ID = sessionID
+			response.StartedAt = startedAt
+			_, err = tx.Exec(ctx,
+				"UPDATE responses SET answers = $1, status = $2, completed_at = $3, idempotency_key = $4, quality_score = $5, quality_flags = $6, review_status = $7, review_note = $8, survey_version = $9, served_order = $10, locale = $11, progress = 100, last_activity_at = NOW() WHERE id = $12",
+				response.Answers, response.Status, response.CompletedAt, response.IdempotencyKey, response.QualityScore, response.QualityFlags, response.ReviewStatus, response.ReviewNote, response.SurveyVersion, response.ServedOrder, response.Locale, response.ID)
+			if err != nil {
+				return err
+			}
+		} else {
+			tag, err := tx.Exec(ctx,
+				"INSERT INTO responses (id, survey_id, filler_id, answers, status, started_at, completed_at, idempotency_key, quality_score, quality_flags, review_status, review_note, survey_version, served_order, locale, progress, last_activity_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, 100, NOW()) ON CONFLICT (survey_id, filler_id) DO NOTHING",
+				response.ID, response.SurveyID, response.FillerID, response.Answers, response.Status, response.StartedAt, response.CompletedAt, response.IdempotencyKey, response.QualityScore, response.QualityFlags, response.ReviewStatus, response.ReviewNote, response.SurveyVersion, response.ServedOrder, response.Locale)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return ErrAlreadyResponded
+			}
+		}
+
+		if err := recordQuestionTimings(ctx, tx, response.ID, nil, answered); err != nil {
+			return err
+		}
+
+		if response.Status == models.ResponseStatusRejected {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx,
+			"UPDATE surveys SET current_responses = current_responses + 1, updated_at = NOW() WHERE id = $1",
+			response.SurveyID); err != nil {
+			return err
+		}
+		if state.TargetResponses > 0 && state.CurrentResponses+1 >= state.TargetResponses {
+			reason := "target responses reached"
+			if err := transitionStatus(ctx, tx, response.SurveyID, state.SurveyStatus, models.SurveyStatusCompleted, nil, &reason); err != nil {
+				return err
+			}
+		}
+
+		earning.ResponseID = &response.ID
+		return createEarning(ctx, tx, earning)
+	})
+}
+
+// StartSession opens an in_progress response for the filler, or resumes their existing
+// in_progress/abandoned one. It reports whether an existing session was resumed.
+func (r *SurveyRepository) StartSession(ctx context.Context, surveyID, fillerID uuid.UUID) (*models.Response, bool, error) {
+	var session models.Response
+	newID := uuid.New()
+	err := pgxscan.Get(ctx, r.db, &session, `
+		INSERT INTO responses (id, survey_id, filler_id, answers, status, started_at, progress, last_activity_at, survey_version)
+		VALUES ($1, $2, $3, '{}', $4, NOW(), 0, NOW(), (SELECT version FROM surveys WHERE id = $2))
+		ON CONFLICT (survey_id, filler_id) DO UPDATE SET
+			status = CASE WHEN responses.status IN ($5, $6) THEN responses.status ELSE $4 END,
+			last_activity_at = CASE WHEN responses.status IN ($5, $6) THEN responses.last_activity_at ELSE NOW() END
+		RETURNING *`,
+		newID, surveyID, fillerID, models.ResponseStatusInProgress, models.ResponseStatusCompleted, models.ResponseStatusRejected)
+	if err != nil {
+		return nil, false, err
+	}
+	if models.IsFinishedResponseStatus(session.Status) {
+		return &session, false, ErrAlreadyResponded
+	}
+	return &session, session.ID != newID, nil
+}
+
+// SetServedOrder records the question and option order drawn for a filler's session, along with the
+// survey version it was drawn for
+func (r *SurveyRepository) SetServedOrder(ctx context.Context, sessionID uuid.UUID, version int, order []byte) error {
 	_, err := r.db.Exec(ctx,
-		"INSERT INTO responses (id, survey_id, filler_id, answers, status, started_at, completed_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-		response.ID, response.SurveyID, response.FillerID, response.Answers, response.Status, response.StartedAt, response.CompletedAt)
+		"UPDATE responses SET served_order = $1, survey_version = $2 WHERE id = $3",
+		order, version, sessionID)
 	return err
 }
 
-func (r *SurveyRepository) IncrementResponseCount(ctx context.Context, surveyID uuid.UUID) error {
-	_, err := r.db.Exec(ctx, "UPDATE surveys SET current_responses = current_responses + 1 WHERE id = $1", surveyID)
+// SaveSessionProgress stores partial answers on the filler's open session and records per-question
+// timestamps: shown questions get first_shown_at, answered questions get answered_at
+func (r *SurveyRepository) SaveSessionProgress(ctx context.Context, surveyID, fillerID uuid.UUID, answers []byte, progress int, shown, answered []uuid.UUID) (*models.Response, error) {
+	var session models.Response
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		err := pgxscan.Get(ctx, tx, &session, `
+			UPDATE responses SET answers = $1, progress = $2, status = $3, last_activity_at = NOW()
+			WHERE survey_id = $4 AND filler_id = $5 AND status NOT IN ($6, $7)
+			RETURNING *`,
+			answers, progress, models.ResponseStatusInProgress, surveyID, fillerID, models.ResponseStatusCompleted, models.ResponseStatusRejected)
+		if err != nil {
+			if pgxscan.NotFound(err) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+		return recordQuestionTimings(ctx, tx, session.ID, shown, answered)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// AbandonStaleSessions marks in_progress sessions with no activity within idle as abandoned
+func (r *SurveyRepository) AbandonStaleSessions(ctx context.Context, idle time.Duration) (int64, error) {
+	tag, err := r.db.Exec(ctx,
+		"UPDATE responses SET status = $1 WHERE status = $2 AND COALESCE(last_activity_at, started_at) < $3",
+		models.ResponseStatusAbandoned, models.ResponseStatusInProgress, time.Now().Add(-idle))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func recordQuestionTimings(ctx context.Context, db DBTX, responseID uuid.UUID, shown, answered []uuid.UUID) error {
+	for _, questionID := range shown {
+		if _, err := db.Exec(ctx,
+			"INSERT INTO response_question_timings (response_id, question_id, first_shown_at) VALUES ($1, $2, NOW()) ON CONFLICT (response_id, question_id) DO NOTHING",
+			responseID, questionID); err != nil {
+			return err
+		}
+	}
+	for _, questionID := range answered {
+		if _, err := db.Exec(ctx,
+			`INSERT INTO response_question_timings (response_id, question_id, first_shown_at, answered_at) VALUES ($1, $2, NOW(), NOW())
+			 ON CONFLICT (response_id, question_id) DO UPDATE SET answered_at = COALESCE(response_question_timings.answered_at, EXCLUDED.answered_at)`,
+			responseID, questionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createEarning records a survey earning and moves its amount out of the survey's escrow, into the
+// filler's pending earnings until it is reviewed or straight into their wallet if it needs no review.
+// A funded survey pays only from what its escrow holds, failing with ErrInsufficientFunds when the
+// reward is not there; the caller holds the survey row lock, so the balance cannot change meanwhile.
+func createEarning(ctx context.Context, db DBTX, earning *models.Earning) error {
+	if earning.Amount > 0 && earning.SurveyID != nil {
+		var funded bool
+		if err := db.QueryRow(ctx, "SELECT funded_at IS NOT NULL FROM surveys WHERE id = $1", *earning.SurveyID).Scan(&funded); err != nil {
+			return err
+		}
+		if funded {
+			balance, err := accountBalance(ctx, db, models.EscrowAccount(*earning.SurveyID))
+			if err != nil {
+				return err
+			}
+			if balance < earning.Amount {
+				return fmt.Errorf("%w in %s", ErrInsufficientFunds, models.EscrowAccount(*earning.SurveyID))
+			}
+		}
+	}
+
+	_, err := db.Exec(ctx,
+		"INSERT INTO earnings (id, user_id, survey_id, response_id, amount, type, status, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		earning.ID, earning.UserID, earning.SurveyID, earning.ResponseID, earning.Amount, earning.Type, earning.Status, earning.CreatedAt)
+	if err != nil || earning.Amount <= 0 || earning.SurveyID == nil {
+		return err
+	}
+
+	to := models.FillerPendingAccount(earning.UserID)
+	if earning.Status == models.EarningStatusAvailable {
+		to = models.FillerWalletAccount(earning.UserID)
+	}
+	_, err = postJournal(ctx, db, models.Transfer(models.JournalEarningAccrued,
+		fmt.Sprintf("earning:%s:accrued", earning.ID), "Survey reward",
+		models.EscrowAccount(*earning.SurveyID), to, earning.Amount))
 	return err
 }
 
-func (r *SurveyRepository) CreateEarning(ctx context.Context, earning *models.Earning) error {
-	_, err := r.db.Exec(ctx,
-		"INSERT INTO earnings (id, user_id, survey_id, amount, type, status, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
-		earning.ID, earning.UserID, earning.SurveyID, earning.Amount, earning.Type, earning.Status, earning.CreatedAt)
+// settleEarning posts a reviewed earning to the ledger: approval releases it from the filler's
+// pending earnings into their wallet, rejection returns it to the survey's escrow
+func settleEarning(ctx context.Context, db DBTX, earningID, fillerID, surveyID uuid.UUID, amount int, status string) error {
+	if amount <= 0 {
+		return nil
+	}
+	journal := models.Transfer(models.JournalEarningReleased,
+		fmt.Sprintf("earning:%s:released", earningID), "Survey reward approved",
+		models.FillerPendingAccount(fillerID), models.FillerWalletAccount(fillerID), amount)
+	if status == models.EarningStatusReversed {
+		journal = models.Transfer(models.JournalEarningReversed,
+			fmt.Sprintf("earning:%s:reversed", earningID), "Survey reward reversed",
+			models.FillerPendingAccount(fillerID), models.EscrowAccount(surveyID), amount)
+	}
+	_, err := postJournal(ctx, db, journal)
 	return err
 }
 
-func (r *SurveyRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
-	_, err := r.db.Exec(ctx, "UPDATE surveys SET status = $1, updated_at = NOW() WHERE id = $2", status, id)
+// SettleReview moves a submitted response to the review outcome decide returns and settles its
+// earning; an uncounted response frees its quota slot
+func (r *SurveyRepository) SettleReview(ctx context.Context, surveyID, responseID uuid.UUID, note *string, decide func(reviewStatus *string) (*models.ReviewOutcome, error)) (*models.ReviewedResponse, error) {
+	reviewed := models.ReviewedResponse{ResponseID: responseID, SurveyID: surveyID}
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		// Lock the survey before the response, in the same order as RecordSubmission
+		var surveyStatus string
+		err := tx.QueryRow(ctx, "SELECT status, title FROM surveys WHERE id = $1 FOR UPDATE", surveyID).Scan(&surveyStatus, &reviewed.SurveyTitle)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrResponseNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var reviewStatus *string
+		err = tx.QueryRow(ctx,
+			"SELECT filler_id, review_status FROM responses WHERE id = $1 AND survey_id = $2 AND status IN ($3, $4) FOR UPDATE",
+			responseID, surveyID, models.ResponseStatusCompleted, models.ResponseStatusRejected).Scan(&reviewed.FillerID, &reviewStatus)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrResponseNotFound
+		}
+		if err != nil {
+			return err
+		}
+		outcome, err := decide(reviewStatus)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(ctx,
+			"UPDATE responses SET review_status = $1, review_note = $2, reviewed_at = NOW(), status = $3 WHERE id = $4",
+			outcome.ReviewStatus, note, outcome.ResponseStatus, responseID); err != nil {
+			return err
+		}
+
+		var earningID uuid.UUID
+		err = tx.QueryRow(ctx,
+			"UPDATE earnings SET status = $1 WHERE response_id = $2 AND status = $3 RETURNING id, amount",
+			outcome.EarningStatus, responseID, models.EarningStatusPending).Scan(&earningID, &reviewed.Amount)
+		switch {
+		case err == nil:
+			if err := settleEarning(ctx, tx, earningID, reviewed.FillerID, surveyID, reviewed.Amount, outcome.EarningStatus); err != nil {
+				return err
+			}
+		case !errors.Is(err, pgx.ErrNoRows):
+			return err
+		}
+
+		if outcome.Uncounted {
+			if _, err := tx.Exec(ctx,
+				"UPDATE surveys SET current_responses = GREATEST(current_responses - 1, 0), updated_at = NOW() WHERE id = $1",
+				surveyID); err != nil {
+				return err
+			}
+			// A rejected response no longer counts toward the target, so a filled survey reopens
+			if surveyStatus == models.SurveyStatusCompleted {
+				reason := "response rejected, target no longer reached"
+				if err := transitionStatus(ctx, tx, surveyID, surveyStatus, models.SurveyStatusActive, nil, &reason); err != nil {
+					return err
+				}
+			} else if models.IsClosingSurveyStatus(surveyStatus) {
+				// The reversed reward went back to a closed survey's escrow; return it to the creator
+				if err := refundUnusedBudget(ctx, tx, surveyID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reviewed, nil
+}
+
+// ApprovePendingReviews approves responses left in pending_review since before cutoff and releases
+// their earnings, returning what was approved so fillers can be notified
+func (r *SurveyRepository) ApprovePendingReviews(ctx context.Context, cutoff time.Time) ([]models.ReviewedResponse, error) {
+	var approved []models.ReviewedResponse
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		err := pgxscan.Select(ctx, tx, &approved, `
+			WITH approved AS (
+				UPDATE responses SET review_status = $1, reviewed_at = NOW()
+				WHERE status = $2 AND review_status = $3 AND completed_at < $4
+				RETURNING id, filler_id, survey_id
+			), released AS (
+				UPDATE earnings e SET status = $5 FROM approved a
+				WHERE e.response_id = a.id AND e.status = $6
+				RETURNING e.id, e.response_id, e.amount
+			)
+			SELECT a.id AS response_id, a.filler_id, a.survey_id, s.title AS survey_title,
+				rel.id AS earning_id, COALESCE(rel.amount, 0) AS amount
+			FROM approved a
+			JOIN surveys s ON s.id = a.survey_id
+			LEFT JOIN released rel ON rel.response_id = a.id`,
+			models.ReviewStatusApproved, models.ResponseStatusCompleted, models.ReviewStatusPending, cutoff,
+			models.EarningStatusAvailable, models.EarningStatusPending)
+		if err != nil {
+			return err
+		}
+		for _, a := range approved {
+			if a.EarningID == nil {
+				continue
+			}
+			if err := settleEarning(ctx, tx, *a.EarningID, a.FillerID, a.SurveyID, a.Amount, models.EarningStatusAvailable); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return approved, nil
+}
+
+// GetFillerProfile returns the filler's demographic profile, or nil if they have not filled one in
+func (r *SurveyRepository) GetFillerProfile(ctx context.Context, fillerID uuid.UUID) (*models.FillerProfile, error) {
+	var profile models.FillerProfile
+	err := pgxscan.Get(ctx, r.db, &profile,
+		"SELECT age_range, gender, country, state, location, education, employment, income_range FROM user_profiles WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1",
+		fillerID)
+	if pgxscan.NotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// GetPreferredLanguage returns the locale the user prefers to take surveys in, or "" if they have not chosen one
+func (r *SurveyRepository) GetPreferredLanguage(ctx context.Context, userID uuid.UUID) (string, error) {
+	var lang *string
+	err := r.db.QueryRow(ctx,
+		"SELECT preferred_language FROM user_profiles WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1",
+		userID).Scan(&lang)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil || lang == nil {
+		return "", err
+	}
+	return *lang, nil
+}
+
+// FullSegments returns the quotas that have already reached their maximum completed responses
+func (r *SurveyRepository) FullSegments(ctx context.Context, surveyID uuid.UUID, quotas []models.SegmentQuota) ([]models.SegmentQuota, error) {
+	return fullSegments(ctx, r.db, surveyID, quotas)
+}
+
+func fullSegments(ctx context.Context, db DBTX, surveyID uuid.UUID, quotas []models.SegmentQuota) ([]models.SegmentQuota, error) {
+	var full []models.SegmentQuota
+	for _, quota := range quotas {
+		condition, ok := segmentConditions[quota.Field]
+		if !ok {
+			continue
+		}
+		var count int
+		err := db.QueryRow(ctx,
+			"SELECT COUNT(*) FROM responses r JOIN user_profiles up ON up.user_id = r.filler_id WHERE r.survey_id = $1 AND r.status = 'completed' AND "+condition,
+			surveyID, strings.ToLower(strings.TrimSpace(quota.Value))).Scan(&count)
+		if err != nil {
+			return nil, err
+		}
+		if count >= quota.Max {
+			full = append(full, quota)
+		}
+	}
+	return full, nil
+}
+
+// GetFillerResponse returns the filler's response to a survey, if any
+func (r *SurveyRepository) GetFillerResponse(ctx context.Context, surveyID, fillerID uuid.UUID) (*models.Response, error) {
+	var response models.Response
+	err := pgxscan.Get(ctx, r.db, &response, "SELECT * FROM responses WHERE survey_id = $1 AND filler_id = $2", surveyID, fillerID)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetRecentAnswers returns the answers of the survey's latest submitted responses, newest first
+func (r *SurveyRepository) GetRecentAnswers(ctx context.Context, surveyID uuid.UUID, limit int) ([]json.RawMessage, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT answers FROM responses WHERE survey_id = $1 AND status IN ($2, $3) ORDER BY completed_at DESC LIMIT $4",
+		surveyID, models.ResponseStatusCompleted, models.ResponseStatusRejected, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var answers []json.RawMessage
+	for rows.Next() {
+		var a json.RawMessage
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
+
+// TransitionStatus moves a survey to another lifecycle state if the transition is allowed,
+// stamping when the state was entered and recording the change in survey_status_history.
+// actorID is nil for transitions made by the system.
+func (r *SurveyRepository) TransitionStatus(ctx context.Context, surveyID uuid.UUID, to string, actorID *uuid.UUID, reason *string) (*models.Survey, error) {
+	return r.transition(ctx, surveyID, to, actorID, reason, nil)
+}
+
+// SubmitForReview moves a survey to pending_review and locks its quoted cost in escrow in the same
+// transaction, failing with ErrInsufficientFunds when the creator's credits cannot cover it
+func (r *SurveyRepository) SubmitForReview(ctx context.Context, surveyID uuid.UUID, quote *models.SurveyQuote, actorID *uuid.UUID, reason *string) (*models.Survey, error) {
+	return r.transition(ctx, surveyID, models.SurveyStatusPendingReview, actorID, reason, quote)
+}
+
+func (r *SurveyRepository) transition(ctx context.Context, surveyID uuid.UUID, to string, actorID *uuid.UUID, reason *string, quote *models.SurveyQuote) (*models.Survey, error) {
+	var survey models.Survey
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var from string
+		err := tx.QueryRow(ctx, "SELECT status FROM surveys WHERE id = $1 FOR UPDATE", surveyID).Scan(&from)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSurveyNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := transitionStatus(ctx, tx, surveyID, from, to, actorID, reason); err != nil {
+			return err
+		}
+		if quote != nil {
+			if err := fundSurvey(ctx, tx, surveyID, quote); err != nil {
+				return err
+			}
+		}
+		return pgxscan.Get(ctx, tx, &survey, "SELECT * FROM surveys WHERE id = $1", surveyID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &survey, nil
+}
+
+// GetStatusHistory lists a survey's lifecycle transitions, oldest first
+func (r *SurveyRepository) GetStatusHistory(ctx context.Context, surveyID uuid.UUID) ([]models.SurveyStatusChange, error) {
+	history := []models.SurveyStatusChange{}
+	err := pgxscan.Select(ctx, r.db, &history,
+		"SELECT id, survey_id, from_status, to_status, actor_id, reason, created_at FROM survey_status_history WHERE survey_id = $1 ORDER BY created_at, id",
+		surveyID)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// transitionStatus applies a lifecycle transition to a survey row the caller has locked.
+// The first publication takes the platform fee from the survey's escrow, entering a closing state
+// refunds what is left of it, and going back to draft or being rejected returns the whole funding
+// and unlocks its pricing terms, so the survey is priced and charged afresh when it is resubmitted.
+func transitionStatus(ctx context.Context, db DBTX, surveyID uuid.UUID, from, to string, actorID *uuid.UUID, reason *string) error {
+	if !models.CanTransitionSurvey(from, to) {
+		return ErrInvalidTransition
+	}
+
+	firstPublication := false
+	if to == models.SurveyStatusActive {
+		var publishedAt *time.Time
+		if err := db.QueryRow(ctx, "SELECT published_at FROM surveys WHERE id = $1", surveyID).Scan(&publishedAt); err != nil {
+			return err
+		}
+		firstPublication = publishedAt == nil
+	}
+
+	if err := stampStatus(ctx, db, surveyID, to); err != nil {
+		return err
+	}
+
+	switch {
+	case firstPublication:
+		if err := takePlatformFee(ctx, db, surveyID); err != nil {
+			return err
+		}
+	case models.IsClosingSurveyStatus(to):
+		if err := refundUnusedBudget(ctx, db, surveyID); err != nil {
+			return err
+		}
+	case to == models.SurveyStatusDraft || to == models.SurveyStatusRejected:
+		if err := refundUnusedBudget(ctx, db, surveyID); err != nil {
+			return err
+		}
+		if _, err := db.Exec(ctx, "UPDATE surveys SET budget = 0, platform_fee = 0, funded_at = NULL, pricing_terms = NULL WHERE id = $1", surveyID); err != nil {
+			return err
+		}
+		// A resubmitted survey is priced afresh, and may redeem its promo code again
+		if _, err := db.Exec(ctx, "DELETE FROM promo_redemptions WHERE survey_id = $1", surveyID); err != nil {
+			return err
+		}
+	}
+	return recordStatusChange(ctx, db, surveyID, &from, to, actorID, reason)
+}
+
+// stampStatus sets the survey's status and the timestamp of entering it. published_at keeps the
+// first publication so resuming a paused survey does not move it, and the first publication
+// starts the survey's run of run_days.
+func stampStatus(ctx context.Context, db DBTX, surveyID uuid.UUID, status string) error {
+	query := "UPDATE surveys SET status = $1, updated_at = NOW()"
+	if column, ok := statusTimestamps[status]; ok {
+		if status == models.SurveyStatusActive {
+			query += fmt.Sprintf(", %s = COALESCE(%s, NOW())", column, column)
+			query += ", expires_at = COALESCE(expires_at, CASE WHEN run_days > 0 THEN NOW() + run_days * INTERVAL '1 day' END)"
+		} else {
+			query += fmt.Sprintf(", %s = NOW()", column)
+		}
+	}
+	_, err := db.Exec(ctx, query+" WHERE id = $2", status, surveyID)
+	return err
+}
+
+// refundUnusedBudget returns what is left in the survey's escrow to the creator's credits: the
+// rewards of responses never collected, and the platform fee if the survey was never published.
+// refunded_amount totals what has been returned, so an empty escrow is never refunded twice.
+func refundUnusedBudget(ctx context.Context, db DBTX, surveyID uuid.UUID) error {
+	held, err := accountBalance(ctx, db, models.EscrowAccount(surveyID))
+	if err != nil || held <= 0 {
+		return err
+	}
+
+	var creatorID uuid.UUID
+	var title string
+	var refunded int
+	if err := db.QueryRow(ctx,
+		"UPDATE surveys SET refunded_amount = refunded_amount + $1 WHERE id = $2 RETURNING creator_id, title, refunded_amount",
+		held, surveyID).Scan(&creatorID, &title, &refunded); err != nil {
+		return err
+	}
+
+	// Each refund raises refunded_amount, which keeps the journal reference unique per refund
+	_, err = postJournal(ctx, db, models.Transfer(models.JournalBudgetRefund,
+		fmt.Sprintf("survey:%s:refund:%d", surveyID, refunded), fmt.Sprintf("Unused budget refund: %s", title),
+		models.EscrowAccount(surveyID), models.CreatorCreditsAccount(creatorID), held))
 	return err
 }
 
-func (r *SurveyRepository) GetSurveyResponses(ctx context.Context, surveyID uuid.UUID, limit, offset int) ([]models.Response, int, error) {
+// ActivateScheduledSurveys publishes scheduled surveys whose start time has passed
+func (r *SurveyRepository) ActivateScheduledSurveys(ctx context.Context) ([]models.Survey, error) {
+	reason := "scheduled start reached"
+	return r.transitionDue(ctx,
+		"SELECT id FROM surveys WHERE status = $1 AND starts_at <= NOW()",
+		[]interface{}{models.SurveyStatusScheduled}, models.SurveyStatusActive, &reason)
+}
+
+// ExpireSurveys closes running surveys whose run has ended, refunding their unused budget
+func (r *SurveyRepository) ExpireSurveys(ctx context.Context) ([]models.Survey, error) {
+	reason := "survey run ended"
+	return r.transitionDue(ctx,
+		"SELECT id FROM surveys WHERE status IN ($1, $2) AND expires_at <= NOW()",
+		[]interface{}{models.SurveyStatusActive, models.SurveyStatusPaused}, models.SurveyStatusExpired, &reason)
+}
+
+// transitionDue moves every survey matched by query to the given state as a system transition,
+// skipping surveys whose state changed in the meantime
+func (r *SurveyRepository) transitionDue(ctx context.Context, query string, args []interface{}, to string, reason *string) ([]models.Survey, error) {
+	var ids []uuid.UUID
+	if err := pgxscan.Select(ctx, r.db, &ids, query, args...); err != nil {
+		return nil, err
+	}
+
+	var moved []models.Survey
+	for _, id := range ids {
+		survey, err := r.TransitionStatus(ctx, id, to, nil, reason)
+		if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrSurveyNotFound) {
+			continue
+		}
+		if err != nil {
+			return moved, err
+		}
+		moved = append(moved, *survey)
+	}
+	return moved, nil
+}
+
+func recordStatusChange(ctx context.Context, db DBTX, surveyID uuid.UUID, from *string, to string, actorID *uuid.UUID, reason *string) error {
+	_, err := db.Exec(ctx,
+		"INSERT INTO survey_status_history (id, survey_id, from_status, to_status, actor_id, reason, created_at) VALUES ($1, $2, $3, $4, $5, $6, NOW())",
+		uuid.New(), surveyID, from, to, actorID, reason)
+	return err
+}
+
+// GetSurveyResponses lists submitted responses, optionally only those in the given review status
+// and answered against the given survey version (0 for every version)
+func (r *SurveyRepository) GetSurveyResponses(ctx context.Context, surveyID uuid.UUID, reviewStatus string, version, limit, offset int) ([]models.Response, int, error) {
 	var responses []models.Response
 	var total int
 
-	query := "SELECT * FROM responses WHERE survey_id = $1 ORDER BY started_at DESC LIMIT $2 OFFSET $3"
-	countQuery := "SELECT COUNT(*) FROM responses WHERE survey_id = $1"
+	filter := "survey_id = $1 AND status IN ('completed', 'rejected')"
+	args := []interface{}{surveyID}
+	if reviewStatus != "" {
+		args = append(args, reviewStatus)
+		filter += fmt.Sprintf(" AND review_status = $%d", len(args))
+	}
+	if version > 0 {
+		args = append(args, version)
+		filter += fmt.Sprintf(" AND survey_version = $%d", len(args))
+	}
 
-	err := pgxscan.Select(ctx, r.db, &responses, query, surveyID, limit, offset)
+	query := fmt.Sprintf("SELECT * FROM responses WHERE %s ORDER BY started_at DESC LIMIT $%d OFFSET $%d", filter, len(args)+1, len(args)+2)
+	countQuery := "SELECT COUNT(*) FROM responses WHERE " + filter
+
+	err := pgxscan.Select(ctx, r.db, &responses, query, append(args, limit, offset)...)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	err = r.db.QueryRow(ctx, countQuery, surveyID).Scan(&total)
+	err = r.db.QueryRow(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -154,6 +1015,9 @@ func (r *SurveyRepository) GetSurveyResponses(ctx context.Context, surveyID uuid
 func (r *SurveyRepository) GetResponseDetails(ctx context.Context, surveyID, responseID uuid.UUID) (*models.Response, error) {
 	var response models.Response
 	err := pgxscan.Get(ctx, r.db, &response, "SELECT * FROM responses WHERE survey_id = $1 AND id = $2", surveyID, responseID)
+	if pgxscan.NotFound(err) {
+		return nil, ErrResponseNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrTemplateNotFound = errors.New("survey template not found")
+
+type TemplateRepository struct {
+	*BaseRepository
+}
+
+func NewTemplateRepository(base *BaseRepository) *TemplateRepository {
+	return &TemplateRepository{BaseRepository: base}
+}
+
+// List returns the curated templates plus, when ownerID is set, that creator's private templates.
+// An empty category returns every category.
+func (r *TemplateRepository) List(ctx context.Context, ownerID *uuid.UUID, category string) ([]models.SurveyTemplate, error) {
+	templates := []models.SurveyTemplate{}
+	err := pgxscan.Select(ctx, r.db, &templates, `
+		SELECT * FROM survey_templates
+		WHERE (owner_id IS NULL OR owner_id = $1) AND ($2 = '' OR category = $2)
+		ORDER BY owner_id NULLS FIRST, name`,
+		ownerID, category)
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *TemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SurveyTemplate, error) {
+	var template models.SurveyTemplate
+	err := pgxscan.Get(ctx, r.db, &template, "SELECT * FROM survey_templates WHERE id = $1", id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (r *TemplateRepository) Create(ctx context.Context, template *models.SurveyTemplate) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO survey_templates (id, owner_id, name, description, category, definition, question_count, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING created_at, updated_at`,
+		template.ID, template.OwnerID, template.Name, template.Description, template.Category,
+		template.Definition, template.QuestionCount, template.CreatedBy,
+	).Scan(&template.CreatedAt, &template.UpdatedAt)
+}
+
+// Update replaces a template's content, keeping its owner
+func (r *TemplateRepository) Update(ctx context.Context, template *models.SurveyTemplate) error {
+	err := r.db.QueryRow(ctx, `
+		UPDATE survey_templates
+		SET name = $2, description = $3, category = $4, definition = $5, question_count = $6, updated_at = NOW()
+		WHERE id = $1
+		RETURNING updated_at`,
+		template.ID, template.Name, template.Description, template.Category, template.Definition, template.QuestionCount,
+	).Scan(&template.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrTemplateNotFound
+	}
+	return err
+}
+
+func (r *TemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tag, err := r.db.Exec(ctx, "DELETE FROM survey_templates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrWithdrawalNotFound   = errors.New("withdrawal not found")
+	ErrWithdrawalNotPending = errors.New("withdrawal is not pending")
+)
+
+// withdrawalColumns are the columns scanned by scanWithdrawal, in order
+const withdrawalColumns = `id, user_id, amount, bank_name, account_number, account_name, bank_code, paystack_reference,
+	status, attempts, last_error, next_attempt_at, approved_by, approved_at, created_at, updated_at, processed_at`
+
+type WithdrawalRepository struct {
+	*BaseRepository
+}
+
+func NewWithdrawalRepository(base *BaseRepository) *WithdrawalRepository {
+	return &WithdrawalRepository{BaseRepository: base}
+}
+
+// Create records a pending withdrawal and moves its amount from the filler's wallet into payout
+// clearing, failing with ErrInsufficientFunds when the wallet does not hold enough
+func (r *WithdrawalRepository) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	withdrawal.Status = models.WithdrawalStatusPending
+	return r.WithTx(ctx, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO withdrawals (id, user_id, amount, bank_name, account_number, account_name, bank_code, status, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+			RETURNING created_at`,
+			withdrawal.ID, withdrawal.UserID, withdrawal.Amount, withdrawal.BankName, withdrawal.AccountNumber,
+			withdrawal.AccountName, withdrawal.BankCode, withdrawal.Status).Scan(&withdrawal.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = postJournal(ctx, tx, models.Transfer(models.JournalWithdrawalRequested,
+			fmt.Sprintf("withdrawal:%s:requested", withdrawal.ID), "Withdrawal to "+withdrawal.BankName,
+			models.FillerWalletAccount(withdrawal.UserID), models.PlatformAccount(models.LedgerAccountPayoutClearing), withdrawal.Amount))
+		return err
+	})
+}
+
+func scanWithdrawal(row pgx.Row) (*models.Withdrawal, error) {
+	var w models.Withdrawal
+	err := row.Scan(&w.ID, &w.UserID, &w.Amount, &w.BankName, &w.AccountNumber, &w.AccountName, &w.BankCode, &w.PaystackReference,
+		&w.Status, &w.Attempts, &w.LastError, &w.NextAttemptAt, &w.ApprovedBy, &w.ApprovedAt, &w.CreatedAt, &w.UpdatedAt, &w.ProcessedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func collectWithdrawals(rows pgx.Rows) ([]models.Withdrawal, error) {
+	defer rows.Close()
+	withdrawals := []models.Withdrawal{}
+	for rows.Next() {
+		w, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, *w)
+	}
+	return withdrawals, rows.Err()
+}
+
+func (r *WithdrawalRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Withdrawal, error) {
+	return scanWithdrawal(r.db.QueryRow(ctx, "SELECT "+withdrawalColumns+" FROM withdrawals WHERE id = $1", id))
+}
+
+// Approve releases a pending withdrawal to the payout worker
+func (r *WithdrawalRepository) Approve(ctx context.Context, id, adminID uuid.UUID) (*models.Withdrawal, error) {
+	w, err := scanWithdrawal(r.db.QueryRow(ctx, `
+		UPDATE withdrawals SET status = $1, approved_by = $2, approved_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4
+		RETURNING `+withdrawalColumns,
+		models.WithdrawalStatusApproved, adminID, id, models.WithdrawalStatusPending))
+	if errors.Is(err, ErrWithdrawalNotFound) {
+		if _, getErr := r.GetByID(ctx, id); getErr == nil {
+			return nil, ErrWithdrawalNotPending
+		}
+	}
+	return w, err
+}
+
+// Payouts that stay processing without a transfer code for this long were interrupted mid-run
+const payoutClaimTimeout = 10 * time.Minute
+
+// ClaimPayouts moves approved withdrawals whose next attempt is due, limited to ids when any are
+// given, to processing and counts the attempt. Withdrawals a crashed run left processing without a
+// transfer code are claimed again. Claimed rows are skipped by concurrent runs.
+func (r *WithdrawalRepository) ClaimPayouts(ctx context.Context, ids []uuid.UUID, limit int) ([]models.Withdrawal, error) {
+	if ids == nil {
+		ids = []uuid.UUID{}
+	}
+	rows, err := r.db.Query(ctx, `
+		UPDATE withdrawals SET status = $1, attempts = attempts + 1, next_attempt_at = NULL, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM withdrawals
+			WHERE ((status = $2 AND (next_attempt_at IS NULL OR next_attempt_at <= NOW()))
+				OR (status = $1 AND paystack_reference IS NULL AND updated_at < NOW() - $3 * INTERVAL '1 second'))
+				AND (cardinality($4::uuid[]) = 0 OR id = ANY($4))
+			ORDER BY approved_at, created_at
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+withdrawalColumns,
+		models.WithdrawalStatusProcessing, models.WithdrawalStatusApproved, int(payoutClaimTimeout.Seconds()), ids, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectWithdrawals(rows)
+}
+
+// UnsettledTransfers lists transfers that have been processing at Paystack for longer than olderThan
+func (r *WithdrawalRepository) UnsettledTransfers(ctx context.Context, olderThan time.Duration, limit int) ([]models.Withdrawal, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+withdrawalColumns+` FROM withdrawals
+		WHERE status = $1 AND paystack_reference IS NOT NULL AND updated_at < NOW() - $2 * INTERVAL '1 second'
+		ORDER BY updated_at
+		LIMIT $3`,
+		models.WithdrawalStatusProcessing, int(olderThan.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectWithdrawals(rows)
+}
+
+// TransferRecipient returns the Paystack recipient code cached for a filler's bank account, or ""
+func (r *WithdrawalRepository) TransferRecipient(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (string, error) {
+	var code string
+	err := r.db.QueryRow(ctx,
+		"SELECT recipient_code FROM transfer_recipients WHERE user_id = $1 AND bank_code = $2 AND account_number = $3",
+		userID, bankCode, accountNumber).Scan(&code)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return code, err
+}
+
+func (r *WithdrawalRepository) SaveTransferRecipient(ctx context.Context, recipient *models.TransferRecipient) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO transfer_recipients (id, user_id, bank_code, account_number, account_name, recipient_code, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id, bank_code, account_number)
+		DO UPDATE SET recipient_code = EXCLUDED.recipient_code, account_name = EXCLUDED.account_name
+		RETURNING id, created_at`,
+		recipient.ID, recipient.UserID, recipient.BankCode, recipient.AccountNumber, recipient.AccountName,
+		recipient.RecipientCode).Scan(&recipient.ID, &recipient.CreatedAt)
+}
+
+// MarkTransferInitiated records the transfer code Paystack gave a claimed withdrawal's transfer,
+// unless a webhook has settled the withdrawal in the meantime
+func (r *WithdrawalRepository) MarkTransferInitiated(ctx context.Context, id uuid.UUID, transferCode, status string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE withdrawals SET status = $1, paystack_reference = COALESCE(NULLIF($2, ''), paystack_reference),
+			last_error = NULL, updated_at = NOW()
+		WHERE id = $3 AND status IN ($4, $5)`,
+		status, transferCode, id, models.WithdrawalStatusProcessing, models.WithdrawalStatusAwaitingOTP)
+	return err
+}
+
+// RetryPayout hands a claimed withdrawal back to the payout worker for another attempt at the given time
+func (r *WithdrawalRepository) RetryPayout(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE withdrawals SET status = $1, last_error = $2, next_attempt_at = $3, updated_at = NOW()
+		WHERE id = $4 AND status = $5`,
+		models.WithdrawalStatusApproved, reason, at, id, models.WithdrawalStatusProcessing)
+	return err
+}
+
+// lockTransferWithdrawal locks the withdrawal a Paystack transfer paid out. Transfers are referenced
+// by the withdrawal's ID, and the withdrawal keeps the transfer code Paystack returned.
+func lockTransferWithdrawal(ctx context.Context, tx pgx.Tx, reference, transferCode string) (*models.Withdrawal, error) {
+	var w models.Withdrawal
+	err := tx.QueryRow(ctx, `
+		SELECT id, user_id, amount, status FROM withdrawals
+		WHERE id::text = $1 OR paystack_reference = $1 OR (paystack_reference = $2 AND $2 <> '')
+		LIMIT 1 FOR UPDATE`,
+		reference, transferCode).Scan(&w.ID, &w.UserID, &w.Amount, &w.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWithdrawalNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// CompleteTransfer settles a withdrawal whose transfer succeeded: its amount leaves payout clearing
+// for the payment gateway and the payout is recorded among payment transactions. Settling an
+// already settled withdrawal changes nothing.
+func (r *WithdrawalRepository) CompleteTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if withdrawal, err = lockTransferWithdrawal(ctx, tx, reference, transferCode); err != nil {
+			return err
+		}
+		if withdrawal.Status == models.WithdrawalStatusCompleted || withdrawal.Status == models.WithdrawalStatusFailed {
+			return nil
+		}
+
+		withdrawal.Status = models.WithdrawalStatusCompleted
+		if _, err := tx.Exec(ctx,
+			"UPDATE withdrawals SET status = $1, processed_at = NOW(), updated_at = NOW() WHERE id = $2",
+			withdrawal.Status, withdrawal.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO payment_transactions (id, user_id, type, amount, credits, status, paystack_reference, description, created_at)
+			VALUES ($1, $2, $3, $4, 0, $5, $6, $7, NOW())
+			ON CONFLICT (paystack_reference) WHERE paystack_reference IS NOT NULL DO NOTHING`,
+			uuid.New(), withdrawal.UserID, models.PaymentTypePayout, withdrawal.Amount, models.PaymentStatusSuccess,
+			"transfer:"+withdrawal.ID.String(), "Withdrawal payout"); err != nil {
+			return err
+		}
+
+		_, err = postJournal(ctx, tx, models.Transfer(models.JournalWithdrawalPaid,
+			fmt.Sprintf("withdrawal:%s:paid", withdrawal.ID), "Withdrawal paid out",
+			models.PlatformAccount(models.LedgerAccountPayoutClearing), models.PlatformAccount(models.LedgerAccountPaymentGateway), withdrawal.Amount))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// FailTransfer returns the amount of a withdrawal whose transfer failed or was reversed to the
+// filler's wallet. A transfer reversed after it completed takes the money back from the payment
+// gateway rather than payout clearing. Failing an already failed withdrawal changes nothing.
+func (r *WithdrawalRepository) FailTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if withdrawal, err = lockTransferWithdrawal(ctx, tx, reference, transferCode); err != nil {
+			return err
+		}
+		return failWithdrawal(ctx, tx, withdrawal, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// FailPayout fails a withdrawal the payout worker could not pay out, recording why, and returns its
+// amount to the filler's wallet
+func (r *WithdrawalRepository) FailPayout(ctx context.Context, id uuid.UUID, reason string) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := r.WithTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		if withdrawal, err = lockTransferWithdrawal(ctx, tx, id.String(), ""); err != nil {
+			return err
+		}
+		return failWithdrawal(ctx, tx, withdrawal, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+func failWithdrawal(ctx context.Context, tx pgx.Tx, withdrawal *models.Withdrawal, reason string) error {
+	if withdrawal.Status == models.WithdrawalStatusFailed {
+		return nil
+	}
+
+	from := models.PlatformAccount(models.LedgerAccountPayoutClearing)
+	if withdrawal.Status == models.WithdrawalStatusCompleted {
+		from = models.PlatformAccount(models.LedgerAccountPaymentGateway)
+		if _, err := tx.Exec(ctx,
+			"UPDATE payment_transactions SET status = $1 WHERE paystack_reference = $2",
+			models.PaymentStatusReversed, "transfer:"+withdrawal.ID.String()); err != nil {
+			return err
+		}
+	}
+
+	withdrawal.Status = models.WithdrawalStatusFailed
+	if _, err := tx.Exec(ctx,
+		"UPDATE withdrawals SET status = $1, last_error = COALESCE(NULLIF($2, ''), last_error), processed_at = NOW(), updated_at = NOW() WHERE id = $3",
+		withdrawal.Status, reason, withdrawal.ID); err != nil {
+		return err
+	}
+
+	_, err := postJournal(ctx, tx, models.Transfer(models.JournalWithdrawalReturned,
+		fmt.Sprintf("withdrawal:%s:returned", withdrawal.ID), "Withdrawal returned to wallet",
+		from, models.FillerWalletAccount(withdrawal.UserID), withdrawal.Amount))
+	return err
+}
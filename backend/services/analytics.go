@@ -53,19 +53,14 @@ func (s *AnalyticsService) GetFillerAnalytics(ctx context.Context, userID string
 		analytics.TotalSurveysCompleted = 0
 	}
 
-	// Total earnings and balance
+	// Total earnings; pending earnings and the available balance come from the ledger
 	err = s.db.QueryRow(ctx, `
-		SELECT
-			COALESCE(SUM(CASE WHEN status = 'paid' THEN amount ELSE 0 END), 0) as total_earnings,
-			COALESCE(SUM(CASE WHEN status = 'pending' THEN amount ELSE 0 END), 0) as pending_earnings,
-			COALESCE(SUM(CASE WHEN status = 'approved' THEN amount ELSE 0 END), 0) as available_balance
+		SELECT COALESCE(SUM(amount), 0)
 		FROM earnings
-		WHERE user_id = $1
-	`, userID).Scan(&analytics.TotalEarnings, &analytics.PendingEarnings, &analytics.AvailableBalance)
+		WHERE user_id = $1 AND status <> 'reversed'
+	`, userID).Scan(&analytics.TotalEarnings)
 	if err != nil {
 		analytics.TotalEarnings = 0
-		analytics.PendingEarnings = 0
-		analytics.AvailableBalance = 0
 	}
 
 	// This month earnings
@@ -179,16 +174,6 @@ func (s *AnalyticsService) GetCreatorAnalytics(ctx context.Context, userID strin
 		analytics.TotalSpent = 0
 	}
 
-	// Available credits
-	err = s.db.QueryRow(ctx, `
-		SELECT COALESCE(SUM(amount), 0)
-		FROM credits
-		WHERE user_id = $1
-	`, userID).Scan(&analytics.AvailableCredits)
-	if err != nil {
-		analytics.AvailableCredits = 0
-	}
-
 	// This month stats
 	err = s.db.QueryRow(ctx, `
 		SELECT
@@ -413,21 +398,17 @@ func (s *AnalyticsService) GetEarningsBreakdown(ctx context.Context, userID stri
 		return &breakdown, nil
 	}
 
-	// Total earnings by status
+	// Total earnings by status; pending earnings and the available balance come from the ledger
 	err := s.db.QueryRow(ctx, `
 		SELECT
-			COALESCE(SUM(amount), 0) as total,
-			COALESCE(SUM(CASE WHEN status = 'completed' THEN amount ELSE 0 END), 0) as paid,
-			COALESCE(SUM(CASE WHEN status = 'pending' THEN amount ELSE 0 END), 0) as pending,
-			COALESCE(SUM(CASE WHEN status = 'completed' THEN amount ELSE 0 END), 0) as available
+			COALESCE(SUM(CASE WHEN status <> 'reversed' THEN amount ELSE 0 END), 0) as total,
+			COALESCE(SUM(CASE WHEN status = 'available' THEN amount ELSE 0 END), 0) as paid
 		FROM earnings
 		WHERE user_id = $1
-	`, userID).Scan(&breakdown.TotalEarnings, &breakdown.PaidEarnings, &breakdown.PendingEarnings, &breakdown.AvailableBalance)
+	`, userID).Scan(&breakdown.TotalEarnings, &breakdown.PaidEarnings)
 	if err != nil {
 		breakdown.TotalEarnings = 0
 		breakdown.PaidEarnings = 0
-		breakdown.PendingEarnings = 0
-		breakdown.AvailableBalance = 0
 	}
 
 	// Monthly earnings (last 6 months)
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"onetimer-backend/models"
+)
+
+// Answer validation error codes
+const (
+	AnswerErrRequired        = "required"
+	AnswerErrInvalidType     = "invalid_type"
+	AnswerErrInvalidOption   = "invalid_option"
+	AnswerErrOutOfRange      = "out_of_range"
+	AnswerErrIncomplete      = "incomplete"
+	AnswerErrUnknownQuestion = "unknown_question"
+	AnswerErrUnsupported     = "unsupported_type"
+)
+
+const (
+	defaultRatingScale = 5
+	maxTextAnswerChars = 5000
+)
+
+// AnswerValidator checks submitted answers against each question's type and configuration
+type AnswerValidator struct {
+	types *QuestionTypeRegistry
+}
+
+type AnswerError struct {
+	QuestionID string `json:"question_id"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func NewAnswerValidator() *AnswerValidator {
+	return &AnswerValidator{types: NewQuestionTypeRegistry()}
+}
+
+// Validate checks answers (keyed by question ID) against the questions shown to the filler.
+// Answers for questions outside that list are reported as unknown.
+func (v *AnswerValidator) Validate(questions []models.Question, answers map[string]interface{}) []AnswerError {
+	var errs []AnswerError
+	known := make(map[string]bool, len(questions))
+
+	for _, q := range questions {
+		id := q.ID.String()
+		known[id] = true
+
+		answer, present := answers[id]
+		if !present || !IsAnswered(answer) {
+			if q.Required {
+				errs = append(errs, AnswerError{QuestionID: id, Code: AnswerErrRequired, Message: "This question is required"})
+			}
+			continue
+		}
+
+		if err := v.validateAnswer(q, answer); err != nil {
+			err.QuestionID = id
+			errs = append(errs, *err)
+		}
+	}
+
+	for id := range answers {
+		if !known[id] {
+			errs = append(errs, AnswerError{QuestionID: id, Code: AnswerErrUnknownQuestion, Message: "Answer does not match a question in this survey"})
+		}
+	}
+
+	return errs
+}
+
+func (v *AnswerValidator) validateAnswer(q models.Question, answer interface{}) *AnswerError {
+	t, ok := v.types.Lookup(q.Type)
+	if !ok {
+		return &AnswerError{Code: AnswerErrUnsupported, Message: fmt.Sprintf("Question type '%s' is not supported", q.Type)}
+	}
+	return t.ValidateAnswer(q, answer)
+}
+
+// containsOption reports whether value is one of options; an empty option list accepts anything
+func containsOption(options []string, value string) bool {
+	if len(options) == 0 {
+		return true
+	}
+	for _, option := range options {
+		if option == value {
+			return true
+		}
+	}
+	return false
+}
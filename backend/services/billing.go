@@ -2,11 +2,37 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"onetimer-backend/models"
 	"onetimer-backend/utils"
+	"time"
+
+	"github.com/google/uuid"
 )
 
-type BillingService struct{}
+// ErrPromoCodeInvalid is returned when quoting with a promo code that does not exist or cannot be redeemed
+var ErrPromoCodeInvalid = errors.New("promo code is invalid, expired or fully redeemed")
+
+// PricingStore keeps the pricing rules, organization rates and promo codes surveys are priced on
+type PricingStore interface {
+	// ActivePricing returns the pricing version in force at a time, or nil when none has been stored
+	ActivePricing(ctx context.Context, at time.Time) (*models.PricingVersion, error)
+	// OrganizationRate returns a creator's organization rate in force at a time, or nil when there is none
+	OrganizationRate(ctx context.Context, creatorID uuid.UUID, at time.Time) (*models.OrganizationRate, error)
+	// PromoCode returns a promo code, matched case-insensitively, or nil when there is none
+	PromoCode(ctx context.Context, code string) (*models.PromoCode, error)
+}
+
+// BillingService prices surveys. Without a store it prices on the built-in default rules.
+type BillingService struct {
+	store PricingStore
+}
+
+// BaseSurveyDays is how long a published survey runs before any purchased extra days
+const BaseSurveyDays = 14
 
 type SurveyBilling struct {
 	Pages              int  `json:"pages"`
@@ -23,95 +49,247 @@ type BillingResult struct {
 	TotalCost         int    `json:"total_cost"`
 	ComplexityLevel   string `json:"complexity_level"`
 	EstimatedDuration string `json:"estimated_duration"`
+	PricingVersion    int    `json:"pricing_version"`
 }
 
 func NewBillingService() *BillingService {
 	return &BillingService{}
 }
 
+// WithStore prices on the rules, organization rates and promo codes kept in a store
+func (bs *BillingService) WithStore(store PricingStore) *BillingService {
+	bs.store = store
+	return bs
+}
+
+// Pricing returns the pricing version in force now: the latest stored one to have taken effect, or
+// the built-in defaults as version 0 when there is none or the store cannot be read
+func (bs *BillingService) Pricing(ctx context.Context) (*models.PricingVersion, error) {
+	if bs.store != nil {
+		version, err := bs.store.ActivePricing(ctx, time.Now())
+		if err != nil {
+			utils.LogError(ctx, "Failed to load pricing, using the default rules", err)
+		} else if version != nil {
+			return version, nil
+		}
+	}
+	return &models.PricingVersion{Rules: models.DefaultPricingRules()}, nil
+}
+
 func (bs *BillingService) CalculateSurveyCost(billing SurveyBilling) (*BillingResult, error) {
 	ctx := context.Background()
+	pricing, err := bs.Pricing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := bs.calculate(ctx, pricing.Rules, billing)
+	if err != nil {
+		return nil, err
+	}
+	result.PricingVersion = pricing.Version
+	return result, nil
+}
+
+func (bs *BillingService) calculate(ctx context.Context, rules models.PricingRules, billing SurveyBilling) (*BillingResult, error) {
 	if billing.Pages <= 0 {
 		utils.LogWarn(ctx, "Invalid billing calculation: pages must be greater than 0")
 		return nil, errors.New("pages must be greater than 0")
 	}
-	if billing.RewardPerUser < 100 {
-		utils.LogWarn(ctx, "Invalid billing calculation: reward per user must be at least ₦100")
-		return nil, errors.New("reward per user must be at least ₦100")
+	if billing.RewardPerUser < rules.MinReward {
+		utils.LogWarn(ctx, "Invalid billing calculation: reward per user below minimum", "min_reward", rules.MinReward)
+		return nil, fmt.Errorf("reward per user must be at least ₦%d", rules.MinReward)
 	}
 	if billing.Respondents <= 0 {
 		utils.LogWarn(ctx, "Invalid billing calculation: respondents must be greater than 0")
 		return nil, errors.New("respondents must be greater than 0")
 	}
 
-	// Calculate platform fee based on complexity
-	var platformFee int
-	var complexityLevel string
-	var estimatedDuration string
-
-	if billing.Pages <= 5 {
-		platformFee = 150
-		complexityLevel = "Basic"
-		estimatedDuration = "2-5 mins"
-	} else if billing.Pages <= 10 {
-		platformFee = 300
-		complexityLevel = "Standard"
-		estimatedDuration = "5-10 mins"
-	} else if billing.Pages <= 20 {
-		platformFee = 500
-		complexityLevel = "Advanced"
-		estimatedDuration = "10-20 mins"
-	} else {
-		platformFee = 1000
-		complexityLevel = "Enterprise"
-		estimatedDuration = "20+ mins"
-	}
-
-	// Calculate base cost
-	totalCost := (billing.RewardPerUser * billing.Respondents) + platformFee
+	// Platform fee based on complexity, plus any share of the rewards
+	tier := rules.Tier(billing.Pages)
+	rewards := billing.RewardPerUser * billing.Respondents
+	platformFee := tier.PlatformFee + int(math.Round(float64(rewards)*rules.PlatformFeePercentage/100))
+	totalCost := rewards + platformFee
 
 	// Add-ons
 	if billing.PriorityPlacement {
-		totalCost += 500
+		totalCost += rules.PriorityPlacement
 	}
 	if billing.DemographicFilters > 0 {
-		totalCost += billing.DemographicFilters * 200
+		totalCost += billing.DemographicFilters * rules.DemographicFilter
 	}
 	if billing.ExtraDays > 0 {
-		totalCost += billing.ExtraDays * 100
+		totalCost += billing.ExtraDays * rules.ExtraDay
 	}
 	if billing.DataExport {
-		totalCost += 300
+		totalCost += rules.DataExport
 	}
 
 	result := &BillingResult{
 		PlatformFee:       platformFee,
 		TotalCost:         totalCost,
-		ComplexityLevel:   complexityLevel,
-		EstimatedDuration: estimatedDuration,
+		ComplexityLevel:   tier.Level,
+		EstimatedDuration: tier.Duration,
 	}
 
-	utils.LogInfo(ctx, "✅ Survey cost calculated", "complexity", complexityLevel, "platform_fee", platformFee, "total_cost", totalCost)
+	utils.LogInfo(ctx, "✅ Survey cost calculated", "complexity", tier.Level, "platform_fee", platformFee, "total_cost", totalCost)
 
 	return result, nil
 }
 
-func (bs *BillingService) ValidateRewardRange(pages int, rewardPerUser int) error {
-	var minReward, maxReward int
+// QuoteSurvey prices publishing a survey from its stored settings. Each question is billed as a
+// page, and each demographic its targeting narrows as a filter. A funded survey is priced on the
+// terms locked when it was funded; any other on the rules in force now, its creator's organization
+// rate and promoCode, if given.
+func (bs *BillingService) QuoteSurvey(ctx context.Context, survey *models.Survey, questionCount int, promoCode string) (*models.SurveyQuote, error) {
+	terms, err := bs.surveyTerms(ctx, survey, promoCode)
+	if err != nil {
+		return nil, err
+	}
 
-	if pages <= 5 {
-		minReward, maxReward = 100, 150
-	} else if pages <= 10 {
-		minReward, maxReward = 150, 250
-	} else if pages <= 20 {
-		minReward, maxReward = 300, 500
-	} else {
-		minReward, maxReward = 600, 1000
+	rules := terms.Rules
+	if rate := terms.OrganizationRate; rate != nil && rate.PlatformFee != nil {
+		rules.Tiers = append([]models.PricingTier(nil), rules.Tiers...)
+		for i := range rules.Tiers {
+			rules.Tiers[i].PlatformFee = *rate.PlatformFee
+		}
+	}
+	result, err := bs.calculate(ctx, rules, SurveyBilling{
+		Pages:              questionCount,
+		RewardPerUser:      survey.RewardAmount,
+		Respondents:        survey.TargetResponses,
+		PriorityPlacement:  survey.PriorityPlacement,
+		DemographicFilters: survey.TargetingCriteria().Filters(),
+		ExtraDays:          survey.RunDays - BaseSurveyDays,
+		DataExport:         survey.DataExport,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if rewardPerUser < minReward || rewardPerUser > maxReward {
+	budget := survey.RewardAmount * survey.TargetResponses
+	fee := result.TotalCost - budget
+	discount := feeDiscount(terms, fee)
+	quote := &models.SurveyQuote{
+		Budget:         budget,
+		PlatformFee:    fee - discount,
+		Discount:       discount,
+		TotalCost:      result.TotalCost - discount,
+		PricingVersion: terms.Version,
+		Terms:          terms,
+	}
+	if terms.PromoCode != nil {
+		quote.PromoCode = terms.PromoCode.Code
+	}
+	return quote, nil
+}
+
+// surveyTerms returns the terms to price a survey on
+func (bs *BillingService) surveyTerms(ctx context.Context, survey *models.Survey, promoCode string) (*models.PricingTerms, error) {
+	if survey.FundedAt != nil && len(survey.PricingTerms) > 0 {
+		var terms models.PricingTerms
+		if err := json.Unmarshal(survey.PricingTerms, &terms); err != nil {
+			return nil, fmt.Errorf("invalid locked pricing terms: %w", err)
+		}
+		return &terms, nil
+	}
+
+	pricing, err := bs.Pricing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := &models.PricingTerms{Version: pricing.Version, Rules: pricing.Rules}
+	if pricing.ID != uuid.Nil {
+		terms.VersionID = &pricing.ID
+	}
+	if bs.store == nil {
+		if promoCode != "" {
+			return nil, ErrPromoCodeInvalid
+		}
+		return terms, nil
+	}
+
+	now := time.Now()
+	if terms.OrganizationRate, err = bs.store.OrganizationRate(ctx, survey.CreatorID, now); err != nil {
+		return nil, err
+	}
+	if promoCode != "" {
+		promo, err := bs.store.PromoCode(ctx, promoCode)
+		if err != nil {
+			return nil, err
+		}
+		if promo == nil || !promo.Redeemable(now) {
+			return nil, ErrPromoCodeInvalid
+		}
+		terms.PromoCode = promo
+	}
+	return terms, nil
+}
+
+// feeDiscount works out what the terms' organization rate and then promo code take off a platform fee
+func feeDiscount(terms *models.PricingTerms, fee int) int {
+	discounted := fee
+	if rate := terms.OrganizationRate; rate != nil {
+		discounted -= discounted * rate.FeeDiscountPercent / 100
+	}
+	if promo := terms.PromoCode; promo != nil {
+		discounted -= discounted*promo.PercentOff/100 + promo.AmountOff
+	}
+	return fee - max(discounted, 0)
+}
+
+// SurveyRunDays returns how many days a survey stays open once published
+func (bs *BillingService) SurveyRunDays(extraDays int) int {
+	if extraDays < 0 {
+		extraDays = 0
+	}
+	return BaseSurveyDays + extraDays
+}
+
+func (bs *BillingService) ValidateRewardRange(pages int, rewardPerUser int) error {
+	pricing, err := bs.Pricing(context.Background())
+	if err != nil {
+		return err
+	}
+	tier := pricing.Rules.Tier(pages)
+
+	if rewardPerUser < tier.MinReward || rewardPerUser > tier.MaxReward {
 		return errors.New("reward amount is outside the valid range for this survey complexity")
 	}
 
 	return nil
 }
+
+// ValidatePricingRules checks rules before they are stored: tiers in order of page count with only
+// the last open-ended, sensible reward ranges and no negative prices
+func ValidatePricingRules(rules models.PricingRules) error {
+	if len(rules.Tiers) == 0 {
+		return errors.New("at least one pricing tier is required")
+	}
+	previous := 0
+	for i, tier := range rules.Tiers {
+		last := i == len(rules.Tiers)-1
+		switch {
+		case tier.Level == "":
+			return fmt.Errorf("tier %d needs a level", i+1)
+		case last && tier.MaxPages != 0:
+			return fmt.Errorf("the last tier, %s, must cover every longer survey and have no max_pages", tier.Level)
+		case !last && tier.MaxPages <= previous:
+			return fmt.Errorf("tier %s must cover more pages than the tier before it", tier.Level)
+		case tier.PlatformFee < 0:
+			return fmt.Errorf("tier %s has a negative platform fee", tier.Level)
+		case tier.MinReward < rules.MinReward || tier.MaxReward < tier.MinReward:
+			return fmt.Errorf("tier %s has an invalid reward range", tier.Level)
+		}
+		previous = tier.MaxPages
+	}
+	if rules.MinReward <= 0 {
+		return errors.New("min_reward must be greater than 0")
+	}
+	if rules.PriorityPlacement < 0 || rules.DemographicFilter < 0 || rules.ExtraDay < 0 || rules.DataExport < 0 {
+		return errors.New("add-on prices cannot be negative")
+	}
+	if rules.PlatformFeePercentage < 0 || rules.PlatformFeePercentage > 100 {
+		return errors.New("platform_fee_percentage must be between 0 and 100")
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrPaymentNotFound is returned when Paystack reports on a credit purchase that is not on record
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentAmountMismatch is returned when a charge paid other than what its purchase was priced at
+	ErrPaymentAmountMismatch = errors.New("the amount paid does not match the purchase")
+	// ErrPaymentNotRefundable is returned when refunding a purchase that was never paid or is fully refunded
+	ErrPaymentNotRefundable = errors.New("payment cannot be refunded")
+	// ErrRefundExceedsPayment is returned when a refund is for more than is left of its purchase
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left to refund")
+	// ErrCreditsSpent is returned when the buyer no longer holds the credits a refund takes back
+	ErrCreditsSpent = errors.New("the purchased credits have already been spent")
+)
+
+// ChargeStore settles the charges Paystack reports against credit purchases
+type ChargeStore interface {
+	// SettleCharge locks the credit purchase recorded under a Paystack reference and hands it to
+	// settle, nil when none was recorded. The purchase settle returns is saved in the same
+	// transaction, recorded when it is new, and the journal it returns, if any, posted. It reports
+	// whether the journal was posted by this call, as a journal is posted once per reference.
+	SettleCharge(ctx context.Context, reference string, settle func(purchase *models.PaymentTransaction) (*models.PaymentTransaction, *models.LedgerJournal, error)) (bool, error)
+}
+
+// ChargeFulfiller grants the credits of the purchases Paystack charges. Payment verification, the
+// charge.success webhook and top-ups all land here, and the purchase journal's reference makes sure
+// the credits are granted exactly once whichever arrives first.
+type ChargeFulfiller struct {
+	store ChargeStore
+}
+
+func NewChargeFulfiller(store ChargeStore) *ChargeFulfiller {
+	return &ChargeFulfiller{store: store}
+}
+
+// Fulfil records a successful charge of amount naira and grants its purchase's credits to the
+// buyer. A purchase recorded at initialization grants the credits it was priced with, and only
+// when the amount paid is the amount it was priced at; otherwise it is marked failed and
+// ErrPaymentAmountMismatch returned. A charge with no recorded purchase, such as one made from the
+// Paystack dashboard, is recorded now for userID and grants the amount paid, or fails with
+// ErrPaymentNotFound when there is no userID. It returns the credits the purchase grants and
+// whether this call granted them.
+func (cf *ChargeFulfiller) Fulfil(ctx context.Context, reference string, userID *uuid.UUID, amount int) (int, bool, error) {
+	var credits int
+	var mismatch bool
+	granted, err := cf.store.SettleCharge(ctx, reference, func(purchase *models.PaymentTransaction) (*models.PaymentTransaction, *models.LedgerJournal, error) {
+		switch {
+		case purchase == nil:
+			if userID == nil {
+				return nil, nil, ErrPaymentNotFound
+			}
+			description := "Credit purchase"
+			purchase = &models.PaymentTransaction{
+				ID:                uuid.New(),
+				UserID:            userID,
+				Type:              models.PaymentTypePurchase,
+				Amount:            amount,
+				Credits:           amount,
+				Status:            models.PaymentStatusSuccess,
+				PaystackReference: &reference,
+				Description:       &description,
+			}
+		case purchase.UserID == nil:
+			return nil, nil, ErrPaymentNotFound
+		case purchase.Amount != amount:
+			// Kept failed so reconciliation flags what Paystack settled
+			mismatch = true
+			if purchase.Status == models.PaymentStatusPending {
+				purchase.Status = models.PaymentStatusFailed
+			}
+			return purchase, nil, nil
+		case purchase.Status == models.PaymentStatusPending, purchase.Status == models.PaymentStatusFailed:
+			// A purchase whose initialization seemed to fail may still have been paid
+			purchase.Status = models.PaymentStatusSuccess
+		}
+		credits = purchase.Credits
+		return purchase, models.PurchaseJournal(reference, *purchase.UserID, amount, purchase.Credits), nil
+	})
+	if err == nil && mismatch {
+		err = ErrPaymentAmountMismatch
+	}
+	return credits, granted, err
+}
+
+// RefundStore records refunds against credit purchases and the journals that move their credits
+type RefundStore interface {
+	// IssueRefund locks the credit purchase refund is against and hands it to issue, nil when there
+	// is none. The refund issue fills in is recorded, and the clawback journal it returns posted, in
+	// the same transaction.
+	IssueRefund(ctx context.Context, refund *models.Refund, issue func(purchase *models.RefundablePurchase) (*models.LedgerJournal, error)) error
+	// FailRefund locks an issued refund and hands it to fail with the journal that took its credits
+	// back, nil when none did. The refund is saved when fail changes its status, and the journal
+	// fail returns, if any, posted.
+	FailRefund(ctx context.Context, refundID uuid.UUID, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error
+	// FailIssuedRefund is FailRefund for the refund a refund.failed event reports on: the one issued
+	// against the purchase with Paystack's refund id, or else its oldest unsettled refund of amount.
+	// The refund is nil when none was issued.
+	FailIssuedRefund(ctx context.Context, transactionReference, refundReference string, amount int, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error
+	// RecordRefund records, once per refund reference, a refund Paystack processed against the
+	// purchase with transactionReference. It locks the purchase and hands record it, nil when there
+	// is none, and the refund issued against it that Paystack reports on, nil when none was. The
+	// refund record returns is saved, inserted when it is new, the purchase is saved with the status
+	// record gives it, and the journal record returns, if any, posted.
+	RecordRefund(ctx context.Context, transactionReference, refundReference string, amount int, record func(purchase *models.RefundablePurchase, issued *models.Refund) (*models.Refund, *models.LedgerJournal, error)) error
+}
+
+// PaymentEventStore stores the webhook events Paystack delivers and applies the charges and refunds
+// they report to credit purchases
+type PaymentEventStore interface {
+	// RecordEvent stores a webhook delivery, or counts another attempt at one already stored. It
+	// reports whether the event was already processed, in which case the delivery is a duplicate.
+	RecordEvent(ctx context.Context, event *models.PaystackEvent) (bool, error)
+	// FinishEvent marks an event processed, or records why processing failed so a redelivery retries it
+	FinishEvent(ctx context.Context, eventID uuid.UUID, processErr error) error
+	ChargeStore
+	RefundStore
+}
+
+// PurchaseRefunder refunds credit purchases. A refund takes back the credits bought with its
+// amount, bonus included, in proportion to the price paid: straight away when an admin issues it,
+// or when Paystack reports one made from its dashboard. A refund that fails gives them back.
+type PurchaseRefunder struct {
+	store RefundStore
+}
+
+func NewPurchaseRefunder(store RefundStore) *PurchaseRefunder {
+	return &PurchaseRefunder{store: store}
+}
+
+// Issue records a refund an admin issues against a credit purchase, all of what is left of it when
+// refund.Amount is zero, and takes its credits back from the creator. Credits the creator has
+// already spent, including those escrowed for surveys, cannot be refunded and fail with
+// ErrCreditsSpent. The refund stays pending until Paystack accepts it.
+func (pr *PurchaseRefunder) Issue(ctx context.Context, refund *models.Refund) error {
+	return pr.store.IssueRefund(ctx, refund, func(purchase *models.RefundablePurchase) (*models.LedgerJournal, error) {
+		if purchase == nil {
+			return nil, ErrPaymentNotFound
+		}
+		if purchase.Status != models.PaymentStatusSuccess && purchase.Status != models.PaymentStatusPartiallyRefunded {
+			return nil, ErrPaymentNotRefundable
+		}
+		remaining := purchase.Amount - purchase.Refunded
+		if refund.Amount == 0 {
+			refund.Amount = remaining
+		}
+		if refund.Amount <= 0 || refund.Amount > remaining {
+			return nil, ErrRefundExceedsPayment
+		}
+		credits := models.RefundedCredits(purchase.Amount, purchase.Credits, purchase.Refunded, refund.Amount)
+		if purchase.Balance < credits {
+			return nil, ErrCreditsSpent
+		}
+
+		refund.UserID = purchase.UserID
+		refund.PaystackReference = purchase.Reference
+		refund.Status = models.RefundStatusPending
+		return models.ClawbackJournal(models.RefundClawbackReference(refund.ID), refund.UserID, refund.Amount, credits), nil
+	})
+}
+
+// Fail fails an issued refund Paystack refused and gives its credits back
+func (pr *PurchaseRefunder) Fail(ctx context.Context, refundID uuid.UUID, reason string) error {
+	return pr.store.FailRefund(ctx, refundID, failRefund(reason))
+}
+
+// FailIssued fails the refund a refund.failed event reports on and gives its credits back
+func (pr *PurchaseRefunder) FailIssued(ctx context.Context, transactionReference, refundReference string, amount int) error {
+	return pr.store.FailIssuedRefund(ctx, transactionReference, refundReference, amount, failRefund("Paystack could not complete the refund"))
+}
+
+// failRefund marks an unsettled refund failed and restores exactly what its clawback took, by
+// reversing each of its postings
+func failRefund(reason string) func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error) {
+	return func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error) {
+		// A refund made from the Paystack dashboard can fail before anything was recorded
+		if refund == nil || refund.Status == models.RefundStatusProcessed || refund.Status == models.RefundStatusFailed {
+			return nil, nil
+		}
+		refund.Status = models.RefundStatusFailed
+		refund.LastError = &reason
+		if clawback == nil {
+			return nil, nil
+		}
+
+		restore := &models.LedgerJournal{
+			Kind:        models.JournalRefundFailed,
+			Reference:   fmt.Sprintf("refund:%s:restored", refund.ID),
+			Description: "Refund failed, credits restored",
+		}
+		for _, posting := range clawback.Postings {
+			posting.Amount = -posting.Amount
+			restore.Postings = append(restore.Postings, posting)
+		}
+		return restore, nil
+	}
+}
+
+// Record records a refund Paystack has processed against a credit purchase. A refund issued here
+// already took its credits back; one made from the Paystack dashboard takes them back now. Credits
+// already spent cannot be taken back, so it reports how many credits the buyer no longer had.
+func (pr *PurchaseRefunder) Record(ctx context.Context, transactionReference, refundReference string, amount int) (int, error) {
+	var shortfall int
+	err := pr.store.RecordRefund(ctx, transactionReference, refundReference, amount, func(purchase *models.RefundablePurchase, issued *models.Refund) (*models.Refund, *models.LedgerJournal, error) {
+		if purchase == nil {
+			return nil, nil, ErrPaymentNotFound
+		}
+
+		refund := issued
+		var clawback *models.LedgerJournal
+		if issued != nil && issued.Status != models.RefundStatusFailed {
+			issued.Status = models.RefundStatusProcessed
+			if issued.PaystackRefundID == nil {
+				issued.PaystackRefundID = &refundReference
+			}
+		} else {
+			refund = &models.Refund{
+				ID:                uuid.New(),
+				PaymentID:         purchase.ID,
+				UserID:            purchase.UserID,
+				PaystackReference: transactionReference,
+				PaystackRefundID:  &refundReference,
+				Amount:            amount,
+				Reason:            "Refunded on Paystack",
+				Status:            models.RefundStatusProcessed,
+			}
+			credits := models.RefundedCredits(purchase.Amount, purchase.Credits, purchase.Refunded, amount)
+			purchase.Refunded += amount
+
+			// What the buyer still has goes to the refunded amount first, then to the bonus
+			taken := min(credits, max(purchase.Balance, 0))
+			shortfall = credits - taken
+			if taken > 0 {
+				clawback = models.ClawbackJournal(fmt.Sprintf("payment:%s:refund:%s", transactionReference, refundReference),
+					purchase.UserID, min(amount, taken), taken)
+			}
+		}
+
+		purchase.Status = models.PaymentStatusPartiallyRefunded
+		if purchase.Refunded >= purchase.Amount {
+			purchase.Status = models.PaymentStatusRefunded
+		}
+		return refund, clawback, nil
+	})
+	return shortfall, err
+}
@@ -3,10 +3,14 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"html/template"
+	"mime/multipart"
 	"net/smtp"
+	"net/textproto"
 	"onetimer-backend/config"
+	"onetimer-backend/models"
 	"onetimer-backend/utils"
 	"strconv"
 	"strings"
@@ -165,6 +169,18 @@ func (e *EmailService) SendPaymentConfirmation(email, name string, amount int, c
 	return e.sendSMTP(ctx, email, subject, body)
 }
 
+// SendInvoice emails the invoice for a credit purchase to its buyer, with the PDF attached
+func (e *EmailService) SendInvoice(invoice *models.Invoice, pdf []byte) error {
+	ctx := context.Background()
+	subject := fmt.Sprintf("Invoice %s - Payment Received", invoice.Number)
+	body := e.getInvoiceTemplate(invoice)
+	return e.sendSMTP(ctx, invoice.CustomerEmail, subject, body, emailAttachment{
+		Filename:    "invoice-" + invoice.Number + ".pdf",
+		ContentType: "application/pdf",
+		Content:     pdf,
+	})
+}
+
 // SendWithdrawalRequest sends email when withdrawal is requested
 func (e *EmailService) SendWithdrawalRequest(email, name string, amount int) error {
 	ctx := context.Background()
@@ -212,7 +228,14 @@ func (e *EmailService) SendPasswordReset(email, resetToken string) error {
 	return e.sendSMTP(ctx, email, subject, body)
 }
 
-func (e *EmailService) sendSMTP(ctx context.Context, to, subject, body string) error {
+// emailAttachment is a file sent along with an email
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+func (e *EmailService) sendSMTP(ctx context.Context, to, subject, body string, attachments ...emailAttachment) error {
 	defer func() {
 		if r := recover(); r != nil {
 			utils.LogErrorSimple("sendSMTP panicked", "to", to, "subject", subject, "panic", r)
@@ -232,6 +255,9 @@ func (e *EmailService) sendSMTP(ctx context.Context, to, subject, body string) e
 	port := e.config.SMTPPort
 
 	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s", from, to, subject, body)
+	if len(attachments) > 0 {
+		msg = mixedMessage(from, to, subject, body, attachments)
+	}
 
 	auth := smtp.PlainAuth("", from, password, host)
 	err := smtp.SendMail(host+":"+port, auth, from, []string{to}, []byte(msg))
@@ -245,6 +271,32 @@ func (e *EmailService) sendSMTP(ctx context.Context, to, subject, body string) e
 	return nil
 }
 
+// mixedMessage builds a multipart email carrying an HTML body and attachments
+func mixedMessage(from, to, subject, body string, attachments []emailAttachment) string {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n",
+		from, to, subject, writer.Boundary())
+
+	part, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=UTF-8"}})
+	part.Write([]byte(body))
+	for _, a := range attachments {
+		part, _ := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf("attachment; filename=%q", a.Filename)},
+		})
+		encoded := base64.StdEncoding.EncodeToString(a.Content)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded))
+	}
+	writer.Close()
+	return buf.String()
+}
+
 func (e *EmailService) getWelcomeTemplate(name string) string {
 	tmpl := `
 <!DOCTYPE html>
@@ -511,6 +563,47 @@ func (e *EmailService) getPaymentConfirmationTemplate(name string, amount, credi
 	return buf.String()
 }
 
+func (e *EmailService) getInvoiceTemplate(invoice *models.Invoice) string {
+	tmpl := `
+<!DOCTYPE html>
+<html>
+<head>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #013F5C; color: white; padding: 20px; text-align: center; }
+        .content { padding: 20px; background: #f9f9f9; }
+        .total { font-size: 24px; font-weight: bold; color: #013F5C; text-align: center; padding: 20px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            <h1>🧾 Invoice {{.Number}}</h1>
+        </div>
+        <div class="content">
+            <h2>Hi {{.Name}},</h2>
+            <p>Thank you for your payment. Your invoice for <strong>{{.Description}}</strong> is attached.</p>
+            <div class="total">{{.Total}} paid</div>
+            <p>{{.Credits}} credits have been added to your account. Keep the attached invoice for your records; it includes VAT.</p>
+            <p>Thank you for using Onetime Survey!</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+	t, _ := template.New("invoice").Parse(tmpl)
+	var buf bytes.Buffer
+	t.Execute(&buf, map[string]string{
+		"Number":      invoice.Number,
+		"Name":        invoice.CustomerName,
+		"Description": invoice.Description,
+		"Total":       formatKobo(invoice.Total),
+		"Credits":     strconv.Itoa(invoice.Credits),
+	})
+	return buf.String()
+}
+
 func (e *EmailService) getWithdrawalRequestTemplate(name string, amount int) string {
 	tmpl := `
 <!DOCTYPE html>
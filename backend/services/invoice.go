@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+	"onetimer-backend/utils"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ErrPaymentNotPaid is returned when invoicing a credit purchase that has not been paid
+var ErrPaymentNotPaid = errors.New("only paid credit purchases are invoiced")
+
+// InvoiceStore issues the invoices for credit purchases and records which were emailed
+type InvoiceStore interface {
+	// IssueInvoice locks the credit purchase with paymentID and returns its invoice. When it has
+	// none yet, issue is handed the purchase, nil when there is none, and the next number in the
+	// sequence, and the invoice it returns recorded. Numbers are drawn one invoice at a time, so a
+	// purchase issue refuses takes none and the sequence has no gaps.
+	IssueInvoice(ctx context.Context, paymentID uuid.UUID, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error)
+	// IssueInvoiceForReference is IssueInvoice for the purchase with a Paystack reference
+	IssueInvoiceForReference(ctx context.Context, reference string, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error)
+	// ClaimInvoiceEmail marks an invoice emailed, reporting whether it had not been yet
+	ClaimInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) (bool, error)
+	// ReleaseInvoiceEmail clears an invoice's emailed mark, so it is sent again
+	ReleaseInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) error
+}
+
+// InvoiceMailer emails an invoice to its buyer
+type InvoiceMailer interface {
+	SendInvoice(invoice *models.Invoice, pdf []byte) error
+}
+
+// Invoicer issues the tax invoices for paid credit purchases as issuer, and emails each to its
+// buyer once
+type Invoicer struct {
+	store  InvoiceStore
+	mailer InvoiceMailer
+	issuer models.InvoiceIssuer
+}
+
+func NewInvoicer(store InvoiceStore, mailer InvoiceMailer, issuer models.InvoiceIssuer) *Invoicer {
+	return &Invoicer{store: store, mailer: mailer, issuer: issuer}
+}
+
+// Invoice returns the invoice for one of a buyer's credit purchases, issuing it when it has none
+// yet. Another buyer's purchase fails with ErrPaymentNotFound and is never invoiced on their
+// behalf; an unpaid one fails with ErrPaymentNotPaid.
+func (iv *Invoicer) Invoice(ctx context.Context, userID, paymentID uuid.UUID) (*models.Invoice, error) {
+	invoice, err := iv.store.IssueInvoice(ctx, paymentID, iv.issue(&userID))
+	if err == nil && invoice.UserID != userID {
+		return nil, ErrPaymentNotFound
+	}
+	return invoice, err
+}
+
+// Send issues the invoice for a purchase whose credits were just granted and emails it to the
+// buyer, unless it already was. Failing to invoice never fails the payment, so it is only logged;
+// the invoice can still be downloaded, and a failed email is sent again the next time.
+func (iv *Invoicer) Send(ctx context.Context, reference string) {
+	invoice, err := iv.store.IssueInvoiceForReference(ctx, reference, iv.issue(nil))
+	if err != nil {
+		utils.LogError(ctx, "Failed to issue invoice", err, "reference", reference)
+		return
+	}
+	claimed, err := iv.store.ClaimInvoiceEmail(ctx, invoice.ID)
+	if err != nil {
+		utils.LogError(ctx, "Failed to claim invoice email", err, "invoice", invoice.Number)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	pdf, err := RenderInvoice(invoice)
+	if err == nil {
+		err = iv.mailer.SendInvoice(invoice, pdf)
+	}
+	if err != nil {
+		utils.LogError(ctx, "⚠️ Failed to email invoice", err, "invoice", invoice.Number)
+		if err := iv.store.ReleaseInvoiceEmail(ctx, invoice.ID); err != nil {
+			utils.LogError(ctx, "Failed to release invoice email", err, "invoice", invoice.Number)
+		}
+		return
+	}
+	utils.LogInfo(ctx, "✅ Invoice emailed", "invoice", invoice.Number, "email", invoice.CustomerEmail)
+}
+
+// issue invoices a paid purchase with the number it is handed, refusing one bought by anyone but
+// buyer, when given
+func (iv *Invoicer) issue(buyer *uuid.UUID) func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error) {
+	return func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error) {
+		if purchase == nil || (buyer != nil && purchase.UserID != *buyer) {
+			return nil, ErrPaymentNotFound
+		}
+		switch purchase.Status {
+		case models.PaymentStatusSuccess, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+		default:
+			return nil, ErrPaymentNotPaid
+		}
+
+		invoice := &models.Invoice{
+			ID:               uuid.New(),
+			Number:           models.InvoiceNumber(sequence),
+			Sequence:         sequence,
+			PaymentID:        purchase.ID,
+			UserID:           purchase.UserID,
+			CustomerName:     purchase.CustomerName,
+			CustomerEmail:    purchase.CustomerEmail,
+			CustomerAddress:  purchase.CustomerAddress,
+			OrganizationName: purchase.OrganizationName,
+			OrganizationType: purchase.OrganizationType,
+			SellerName:       iv.issuer.Name,
+			SellerAddress:    iv.issuer.Address,
+			SellerTaxID:      iv.issuer.TaxID,
+			Description:      "Credit purchase",
+			Credits:          purchase.Credits,
+			Reference:        purchase.Reference,
+			VATRate:          iv.issuer.VATRate,
+			Total:            purchase.Amount * 100,
+			PaidAt:           purchase.PaidAt,
+		}
+		if purchase.Description != nil && *purchase.Description != "" {
+			invoice.Description = *purchase.Description
+		}
+		invoice.Subtotal, invoice.VAT = models.SplitVAT(invoice.Total, invoice.VATRate)
+		return invoice, nil
+	}
+}
+
+// RenderInvoice lays an invoice out as a PDF tax invoice and receipt
+func RenderInvoice(invoice *models.Invoice) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(20, 20, 20)
+	pdf.AddPage()
+
+	// Seller
+	pdf.SetFont("Arial", "B", 18)
+	pdf.CellFormat(100, 9, tr(invoice.SellerName), "", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(70, 9, "TAX INVOICE / RECEIPT", "", 1, "R", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	if invoice.SellerAddress != "" {
+		pdf.MultiCell(100, 5, tr(invoice.SellerAddress), "", "L", false)
+	}
+	if invoice.SellerTaxID != "" {
+		pdf.CellFormat(100, 5, "TIN: "+tr(invoice.SellerTaxID), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// Invoice details and buyer
+	top := pdf.GetY()
+	details := [][2]string{
+		{"Invoice no.", invoice.Number},
+		{"Issued", invoice.IssuedAt.Format("2 January 2006")},
+		{"Paid", invoice.PaidAt.Format("2 January 2006")},
+		{"Reference", invoice.Reference},
+	}
+	pdf.SetXY(110, top)
+	for _, row := range details {
+		pdf.SetX(110)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(25, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.CellFormat(55, 6, tr(row[1]), "", 1, "L", false, 0, "")
+	}
+	detailsBottom := pdf.GetY()
+
+	pdf.SetXY(20, top)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(85, 6, "Billed to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	buyer := []string{}
+	if invoice.OrganizationName != nil && *invoice.OrganizationName != "" {
+		buyer = append(buyer, *invoice.OrganizationName)
+		buyer = append(buyer, "Attn: "+invoice.CustomerName)
+	} else {
+		buyer = append(buyer, invoice.CustomerName)
+	}
+	if invoice.CustomerAddress != nil && *invoice.CustomerAddress != "" {
+		buyer = append(buyer, *invoice.CustomerAddress)
+	}
+	buyer = append(buyer, invoice.CustomerEmail)
+	for _, line := range buyer {
+		pdf.CellFormat(85, 6, tr(line), "", 1, "L", false, 0, "")
+	}
+	pdf.SetY(max(pdf.GetY(), detailsBottom) + 8)
+
+	// Line item
+	pdf.SetFillColor(1, 63, 92)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(95, 8, "Description", "", 0, "L", true, 0, "")
+	pdf.CellFormat(30, 8, "Credits", "", 0, "R", true, 0, "")
+	pdf.CellFormat(45, 8, "Amount", "", 1, "R", true, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(95, 8, tr(invoice.Description), "B", 0, "L", false, 0, "")
+	pdf.CellFormat(30, 8, groupThousands(strconv.Itoa(invoice.Credits)), "B", 0, "R", false, 0, "")
+	pdf.CellFormat(45, 8, formatKobo(invoice.Subtotal), "B", 1, "R", false, 0, "")
+	pdf.Ln(2)
+
+	// Totals
+	totals := [][2]string{
+		{"Subtotal", formatKobo(invoice.Subtotal)},
+		{fmt.Sprintf("VAT (%s%%)", strconv.FormatFloat(invoice.VATRate, 'f', -1, 64)), formatKobo(invoice.VAT)},
+		{"Total paid", formatKobo(invoice.Total)},
+	}
+	for i, row := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Arial", "B", 11)
+		}
+		pdf.CellFormat(125, 7, row[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(45, 7, row[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(10)
+
+	pdf.SetFont("Arial", "I", 9)
+	pdf.MultiCell(170, 5, "Paid in full by card through Paystack. Prices include VAT. "+
+		"Credits are held in naira and used to fund surveys on the platform.", "", "L", false)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render invoice %s: %w", invoice.Number, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formatKobo formats an amount in kobo as naira, such as NGN 13,953.49
+func formatKobo(kobo int) string {
+	sign := ""
+	if kobo < 0 {
+		sign, kobo = "-", -kobo
+	}
+	return fmt.Sprintf("%sNGN %s.%02d", sign, groupThousands(strconv.Itoa(kobo/100)), kobo%100)
+}
+
+func groupThousands(digits string) string {
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
@@ -47,6 +47,36 @@ func (n *NotificationService) NotifyCreatorSurveyApproved(creatorID uuid.UUID, s
 	return n.sendNotification(notification)
 }
 
+func (n *NotificationService) NotifyCreatorSurveyRejected(creatorID uuid.UUID, surveyTitle string, reason string) error {
+	data, _ := json.Marshal(map[string]interface{}{"survey_title": surveyTitle, "reason": reason})
+	notification := &Notification{
+		ID:      uuid.New(),
+		UserID:  creatorID,
+		Type:    "survey_rejected",
+		Title:   "Survey Rejected",
+		Message: fmt.Sprintf("Your survey '%s' was not approved: %s", surveyTitle, reason),
+		Data:    string(data),
+	}
+	return n.sendNotification(notification)
+}
+
+func (n *NotificationService) NotifyCreatorSurveyExpired(creatorID uuid.UUID, surveyTitle string, responses int, refunded int) error {
+	data, _ := json.Marshal(map[string]interface{}{"survey_title": surveyTitle, "responses": responses, "refunded": refunded})
+	message := fmt.Sprintf("Your survey '%s' has closed with %d responses", surveyTitle, responses)
+	if refunded > 0 {
+		message += fmt.Sprintf(". ₦%d of unused budget was returned to your credits", refunded)
+	}
+	notification := &Notification{
+		ID:      uuid.New(),
+		UserID:  creatorID,
+		Type:    "survey_expired",
+		Title:   "Survey Closed",
+		Message: message,
+		Data:    string(data),
+	}
+	return n.sendNotification(notification)
+}
+
 func (n *NotificationService) NotifyFillerKYCApproved(fillerID uuid.UUID) error {
 	notification := &Notification{
 		ID:      uuid.New(),
@@ -58,6 +88,32 @@ func (n *NotificationService) NotifyFillerKYCApproved(fillerID uuid.UUID) error
 	return n.sendNotification(notification)
 }
 
+func (n *NotificationService) NotifyFillerResponseApproved(fillerID uuid.UUID, surveyTitle string, amount int) error {
+	data, _ := json.Marshal(map[string]interface{}{"survey_title": surveyTitle, "amount": amount})
+	notification := &Notification{
+		ID:      uuid.New(),
+		UserID:  fillerID,
+		Type:    "response_approved",
+		Title:   "Response Approved",
+		Message: fmt.Sprintf("Your response to '%s' was approved. ₦%d is now available to withdraw", surveyTitle, amount),
+		Data:    string(data),
+	}
+	return n.sendNotification(notification)
+}
+
+func (n *NotificationService) NotifyFillerResponseRejected(fillerID uuid.UUID, surveyTitle string, reason string) error {
+	data, _ := json.Marshal(map[string]interface{}{"survey_title": surveyTitle, "reason": reason})
+	notification := &Notification{
+		ID:      uuid.New(),
+		UserID:  fillerID,
+		Type:    "response_rejected",
+		Title:   "Response Rejected",
+		Message: fmt.Sprintf("Your response to '%s' was rejected and its reward reversed: %s", surveyTitle, reason),
+		Data:    string(data),
+	}
+	return n.sendNotification(notification)
+}
+
 func (n *NotificationService) NotifyAdminNewSurvey(adminID uuid.UUID, surveyTitle string, creatorName string) error {
 	notification := &Notification{
 		ID:      uuid.New(),
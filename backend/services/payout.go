@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+	"onetimer-backend/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	payoutBatchSize     = 50
+	maxPayoutAttempts   = 5
+	payoutRetryBackoff  = 5 * time.Minute
+	payoutSettleTimeout = time.Hour
+	payoutReason        = "OneTimer withdrawal"
+)
+
+// ErrPayoutNotAwaitingOTP is returned when finalizing a withdrawal whose transfer is not held for an OTP
+var ErrPayoutNotAwaitingOTP = errors.New("payout is not awaiting an OTP")
+
+// PayoutStore keeps the withdrawals the payout worker pays out
+type PayoutStore interface {
+	// ClaimPayouts moves approved withdrawals that are due, limited to ids when any are given, to
+	// processing and counts the attempt. Withdrawals left processing without a transfer code by an
+	// interrupted run are claimed again.
+	ClaimPayouts(ctx context.Context, ids []uuid.UUID, limit int) ([]models.Withdrawal, error)
+	// UnsettledTransfers lists processing transfers Paystack has not reported on for olderThan
+	UnsettledTransfers(ctx context.Context, olderThan time.Duration, limit int) ([]models.Withdrawal, error)
+	// TransferRecipient returns the cached recipient code for a bank account, or "" when there is none
+	TransferRecipient(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (string, error)
+	SaveTransferRecipient(ctx context.Context, recipient *models.TransferRecipient) error
+	// MarkTransferInitiated records the transfer code of a transfer Paystack accepted and its status
+	MarkTransferInitiated(ctx context.Context, id uuid.UUID, transferCode, status string) error
+	// RetryPayout returns a claimed withdrawal to approved, to be claimed again at the given time
+	RetryPayout(ctx context.Context, id uuid.UUID, reason string, at time.Time) error
+	// FailPayout fails a withdrawal and returns its amount to the filler's wallet
+	FailPayout(ctx context.Context, id uuid.UUID, reason string) (*models.Withdrawal, error)
+	CompleteTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error)
+}
+
+// TransferStore settles the withdrawals whose transfers Paystack reports on
+type TransferStore interface {
+	// CompleteTransfer settles a withdrawal whose transfer succeeded
+	CompleteTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error)
+	// FailTransfer returns the amount of a withdrawal whose transfer failed or was reversed to the
+	// filler's wallet
+	FailTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error)
+}
+
+// PayoutRunResult counts what a payout run did with the withdrawals it claimed
+type PayoutRunResult struct {
+	Claimed     int `json:"claimed"`
+	Initiated   int `json:"initiated"`
+	AwaitingOTP int `json:"awaiting_otp"`
+	Completed   int `json:"completed"`
+	Failed      int `json:"failed"`
+	Retrying    int `json:"retrying"`
+}
+
+// PayoutWorker pays approved withdrawals out through Paystack transfers. Every withdrawal is
+// transferred with the same reference on each attempt, and a retry first asks Paystack whether an
+// earlier attempt got through, so a withdrawal is never paid twice. Transfers settle through the
+// transfer webhooks, or through Reconcile when a webhook never arrives.
+type PayoutWorker struct {
+	paystack *PaystackService
+	store    PayoutStore
+}
+
+func NewPayoutWorker(paystack *PaystackService, store PayoutStore) *PayoutWorker {
+	return &PayoutWorker{paystack: paystack, store: store}
+}
+
+// Run claims approved withdrawals, or only those among ids when any are given, and initiates their
+// transfers: one at a time, or as a Paystack bulk transfer when there are several
+func (pw *PayoutWorker) Run(ctx context.Context, ids []uuid.UUID) (*PayoutRunResult, error) {
+	claimed, err := pw.store.ClaimPayouts(ctx, ids, payoutBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	result := &PayoutRunResult{Claimed: len(claimed)}
+
+	var errs []error
+	var ready []models.Withdrawal
+	var transfers []TransferInitRequest
+	for i := range claimed {
+		w := &claimed[i]
+		if w.Attempts > 1 {
+			// An earlier attempt may have reached Paystack before it failed
+			transfer, err := pw.paystack.VerifyTransfer(w.TransferReference())
+			if err == nil {
+				errs = append(errs, pw.apply(ctx, w, transfer, result))
+				continue
+			}
+			if !errors.Is(err, ErrPaystackNotFound) {
+				errs = append(errs, pw.retry(ctx, w, err, result))
+				continue
+			}
+			if w.Attempts > maxPayoutAttempts {
+				errs = append(errs, pw.fail(ctx, w, fmt.Sprintf("gave up after %d attempts", maxPayoutAttempts), result))
+				continue
+			}
+		}
+
+		recipient, err := pw.recipient(ctx, w)
+		if err != nil {
+			errs = append(errs, pw.retry(ctx, w, err, result))
+			continue
+		}
+		ready = append(ready, *w)
+		transfers = append(transfers, TransferInitRequest{
+			Source:    "balance",
+			Amount:    w.Amount * 100,
+			Recipient: recipient,
+			Reason:    payoutReason,
+			Reference: w.TransferReference(),
+		})
+	}
+
+	switch len(ready) {
+	case 0:
+	case 1:
+		t := transfers[0]
+		transfer, err := pw.paystack.InitiateTransfer(t.Amount, t.Recipient, t.Reason, t.Reference)
+		if err != nil {
+			errs = append(errs, pw.retry(ctx, &ready[0], err, result))
+		} else {
+			errs = append(errs, pw.apply(ctx, &ready[0], transfer, result))
+		}
+	default:
+		initiated, err := pw.paystack.InitiateBulkTransfer(transfers)
+		byReference := make(map[string]*PaystackTransferData, len(initiated))
+		for i := range initiated {
+			byReference[initiated[i].Reference] = &initiated[i]
+		}
+		for i := range ready {
+			w := &ready[i]
+			transfer, ok := byReference[w.TransferReference()]
+			switch {
+			case err != nil:
+				errs = append(errs, pw.retry(ctx, w, err, result))
+			case !ok:
+				errs = append(errs, pw.retry(ctx, w, errors.New("missing from the bulk transfer response"), result))
+			default:
+				errs = append(errs, pw.apply(ctx, w, transfer, result))
+			}
+		}
+	}
+
+	if result.Claimed > 0 {
+		utils.LogInfo(ctx, "✅ Payout run finished", "claimed", result.Claimed, "initiated", result.Initiated,
+			"awaiting_otp", result.AwaitingOTP, "completed", result.Completed, "failed", result.Failed, "retrying", result.Retrying)
+	}
+	return result, errors.Join(errs...)
+}
+
+// Reconcile asks Paystack about transfers that have been processing for too long, settling those
+// whose webhook was missed
+func (pw *PayoutWorker) Reconcile(ctx context.Context) (*PayoutRunResult, error) {
+	unsettled, err := pw.store.UnsettledTransfers(ctx, payoutSettleTimeout, payoutBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	result := &PayoutRunResult{}
+
+	var errs []error
+	for i := range unsettled {
+		w := &unsettled[i]
+		transfer, err := pw.paystack.VerifyTransfer(w.TransferReference())
+		if err != nil {
+			utils.LogWarn(ctx, "⚠️ Could not verify payout transfer", "withdrawal_id", w.ID, "error", err.Error())
+			continue
+		}
+		errs = append(errs, pw.apply(ctx, w, transfer, result))
+	}
+	return result, errors.Join(errs...)
+}
+
+// Finalize completes a withdrawal's transfer that Paystack is holding for an OTP
+func (pw *PayoutWorker) Finalize(ctx context.Context, w *models.Withdrawal, otp string) (*PayoutRunResult, error) {
+	if w.Status != models.WithdrawalStatusAwaitingOTP || w.PaystackReference == nil {
+		return nil, ErrPayoutNotAwaitingOTP
+	}
+	transfer, err := pw.paystack.FinalizeTransfer(*w.PaystackReference, otp)
+	if err != nil {
+		return nil, err
+	}
+	result := &PayoutRunResult{}
+	return result, pw.apply(ctx, w, transfer, result)
+}
+
+// recipient returns the Paystack recipient for a withdrawal's bank account, creating and caching
+// it the first time the account is paid
+func (pw *PayoutWorker) recipient(ctx context.Context, w *models.Withdrawal) (string, error) {
+	code, err := pw.store.TransferRecipient(ctx, w.UserID, w.BankCode, w.AccountNumber)
+	if err != nil || code != "" {
+		return code, err
+	}
+	code, err = pw.paystack.CreateTransferRecipient(w.AccountName, w.AccountNumber, w.BankCode)
+	if err != nil {
+		return "", err
+	}
+	return code, pw.store.SaveTransferRecipient(ctx, &models.TransferRecipient{
+		ID:            uuid.New(),
+		UserID:        w.UserID,
+		BankCode:      w.BankCode,
+		AccountNumber: w.AccountNumber,
+		AccountName:   w.AccountName,
+		RecipientCode: code,
+	})
+}
+
+// apply records the status Paystack reported for a withdrawal's transfer
+func (pw *PayoutWorker) apply(ctx context.Context, w *models.Withdrawal, transfer *PaystackTransferData, result *PayoutRunResult) error {
+	switch transfer.Status {
+	case "success":
+		result.Completed++
+		_, err := pw.store.CompleteTransfer(ctx, w.TransferReference(), transfer.TransferCode)
+		return err
+	case "failed", "reversed", "abandoned", "rejected", "blocked":
+		return pw.fail(ctx, w, "Paystack transfer "+transfer.Status, result)
+	case "otp":
+		result.AwaitingOTP++
+		return pw.store.MarkTransferInitiated(ctx, w.ID, transfer.TransferCode, models.WithdrawalStatusAwaitingOTP)
+	default:
+		result.Initiated++
+		return pw.store.MarkTransferInitiated(ctx, w.ID, transfer.TransferCode, models.WithdrawalStatusProcessing)
+	}
+}
+
+// retry schedules another attempt at a withdrawal, backing off exponentially. The attempt after the
+// last allowed one only checks whether any earlier attempt got through before failing it.
+func (pw *PayoutWorker) retry(ctx context.Context, w *models.Withdrawal, cause error, result *PayoutRunResult) error {
+	utils.LogWarn(ctx, "⚠️ Payout attempt failed", "withdrawal_id", w.ID, "attempt", w.Attempts, "error", cause.Error())
+	result.Retrying++
+	backoff := payoutRetryBackoff << min(w.Attempts-1, maxPayoutAttempts)
+	return pw.store.RetryPayout(ctx, w.ID, cause.Error(), time.Now().Add(backoff))
+}
+
+func (pw *PayoutWorker) fail(ctx context.Context, w *models.Withdrawal, reason string, result *PayoutRunResult) error {
+	utils.LogWarn(ctx, "⚠️ Payout failed, returning funds to wallet", "withdrawal_id", w.ID, "reason", reason)
+	result.Failed++
+	_, err := pw.store.FailPayout(ctx, w.ID, reason)
+	return err
+}
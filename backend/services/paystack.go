@@ -3,10 +3,15 @@ package services
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"onetimer-backend/utils"
 	"time"
 )
@@ -17,9 +22,10 @@ type PaystackService struct {
 }
 
 type PaystackInitRequest struct {
-	Email  string `json:"email"`
-	Amount int    `json:"amount"` // in kobo (e.g., 50000 = ₦500)
-	Ref    string `json:"reference,omitempty"`
+	Email    string                 `json:"email"`
+	Amount   int                    `json:"amount"` // in kobo (e.g., 50000 = ₦500)
+	Ref      string                 `json:"reference,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // echoed back in the charge.success webhook
 }
 
 type PaystackInitResponse struct {
@@ -36,37 +42,126 @@ type PaystackVerifyResponse struct {
 	Status  bool   `json:"status"`
 	Message string `json:"message"`
 	Data    struct {
-		Reference     string `json:"reference"`
-		Amount        int    `json:"amount"`
-		Status        string `json:"status"`
-		PaidAt        string `json:"paid_at"`
-		Authorization struct {
-			AuthorizationCode string `json:"authorization_code"`
-			Bin               string `json:"bin"`
-			Last4             string `json:"last4"`
-			ExpMonth          string `json:"exp_month"`
-			ExpYear           string `json:"exp_year"`
-			Brand             string `json:"brand"`
-		} `json:"authorization"`
+		Reference     string                 `json:"reference"`
+		Amount        int                    `json:"amount"`
+		Status        string                 `json:"status"`
+		PaidAt        string                 `json:"paid_at"`
+		Metadata      map[string]interface{} `json:"metadata"`
+		Authorization PaystackAuthorization  `json:"authorization"`
+		Customer      struct {
+			Email string `json:"email"`
+		} `json:"customer"`
 	} `json:"data"`
 }
 
+// PaystackAuthorization is the card a transaction was paid with. A reusable authorization can be
+// charged again with its code, which must be kept secret.
+type PaystackAuthorization struct {
+	AuthorizationCode string `json:"authorization_code"`
+	Bin               string `json:"bin"`
+	Last4             string `json:"last4"`
+	ExpMonth          string `json:"exp_month"`
+	ExpYear           string `json:"exp_year"`
+	CardType          string `json:"card_type"`
+	Bank              string `json:"bank"`
+	Brand             string `json:"brand"`
+	Reusable          bool   `json:"reusable"`
+	Signature         string `json:"signature"`
+}
+
+// ErrPaystackNotFound is returned when Paystack has no record of what was looked up
+var ErrPaystackNotFound = errors.New("paystack: not found")
+
 type TransferInitRequest struct {
 	Source    string `json:"source"` // "balance"
 	Reason    string `json:"reason,omitempty"`
-	Amount    int    `json:"amount"`
-	Recipient int    `json:"recipient"`
+	Amount    int    `json:"amount"` // in kobo
+	Recipient string `json:"recipient"`
 	Reference string `json:"reference,omitempty"`
 }
 
-type TransferInitResponse struct {
-	Status  bool   `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		Reference    string `json:"reference"`
-		TransferCode string `json:"transfer_code"`
-		Status       string `json:"status"`
-	} `json:"data"`
+// BulkTransferRequest initiates several transfers in one call; it needs OTP disabled on the account
+type BulkTransferRequest struct {
+	Currency  string                `json:"currency"`
+	Source    string                `json:"source"`
+	Transfers []TransferInitRequest `json:"transfers"`
+}
+
+type TransferRecipientRequest struct {
+	Type          string `json:"type"` // "nuban"
+	Name          string `json:"name"`
+	AccountNumber string `json:"account_number"`
+	BankCode      string `json:"bank_code"`
+	Currency      string `json:"currency"`
+}
+
+// ChargeAuthorizationRequest charges a saved card without the customer at a checkout
+type ChargeAuthorizationRequest struct {
+	Email             string                 `json:"email"`
+	Amount            int                    `json:"amount"` // in kobo
+	AuthorizationCode string                 `json:"authorization_code"`
+	Reference         string                 `json:"reference"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type RefundRequest struct {
+	Transaction  string `json:"transaction"`      // the reference of the transaction to refund
+	Amount       int    `json:"amount,omitempty"` // in kobo; the whole transaction when zero
+	MerchantNote string `json:"merchant_note,omitempty"`
+}
+
+// paystackResponse is the envelope of every Paystack API response
+type paystackResponse struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// PaystackWebhookEvent is the envelope of every webhook delivery
+type PaystackWebhookEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// PaystackChargeData is the data of a charge.* event; amounts are in kobo
+type PaystackChargeData struct {
+	Reference string                 `json:"reference"`
+	Amount    int                    `json:"amount"`
+	Currency  string                 `json:"currency"`
+	Status    string                 `json:"status"`
+	PaidAt    string                 `json:"paid_at"`
+	Metadata  map[string]interface{} `json:"metadata"`
+	// GatewayResponse explains a declined charge, such as "Insufficient Funds"
+	GatewayResponse string `json:"gateway_response"`
+	Customer        struct {
+		Email string `json:"email"`
+	} `json:"customer"`
+}
+
+// PaystackTransferData is the data of a transfer.* event; amounts are in kobo
+type PaystackTransferData struct {
+	Reference    string `json:"reference"`
+	TransferCode string `json:"transfer_code"`
+	Amount       int    `json:"amount"`
+	Status       string `json:"status"`
+	Reason       string `json:"reason"`
+}
+
+// PaystackRefundData is the data of a refund.* event; amounts are in kobo
+type PaystackRefundData struct {
+	ID                   int64  `json:"id"`
+	RefundReference      string `json:"refund_reference"`
+	TransactionReference string `json:"transaction_reference"`
+	Amount               int    `json:"amount"`
+	Status               string `json:"status"`
+}
+
+// Reference identifies the refund, falling back to Paystack's refund id when it carries no reference
+func (d PaystackRefundData) Reference() string {
+	if d.RefundReference != "" {
+		return d.RefundReference
+	}
+	return fmt.Sprintf("%d", d.ID)
 }
 
 func NewPaystackService(secretKey string) *PaystackService {
@@ -76,13 +171,34 @@ func NewPaystackService(secretKey string) *PaystackService {
 	}
 }
 
+// WithBaseURL points the service at another Paystack API, such as a local fake for testing
+func (ps *PaystackService) WithBaseURL(baseURL string) *PaystackService {
+	if baseURL != "" {
+		ps.baseURL = baseURL
+	}
+	return ps
+}
+
+// VerifyWebhookSignature checks the x-paystack-signature header: the hex HMAC-SHA512 of the raw
+// request body keyed with the secret key
+func (ps *PaystackService) VerifyWebhookSignature(body []byte, signature string) bool {
+	if ps.secretKey == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha512.New, []byte(ps.secretKey))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
 // InitializeTransaction initializes a payment transaction
-func (ps *PaystackService) InitializeTransaction(email string, amount int, reference string) (*PaystackInitResponse, error) {
+func (ps *PaystackService) InitializeTransaction(email string, amount int, reference string, metadata map[string]interface{}) (*PaystackInitResponse, error) {
 	ctx := context.Background()
 	req := PaystackInitRequest{
-		Email:  email,
-		Amount: amount,
-		Ref:    reference,
+		Email:    email,
+		Amount:   amount,
+		Ref:      reference,
+		Metadata: metadata,
 	}
 
 	data, err := json.Marshal(req)
@@ -169,57 +285,224 @@ func (ps *PaystackService) VerifyTransaction(reference string) (*PaystackVerifyR
 	return &result, nil
 }
 
-// InitiateTransfer initiates a transfer/withdrawal
-func (ps *PaystackService) InitiateTransfer(amount int, recipientID int, reason string, reference string) (*TransferInitResponse, error) {
+// call sends a request to the Paystack API and decodes the data of a successful response into out
+func (ps *PaystackService) call(method, path string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	httpReq, err := http.NewRequest(method, ps.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ps.secretKey))
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrPaystackNotFound
+	}
+
+	var result paystackResponse
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("paystack: unreadable response (HTTP %d)", resp.StatusCode)
+	}
+	if !result.Status {
+		return fmt.Errorf("paystack error: %s", result.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// CreateTransferRecipient registers a bank account as a transfer recipient and returns its code
+func (ps *PaystackService) CreateTransferRecipient(name, accountNumber, bankCode string) (string, error) {
 	ctx := context.Background()
-	req := TransferInitRequest{
+	var data struct {
+		RecipientCode string `json:"recipient_code"`
+	}
+	err := ps.call("POST", "/transferrecipient", TransferRecipientRequest{
+		Type:          "nuban",
+		Name:          name,
+		AccountNumber: accountNumber,
+		BankCode:      bankCode,
+		Currency:      "NGN",
+	}, &data)
+	if err != nil {
+		utils.LogError(ctx, "Failed to create Paystack transfer recipient", err, "bank_code", bankCode)
+		return "", err
+	}
+
+	utils.LogInfo(ctx, "✅ Paystack transfer recipient created", "recipient_code", data.RecipientCode, "bank_code", bankCode)
+	return data.RecipientCode, nil
+}
+
+// InitiateTransfer transfers an amount in kobo from the Paystack balance to a recipient. Paystack
+// answers with the transfer's status: "otp" when it awaits finalization, "pending" or "success".
+func (ps *PaystackService) InitiateTransfer(amount int, recipientCode string, reason string, reference string) (*PaystackTransferData, error) {
+	ctx := context.Background()
+	var result PaystackTransferData
+	err := ps.call("POST", "/transfer", TransferInitRequest{
 		Source:    "balance",
 		Amount:    amount,
-		Recipient: recipientID,
+		Recipient: recipientCode,
 		Reason:    reason,
 		Reference: reference,
+	}, &result)
+	if err != nil {
+		utils.LogError(ctx, "Paystack transfer failed", err, "amount", amount, "reference", reference)
+		return nil, err
 	}
 
-	data, err := json.Marshal(req)
+	utils.LogInfo(ctx, "✅ Paystack transfer initiated", "reference", result.Reference, "amount", amount, "status", result.Status)
+	return &result, nil
+}
+
+// InitiateBulkTransfer initiates several transfers in one request and returns each one's transfer
+// code and status
+func (ps *PaystackService) InitiateBulkTransfer(transfers []TransferInitRequest) ([]PaystackTransferData, error) {
+	ctx := context.Background()
+	var results []PaystackTransferData
+	err := ps.call("POST", "/transfer/bulk", BulkTransferRequest{
+		Currency:  "NGN",
+		Source:    "balance",
+		Transfers: transfers,
+	}, &results)
 	if err != nil {
-		utils.LogError(ctx, "Failed to marshal transfer request", err, "amount", amount, "recipient_id", recipientID)
+		utils.LogError(ctx, "Paystack bulk transfer failed", err, "count", len(transfers))
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequest("POST", fmt.Sprintf("%s/transfer", ps.baseURL), bytes.NewBuffer(data))
+	utils.LogInfo(ctx, "✅ Paystack bulk transfer initiated", "count", len(results))
+	return results, nil
+}
+
+// FinalizeTransfer completes a transfer Paystack is holding for an OTP
+func (ps *PaystackService) FinalizeTransfer(transferCode, otp string) (*PaystackTransferData, error) {
+	ctx := context.Background()
+	var result PaystackTransferData
+	err := ps.call("POST", "/transfer/finalize_transfer", map[string]string{
+		"transfer_code": transferCode,
+		"otp":           otp,
+	}, &result)
 	if err != nil {
-		utils.LogError(ctx, "Failed to create transfer request", err, "amount", amount, "recipient_id", recipientID)
+		utils.LogError(ctx, "Failed to finalize Paystack transfer", err, "transfer_code", transferCode)
 		return nil, err
 	}
 
-	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ps.secretKey))
-	httpReq.Header.Set("Content-Type", "application/json")
+	utils.LogInfo(ctx, "✅ Paystack transfer finalized", "transfer_code", transferCode, "status", result.Status)
+	return &result, nil
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		utils.LogError(ctx, "Paystack transfer API call failed", err, "amount", amount, "recipient_id", recipientID)
+// VerifyTransfer looks a transfer up by its reference, failing with ErrPaystackNotFound when
+// Paystack never received it
+func (ps *PaystackService) VerifyTransfer(reference string) (*PaystackTransferData, error) {
+	var result PaystackTransferData
+	if err := ps.call("GET", "/transfer/verify/"+url.PathEscape(reference), nil, &result); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &result, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// CreateRefund refunds an amount in kobo of a transaction. Paystack answers with the refund
+// pending and reports its outcome through the refund webhooks.
+func (ps *PaystackService) CreateRefund(transactionReference string, amount int, note string) (*PaystackRefundData, error) {
+	ctx := context.Background()
+	var result PaystackRefundData
+	err := ps.call("POST", "/refund", RefundRequest{
+		Transaction:  transactionReference,
+		Amount:       amount,
+		MerchantNote: note,
+	}, &result)
 	if err != nil {
-		utils.LogError(ctx, "Failed to read transfer response", err, "amount", amount)
+		utils.LogError(ctx, "Paystack refund failed", err, "reference", transactionReference, "amount", amount)
 		return nil, err
 	}
 
-	var result TransferInitResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		utils.LogError(ctx, "Failed to parse transfer response", err, "amount", amount, "recipient_id", recipientID)
+	utils.LogInfo(ctx, "✅ Paystack refund created", "reference", transactionReference, "refund_id", result.ID, "status", result.Status)
+	return &result, nil
+}
+
+// ChargeAuthorization charges a saved card an amount in kobo. Paystack answers with the charge's
+// status: success, failed, or pending while the bank decides, when the charge.success webhook
+// reports it later.
+func (ps *PaystackService) ChargeAuthorization(email string, amount int, authorizationCode, reference string, metadata map[string]interface{}) (*PaystackChargeData, error) {
+	ctx := context.Background()
+	var result PaystackChargeData
+	err := ps.call("POST", "/transaction/charge_authorization", ChargeAuthorizationRequest{
+		Email:             email,
+		Amount:            amount,
+		AuthorizationCode: authorizationCode,
+		Reference:         reference,
+		Metadata:          metadata,
+	}, &result)
+	if err != nil {
+		utils.LogError(ctx, "Paystack authorization charge failed", err, "reference", reference, "amount", amount)
 		return nil, err
 	}
 
-	if !result.Status {
-		utils.LogWarn(ctx, "⚠️ Paystack transfer failed", "message", result.Message, "amount", amount, "recipient_id", recipientID)
-		return nil, fmt.Errorf("paystack error: %s", result.Message)
+	utils.LogInfo(ctx, "✅ Paystack authorization charged", "reference", reference, "status", result.Status)
+	return &result, nil
+}
+
+// Listings are fetched a page at a time, up to paystackMaxPages pages
+const (
+	paystackPageSize = 100
+	paystackMaxPages = 100
+)
+
+// listPages fetches every page of a Paystack listing between two times
+func listPages[T any](ps *PaystackService, path string, from, to time.Time) ([]T, error) {
+	var all []T
+	for page := 1; page <= paystackMaxPages; page++ {
+		query := url.Values{}
+		query.Set("from", from.UTC().Format(time.RFC3339))
+		query.Set("to", to.UTC().Format(time.RFC3339))
+		query.Set("perPage", fmt.Sprintf("%d", paystackPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var items []T
+		if err := ps.call("GET", path+"?"+query.Encode(), nil, &items); err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if len(items) < paystackPageSize {
+			return all, nil
+		}
 	}
+	return all, fmt.Errorf("paystack: more than %d pages of %s", paystackMaxPages, path)
+}
 
-	utils.LogInfo(ctx, "✅ Paystack transfer initiated", "reference", result.Data.Reference, "amount", amount, "recipient_id", recipientID)
-	return &result, nil
+// ListTransactions returns the transactions created between two times, whatever their status
+func (ps *PaystackService) ListTransactions(from, to time.Time) ([]PaystackChargeData, error) {
+	transactions, err := listPages[PaystackChargeData](ps, "/transaction", from, to)
+	if err != nil {
+		utils.LogError(context.Background(), "Failed to list Paystack transactions", err, "from", from, "to", to)
+	}
+	return transactions, err
+}
+
+// ListTransfers returns the transfers created between two times, whatever their status
+func (ps *PaystackService) ListTransfers(from, to time.Time) ([]PaystackTransferData, error) {
+	transfers, err := listPages[PaystackTransferData](ps, "/transfer", from, to)
+	if err != nil {
+		utils.LogError(context.Background(), "Failed to list Paystack transfers", err, "from", from, "to", to)
+	}
+	return transfers, err
 }
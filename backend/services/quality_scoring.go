@@ -0,0 +1,328 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"onetimer-backend/models"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxQualityScore = 10
+
+// QualityInput is everything a quality check may look at for one submission
+type QualityInput struct {
+	Survey    *models.Survey
+	Questions []models.Question // questions shown to the filler, in order
+	Answers   map[string]interface{}
+	Duration  time.Duration // time from session start to submission, zero if unknown
+	// PeerTextAnswers holds recent open-text answers from other fillers keyed by question ID
+	PeerTextAnswers map[string][]string
+}
+
+// QualityFlag is one issue found by a check; Penalty is subtracted from the maximum score
+type QualityFlag struct {
+	Check   string `json:"check"`
+	Reason  string `json:"reason"`
+	Penalty int    `json:"penalty"`
+}
+
+// QualityCheck is a single step of the scoring pipeline
+type QualityCheck interface {
+	Name() string
+	Evaluate(input QualityInput) []QualityFlag
+}
+
+type QualityResult struct {
+	Score int           `json:"score"`
+	Flags []QualityFlag `json:"flags"`
+}
+
+// QualityScoringService runs submissions through a pipeline of checks and produces a 0-10 score
+type QualityScoringService struct {
+	checks []QualityCheck
+}
+
+// NewQualityScoringService builds a pipeline from the given checks, or the default set if none
+func NewQualityScoringService(checks ...QualityCheck) *QualityScoringService {
+	if len(checks) == 0 {
+		checks = DefaultQualityChecks()
+	}
+	return &QualityScoringService{checks: checks}
+}
+
+func DefaultQualityChecks() []QualityCheck {
+	return []QualityCheck{
+		SpeedingCheck{},
+		StraightLiningCheck{},
+		OpenTextCheck{},
+		AttentionCheck{},
+		ConsistencyCheck{},
+	}
+}
+
+func (s *QualityScoringService) Score(input QualityInput) QualityResult {
+	result := QualityResult{Score: maxQualityScore, Flags: []QualityFlag{}}
+	for _, check := range s.checks {
+		for _, flag := range check.Evaluate(input) {
+			flag.Check = check.Name()
+			result.Flags = append(result.Flags, flag)
+			result.Score -= flag.Penalty
+		}
+	}
+	if result.Score < 0 {
+		result.Score = 0
+	}
+	return result
+}
+
+// SpeedingCheck flags submissions completed far faster than the survey's estimated duration
+type SpeedingCheck struct{}
+
+func (SpeedingCheck) Name() string { return "speeding" }
+
+func (SpeedingCheck) Evaluate(input QualityInput) []QualityFlag {
+	if input.Survey == nil || input.Survey.EstimatedDuration <= 0 || input.Duration <= 0 {
+		return nil
+	}
+	estimate := time.Duration(input.Survey.EstimatedDuration) * time.Minute
+	ratio := float64(input.Duration) / float64(estimate)
+
+	reason := fmt.Sprintf("completed in %s against an estimate of %s", input.Duration.Round(time.Second), estimate)
+	switch {
+	case ratio < 0.25:
+		return []QualityFlag{{Reason: reason, Penalty: 5}}
+	case ratio < 0.5:
+		return []QualityFlag{{Reason: reason, Penalty: 2}}
+	}
+	return nil
+}
+
+// StraightLiningCheck flags identical answers across every row of a matrix or likert question, or across all rating questions
+type StraightLiningCheck struct{}
+
+func (StraightLiningCheck) Name() string { return "straight_lining" }
+
+func (StraightLiningCheck) Evaluate(input QualityInput) []QualityFlag {
+	var flags []QualityFlag
+	var ratings []float64
+
+	for _, q := range input.Questions {
+		answer := input.Answers[q.ID.String()]
+		switch q.Type {
+		case "matrix", "likert":
+			rows, ok := answer.(map[string]interface{})
+			if !ok || len(rows) < 3 {
+				continue
+			}
+			if allSame(rows) {
+				flags = append(flags, QualityFlag{Reason: fmt.Sprintf("same column chosen for every row of '%s'", q.Title), Penalty: 3})
+			}
+		case "rating":
+			if value, ok := answer.(float64); ok {
+				ratings = append(ratings, value)
+			}
+		}
+	}
+
+	if len(ratings) >= 4 {
+		same := true
+		for _, r := range ratings[1:] {
+			if r != ratings[0] {
+				same = false
+				break
+			}
+		}
+		if same {
+			flags = append(flags, QualityFlag{Reason: fmt.Sprintf("identical rating given to all %d rating questions", len(ratings)), Penalty: 3})
+		}
+	}
+	return flags
+}
+
+func allSame(values map[string]interface{}) bool {
+	var first interface{}
+	for _, v := range values {
+		if first == nil {
+			first = v
+			continue
+		}
+		if fmt.Sprint(v) != fmt.Sprint(first) {
+			return false
+		}
+	}
+	return true
+}
+
+// OpenTextCheck flags gibberish open-text answers and answers duplicated across questions or fillers
+type OpenTextCheck struct{}
+
+func (OpenTextCheck) Name() string { return "open_text" }
+
+func (OpenTextCheck) Evaluate(input QualityInput) []QualityFlag {
+	var flags []QualityFlag
+	seen := make(map[string]string)
+	penalty := 0
+
+	for _, q := range input.Questions {
+		if q.Type != "text" && q.Type != "open_ended" {
+			continue
+		}
+		text, ok := input.Answers[q.ID.String()].(string)
+		if !ok {
+			continue
+		}
+		normalized := normalizeText(text)
+		if normalized == "" {
+			continue
+		}
+
+		var reason string
+		switch {
+		case isGibberish(text):
+			reason = fmt.Sprintf("answer to '%s' looks like gibberish", q.Title)
+		case seen[normalized] != "":
+			reason = fmt.Sprintf("answer to '%s' repeats the answer to '%s'", q.Title, seen[normalized])
+		case len(normalized) >= 20 && containsText(input.PeerTextAnswers[q.ID.String()], normalized):
+			reason = fmt.Sprintf("answer to '%s' duplicates another filler's answer", q.Title)
+		}
+		seen[normalized] = q.Title
+
+		// Cap the total so one sloppy filler is not pushed to zero by text alone
+		if reason != "" && penalty < 4 {
+			flags = append(flags, QualityFlag{Reason: reason, Penalty: 2})
+			penalty += 2
+		}
+	}
+	return flags
+}
+
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func containsText(texts []string, normalized string) bool {
+	for _, t := range texts {
+		if normalizeText(t) == normalized {
+			return true
+		}
+	}
+	return false
+}
+
+var keyboardMashes = []string{"asdf", "qwer", "zxcv", "hjkl", "sdfg", "jkl;"}
+
+// isGibberish applies cheap heuristics: long vowel-less runs, repeated characters and keyboard mashing
+func isGibberish(text string) bool {
+	lower := strings.ToLower(strings.TrimSpace(text))
+	if len([]rune(lower)) < 4 {
+		return false
+	}
+
+	for _, mash := range keyboardMashes {
+		if strings.Contains(lower, mash) {
+			return true
+		}
+	}
+
+	letters, vowels, run, maxRun := 0, 0, 0, 0
+	var prev rune
+	for _, r := range lower {
+		if r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		if run > maxRun {
+			maxRun = run
+		}
+		prev = r
+
+		if unicode.IsLetter(r) {
+			letters++
+			if strings.ContainsRune("aeiou", r) {
+				vowels++
+			}
+		}
+	}
+
+	if maxRun >= 5 {
+		return true
+	}
+	if letters >= 8 && float64(vowels)/float64(letters) < 0.15 {
+		return true
+	}
+	for _, word := range strings.Fields(lower) {
+		if len(word) > 25 && !strings.Contains(word, "http") {
+			return true
+		}
+	}
+	return false
+}
+
+// AttentionCheck flags wrong answers to questions the creator marked with an expected answer
+type AttentionCheck struct{}
+
+func (AttentionCheck) Name() string { return "attention_check" }
+
+func (AttentionCheck) Evaluate(input QualityInput) []QualityFlag {
+	var flags []QualityFlag
+	for _, q := range input.Questions {
+		expected := q.ParsedSettings().ExpectedAnswer
+		if expected == nil {
+			continue
+		}
+		if !answerEquals(input.Answers[q.ID.String()], expected) {
+			flags = append(flags, QualityFlag{Reason: fmt.Sprintf("failed attention check '%s'", q.Title), Penalty: 5})
+		}
+	}
+	return flags
+}
+
+// ConsistencyCheck compares answers to question pairs the creator linked as asking the same thing
+type ConsistencyCheck struct{}
+
+func (ConsistencyCheck) Name() string { return "consistency" }
+
+func (ConsistencyCheck) Evaluate(input QualityInput) []QualityFlag {
+	var flags []QualityFlag
+	byID := make(map[string]models.Question, len(input.Questions))
+	for _, q := range input.Questions {
+		byID[q.ID.String()] = q
+	}
+
+	for _, q := range input.Questions {
+		settings := q.ParsedSettings()
+		other, ok := byID[settings.ConsistencyWith]
+		if !ok {
+			continue
+		}
+		a, aok := input.Answers[q.ID.String()]
+		b, bok := input.Answers[other.ID.String()]
+		if !aok || !bok {
+			continue
+		}
+
+		consistent := true
+		if av, ok := toFloat(a); ok {
+			if bv, ok := toFloat(b); ok {
+				if settings.ConsistencyMode == "reverse" {
+					scale := settings.Scale
+					if scale <= 0 {
+						scale = defaultRatingScale
+					}
+					bv = float64(scale+1) - bv
+				}
+				consistent = math.Abs(av-bv) <= 1
+			}
+		} else if settings.ConsistencyMode != "reverse" {
+			consistent = answerEquals(a, b)
+		}
+
+		if !consistent {
+			flags = append(flags, QualityFlag{Reason: fmt.Sprintf("answer to '%s' contradicts '%s'", q.Title, other.Title), Penalty: 3})
+		}
+	}
+	return flags
+}
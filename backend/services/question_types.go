@@ -0,0 +1,962 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"onetimer-backend/models"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Date question modes and the layout answers must use in each
+const (
+	DateModeDate     = "date"
+	DateModeTime     = "time"
+	DateModeDateTime = "datetime"
+)
+
+var dateModeLayouts = map[string]string{
+	DateModeDate:     "2006-01-02",
+	DateModeTime:     "15:04",
+	DateModeDateTime: time.RFC3339,
+}
+
+const (
+	defaultSliderMax       = 100
+	defaultMaxFiles        = 1
+	defaultMaxFileSizeMB   = 5
+	maxFilesPerAnswer      = 10
+	maxResponseFileSizeMB  = 25
+	npsPromoterThreshold   = 9
+	npsDetractorThreshold  = 6
+	formattedListSeparator = "; "
+)
+
+// DefaultLikertScale is used as the columns of a likert question that does not set its own
+var DefaultLikertScale = []string{"Strongly disagree", "Disagree", "Neutral", "Agree", "Strongly agree"}
+
+// QuestionType defines one kind of question: the settings it is configured with, what a valid
+// answer looks like, how its answers are summarised in analytics and how an answer reads in exports
+type QuestionType interface {
+	Schema() QuestionTypeSchema
+	// ValidateConfig checks a question's settings; error fields are relative to the question
+	ValidateConfig(q models.QuestionRequest) []DefinitionError
+	// ValidateAnswer checks an answer that is present; required checks happen before it is called
+	ValidateAnswer(q models.Question, answer interface{}) *AnswerError
+	// Aggregate summarises the answers given to a question, skipping any that are malformed
+	Aggregate(q models.Question, answers []interface{}) map[string]interface{}
+	// Format renders an answer as a single export cell
+	Format(q models.Question, answer interface{}) string
+}
+
+// QuestionTypeSchema describes a question type to clients building surveys
+type QuestionTypeSchema struct {
+	Type     string   `json:"type"`
+	Label    string   `json:"label"`
+	Settings []string `json:"settings"` // question fields the type is configured with
+	Answer   string   `json:"answer"`   // shape of a submitted answer
+	AliasOf  string   `json:"alias_of,omitempty"`
+}
+
+// QuestionTypeRegistry is the single list of question types used when creating surveys,
+// validating answers, computing analytics and exporting responses
+type QuestionTypeRegistry struct {
+	types   map[string]QuestionType
+	aliases map[string]string
+	order   []string
+}
+
+func NewQuestionTypeRegistry() *QuestionTypeRegistry {
+	r := &QuestionTypeRegistry{types: map[string]QuestionType{}, aliases: map[string]string{}}
+	r.Register(singleChoiceType{})
+	r.Register(multiChoiceType{})
+	r.Register(textType{})
+	r.Register(ratingType{})
+	r.Register(npsType{})
+	r.Register(sliderType{})
+	r.Register(rankingType{})
+	r.Register(dateType{})
+	r.Register(fileUploadType{})
+	r.Register(matrixType{})
+	r.Register(likertType{})
+	r.Alias("multiple_choice", "single")
+	r.Alias("open_ended", "text")
+	r.Alias("media_upload", "file_upload")
+	return r
+}
+
+// Register adds a question type, replacing any registered under the same name
+func (r *QuestionTypeRegistry) Register(t QuestionType) {
+	name := t.Schema().Type
+	if _, ok := r.types[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.types[name] = t
+}
+
+// Alias makes name an alternative spelling of a registered type
+func (r *QuestionTypeRegistry) Alias(name, target string) {
+	r.aliases[name] = target
+	r.order = append(r.order, name)
+}
+
+// Lookup returns the type for a name or alias
+func (r *QuestionTypeRegistry) Lookup(name string) (QuestionType, bool) {
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
+	t, ok := r.types[name]
+	return t, ok
+}
+
+// Schemas lists every type and alias in registration order
+func (r *QuestionTypeRegistry) Schemas() []QuestionTypeSchema {
+	schemas := make([]QuestionTypeSchema, 0, len(r.order))
+	for _, name := range r.order {
+		if target, ok := r.aliases[name]; ok {
+			schema := r.types[target].Schema()
+			schema.Type, schema.AliasOf = name, target
+			schemas = append(schemas, schema)
+			continue
+		}
+		schemas = append(schemas, r.types[name].Schema())
+	}
+	return schemas
+}
+
+// ValidateQuestions checks each question's type, type-specific settings and option randomisation
+func (r *QuestionTypeRegistry) ValidateQuestions(questions []models.QuestionRequest) []DefinitionError {
+	var errs []DefinitionError
+	for i, q := range questions {
+		field := fmt.Sprintf("questions[%d]", i)
+		t, ok := r.Lookup(q.Type)
+		if !ok {
+			errs = append(errs, DefinitionError{Field: field + ".type", Message: fmt.Sprintf("unknown question type '%s'", q.Type)})
+			continue
+		}
+		configErrs := t.ValidateConfig(q)
+		if q.RandomizeOptions && !containsString(t.Schema().Settings, "options") {
+			configErrs = append(configErrs, configErr("randomize_options", "'%s' questions have no options to randomize", q.Type))
+		}
+		for _, pinned := range q.PinnedOptions {
+			if !containsString(q.Options, pinned) {
+				configErrs = append(configErrs, configErr("pinned_options", "'%s' is not one of the options", pinned))
+			}
+		}
+		for _, err := range configErrs {
+			if err.Field != "" {
+				err.Field = field + "." + err.Field
+			} else {
+				err.Field = field
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Format renders an answer for export, falling back to its plain value for unknown types
+func (r *QuestionTypeRegistry) Format(q models.Question, answer interface{}) string {
+	if answer == nil {
+		return ""
+	}
+	if t, ok := r.Lookup(q.Type); ok {
+		return t.Format(q, answer)
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+// ResponseFilePrefix is the storage key prefix for files uploaded in answer to a question.
+// File upload answers must point under it so a filler cannot answer with an unrelated file.
+func ResponseFilePrefix(surveyID, questionID uuid.UUID) string {
+	return fmt.Sprintf("responses/%s/%s/", surveyID, questionID)
+}
+
+// ResponseFileKey builds a unique storage key for a filler's upload to a question
+func ResponseFileKey(surveyID, questionID uuid.UUID, userID, ext string) string {
+	return fmt.Sprintf("%s%s_%s%s", ResponseFilePrefix(surveyID, questionID), userID, uuid.New().String()[:8], strings.ToLower(ext))
+}
+
+// FileUploadLimits returns a file upload question's accepted extensions (empty for any),
+// file count and per-file size in bytes
+func FileUploadLimits(q models.Question) ([]string, int, int64) {
+	settings := q.ParsedSettings()
+	exts := make([]string, 0, len(settings.AllowedTypes))
+	for _, ext := range settings.AllowedTypes {
+		exts = append(exts, normalizeExt(ext))
+	}
+	maxFiles := settings.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+	sizeMB := settings.MaxSizeMB
+	if sizeMB <= 0 {
+		sizeMB = defaultMaxFileSizeMB
+	}
+	return exts, maxFiles, int64(sizeMB) * 1024 * 1024
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+func configErr(field, format string, args ...interface{}) DefinitionError {
+	return DefinitionError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// countValues tallies string answers, listing every label even when nobody chose it
+func countValues(labels []string, values []string) map[string]int {
+	counts := make(map[string]int, len(labels))
+	for _, label := range labels {
+		counts[label] = 0
+	}
+	for _, value := range values {
+		counts[value]++
+	}
+	return counts
+}
+
+func stringList(answer interface{}) ([]string, bool) {
+	items, ok := answer.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		value, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, true
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// numberSummary summarises numeric answers with their count, mean and range
+func numberSummary(answers []interface{}) (map[string]interface{}, []float64) {
+	var values []float64
+	for _, answer := range answers {
+		if value, ok := answer.(float64); ok {
+			values = append(values, value)
+		}
+	}
+	summary := map[string]interface{}{"total": len(values)}
+	if len(values) == 0 {
+		return summary, values
+	}
+	sum, lowest, highest := 0.0, values[0], values[0]
+	for _, v := range values {
+		sum += v
+		lowest = math.Min(lowest, v)
+		highest = math.Max(highest, v)
+	}
+	summary["average"] = round(sum/float64(len(values)), 2)
+	summary["min"] = lowest
+	summary["max"] = highest
+	return summary, values
+}
+
+type singleChoiceType struct{}
+
+func (singleChoiceType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "single", Label: "Single choice", Settings: []string{"options"}, Answer: "one of the options"}
+}
+
+func (singleChoiceType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	if len(q.Options) < 2 {
+		return []DefinitionError{configErr("options", "choice questions need at least two options")}
+	}
+	return nil
+}
+
+func (singleChoiceType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	value, ok := answer.(string)
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be a single option"}
+	}
+	if !containsOption(q.OptionList(), value) {
+		return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' is not one of the options", value)}
+	}
+	return nil
+}
+
+func (singleChoiceType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	var values []string
+	for _, answer := range answers {
+		if value, ok := answer.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return map[string]interface{}{"total": len(values), "counts": countValues(q.OptionList(), values)}
+}
+
+func (singleChoiceType) Format(q models.Question, answer interface{}) string {
+	return fmt.Sprintf("%v", answer)
+}
+
+type multiChoiceType struct{}
+
+func (multiChoiceType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "multi", Label: "Multiple choice", Settings: []string{"options"}, Answer: "list of distinct options"}
+}
+
+func (multiChoiceType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	return singleChoiceType{}.ValidateConfig(q)
+}
+
+func (multiChoiceType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	values, ok := answer.([]interface{})
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be a list of options"}
+	}
+	options := q.OptionList()
+	picked := make(map[string]bool, len(values))
+	for _, item := range values {
+		value, ok := item.(string)
+		if !ok {
+			return &AnswerError{Code: AnswerErrInvalidType, Message: "Each selection must be an option"}
+		}
+		if !containsOption(options, value) {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' is not one of the options", value)}
+		}
+		if picked[value] {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' was selected more than once", value)}
+		}
+		picked[value] = true
+	}
+	return nil
+}
+
+func (multiChoiceType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	var values []string
+	total := 0
+	for _, answer := range answers {
+		if picked, ok := stringList(answer); ok {
+			values = append(values, picked...)
+			total++
+		}
+	}
+	return map[string]interface{}{"total": total, "counts": countValues(q.OptionList(), values)}
+}
+
+func (multiChoiceType) Format(q models.Question, answer interface{}) string {
+	if values, ok := stringList(answer); ok {
+		return strings.Join(values, formattedListSeparator)
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+type textType struct{}
+
+func (textType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "text", Label: "Open text", Answer: fmt.Sprintf("text of at most %d characters", maxTextAnswerChars)}
+}
+
+func (textType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	return nil
+}
+
+func (textType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	value, ok := answer.(string)
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be text"}
+	}
+	if len([]rune(value)) > maxTextAnswerChars {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Answer must be at most %d characters", maxTextAnswerChars)}
+	}
+	return nil
+}
+
+func (textType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	total, chars := 0, 0
+	for _, answer := range answers {
+		if value, ok := answer.(string); ok {
+			total++
+			chars += len([]rune(value))
+		}
+	}
+	summary := map[string]interface{}{"total": total}
+	if total > 0 {
+		summary["average_length"] = round(float64(chars)/float64(total), 1)
+	}
+	return summary
+}
+
+func (textType) Format(q models.Question, answer interface{}) string {
+	return fmt.Sprintf("%v", answer)
+}
+
+type ratingType struct{}
+
+func (ratingType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "rating", Label: "Rating", Settings: []string{"scale"}, Answer: fmt.Sprintf("whole number from 1 to scale (default %d)", defaultRatingScale)}
+}
+
+func (ratingType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	if q.Scale < 0 {
+		return []DefinitionError{configErr("scale", "must not be negative")}
+	}
+	return nil
+}
+
+func (ratingType) scale(q models.Question) int {
+	if scale := q.ParsedSettings().Scale; scale > 0 {
+		return scale
+	}
+	return defaultRatingScale
+}
+
+func (t ratingType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	value, ok := answer.(float64)
+	if !ok || value != math.Trunc(value) {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Rating must be a whole number"}
+	}
+	scale := t.scale(q)
+	if value < 1 || value > float64(scale) {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Rating must be between 1 and %d", scale)}
+	}
+	return nil
+}
+
+func (t ratingType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	summary, values := numberSummary(answers)
+	distribution := make(map[string]int, t.scale(q))
+	for i := 1; i <= t.scale(q); i++ {
+		distribution[strconv.Itoa(i)] = 0
+	}
+	for _, v := range values {
+		distribution[formatNumber(v)]++
+	}
+	summary["distribution"] = distribution
+	return summary
+}
+
+func (ratingType) Format(q models.Question, answer interface{}) string {
+	if value, ok := answer.(float64); ok {
+		return formatNumber(value)
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+// npsType is a Net Promoter Score question: how likely, from 0 to 10, the filler is to recommend something
+type npsType struct{}
+
+func (npsType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "nps", Label: "Net Promoter Score", Answer: "whole number from 0 to 10"}
+}
+
+func (npsType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	return nil
+}
+
+func (npsType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	value, ok := answer.(float64)
+	if !ok || value != math.Trunc(value) {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Score must be a whole number"}
+	}
+	if value < 0 || value > 10 {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: "Score must be between 0 and 10"}
+	}
+	return nil
+}
+
+// Aggregate reports promoters (9-10), passives (7-8) and detractors (0-6), and the score:
+// the percentage of promoters minus the percentage of detractors
+func (npsType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	summary, values := numberSummary(answers)
+	promoters, passives, detractors := 0, 0, 0
+	for _, v := range values {
+		switch {
+		case v >= npsPromoterThreshold:
+			promoters++
+		case v <= npsDetractorThreshold:
+			detractors++
+		default:
+			passives++
+		}
+	}
+	summary["promoters"] = promoters
+	summary["passives"] = passives
+	summary["detractors"] = detractors
+	if len(values) > 0 {
+		summary["score"] = round(float64(promoters-detractors)*100/float64(len(values)), 1)
+	}
+	return summary
+}
+
+func (npsType) Format(q models.Question, answer interface{}) string {
+	return ratingType{}.Format(q, answer)
+}
+
+type sliderType struct{}
+
+func (sliderType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "slider", Label: "Slider", Settings: []string{"min", "max", "step"}, Answer: fmt.Sprintf("number from min to max in steps of step (default 0 to %d in steps of 1)", defaultSliderMax)}
+}
+
+// bounds applies the slider defaults to unset settings
+func (sliderType) bounds(minValue, maxValue, step float64) (float64, float64, float64) {
+	if minValue == 0 && maxValue == 0 {
+		maxValue = defaultSliderMax
+	}
+	if step == 0 {
+		step = 1
+	}
+	return minValue, maxValue, step
+}
+
+func (t sliderType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	minValue, maxValue, step := t.bounds(q.Min, q.Max, q.Step)
+	var errs []DefinitionError
+	if maxValue <= minValue {
+		errs = append(errs, configErr("max", "must be greater than min"))
+	} else if step < 0 || step > maxValue-minValue {
+		errs = append(errs, configErr("step", "must be positive and no larger than the range"))
+	}
+	return errs
+}
+
+func (t sliderType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	settings := q.ParsedSettings()
+	minValue, maxValue, step := t.bounds(settings.Min, settings.Max, settings.Step)
+	value, ok := answer.(float64)
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be a number"}
+	}
+	if value < minValue || value > maxValue {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Answer must be between %s and %s", formatNumber(minValue), formatNumber(maxValue))}
+	}
+	steps := (value - minValue) / step
+	if math.Abs(steps-math.Round(steps)) > 1e-9 {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Answer must be in steps of %s", formatNumber(step))}
+	}
+	return nil
+}
+
+func (sliderType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	summary, values := numberSummary(answers)
+	if len(values) > 0 {
+		sort.Float64s(values)
+		middle := len(values) / 2
+		median := values[middle]
+		if len(values)%2 == 0 {
+			median = (values[middle-1] + values[middle]) / 2
+		}
+		summary["median"] = median
+	}
+	return summary
+}
+
+func (sliderType) Format(q models.Question, answer interface{}) string {
+	return ratingType{}.Format(q, answer)
+}
+
+// rankingType asks the filler to put every option in order of preference, first being most preferred
+type rankingType struct{}
+
+func (rankingType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "ranking", Label: "Ranking", Settings: []string{"options"}, Answer: "every option exactly once, most preferred first"}
+}
+
+func (rankingType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	if len(q.Options) < 2 {
+		return []DefinitionError{configErr("options", "ranking questions need at least two options")}
+	}
+	return nil
+}
+
+func (rankingType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	values, ok := stringList(answer)
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be a list of options in ranked order"}
+	}
+	options := q.OptionList()
+	ranked := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !containsOption(options, value) {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' is not one of the options", value)}
+		}
+		if ranked[value] {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' was ranked more than once", value)}
+		}
+		ranked[value] = true
+	}
+	if len(values) < len(options) {
+		return &AnswerError{Code: AnswerErrIncomplete, Message: "Every option must be ranked"}
+	}
+	return nil
+}
+
+// Aggregate reports each option's average position (1 is first) and how often it was ranked first
+func (rankingType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	options := q.OptionList()
+	positions := make(map[string]int, len(options))
+	first := countValues(options, nil)
+	total := 0
+	for _, answer := range answers {
+		values, ok := stringList(answer)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		total++
+		first[values[0]]++
+		for i, value := range values {
+			positions[value] += i + 1
+		}
+	}
+
+	averages := make(map[string]float64, len(positions))
+	if total > 0 {
+		for option, sum := range positions {
+			averages[option] = round(float64(sum)/float64(total), 2)
+		}
+	}
+	return map[string]interface{}{"total": total, "average_rank": averages, "ranked_first": first}
+}
+
+func (rankingType) Format(q models.Question, answer interface{}) string {
+	if values, ok := stringList(answer); ok {
+		return strings.Join(values, " > ")
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+// dateType accepts a date (2006-01-02), a time of day (15:04) or an RFC 3339 timestamp
+type dateType struct{}
+
+func (dateType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "date", Label: "Date / time", Settings: []string{"date_mode", "min_date", "max_date"}, Answer: "YYYY-MM-DD, HH:MM or an RFC 3339 timestamp, depending on date_mode"}
+}
+
+func (dateType) layout(mode string) (string, bool) {
+	if mode == "" {
+		mode = DateModeDate
+	}
+	layout, ok := dateModeLayouts[mode]
+	return layout, ok
+}
+
+func (t dateType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	layout, ok := t.layout(q.DateMode)
+	if !ok {
+		return []DefinitionError{configErr("date_mode", "must be date, time or datetime")}
+	}
+	var errs []DefinitionError
+	var bounds [2]time.Time
+	for i, bound := range []struct{ field, value string }{{"min_date", q.MinDate}, {"max_date", q.MaxDate}} {
+		if bound.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(layout, bound.value)
+		if err != nil {
+			errs = append(errs, configErr(bound.field, "must use the format %s", layout))
+			continue
+		}
+		bounds[i] = parsed
+	}
+	if !bounds[0].IsZero() && !bounds[1].IsZero() && bounds[1].Before(bounds[0]) {
+		errs = append(errs, configErr("max_date", "must not be before min_date"))
+	}
+	return errs
+}
+
+func (t dateType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	settings := q.ParsedSettings()
+	layout, ok := t.layout(settings.DateMode)
+	if !ok {
+		return &AnswerError{Code: AnswerErrUnsupported, Message: fmt.Sprintf("Date mode '%s' is not supported", settings.DateMode)}
+	}
+	value, ok := answer.(string)
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be text"}
+	}
+	parsed, err := time.Parse(layout, value)
+	if err != nil {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: fmt.Sprintf("Answer must use the format %s", layout)}
+	}
+	if minDate, err := time.Parse(layout, settings.MinDate); err == nil && parsed.Before(minDate) {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Answer must not be before %s", settings.MinDate)}
+	}
+	if maxDate, err := time.Parse(layout, settings.MaxDate); err == nil && parsed.After(maxDate) {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("Answer must not be after %s", settings.MaxDate)}
+	}
+	return nil
+}
+
+func (t dateType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	layout, _ := t.layout(q.ParsedSettings().DateMode)
+	var earliest, latest time.Time
+	var earliestValue, latestValue string
+	total := 0
+	for _, answer := range answers {
+		value, ok := answer.(string)
+		if !ok {
+			continue
+		}
+		parsed, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if total == 0 || parsed.Before(earliest) {
+			earliest, earliestValue = parsed, value
+		}
+		if total == 0 || parsed.After(latest) {
+			latest, latestValue = parsed, value
+		}
+		total++
+	}
+	summary := map[string]interface{}{"total": total}
+	if total > 0 {
+		summary["earliest"] = earliestValue
+		summary["latest"] = latestValue
+	}
+	return summary
+}
+
+func (dateType) Format(q models.Question, answer interface{}) string {
+	return fmt.Sprintf("%v", answer)
+}
+
+// fileUploadType answers with the URLs of files uploaded for the question through the response file upload endpoint
+type fileUploadType struct{}
+
+func (fileUploadType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "file_upload", Label: "File upload", Settings: []string{"allowed_types", "max_files", "max_size_mb"}, Answer: "uploaded file URL, or a list of them when max_files is over 1"}
+}
+
+func (fileUploadType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	var errs []DefinitionError
+	if q.MaxFiles < 0 || q.MaxFiles > maxFilesPerAnswer {
+		errs = append(errs, configErr("max_files", "must be between 1 and %d", maxFilesPerAnswer))
+	}
+	if q.MaxSizeMB < 0 || q.MaxSizeMB > maxResponseFileSizeMB {
+		errs = append(errs, configErr("max_size_mb", "must be between 1 and %d", maxResponseFileSizeMB))
+	}
+	for _, ext := range q.AllowedTypes {
+		if normalizeExt(ext) == "" || strings.ContainsAny(ext, "/\\ ") {
+			errs = append(errs, configErr("allowed_types", "'%s' is not a file extension", ext))
+		}
+	}
+	return errs
+}
+
+func (fileUploadType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	urls, ok := stringList(answer)
+	if !ok {
+		url, isString := answer.(string)
+		if !isString {
+			return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be an uploaded file URL"}
+		}
+		urls = []string{url}
+	}
+
+	exts, maxFiles, _ := FileUploadLimits(q)
+	if len(urls) > maxFiles {
+		return &AnswerError{Code: AnswerErrOutOfRange, Message: fmt.Sprintf("At most %d files may be uploaded", maxFiles)}
+	}
+	prefix := ResponseFilePrefix(q.SurveyID, q.ID)
+	for _, url := range urls {
+		if !(strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")) || !strings.Contains(url, prefix) {
+			return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must be a file uploaded for this question"}
+		}
+		if len(exts) > 0 && !containsOption(exts, strings.ToLower(path.Ext(url))) {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("Files must be one of %s", strings.Join(exts, ", "))}
+		}
+	}
+	return nil
+}
+
+func (fileUploadType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	total, files := 0, 0
+	for _, answer := range answers {
+		if urls, ok := stringList(answer); ok {
+			files += len(urls)
+			total++
+		} else if _, ok := answer.(string); ok {
+			files++
+			total++
+		}
+	}
+	return map[string]interface{}{"total": total, "files": files}
+}
+
+func (fileUploadType) Format(q models.Question, answer interface{}) string {
+	if urls, ok := stringList(answer); ok {
+		return strings.Join(urls, " ")
+	}
+	return fmt.Sprintf("%v", answer)
+}
+
+// matrixType asks for one column per row, such as a satisfaction level for each of several aspects
+type matrixType struct{}
+
+func (matrixType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "matrix", Label: "Matrix", Settings: []string{"rows", "cols"}, Answer: "object mapping each row to one of the cols"}
+}
+
+func (matrixType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	if len(q.Rows) == 0 || len(q.Cols) == 0 {
+		return []DefinitionError{configErr("", "matrix questions need rows and cols")}
+	}
+	return nil
+}
+
+func (matrixType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	settings := q.ParsedSettings()
+	return validateGrid(settings.Rows, settings.Cols, q.Required, answer)
+}
+
+func (matrixType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	settings := q.ParsedSettings()
+	total, counts := aggregateGrid(settings.Rows, settings.Cols, answers)
+	return map[string]interface{}{"total": total, "counts": counts}
+}
+
+func (matrixType) Format(q models.Question, answer interface{}) string {
+	return formatGrid(q.ParsedSettings().Rows, answer)
+}
+
+// likertType is a matrix of statements rated on an agreement scale, DefaultLikertScale unless cols are set.
+// Columns score 1 upwards, so each row's mean reads on the same scale as the columns.
+type likertType struct{}
+
+func (likertType) Schema() QuestionTypeSchema {
+	return QuestionTypeSchema{Type: "likert", Label: "Likert matrix", Settings: []string{"rows", "cols"}, Answer: "object mapping each row (statement) to one of the cols"}
+}
+
+func (likertType) cols(q models.Question) []string {
+	if cols := q.ParsedSettings().Cols; len(cols) > 0 {
+		return cols
+	}
+	return DefaultLikertScale
+}
+
+func (likertType) ValidateConfig(q models.QuestionRequest) []DefinitionError {
+	var errs []DefinitionError
+	if len(q.Rows) == 0 {
+		errs = append(errs, configErr("rows", "likert questions need at least one statement"))
+	}
+	if len(q.Cols) == 1 {
+		errs = append(errs, configErr("cols", "a likert scale needs at least two points"))
+	}
+	return errs
+}
+
+func (t likertType) ValidateAnswer(q models.Question, answer interface{}) *AnswerError {
+	return validateGrid(q.ParsedSettings().Rows, t.cols(q), q.Required, answer)
+}
+
+func (t likertType) Aggregate(q models.Question, answers []interface{}) map[string]interface{} {
+	rows, cols := q.ParsedSettings().Rows, t.cols(q)
+	total, counts := aggregateGrid(rows, cols, answers)
+
+	means := make(map[string]float64, len(rows))
+	for _, row := range rows {
+		sum, n := 0, 0
+		for i, col := range cols {
+			sum += counts[row][col] * (i + 1)
+			n += counts[row][col]
+		}
+		if n > 0 {
+			means[row] = round(float64(sum)/float64(n), 2)
+		}
+	}
+	return map[string]interface{}{"total": total, "counts": counts, "means": means, "scale": cols}
+}
+
+func (likertType) Format(q models.Question, answer interface{}) string {
+	return formatGrid(q.ParsedSettings().Rows, answer)
+}
+
+func validateGrid(rows, cols []string, required bool, answer interface{}) *AnswerError {
+	values, ok := answer.(map[string]interface{})
+	if !ok {
+		return &AnswerError{Code: AnswerErrInvalidType, Message: "Answer must map each row to a column"}
+	}
+	for row, col := range values {
+		if !containsOption(rows, row) {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("'%s' is not a row of this matrix", row)}
+		}
+		value, ok := col.(string)
+		if !ok || !containsOption(cols, value) {
+			return &AnswerError{Code: AnswerErrInvalidOption, Message: fmt.Sprintf("Row '%s' must be answered with one of the columns", row)}
+		}
+	}
+	if required {
+		for _, row := range rows {
+			if _, ok := values[row]; !ok {
+				return &AnswerError{Code: AnswerErrIncomplete, Message: fmt.Sprintf("Row '%s' is unanswered", row)}
+			}
+		}
+	}
+	return nil
+}
+
+// aggregateGrid counts, for every row, how often each column was chosen
+func aggregateGrid(rows, cols []string, answers []interface{}) (int, map[string]map[string]int) {
+	counts := make(map[string]map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row] = countValues(cols, nil)
+	}
+	total := 0
+	for _, answer := range answers {
+		values, ok := answer.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		total++
+		for row, col := range values {
+			value, ok := col.(string)
+			if !ok {
+				continue
+			}
+			if counts[row] == nil {
+				counts[row] = map[string]int{}
+			}
+			counts[row][value]++
+		}
+	}
+	return total, counts
+}
+
+// formatGrid writes "row: column" pairs in row order, followed by any rows the question does not list
+func formatGrid(rows []string, answer interface{}) string {
+	values, ok := answer.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("%v", answer)
+	}
+	var extra []string
+	for row := range values {
+		if len(rows) == 0 || !containsOption(rows, row) {
+			extra = append(extra, row)
+		}
+	}
+	sort.Strings(extra)
+	order := append(append([]string{}, rows...), extra...)
+
+	var parts []string
+	for _, row := range order {
+		if col, ok := values[row]; ok {
+			parts = append(parts, fmt.Sprintf("%s: %v", row, col))
+		}
+	}
+	return strings.Join(parts, formattedListSeparator)
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"onetimer-backend/models"
+	"onetimer-backend/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReconciliationStore provides our side of a reconciliation and keeps its reports
+type ReconciliationStore interface {
+	// InternalRecords returns the credit purchases and withdrawal transfers recorded between two
+	// times, along with any carrying one of references whenever they were recorded
+	InternalRecords(ctx context.Context, from, to time.Time, references []string) ([]models.SettlementRecord, error)
+	HasReconciliation(ctx context.Context, from, to time.Time, source string) (bool, error)
+	SaveReconciliation(ctx context.Context, report *models.ReconciliationReport) error
+}
+
+// Reconciler matches our payments and withdrawals against what Paystack settled and stores the
+// discrepancies as a report for finance to resolve
+type Reconciler struct {
+	paystack *PaystackService
+	store    ReconciliationStore
+}
+
+// NewReconciler takes a nil Paystack service when no key is configured; only uploaded settlement
+// files can then be reconciled
+func NewReconciler(paystack *PaystackService, store ReconciliationStore) *Reconciler {
+	return &Reconciler{paystack: paystack, store: store}
+}
+
+// ErrPaystackUnavailable is returned when reconciling against the Paystack API without a key
+var ErrPaystackUnavailable = errors.New("paystack is not configured")
+
+// FetchPaystack lists the transactions and transfers Paystack recorded between two times
+func (rc *Reconciler) FetchPaystack(from, to time.Time) ([]models.SettlementRecord, error) {
+	if rc.paystack == nil {
+		return nil, ErrPaystackUnavailable
+	}
+	transactions, err := rc.paystack.ListTransactions(from, to)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := rc.paystack.ListTransfers(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]models.SettlementRecord, 0, len(transactions)+len(transfers))
+	for _, t := range transactions {
+		records = append(records, models.SettlementRecord{
+			Kind: models.SettlementKindCharge, Reference: t.Reference, Amount: t.Amount / 100,
+			Status: t.Status, Settled: t.Status == "success",
+		})
+	}
+	for _, t := range transfers {
+		records = append(records, models.SettlementRecord{
+			Kind: models.SettlementKindTransfer, Reference: t.Reference, Amount: t.Amount / 100,
+			Status: t.Status, Settled: t.Status == "success",
+		})
+	}
+	return records, nil
+}
+
+// Run reconciles a period against Paystack's records of it and saves the report
+func (rc *Reconciler) Run(ctx context.Context, from, to time.Time, source string, paystack []models.SettlementRecord, createdBy *uuid.UUID) (*models.ReconciliationReport, error) {
+	references := make([]string, 0, len(paystack))
+	for _, r := range paystack {
+		references = append(references, r.Reference)
+	}
+	internal, err := rc.store.InternalRecords(ctx, from, to, references)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, items := ReconcileRecords(internal, paystack)
+	report := &models.ReconciliationReport{
+		ID:            uuid.New(),
+		PeriodStart:   from,
+		PeriodEnd:     to,
+		Source:        source,
+		Status:        models.ReconciliationReportClean,
+		Matched:       matched,
+		Issues:        len(items),
+		InternalTotal: settledTotal(internal),
+		PaystackTotal: settledTotal(paystack),
+		CreatedBy:     createdBy,
+		Items:         items,
+	}
+	if len(items) > 0 {
+		report.Status = models.ReconciliationReportNeedsReview
+	}
+	for i := range report.Items {
+		report.Items[i].ReportID = report.ID
+	}
+	if err := rc.store.SaveReconciliation(ctx, report); err != nil {
+		return nil, err
+	}
+
+	utils.LogInfo(ctx, "✅ Reconciliation finished", "report_id", report.ID, "source", source,
+		"from", from, "to", to, "matched", matched, "issues", len(items))
+	return report, nil
+}
+
+// RunDaily reconciles a day against the Paystack API unless it already has been, returning nil then
+func (rc *Reconciler) RunDaily(ctx context.Context, day time.Time) (*models.ReconciliationReport, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	done, err := rc.store.HasReconciliation(ctx, from, to, models.ReconciliationSourcePaystack)
+	if err != nil || done {
+		return nil, err
+	}
+	records, err := rc.FetchPaystack(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Run(ctx, from, to, models.ReconciliationSourcePaystack, records, nil)
+}
+
+func settledTotal(records []models.SettlementRecord) int {
+	total := 0
+	for _, r := range records {
+		if r.Settled {
+			total += r.Amount
+		}
+	}
+	return total
+}
+
+// ReconcileRecords matches our records against Paystack's by kind and reference. It returns how
+// many matched and an open item for every discrepancy. Records neither side settled, such as
+// abandoned checkouts, only matter when the other side settled them.
+func ReconcileRecords(internal, paystack []models.SettlementRecord) (int, []models.ReconciliationItem) {
+	type pair struct{ internal, paystack []models.SettlementRecord }
+	byKey := map[string]*pair{}
+	entry := func(r models.SettlementRecord) *pair {
+		key := r.Kind + "|" + r.Reference
+		if byKey[key] == nil {
+			byKey[key] = &pair{}
+		}
+		return byKey[key]
+	}
+	for _, r := range internal {
+		p := entry(r)
+		p.internal = append(p.internal, r)
+	}
+	for _, r := range paystack {
+		p := entry(r)
+		p.paystack = append(p.paystack, r)
+	}
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	matched := 0
+	items := []models.ReconciliationItem{}
+	for _, key := range keys {
+		p := byKey[key]
+		var ours, theirs *models.SettlementRecord
+		if len(p.internal) > 0 {
+			ours = &p.internal[0]
+		}
+		if len(p.paystack) > 0 {
+			theirs = &p.paystack[0]
+		}
+		sample := ours
+		if sample == nil {
+			sample = theirs
+		}
+		item := func(issue, detail string) models.ReconciliationItem {
+			it := models.ReconciliationItem{
+				ID:        uuid.New(),
+				Kind:      sample.Kind,
+				Reference: sample.Reference,
+				Issue:     issue,
+				Detail:    detail,
+				Status:    models.ReconciliationItemOpen,
+			}
+			if ours != nil {
+				it.InternalAmount = &ours.Amount
+			}
+			if theirs != nil {
+				it.PaystackAmount = &theirs.Amount
+			}
+			return it
+		}
+
+		switch {
+		case len(p.paystack) > 1:
+			items = append(items, item(models.ReconciliationDuplicate, fmt.Sprintf("%d Paystack records", len(p.paystack))))
+		case len(p.internal) > 1:
+			items = append(items, item(models.ReconciliationDuplicate, fmt.Sprintf("%d internal records", len(p.internal))))
+		case ours == nil:
+			if theirs.Settled {
+				items = append(items, item(models.ReconciliationMissingInternal, "Paystack settled it but it is not on record"))
+			}
+		case theirs == nil:
+			if ours.Settled {
+				items = append(items, item(models.ReconciliationMissingPaystack, "settled on record but unknown to Paystack"))
+			}
+		case ours.Settled != theirs.Settled:
+			items = append(items, item(models.ReconciliationStatusMismatch,
+				fmt.Sprintf("%s on record, %s on Paystack", ours.Status, theirs.Status)))
+		case ours.Settled && ours.Amount != theirs.Amount:
+			items = append(items, item(models.ReconciliationAmountMismatch,
+				fmt.Sprintf("₦%d on record, ₦%d on Paystack", ours.Amount, theirs.Amount)))
+		default:
+			matched++
+		}
+	}
+	return matched, items
+}
+
+// ParseSettlementCSV reads a Paystack settlement or transactions export. It needs a reference and an
+// amount column, in naira; a type column tells transfers from charges, which are the default, and a
+// status column marks what settled, which is everything when there is none.
+func ParseSettlementCSV(r io.Reader) ([]models.SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("settlement file has no header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "reference", "transaction reference", "transaction_reference", "transfer reference":
+			columns["reference"] = i
+		case "amount", "amount paid", "transaction amount":
+			columns["amount"] = i
+		case "type", "kind", "transaction type":
+			columns["type"] = i
+		case "status", "transaction status":
+			columns["status"] = i
+		}
+	}
+	if _, ok := columns["reference"]; !ok {
+		return nil, errors.New("settlement file needs a reference column")
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, errors.New("settlement file needs an amount column")
+	}
+	field := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []models.SettlementRecord
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		reference := field(row, "reference")
+		if reference == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(strings.NewReplacer(",", "", "₦", "", "NGN", "").Replace(field(row, "amount")), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, field(row, "amount"))
+		}
+
+		kind := models.SettlementKindCharge
+		if strings.Contains(strings.ToLower(field(row, "type")), "transfer") {
+			kind = models.SettlementKindTransfer
+		}
+		status := strings.ToLower(field(row, "status"))
+		if status == "" {
+			status = "success"
+		}
+		records = append(records, models.SettlementRecord{
+			Kind:      kind,
+			Reference: reference,
+			Amount:    int(math.Round(amount)),
+			Status:    status,
+			Settled:   status == "success" || status == "successful" || status == "settled",
+		})
+	}
+	return records, nil
+}
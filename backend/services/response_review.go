@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrAlreadyReviewed is returned when reviewing a response that is not pending review
+var ErrAlreadyReviewed = errors.New("response has already been reviewed")
+
+// ReviewStore settles creators' reviews of submitted responses
+type ReviewStore interface {
+	// SettleReview locks a submitted response and hands decide its review status, nil when it was
+	// never held for review. The outcome decide returns is applied in the same transaction: the
+	// response and its held earning move to their new states, and a response that is no longer
+	// counted comes off the survey's count, reopening a survey it had filled.
+	SettleReview(ctx context.Context, surveyID, responseID uuid.UUID, note *string, decide func(reviewStatus *string) (*models.ReviewOutcome, error)) (*models.ReviewedResponse, error)
+	// ApprovePendingReviews approves the responses still pending review that were submitted before
+	// cutoff and releases their earnings
+	ApprovePendingReviews(ctx context.Context, cutoff time.Time) ([]models.ReviewedResponse, error)
+}
+
+// ResponseReviewer moves responses held for review to approved, releasing the filler's earning, or
+// to rejected, reversing it and no longer counting the response
+type ResponseReviewer struct {
+	store ReviewStore
+}
+
+func NewResponseReviewer(store ReviewStore) *ResponseReviewer {
+	return &ResponseReviewer{store: store}
+}
+
+// Review records a creator's decision on a response pending review. It fails with
+// ErrAlreadyReviewed when the response was reviewed before or never held for review.
+func (rr *ResponseReviewer) Review(ctx context.Context, surveyID, responseID uuid.UUID, decision string, note *string) (*models.ReviewedResponse, error) {
+	outcome := models.ReviewOutcome{
+		ReviewStatus:   models.ReviewStatusApproved,
+		ResponseStatus: models.ResponseStatusCompleted,
+		EarningStatus:  models.EarningStatusAvailable,
+	}
+	if decision == models.ReviewStatusRejected {
+		outcome = models.ReviewOutcome{
+			ReviewStatus:   models.ReviewStatusRejected,
+			ResponseStatus: models.ResponseStatusRejected,
+			EarningStatus:  models.EarningStatusReversed,
+			Uncounted:      true,
+		}
+	}
+
+	return rr.store.SettleReview(ctx, surveyID, responseID, note, func(reviewStatus *string) (*models.ReviewOutcome, error) {
+		if reviewStatus == nil || *reviewStatus != models.ReviewStatusPending {
+			return nil, ErrAlreadyReviewed
+		}
+		return &outcome, nil
+	})
+}
+
+// AutoApprove approves responses left pending review for longer than window, returning them so
+// their fillers can be notified
+func (rr *ResponseReviewer) AutoApprove(ctx context.Context, window time.Duration) ([]models.ReviewedResponse, error) {
+	return rr.store.ApprovePendingReviews(ctx, time.Now().Add(-window))
+}
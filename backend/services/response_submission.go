@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"onetimer-backend/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSurveyClosed   = errors.New("survey is no longer accepting responses")
+	ErrSegmentFull    = errors.New("filler's demographic segment quota is full")
+	ErrVersionChanged = errors.New("survey was edited while the response was being prepared")
+)
+
+// SubmissionStore records submitted responses
+type SubmissionStore interface {
+	// RecordSubmission locks the survey and the filler's session and hands admit the survey's state,
+	// counting the filler's full segments among quotas. Only when admit accepts the response does it
+	// record the response with its question timings and, unless the response is rejected, count it
+	// and create its earning, all in one transaction.
+	RecordSubmission(ctx context.Context, response *models.Response, earning *models.Earning, answered []uuid.UUID, quotas []models.SegmentQuota, admit func(*models.SubmissionState) error) error
+}
+
+// ResponseSubmitter admits submitted responses to surveys. The rules are checked on the state the
+// store reads under its locks, so concurrent submissions cannot overfill a survey or a segment.
+type ResponseSubmitter struct {
+	store SubmissionStore
+}
+
+func NewResponseSubmitter(store SubmissionStore) *ResponseSubmitter {
+	return &ResponseSubmitter{store: store}
+}
+
+// Submit records a response and its earning. It fails with ErrSurveyClosed when the survey is not
+// active, has reached its target or has expired, ErrVersionChanged when the survey was edited since
+// the answers were validated, and ErrSegmentFull when one of the filler's quotas is full.
+func (rs *ResponseSubmitter) Submit(ctx context.Context, response *models.Response, earning *models.Earning, answered []uuid.UUID, quotas []models.SegmentQuota) error {
+	return rs.store.RecordSubmission(ctx, response, earning, answered, quotas, func(state *models.SubmissionState) error {
+		return admitResponse(state, response.SurveyVersion, time.Now())
+	})
+}
+
+func admitResponse(state *models.SubmissionState, version int, now time.Time) error {
+	// The scheduler closes expired surveys periodically; don't accept responses in the gap
+	if state.SurveyStatus != models.SurveyStatusActive ||
+		(state.TargetResponses > 0 && state.CurrentResponses >= state.TargetResponses) ||
+		(state.ExpiresAt != nil && now.After(*state.ExpiresAt)) {
+		return ErrSurveyClosed
+	}
+	// Answers were validated against one version's questions; an edit since then invalidates that
+	if state.Version != version {
+		return ErrVersionChanged
+	}
+	if len(state.FullSegments) > 0 {
+		return ErrSegmentFull
+	}
+	return nil
+}
@@ -51,12 +51,22 @@ func (r *RoleCommunicationService) ApproveSurvey(adminID, surveyID uuid.UUID) er
 		return err
 	}
 
-	// Update survey status
-	_, err = r.db.Exec(context.Background(),
-		`UPDATE surveys SET status = 'approved', updated_at = NOW() WHERE id = $1`, surveyID)
+	// Publish the survey and record the transition; only surveys awaiting review can be approved
+	tag, err := r.db.Exec(context.Background(),
+		`WITH published AS (
+			UPDATE surveys SET status = $1, published_at = COALESCE(published_at, NOW()), updated_at = NOW()
+			WHERE id = $2 AND status = $3
+			RETURNING id
+		)
+		INSERT INTO survey_status_history (id, survey_id, from_status, to_status, actor_id, created_at)
+		SELECT $4, id, $3, $1, $5, NOW() FROM published`,
+		models.SurveyStatusActive, surveyID, models.SurveyStatusPendingReview, uuid.New(), adminID)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("survey %s is not awaiting review", surveyID)
+	}
 
 	// Log audit
 	r.logAudit(adminID, "survey_approved", "survey", surveyID, map[string]interface{}{
@@ -87,25 +97,22 @@ func (r *RoleCommunicationService) ApproveFillerKYC(adminID, fillerID uuid.UUID)
 	return r.notification.NotifyFillerKYCApproved(fillerID)
 }
 
+// ProcessWithdrawal approves a pending withdrawal; the payout worker transfers it and settles it
+// once Paystack confirms the transfer
 func (r *RoleCommunicationService) ProcessWithdrawal(adminID, withdrawalID uuid.UUID) error {
-	// Get withdrawal details
 	var userID uuid.UUID
 	var amount int
-	err := r.db.QueryRow(context.Background(),
-		`SELECT user_id, amount FROM withdrawals WHERE id = $1`, withdrawalID).Scan(&userID, &amount)
-	if err != nil {
-		return err
-	}
-
-	// Update withdrawal status
-	_, err = r.db.Exec(context.Background(),
-		`UPDATE withdrawals SET status = 'completed', processed_at = NOW() WHERE id = $1`, withdrawalID)
+	err := r.db.QueryRow(context.Background(), `
+		UPDATE withdrawals SET status = $1, approved_by = $2, approved_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4
+		RETURNING user_id, amount`,
+		models.WithdrawalStatusApproved, adminID, withdrawalID, models.WithdrawalStatusPending).Scan(&userID, &amount)
 	if err != nil {
 		return err
 	}
 
 	// Log audit
-	r.logAudit(adminID, "withdrawal_processed", "withdrawal", withdrawalID, map[string]interface{}{
+	r.logAudit(adminID, "withdrawal_approved", "withdrawal", withdrawalID, map[string]interface{}{
 		"amount":  amount,
 		"user_id": userID,
 	})
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"onetimer-backend/utils"
+	"time"
+)
+
+// Scheduler runs recurring background jobs inside the API process, each on its own interval
+type Scheduler struct {
+	jobs []scheduledJob
+}
+
+type scheduledJob struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Every registers a job that runs when the scheduler starts and then once per interval
+func (s *Scheduler) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, scheduledJob{name: name, interval: interval, run: run})
+}
+
+// Start launches every registered job; they stop when ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+	utils.LogInfo(ctx, "✅ Scheduler started", "jobs", len(s.jobs))
+}
+
+func (s *Scheduler) loop(ctx context.Context, job scheduledJob) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		s.runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs a job, logging failures and recovering panics so one bad run doesn't stop the loop
+func (s *Scheduler) runOnce(ctx context.Context, job scheduledJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogError(ctx, "⚠️ Scheduled job panicked", fmt.Errorf("%v", r), "job", job.name)
+		}
+	}()
+
+	if err := job.run(ctx); err != nil {
+		utils.LogError(ctx, "⚠️ Scheduled job failed", err, "job", job.name)
+	}
+}
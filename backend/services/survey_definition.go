@@ -0,0 +1,264 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"onetimer-backend/models"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Survey definition serialisations
+const (
+	DefinitionFormatJSON = "json"
+	DefinitionFormatYAML = "yaml"
+)
+
+// SurveyDefinitionService converts surveys to and from the portable definition format
+type SurveyDefinitionService struct {
+	targets      *TargetingService
+	types        *QuestionTypeRegistry
+	translations *TranslationService
+}
+
+type DefinitionError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type DefinitionValidationError struct {
+	Errors []DefinitionError `json:"errors"`
+}
+
+func (e *DefinitionValidationError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", err.Field, err.Message))
+	}
+	return strings.Join(messages, ", ")
+}
+
+func NewSurveyDefinitionService() *SurveyDefinitionService {
+	return &SurveyDefinitionService{targets: NewTargetingService(), types: NewQuestionTypeRegistry(), translations: NewTranslationService()}
+}
+
+// DefinitionFormat picks the serialisation from a file name, content type or format name, defaulting to JSON
+func DefinitionFormat(hint string) string {
+	hint = strings.ToLower(hint)
+	if strings.Contains(hint, "yaml") || hint == "yml" || strings.HasSuffix(hint, ".yml") {
+		return DefinitionFormatYAML
+	}
+	return DefinitionFormatJSON
+}
+
+// Decode parses a definition, rejecting unknown fields so typos are not silently dropped
+func (s *SurveyDefinitionService) Decode(data []byte, format string) (*models.SurveyDefinition, error) {
+	var def models.SurveyDefinition
+	if format == DefinitionFormatYAML {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&def); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("invalid YAML survey definition: %w", err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&def); err != nil {
+			return nil, fmt.Errorf("invalid JSON survey definition: %w", err)
+		}
+	}
+	return &def, nil
+}
+
+// Encode writes a definition in the given format
+func (s *SurveyDefinitionService) Encode(def *models.SurveyDefinition, format string) ([]byte, error) {
+	if format == DefinitionFormatYAML {
+		return yaml.Marshal(def)
+	}
+	return json.MarshalIndent(def, "", "  ")
+}
+
+// Validate checks everything about a definition that can be checked without building the survey:
+// the format version, survey settings, question types and options, and that every reference
+// between questions points at a question in the definition
+func (s *SurveyDefinitionService) Validate(def *models.SurveyDefinition) error {
+	var errs []DefinitionError
+	addErr := func(field, format string, args ...interface{}) {
+		errs = append(errs, DefinitionError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case def.FormatVersion == 0:
+		addErr("format_version", "is required")
+	case def.FormatVersion > models.SurveyDefinitionVersion:
+		addErr("format_version", "version %d is newer than the supported version %d", def.FormatVersion, models.SurveyDefinitionVersion)
+	case def.FormatVersion < 0:
+		addErr("format_version", "must be positive")
+	}
+
+	if strings.TrimSpace(def.Title) == "" {
+		addErr("title", "is required")
+	}
+	if strings.TrimSpace(def.Description) == "" {
+		addErr("description", "is required")
+	}
+	if def.RewardAmount < 0 {
+		addErr("reward_amount", "must not be negative")
+	}
+	if def.TargetCount < 0 {
+		addErr("target_count", "must not be negative")
+	}
+	if def.EstimatedDuration < 0 {
+		addErr("estimated_duration", "must not be negative")
+	}
+	if def.MinQualityScore < 0 || def.MinQualityScore > maxQualityScore {
+		addErr("min_quality_score", "must be between 0 and %d", maxQualityScore)
+	}
+	if err := s.targets.ValidateQuotas(def.Quotas); err != nil {
+		addErr("quotas", "%s", err.Error())
+	}
+
+	errs = append(errs, s.types.ValidateQuestions(def.Questions)...)
+	req := def.SurveyRequest()
+	errs = append(errs, s.translations.Validate(&req)...)
+
+	ids := make(map[string]bool, len(def.Questions))
+	for i, q := range def.Questions {
+		field := fmt.Sprintf("questions[%d]", i)
+		if q.ID == "" {
+			continue
+		}
+		if ids[q.ID] {
+			addErr(field+".id", "'%s' is used by more than one question", q.ID)
+		}
+		ids[q.ID] = true
+	}
+
+	for i, q := range def.Questions {
+		field := fmt.Sprintf("questions[%d]", i)
+		if strings.TrimSpace(q.Title) == "" {
+			addErr(field+".title", "is required")
+		}
+		for j, rule := range q.Logic {
+			for k, cond := range rule.Conditions {
+				if !ids[cond.QuestionID] {
+					addErr(fmt.Sprintf("%s.logic[%d].conditions[%d].question_id", field, j, k), "unknown question '%s'", cond.QuestionID)
+				}
+			}
+			if rule.Target != "" && rule.Target != "end" && !ids[rule.Target] {
+				addErr(fmt.Sprintf("%s.logic[%d].target", field, j), "unknown question '%s'", rule.Target)
+			}
+		}
+		for _, text := range []struct{ name, value string }{{"title", q.Title}, {"description", q.Description}} {
+			for _, ref := range PipeReferences(text.value) {
+				if !ids[ref] {
+					addErr(field+"."+text.name, "pipes unknown question '%s'", ref)
+				}
+			}
+		}
+		if q.ConsistencyWith != "" && !ids[q.ConsistencyWith] {
+			addErr(field+".consistency_with", "unknown question '%s'", q.ConsistencyWith)
+		}
+		if q.ConsistencyMode != "" && q.ConsistencyMode != "same" && q.ConsistencyMode != "reverse" {
+			addErr(field+".consistency_mode", "must be same or reverse")
+		}
+	}
+
+	if len(errs) > 0 {
+		return &DefinitionValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// FromSurvey builds the definition of a stored survey. Questions get definition-local ids
+// (q1, q2, ...) in display order and every cross-question reference is rewritten to them.
+func (s *SurveyDefinitionService) FromSurvey(survey *models.Survey, questions []models.Question) *models.SurveyDefinition {
+	def := &models.SurveyDefinition{
+		FormatVersion:     models.SurveyDefinitionVersion,
+		Title:             survey.Title,
+		Description:       survey.Description,
+		RewardAmount:      survey.RewardAmount,
+		TargetCount:       survey.TargetResponses,
+		EstimatedDuration: survey.EstimatedDuration,
+		MinQualityScore:   survey.MinQualityScore,
+		Targeting:         survey.TargetingCriteria(),
+		Quotas:            survey.SegmentQuotas(),
+		Questions:         make([]models.QuestionRequest, 0, len(questions)),
+		Locale:            survey.Locale,
+		Translations:      survey.ParsedTranslations(),
+	}
+	if survey.Category != nil {
+		def.Category = *survey.Category
+	}
+
+	localIDs := make(map[string]string, len(questions))
+	for i, q := range questions {
+		localIDs[q.ID.String()] = fmt.Sprintf("q%d", i+1)
+	}
+	local := func(id string) string {
+		if mapped, ok := localIDs[id]; ok {
+			return mapped
+		}
+		return id
+	}
+
+	for i, q := range questions {
+		settings := q.ParsedSettings()
+		rules, _ := q.Rules()
+		for j := range rules {
+			for k := range rules[j].Conditions {
+				rules[j].Conditions[k].QuestionID = local(rules[j].Conditions[k].QuestionID)
+			}
+			if rules[j].Target != "" {
+				rules[j].Target = local(rules[j].Target)
+			}
+		}
+
+		req := models.QuestionRequest{
+			ID:               localIDs[q.ID.String()],
+			Type:             q.Type,
+			Title:            RewritePipes(q.Title, local),
+			Required:         q.Required,
+			Options:          q.OptionList(),
+			Scale:            settings.Scale,
+			Rows:             settings.Rows,
+			Cols:             settings.Cols,
+			Min:              settings.Min,
+			Max:              settings.Max,
+			Step:             settings.Step,
+			DateMode:         settings.DateMode,
+			MinDate:          settings.MinDate,
+			MaxDate:          settings.MaxDate,
+			AllowedTypes:     settings.AllowedTypes,
+			MaxFiles:         settings.MaxFiles,
+			MaxSizeMB:        settings.MaxSizeMB,
+			RandomizeOptions: settings.RandomizeOptions,
+			PinnedOptions:    settings.PinnedOptions,
+			Block:            settings.Block,
+			Order:            i + 1,
+			Logic:            rules,
+			ExpectedAnswer:   settings.ExpectedAnswer,
+			ConsistencyMode:  settings.ConsistencyMode,
+		}
+		if q.Description != nil {
+			req.Description = RewritePipes(*q.Description, local)
+		}
+		if settings.ConsistencyWith != "" {
+			req.ConsistencyWith = local(settings.ConsistencyWith)
+		}
+		if translations := q.ParsedTranslations(); len(translations) > 0 {
+			req.Translations = make(map[string]models.QuestionTranslation, len(translations))
+			for locale, t := range translations {
+				t.Title = RewritePipes(t.Title, local)
+				t.Description = RewritePipes(t.Description, local)
+				req.Translations[locale] = t
+			}
+		}
+		def.Questions = append(def.Questions, req)
+	}
+	return def
+}
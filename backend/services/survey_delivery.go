@@ -0,0 +1,218 @@
+package services
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math/rand"
+	"onetimer-backend/models"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// pipePattern matches answer piping placeholders in question titles and descriptions:
+// {{q1}} is replaced with the answer to question q1, {{q1|your car}} falls back to
+// "your car" while q1 is unanswered
+var pipePattern = regexp.MustCompile(`\{\{\s*([^{}|\s]+)\s*(?:\|([^{}]*))?\}\}`)
+
+// SurveyDeliveryService lays a survey out for one filler: it draws the randomised block and
+// option order the filler is served and resolves answer piping in question text
+type SurveyDeliveryService struct {
+	types *QuestionTypeRegistry
+}
+
+func NewSurveyDeliveryService() *SurveyDeliveryService {
+	return &SurveyDeliveryService{types: NewQuestionTypeRegistry()}
+}
+
+// PipeReferences lists the question IDs piped into text, in order of appearance
+func PipeReferences(text string) []string {
+	var refs []string
+	for _, match := range pipePattern.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// RewritePipes replaces the question ID of every placeholder in text with rewrite's result,
+// keeping fallbacks
+func RewritePipes(text string, rewrite func(ref string) string) string {
+	return pipePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := pipePattern.FindStringSubmatch(placeholder)
+		ref := rewrite(match[1])
+		if strings.Contains(placeholder, "|") {
+			return "{{" + ref + "|" + match[2] + "}}"
+		}
+		return "{{" + ref + "}}"
+	})
+}
+
+// SessionSeed derives the randomisation seed of a filler's session, so the same session always
+// draws the same order
+func SessionSeed(sessionID uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(sessionID[:8]))
+}
+
+// questionSegment is a run of consecutive questions sharing a block name; unblocked questions
+// form segments with an empty block
+type questionSegment struct {
+	block   string
+	indices []int
+}
+
+func questionSegments(questions []models.Question) []questionSegment {
+	var segments []questionSegment
+	for i, q := range questions {
+		block := q.ParsedSettings().Block
+		if n := len(segments); n > 0 && segments[n-1].block == block {
+			segments[n-1].indices = append(segments[n-1].indices, i)
+			continue
+		}
+		segments = append(segments, questionSegment{block: block, indices: []int{i}})
+	}
+	return segments
+}
+
+// ServeOrder draws the order a filler is served: named blocks are shuffled among the positions
+// blocks occupy, questions keep their order within a block and unblocked questions stay in place.
+// Options of questions with randomize_options are shuffled, pinned options keeping their position.
+func (s *SurveyDeliveryService) ServeOrder(questions []models.Question, version int, seed int64) models.ServedOrder {
+	rng := rand.New(rand.NewSource(seed))
+	segments := questionSegments(questions)
+
+	var slots []int
+	for i, segment := range segments {
+		if segment.block != "" {
+			slots = append(slots, i)
+		}
+	}
+	shuffled := append([]int{}, slots...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	placed := make(map[int]int, len(slots))
+	for i, slot := range slots {
+		placed[slot] = shuffled[i]
+	}
+
+	order := models.ServedOrder{Version: version, Questions: make([]string, 0, len(questions))}
+	for i := range segments {
+		segment := segments[i]
+		if from, ok := placed[i]; ok {
+			segment = segments[from]
+		}
+		for _, index := range segment.indices {
+			order.Questions = append(order.Questions, questions[index].ID.String())
+		}
+	}
+
+	for _, q := range questions {
+		settings := q.ParsedSettings()
+		if !settings.RandomizeOptions {
+			continue
+		}
+		if order.Options == nil {
+			order.Options = map[string][]string{}
+		}
+		order.Options[q.ID.String()] = shuffleOptions(rng, q.OptionList(), settings.PinnedOptions)
+	}
+	return order
+}
+
+// CanonicalOrder is the order of a survey served without randomisation
+func (s *SurveyDeliveryService) CanonicalOrder(questions []models.Question, version int) models.ServedOrder {
+	order := models.ServedOrder{Version: version, Questions: make([]string, 0, len(questions))}
+	for _, q := range questions {
+		order.Questions = append(order.Questions, q.ID.String())
+	}
+	return order
+}
+
+func shuffleOptions(rng *rand.Rand, options, pinned []string) []string {
+	var free []int
+	for i, option := range options {
+		if !containsString(pinned, option) {
+			free = append(free, i)
+		}
+	}
+	shuffled := append([]int{}, free...)
+	rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	served := append([]string{}, options...)
+	for i, index := range free {
+		served[index] = options[shuffled[i]]
+	}
+	return served
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Arrange returns copies of the questions in the served order with their options in served order.
+// Questions the order does not mention (it was drawn for another version) follow in their own order.
+func (s *SurveyDeliveryService) Arrange(questions []models.Question, order *models.ServedOrder) []models.Question {
+	if order == nil {
+		return questions
+	}
+	byID := make(map[string]models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID.String()] = q
+	}
+
+	arranged := make([]models.Question, 0, len(questions))
+	placed := make(map[string]bool, len(questions))
+	for _, id := range order.Questions {
+		if q, ok := byID[id]; ok && !placed[id] {
+			arranged = append(arranged, q)
+			placed[id] = true
+		}
+	}
+	for _, q := range questions {
+		if !placed[q.ID.String()] {
+			arranged = append(arranged, q)
+		}
+	}
+
+	for i, q := range arranged {
+		if options, ok := order.Options[q.ID.String()]; ok {
+			arranged[i].Options, _ = json.Marshal(options)
+		}
+	}
+	return arranged
+}
+
+// Pipe returns copies of the questions with piping placeholders in their titles and descriptions
+// replaced by the filler's answers, formatted as exports write them
+func (s *SurveyDeliveryService) Pipe(questions []models.Question, answers map[string]interface{}) []models.Question {
+	byID := make(map[string]models.Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID.String()] = q
+	}
+	resolve := func(text string) string {
+		return pipePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			match := pipePattern.FindStringSubmatch(placeholder)
+			answer, ok := answers[match[1]]
+			source, known := byID[match[1]]
+			if !ok || !known || !IsAnswered(answer) {
+				return strings.TrimSpace(match[2])
+			}
+			return s.types.Format(source, answer)
+		})
+	}
+
+	piped := make([]models.Question, len(questions))
+	for i, q := range questions {
+		q.Title = resolve(q.Title)
+		if q.Description != nil {
+			description := resolve(*q.Description)
+			q.Description = &description
+		}
+		piped[i] = q
+	}
+	return piped
+}
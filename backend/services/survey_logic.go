@@ -0,0 +1,421 @@
+package services
+
+import (
+	"fmt"
+	"onetimer-backend/models"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SurveyLogicService validates and evaluates per-question display rules (show/skip/jump_to)
+type SurveyLogicService struct{}
+
+type LogicError struct {
+	QuestionID string `json:"question_id"`
+	Message    string `json:"message"`
+}
+
+type LogicValidationError struct {
+	Errors []LogicError `json:"errors"`
+}
+
+func (e *LogicValidationError) Error() string {
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", err.QuestionID, err.Message))
+	}
+	return strings.Join(messages, ", ")
+}
+
+var validLogicOperators = map[string]bool{
+	"equals": true, "not_equals": true, "contains": true, "not_contains": true, "in": true,
+	"gt": true, "gte": true, "lt": true, "lte": true, "answered": true, "not_answered": true,
+}
+
+func NewSurveyLogicService() *SurveyLogicService {
+	return &SurveyLogicService{}
+}
+
+// Validate checks every rule of an ordered question list. Conditions may only reference
+// earlier questions and jumps may only go forward, which keeps the flow acyclic; questions
+// that no path through the survey can reach are reported as unreachable. Answer pipes must
+// reference earlier questions too, and with randomized blocks "earlier" must hold in every served order.
+func (s *SurveyLogicService) Validate(questions []models.Question) error {
+	position := make(map[string]int, len(questions))
+	for i, q := range questions {
+		position[q.ID.String()] = i
+	}
+
+	var errs []LogicError
+	addErr := func(q models.Question, format string, args ...interface{}) {
+		errs = append(errs, LogicError{QuestionID: q.ID.String(), Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Named blocks are shuffled among the positions blocks occupy, so a question is only certain to be
+	// served before another when both are in the same block or segment, or its latest possible segment
+	// precedes the other's earliest
+	segments := questionSegments(questions)
+	segmentOf := make([]int, len(questions))
+	var blockSlots []int
+	firstSegment := make(map[string]int)
+	for si, segment := range segments {
+		for _, i := range segment.indices {
+			segmentOf[i] = si
+		}
+		if segment.block == "" {
+			continue
+		}
+		blockSlots = append(blockSlots, si)
+		if _, seen := firstSegment[segment.block]; seen {
+			addErr(questions[segment.indices[0]], "questions of block '%s' must be consecutive", segment.block)
+		}
+		firstSegment[segment.block] = si
+	}
+	inBlock := func(i int) bool { return segments[segmentOf[i]].block != "" }
+	bounds := func(i int) (int, int) {
+		if inBlock(i) {
+			return blockSlots[0], blockSlots[len(blockSlots)-1]
+		}
+		return segmentOf[i], segmentOf[i]
+	}
+	servedBefore := func(r, i int) bool {
+		if segmentOf[r] == segmentOf[i] {
+			return r < i
+		}
+		_, latest := bounds(r)
+		earliest, _ := bounds(i)
+		return latest < earliest
+	}
+	// crossesBlocks reports whether a jump from i to target (end for the end of the survey) leaves i's
+	// block or passes over or into one, where the skipped questions depend on the served order
+	crossesBlocks := func(i, target int) bool {
+		if inBlock(i) {
+			return target == len(questions) || segmentOf[target] != segmentOf[i]
+		}
+		for k := i + 1; k <= target && k < len(questions); k++ {
+			if inBlock(k) {
+				return true
+			}
+		}
+		return false
+	}
+
+	end := len(questions)
+	// edges[i] lists the positions reachable directly after question i (end == len(questions))
+	edges := make([][]int, len(questions))
+	alwaysHidden := make([]bool, len(questions))
+
+	for i, q := range questions {
+		rules, err := q.Rules()
+		if err != nil {
+			addErr(q, "logic is not valid JSON")
+			edges[i] = []int{i + 1}
+			continue
+		}
+
+		for _, text := range []string{q.Title, questionDescription(q)} {
+			for _, ref := range PipeReferences(text) {
+				r, ok := position[ref]
+				switch {
+				case !ok:
+					addErr(q, "pipes unknown question '%s'", ref)
+				case !servedBefore(r, i):
+					addErr(q, "pipes question '%s' which is not answered before this one", ref)
+				}
+			}
+		}
+
+		fallsThrough := true
+		for _, rule := range rules {
+			if rule.Match != "" && rule.Match != "all" && rule.Match != "any" {
+				addErr(q, "match must be 'all' or 'any'")
+			}
+
+			for _, cond := range rule.Conditions {
+				if !validLogicOperators[cond.Operator] {
+					addErr(q, "unknown operator '%s'", cond.Operator)
+				}
+				if cond.Operator != "answered" && cond.Operator != "not_answered" && cond.Value == nil {
+					addErr(q, "operator '%s' requires a value", cond.Operator)
+				}
+
+				ref, ok := position[cond.QuestionID]
+				switch {
+				case !ok:
+					addErr(q, "condition references unknown question '%s'", cond.QuestionID)
+				case rule.Action == models.LogicActionJumpTo && ref > i:
+					addErr(q, "jump condition references later question '%s'", cond.QuestionID)
+				case rule.Action != models.LogicActionJumpTo && ref >= i:
+					addErr(q, "condition references question '%s' which is not answered before this one (cycle)", cond.QuestionID)
+				case ref != i && !servedBefore(ref, i):
+					addErr(q, "condition references question '%s' in a randomized block that may be served after this one", cond.QuestionID)
+				}
+			}
+
+			switch rule.Action {
+			case models.LogicActionShow:
+			case models.LogicActionSkip:
+				if len(rule.Conditions) == 0 {
+					alwaysHidden[i] = true
+				}
+			case models.LogicActionJumpTo:
+				target := end
+				if rule.Target != models.LogicTargetEnd {
+					t, ok := position[rule.Target]
+					if !ok {
+						addErr(q, "jump target '%s' does not exist", rule.Target)
+						continue
+					}
+					if t <= i {
+						addErr(q, "jump target '%s' must come after this question (backward jumps create cycles)", rule.Target)
+						continue
+					}
+					target = t
+				}
+				if crossesBlocks(i, target) {
+					addErr(q, "jumps cannot leave, skip over or enter a randomized block")
+					continue
+				}
+				edges[i] = append(edges[i], target)
+				if len(rule.Conditions) == 0 {
+					fallsThrough = false
+				}
+			default:
+				addErr(q, "unknown action '%s'", rule.Action)
+			}
+		}
+
+		if fallsThrough {
+			edges[i] = append(edges[i], i+1)
+		}
+	}
+
+	if len(questions) > 0 {
+		reached := make([]bool, len(questions)+1)
+		queue := []int{0}
+		reached[0] = true
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			if i == end {
+				continue
+			}
+			for _, next := range edges[i] {
+				if !reached[next] {
+					reached[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+
+		for i, q := range questions {
+			if !reached[i] || alwaysHidden[i] {
+				addErr(q, "question is unreachable")
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &LogicValidationError{Errors: errs}
+	}
+	return nil
+}
+
+func questionDescription(q models.Question) string {
+	if q.Description == nil {
+		return ""
+	}
+	return *q.Description
+}
+
+// VisibleQuestions walks the ordered questions with the filler's answers and returns the set
+// of questions that were displayed. Answers to hidden questions are ignored by later conditions.
+func (s *SurveyLogicService) VisibleQuestions(questions []models.Question, answers map[string]interface{}) map[uuid.UUID]bool {
+	visible := make(map[uuid.UUID]bool, len(questions))
+	seen := make(map[string]interface{}, len(answers))
+	jumpTo := ""
+
+	for _, q := range questions {
+		id := q.ID.String()
+		if jumpTo == models.LogicTargetEnd {
+			break
+		}
+		if jumpTo != "" && jumpTo != id {
+			continue
+		}
+		jumpTo = ""
+
+		rules, _ := q.Rules()
+		shown := true
+		for _, rule := range rules {
+			switch rule.Action {
+			case models.LogicActionShow:
+				if !matchRule(rule, seen) {
+					shown = false
+				}
+			case models.LogicActionSkip:
+				if matchRule(rule, seen) {
+					shown = false
+				}
+			}
+		}
+		if !shown {
+			continue
+		}
+
+		visible[q.ID] = true
+		if answer, ok := answers[id]; ok {
+			seen[id] = answer
+		}
+
+		for _, rule := range rules {
+			if rule.Action == models.LogicActionJumpTo && matchRule(rule, seen) {
+				jumpTo = rule.Target
+				break
+			}
+		}
+	}
+
+	return visible
+}
+
+func matchRule(rule models.DisplayRule, answers map[string]interface{}) bool {
+	if len(rule.Conditions) == 0 {
+		return true
+	}
+	matchAny := rule.Match == "any"
+	for _, cond := range rule.Conditions {
+		matched := matchCondition(cond, answers[cond.QuestionID])
+		if matchAny && matched {
+			return true
+		}
+		if !matchAny && !matched {
+			return false
+		}
+	}
+	return !matchAny
+}
+
+func matchCondition(cond models.RuleCondition, answer interface{}) bool {
+	switch cond.Operator {
+	case "answered":
+		return IsAnswered(answer)
+	case "not_answered":
+		return !IsAnswered(answer)
+	}
+	if !IsAnswered(answer) {
+		return false
+	}
+
+	switch cond.Operator {
+	case "equals":
+		return answerEquals(answer, cond.Value)
+	case "not_equals":
+		return !answerEquals(answer, cond.Value)
+	case "contains":
+		return answerContains(answer, cond.Value)
+	case "not_contains":
+		return !answerContains(answer, cond.Value)
+	case "in":
+		values, ok := cond.Value.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if answerEquals(answer, v) {
+				return true
+			}
+		}
+		return false
+	case "gt", "gte", "lt", "lte":
+		a, ok1 := toFloat(answer)
+		b, ok2 := toFloat(cond.Value)
+		if !ok1 || !ok2 {
+			return false
+		}
+		switch cond.Operator {
+		case "gt":
+			return a > b
+		case "gte":
+			return a >= b
+		case "lt":
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+// IsAnswered reports whether a decoded JSON answer carries a value
+func IsAnswered(answer interface{}) bool {
+	switch v := answer.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(v) != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// SessionTimings picks the questions a progress save records timings for: the question the filler
+// is on as shown, and every question with an answer as answered. Keys that are not question IDs are
+// ignored.
+func SessionTimings(currentQuestionID string, answers map[string]interface{}) (shown, answered []uuid.UUID) {
+	if id, err := uuid.Parse(currentQuestionID); err == nil {
+		shown = append(shown, id)
+	}
+	for key, answer := range answers {
+		if id, err := uuid.Parse(key); err == nil && IsAnswered(answer) {
+			answered = append(answered, id)
+		}
+	}
+	return shown, answered
+}
+
+func answerEquals(answer, value interface{}) bool {
+	if list, ok := answer.([]interface{}); ok {
+		return len(list) == 1 && answerEquals(list[0], value)
+	}
+	if a, ok := toFloat(answer); ok {
+		if b, ok := toFloat(value); ok {
+			return a == b
+		}
+	}
+	return fmt.Sprint(answer) == fmt.Sprint(value)
+}
+
+func answerContains(answer, value interface{}) bool {
+	switch v := answer.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if answerEquals(item, value) {
+				return true
+			}
+		}
+		return false
+	case string:
+		return strings.Contains(strings.ToLower(v), strings.ToLower(fmt.Sprint(value)))
+	}
+	return answerEquals(answer, value)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(n), 64); err == nil {
+			return f, true
+		}
+	}
+	return 0, false
+}
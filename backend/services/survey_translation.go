@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"onetimer-backend/models"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TranslationService serves surveys in the filler's language and maps answers given against a
+// translation back to the canonical option, row and column labels the survey was written with
+type TranslationService struct{}
+
+func NewTranslationService() *TranslationService {
+	return &TranslationService{}
+}
+
+// SurveyLocale is the language a survey request is written in, English unless it says otherwise
+func SurveyLocale(locale string) string {
+	if locale == "" {
+		return models.LocaleEnglish
+	}
+	return locale
+}
+
+// Validate checks a survey's translations: locales must be supported and differ from the survey's
+// own language, and option, row and column translations must name labels the question has and
+// translate them to labels that are distinct and not another canonical label
+func (s *TranslationService) Validate(req *models.SurveyRequest) []DefinitionError {
+	var errs []DefinitionError
+	locale := SurveyLocale(req.Locale)
+	if !models.IsLocale(locale) {
+		errs = append(errs, configErr("locale", "unsupported locale '%s'", locale))
+	}
+
+	for _, lang := range sortedKeys(req.Translations) {
+		field := "translations." + lang
+		if err := translationLocaleErr(field, lang, locale); err != nil {
+			errs = append(errs, *err)
+			continue
+		}
+		if strings.TrimSpace(req.Translations[lang].Title) == "" {
+			errs = append(errs, configErr(field+".title", "is required"))
+		}
+	}
+
+	for i, q := range req.Questions {
+		for _, lang := range sortedKeys(q.Translations) {
+			field := fmt.Sprintf("questions[%d].translations.%s", i, lang)
+			if err := translationLocaleErr(field, lang, locale); err != nil {
+				errs = append(errs, *err)
+				continue
+			}
+			t := q.Translations[lang]
+			if strings.TrimSpace(t.Title) == "" {
+				errs = append(errs, configErr(field+".title", "is required"))
+			}
+
+			// Piping is resolved the same way in every language, so a translation may only pipe
+			// questions the original text pipes
+			piped := append(PipeReferences(q.Title), PipeReferences(q.Description)...)
+			for _, text := range []struct{ name, value string }{{"title", t.Title}, {"description", t.Description}} {
+				for _, ref := range PipeReferences(text.value) {
+					if !containsString(piped, ref) {
+						errs = append(errs, configErr(field+"."+text.name, "pipes question '%s', which the original text does not", ref))
+					}
+				}
+			}
+
+			errs = append(errs, validateLabelTranslations(field+".options", t.Options, q.Options)...)
+			errs = append(errs, validateLabelTranslations(field+".rows", t.Rows, q.Rows)...)
+			errs = append(errs, validateLabelTranslations(field+".cols", t.Cols, q.Cols)...)
+		}
+	}
+	return errs
+}
+
+func translationLocaleErr(field, lang, surveyLocale string) *DefinitionError {
+	if !models.IsLocale(lang) {
+		err := configErr(field, "unsupported locale '%s'", lang)
+		return &err
+	}
+	if lang == surveyLocale {
+		err := configErr(field, "is the language the survey is written in")
+		return &err
+	}
+	return nil
+}
+
+// validateLabelTranslations checks that a label translation map can be reversed unambiguously
+func validateLabelTranslations(field string, translations map[string]string, labels []string) []DefinitionError {
+	var errs []DefinitionError
+	seen := make(map[string]string, len(translations))
+	for _, canonical := range sortedKeys(translations) {
+		translated := translations[canonical]
+		switch {
+		case !containsString(labels, canonical):
+			errs = append(errs, configErr(field, "'%s' is not one of the question's labels", canonical))
+		case strings.TrimSpace(translated) == "":
+			errs = append(errs, configErr(field, "translation of '%s' is empty", canonical))
+		case translated != canonical && containsString(labels, translated):
+			errs = append(errs, configErr(field, "'%s' is translated to '%s', which is another label", canonical, translated))
+		case seen[translated] != "":
+			errs = append(errs, configErr(field, "'%s' and '%s' are both translated to '%s'", seen[translated], canonical, translated))
+		default:
+			seen[translated] = canonical
+		}
+	}
+	return errs
+}
+
+// sortedKeys lists a map's keys in order, so validation reports errors deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseAcceptLanguage lists the languages of an Accept-Language header, most preferred first
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang   string
+		weight float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.TrimSpace(fields[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+					weight = parsed
+				}
+			}
+		}
+		if weight > 0 {
+			langs = append(langs, weighted{lang: lang, weight: weight})
+		}
+	}
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].weight > langs[j].weight })
+
+	preferences := make([]string, len(langs))
+	for i, l := range langs {
+		preferences[i] = l.lang
+	}
+	return preferences
+}
+
+// NegotiateLocale picks the language to serve a survey in: the first preference the survey is
+// available in, matching regional tags like yo-NG on their base language, or the survey's own
+// language when none is
+func (s *TranslationService) NegotiateLocale(survey *models.Survey, preferences ...string) string {
+	available := survey.Locales()
+	for _, preference := range preferences {
+		lang := strings.ToLower(strings.TrimSpace(preference))
+		if lang == "" {
+			continue
+		}
+		if containsString(available, lang) {
+			return lang
+		}
+		if base, _, ok := strings.Cut(strings.ReplaceAll(lang, "_", "-"), "-"); ok && containsString(available, base) {
+			return base
+		}
+	}
+	return survey.Locale
+}
+
+// LocalizeSurvey returns a copy of the survey with its title and description in locale
+func (s *TranslationService) LocalizeSurvey(survey *models.Survey, locale string) *models.Survey {
+	t, ok := survey.ParsedTranslations()[locale]
+	if !ok || locale == survey.Locale {
+		return survey
+	}
+	localized := *survey
+	localized.Title = t.Title
+	if t.Description != "" {
+		localized.Description = t.Description
+	}
+	return &localized
+}
+
+// LocalizeQuestions returns copies of the questions with their text, options, rows and columns in
+// locale. Questions without a translation for it keep their original text.
+func (s *TranslationService) LocalizeQuestions(questions []models.Question, locale string) []models.Question {
+	localized := make([]models.Question, len(questions))
+	for i, q := range questions {
+		t, ok := q.ParsedTranslations()[locale]
+		if !ok {
+			localized[i] = q
+			continue
+		}
+
+		q.Title = t.Title
+		if t.Description != "" {
+			description := t.Description
+			q.Description = &description
+		}
+		if len(t.Options) > 0 {
+			q.Options, _ = json.Marshal(translateLabels(q.OptionList(), t.Options))
+		}
+		if len(t.Options) > 0 || len(t.Rows) > 0 || len(t.Cols) > 0 {
+			settings := q.ParsedSettings()
+			settings.Rows = translateLabels(settings.Rows, t.Rows)
+			settings.Cols = translateLabels(settings.Cols, t.Cols)
+			settings.PinnedOptions = translateLabels(settings.PinnedOptions, t.Options)
+			q.Settings, _ = json.Marshal(settings)
+		}
+		localized[i] = q
+	}
+	return localized
+}
+
+// LocalizeAnswers returns a copy of answers with choice labels translated into locale, so answers
+// piped into localized question text read in the same language
+func (s *TranslationService) LocalizeAnswers(questions []models.Question, locale string, answers map[string]interface{}) map[string]interface{} {
+	return mapAnswers(questions, locale, answers, func(t models.QuestionTranslation) (options, rows, cols map[string]string) {
+		return t.Options, t.Rows, t.Cols
+	})
+}
+
+// CanonicalAnswers returns a copy of answers given against the locale translation with every
+// translated option, row and column label replaced by the canonical label it translates. Labels
+// that are already canonical are kept, so converting canonical answers is harmless.
+func (s *TranslationService) CanonicalAnswers(questions []models.Question, locale string, answers map[string]interface{}) map[string]interface{} {
+	return mapAnswers(questions, locale, answers, func(t models.QuestionTranslation) (options, rows, cols map[string]string) {
+		return reverseLabels(t.Options), reverseLabels(t.Rows), reverseLabels(t.Cols)
+	})
+}
+
+// mapAnswers translates the answers to questions with a locale translation through the label maps
+// labelMaps derives from it
+func mapAnswers(questions []models.Question, locale string, answers map[string]interface{}, labelMaps func(models.QuestionTranslation) (options, rows, cols map[string]string)) map[string]interface{} {
+	mapped := make(map[string]interface{}, len(answers))
+	for id, answer := range answers {
+		mapped[id] = answer
+	}
+	for _, q := range questions {
+		answer, ok := answers[q.ID.String()]
+		if !ok {
+			continue
+		}
+		t, ok := q.ParsedTranslations()[locale]
+		if !ok {
+			continue
+		}
+		options, rows, cols := labelMaps(t)
+		mapped[q.ID.String()] = translateAnswer(answer, options, rows, cols)
+	}
+	return mapped
+}
+
+// translateAnswer maps the labels in an answer: choice answers through options, and grid answers'
+// rows and the columns chosen for them through rows and cols
+func translateAnswer(answer interface{}, options, rows, cols map[string]string) interface{} {
+	switch v := answer.(type) {
+	case string:
+		return translateLabel(v, options)
+	case []interface{}:
+		translated := make([]interface{}, len(v))
+		for i, item := range v {
+			translated[i] = translateAnswer(item, options, nil, nil)
+		}
+		return translated
+	case []string:
+		return translateLabels(v, options)
+	case map[string]interface{}:
+		translated := make(map[string]interface{}, len(v))
+		for row, value := range v {
+			translated[translateLabel(row, rows)] = translateAnswer(value, cols, nil, nil)
+		}
+		return translated
+	}
+	return answer
+}
+
+func translateLabel(label string, translations map[string]string) string {
+	if translated, ok := translations[label]; ok {
+		return translated
+	}
+	return label
+}
+
+func translateLabels(labels []string, translations map[string]string) []string {
+	if len(labels) == 0 || len(translations) == 0 {
+		return labels
+	}
+	translated := make([]string, len(labels))
+	for i, label := range labels {
+		translated[i] = translateLabel(label, translations)
+	}
+	return translated
+}
+
+func reverseLabels(translations map[string]string) map[string]string {
+	reversed := make(map[string]string, len(translations))
+	for canonical, translated := range translations {
+		reversed[translated] = canonical
+	}
+	return reversed
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"onetimer-backend/models"
+	"strings"
+)
+
+var segmentFields = map[string]bool{
+	models.SegmentAgeGroup:    true,
+	models.SegmentGender:      true,
+	models.SegmentLocation:    true,
+	models.SegmentEducation:   true,
+	models.SegmentEmployment:  true,
+	models.SegmentIncomeRange: true,
+}
+
+// TargetingService matches filler profiles against a survey's demographic targeting and quotas
+type TargetingService struct{}
+
+func NewTargetingService() *TargetingService {
+	return &TargetingService{}
+}
+
+// ValidateQuotas checks quota definitions submitted by a creator
+func (s *TargetingService) ValidateQuotas(quotas []models.SegmentQuota) error {
+	seen := make(map[string]bool, len(quotas))
+	for _, q := range quotas {
+		if !segmentFields[q.Field] {
+			return fmt.Errorf("unknown quota field '%s'", q.Field)
+		}
+		if strings.TrimSpace(q.Value) == "" {
+			return fmt.Errorf("quota for '%s' needs a value", q.Field)
+		}
+		if q.Max <= 0 {
+			return fmt.Errorf("quota for %s '%s' must allow at least one response", q.Field, q.Value)
+		}
+		key := q.Field + ":" + strings.ToLower(q.Value)
+		if seen[key] {
+			return fmt.Errorf("duplicate quota for %s '%s'", q.Field, q.Value)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Mismatches returns the targeting criteria the filler's profile fails; none means eligible.
+// A filler without a profile only matches untargeted surveys.
+func (s *TargetingService) Mismatches(criteria models.TargetingCriteria, profile *models.FillerProfile) []string {
+	checks := []struct {
+		field   string
+		allowed []string
+	}{
+		{models.SegmentAgeGroup, criteria.AgeGroups},
+		{models.SegmentGender, criteria.Genders},
+		{models.SegmentLocation, criteria.Locations},
+		{models.SegmentEducation, criteria.Education},
+		{models.SegmentEmployment, criteria.Employment},
+		{models.SegmentIncomeRange, criteria.IncomeRanges},
+	}
+
+	var mismatches []string
+	for _, check := range checks {
+		if len(check.allowed) == 0 {
+			continue
+		}
+		if profile == nil || !matchesAny(profile.Values(check.field), check.allowed) {
+			mismatches = append(mismatches, check.field)
+		}
+	}
+	return mismatches
+}
+
+// ApplicableQuotas returns the quotas for segments the filler belongs to
+func (s *TargetingService) ApplicableQuotas(quotas []models.SegmentQuota, profile *models.FillerProfile) []models.SegmentQuota {
+	if profile == nil {
+		return nil
+	}
+	var applicable []models.SegmentQuota
+	for _, q := range quotas {
+		if matchesAny(profile.Values(q.Field), []string{q.Value}) {
+			applicable = append(applicable, q)
+		}
+	}
+	return applicable
+}
+
+func matchesAny(values, allowed []string) bool {
+	for _, v := range values {
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(a)) {
+				return true
+			}
+		}
+	}
+	return false
+}
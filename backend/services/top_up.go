@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"onetimer-backend/models"
+	"onetimer-backend/utils"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	autoTopUpBatchSize = 50
+	// autoTopUpCooldown keeps a creator from being charged again while a charge the bank has yet
+	// to decide, or its webhook, is still on its way
+	autoTopUpCooldown = time.Hour
+)
+
+var (
+	// ErrNoPaymentMethod is returned when a creator has no saved card to charge
+	ErrNoPaymentMethod = errors.New("no saved payment method")
+	// ErrChargeDeclined is returned when Paystack declines a charge to a saved card
+	ErrChargeDeclined = errors.New("charge declined")
+)
+
+// PurchaseStore records credit purchases and grants their credits once they are paid
+type PurchaseStore interface {
+	CreatePurchase(ctx context.Context, payment *models.PaymentTransaction) error
+	FailPurchase(ctx context.Context, reference string) error
+	ChargeStore
+}
+
+// PaymentMethodStore keeps creators' saved cards and their auto top-up settings
+type PaymentMethodStore interface {
+	// PaymentMethod returns a creator's card with its authorization code, their default card when
+	// id is nil, or nil when there is no such card
+	PaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error)
+	MarkPaymentMethodUsed(ctx context.Context, id uuid.UUID) error
+	// ClaimAutoTopUps marks triggered, and returns, the auto top-ups due for creators whose credits
+	// fell below their threshold and that were not triggered within the cooldown
+	ClaimAutoTopUps(ctx context.Context, cooldown time.Duration, limit int) ([]models.AutoTopUp, error)
+	// FinishAutoTopUp records a triggered auto top-up's failure, "" when it had none, switching auto
+	// top-up off when disable is set
+	FinishAutoTopUp(ctx context.Context, userID uuid.UUID, failure string, disable bool) error
+}
+
+// AutoTopUpRunResult counts what an auto top-up run did
+type AutoTopUpRunResult struct {
+	Claimed  int `json:"claimed"`
+	Charged  int `json:"charged"`
+	Pending  int `json:"pending"`
+	Declined int `json:"declined"`
+	Failed   int `json:"failed"`
+}
+
+// TopUpService buys credits with the cards creators saved, without a checkout: on request, or
+// automatically when their credits run low. A charge is recorded as a pending purchase first and
+// keeps its reference, so the charge.success webhook grants a charge the bank decides later once.
+type TopUpService struct {
+	paystack  *PaystackService
+	purchases PurchaseStore
+	charges   *ChargeFulfiller
+	methods   PaymentMethodStore
+	invoices  *Invoicer
+}
+
+func NewTopUpService(paystack *PaystackService, purchases PurchaseStore, methods PaymentMethodStore) *TopUpService {
+	return &TopUpService{paystack: paystack, purchases: purchases, charges: NewChargeFulfiller(purchases), methods: methods}
+}
+
+// WithInvoices emails the buyer the invoice for every top-up charged
+func (ts *TopUpService) WithInvoices(invoices *Invoicer) *TopUpService {
+	ts.invoices = invoices
+	return ts
+}
+
+// TopUp charges a priced purchase to one of a creator's saved cards, their default when methodID is
+// nil. The purchase it returns is a success, with its credits granted, or pending while the bank
+// decides. A declined charge fails the purchase and returns ErrChargeDeclined.
+func (ts *TopUpService) TopUp(ctx context.Context, userID, methodID uuid.UUID, payment *models.PaymentTransaction) (*models.PaymentTransaction, error) {
+	method, err := ts.methods.PaymentMethod(ctx, userID, methodID)
+	if err != nil {
+		return nil, err
+	}
+	if method == nil {
+		return nil, ErrNoPaymentMethod
+	}
+
+	payment.ID = uuid.New()
+	reference := payment.ID.String()
+	payment.UserID = &userID
+	payment.PaystackReference = &reference
+	if err := ts.purchases.CreatePurchase(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]interface{}{
+		"user_id":           userID.String(),
+		"payment_id":        payment.ID.String(),
+		"credits":           payment.Credits,
+		"payment_method_id": method.ID.String(),
+	}
+	if payment.PackageID != nil {
+		metadata["package_id"] = *payment.PackageID
+	}
+	charge, err := ts.paystack.ChargeAuthorization(method.Email, payment.Amount*100, method.AuthorizationCode, reference, metadata)
+	if err != nil {
+		// Should the charge have gone through after all, its webhook still grants the purchase
+		if err := ts.purchases.FailPurchase(ctx, reference); err != nil {
+			utils.LogError(ctx, "Failed to mark top-up failed", err, "reference", reference)
+		}
+		payment.Status = models.PaymentStatusFailed
+		return payment, err
+	}
+	if err := ts.methods.MarkPaymentMethodUsed(ctx, method.ID); err != nil {
+		utils.LogWarn(ctx, "Failed to record payment method use", "payment_method_id", method.ID, "error", err.Error())
+	}
+
+	switch charge.Status {
+	case "success":
+		credits, granted, err := ts.charges.Fulfil(ctx, reference, &userID, charge.Amount/100)
+		if err != nil {
+			return payment, err
+		}
+		if granted && ts.invoices != nil {
+			go ts.invoices.Send(context.Background(), reference)
+		}
+		payment.Status = models.PaymentStatusSuccess
+		payment.Credits = credits
+	case "failed", "abandoned", "reversed":
+		if err := ts.purchases.FailPurchase(ctx, reference); err != nil {
+			utils.LogError(ctx, "Failed to mark top-up failed", err, "reference", reference)
+		}
+		payment.Status = models.PaymentStatusFailed
+		reason := charge.GatewayResponse
+		if reason == "" {
+			reason = charge.Status
+		}
+		return payment, fmt.Errorf("%w: %s", ErrChargeDeclined, reason)
+	default:
+		payment.Status = models.PaymentStatusPending
+	}
+
+	utils.LogInfo(ctx, "✅ Credits topped up", "user_id", userID, "reference", reference, "status", payment.Status, "credits", payment.Credits)
+	return payment, nil
+}
+
+// RunAutoTopUps tops up the creators whose credits fell below their auto top-up threshold. A
+// declined card switches their auto top-up off, so it is not charged again until they turn it back on.
+func (ts *TopUpService) RunAutoTopUps(ctx context.Context) (*AutoTopUpRunResult, error) {
+	due, err := ts.methods.ClaimAutoTopUps(ctx, autoTopUpCooldown, autoTopUpBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	result := &AutoTopUpRunResult{Claimed: len(due)}
+
+	var errs []error
+	for _, settings := range due {
+		description := "Auto top-up"
+		payment, err := ts.TopUp(ctx, settings.UserID, uuid.Nil, &models.PaymentTransaction{
+			Amount:      settings.Amount,
+			Credits:     settings.Amount,
+			Description: &description,
+		})
+
+		failure, disable := "", false
+		switch {
+		case errors.Is(err, ErrChargeDeclined), errors.Is(err, ErrNoPaymentMethod):
+			result.Declined++
+			failure, disable = err.Error(), true
+		case err != nil:
+			result.Failed++
+			failure = err.Error()
+			errs = append(errs, fmt.Errorf("auto top-up for %s: %w", settings.UserID, err))
+		case payment.Status == models.PaymentStatusPending:
+			result.Pending++
+		default:
+			result.Charged++
+		}
+		if err := ts.methods.FinishAutoTopUp(ctx, settings.UserID, failure, disable); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	utils.LogInfo(ctx, "✅ Auto top-ups run", "claimed", result.Claimed, "charged", result.Charged,
+		"pending", result.Pending, "declined", result.Declined, "failed", result.Failed)
+	return result, errors.Join(errs...)
+}
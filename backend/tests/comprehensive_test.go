@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"onetimer-backend/api/middleware"
 	"onetimer-backend/api/routes"
@@ -19,7 +20,10 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TestSuite struct {
@@ -257,6 +261,82 @@ func TestSurveyOperations(t *testing.T) {
 	})
 }
 
+// TestSurveyReviewRoute checks that a creator sends a survey for review on its own route, leaving
+// /submit to fillers' responses. The two handlers parse different request bodies, which tells them
+// apart without a database.
+func TestSurveyReviewRoute(t *testing.T) {
+	ts := setupTestSuite()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{UserID: uuid.New().String(), Role: "creator"}).
+		SignedString([]byte(ts.config.JWTSecret))
+	require.NoError(t, err)
+
+	post := func(path, body string) (int, interface{}) {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := ts.app.Test(req, -1)
+		require.NoError(t, err)
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result["error"]
+	}
+
+	t.Run("Review Reaches SubmitSurveyForReview", func(t *testing.T) {
+		// A status change reads a reason and ignores answers
+		status, message := post("/api/survey/not-a-uuid/review", `{"reason": 5}`)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid request", message)
+
+		status, message = post("/api/survey/not-a-uuid/review", `{"answers": 5}`)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid survey ID", message)
+	})
+
+	t.Run("Submit Reaches SubmitResponse", func(t *testing.T) {
+		// A response reads answers and ignores a reason
+		status, message := post("/api/survey/not-a-uuid/submit", `{"answers": 5}`)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid request", message)
+
+		status, message = post("/api/survey/not-a-uuid/submit", `{"reason": 5}`)
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid survey ID", message)
+	})
+}
+
+func TestSurveyResponseRoutes(t *testing.T) {
+	ts := setupTestSuite()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{UserID: uuid.New().String(), Role: "creator"}).
+		SignedString([]byte(ts.config.JWTSecret))
+	require.NoError(t, err)
+
+	get := func(path string) (int, interface{}) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := ts.app.Test(req, -1)
+		require.NoError(t, err)
+
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result["error"]
+	}
+
+	t.Run("Malformed IDs Are Rejected", func(t *testing.T) {
+		status, message := get("/api/creator/surveys/not-a-uuid/responses")
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid survey ID", message)
+
+		status, message = get("/api/creator/surveys/not-a-uuid/responses/" + uuid.New().String())
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid survey ID", message)
+
+		status, message = get("/api/creator/surveys/" + uuid.New().String() + "/responses/not-a-uuid")
+		assert.Equal(t, 400, status)
+		assert.Equal(t, "Invalid response ID", message)
+	})
+}
+
 func TestPaymentSystem(t *testing.T) {
 	ts := setupTestSuite()
 
@@ -119,12 +119,12 @@ func TestSurveyWorkflow(t *testing.T) {
 		err := roleService.ApproveSurvey(adminID, surveyID)
 		assert.NoError(t, err)
 
-		// Verify survey is approved
+		// Verify survey is published
 		var status string
 		err = db.QueryRow(context.Background(),
 			"SELECT status FROM surveys WHERE id = $1", surveyID).Scan(&status)
 		require.NoError(t, err)
-		assert.Equal(t, "approved", status)
+		assert.Equal(t, "active", status)
 
 		// Filler completes survey
 		err = roleService.CompleteSurveyResponse(fillerID, surveyID)
@@ -165,6 +165,11 @@ func setupTestDB(t *testing.T) *pgxpool.Pool {
 	if err != nil {
 		t.Skip("Test database not available")
 	}
+	// The pool connects lazily, so an unreachable database only shows up on first use
+	if err := db.Ping(context.Background()); err != nil {
+		db.Close()
+		t.Skip("Test database not available")
+	}
 
 	// Setup test schema
 	setupTestSchema(t, db)
@@ -194,10 +199,20 @@ func setupTestSchema(t *testing.T, db *pgxpool.Pool) {
 			reward INTEGER DEFAULT 500,
 			status VARCHAR(50) DEFAULT 'draft',
 			current_responses INTEGER DEFAULT 0,
+			published_at TIMESTAMP,
 			created_at TIMESTAMP DEFAULT NOW(),
 			updated_at TIMESTAMP DEFAULT NOW()
 		);
 		
+		CREATE TABLE IF NOT EXISTS survey_status_history (
+			id UUID PRIMARY KEY,
+			survey_id UUID REFERENCES surveys(id),
+			from_status VARCHAR(50),
+			to_status VARCHAR(50),
+			actor_id UUID REFERENCES users(id),
+			created_at TIMESTAMP DEFAULT NOW()
+		);
+		
 		CREATE TABLE IF NOT EXISTS earnings (
 			id UUID PRIMARY KEY,
 			user_id UUID REFERENCES users(id),
@@ -248,7 +263,7 @@ func createTestSurvey(t *testing.T, db *pgxpool.Pool, creatorID uuid.UUID) uuid.
 	surveyID := uuid.New()
 	_, err := db.Exec(context.Background(),
 		`INSERT INTO surveys (id, creator_id, title, description, reward, status, created_at)
-		 VALUES ($1, $2, 'Test Survey', 'Test Description', 500, 'pending', NOW())`,
+		 VALUES ($1, $2, 'Test Survey', 'Test Description', 500, 'pending_review', NOW())`,
 		surveyID, creatorID)
 	require.NoError(t, err)
 	return surveyID
@@ -1,10 +1,29 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"onetimer-backend/api/controllers"
+	"onetimer-backend/database"
+	"onetimer-backend/models"
+	"onetimer-backend/repository"
+	"onetimer-backend/security"
 	"onetimer-backend/services"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -32,6 +51,35 @@ func TestBillingService(t *testing.T) {
 		err = service.ValidateRewardRange(10, 50) // Too low for 10 pages
 		assert.Error(t, err)
 	})
+
+	t.Run("Survey Run Days", func(t *testing.T) {
+		assert.Equal(t, services.BaseSurveyDays, service.SurveyRunDays(0))
+		assert.Equal(t, services.BaseSurveyDays+7, service.SurveyRunDays(7))
+	})
+
+	t.Run("Quote Survey", func(t *testing.T) {
+		targeting, _ := json.Marshal(models.TargetingCriteria{Genders: []string{"female"}, Locations: []string{"Lagos", "Abuja"}})
+		survey := &models.Survey{
+			RewardAmount:      200,
+			TargetResponses:   50,
+			RunDays:           service.SurveyRunDays(3),
+			PriorityPlacement: true,
+			Targeting:         targeting,
+		}
+
+		quote, err := service.QuoteSurvey(context.Background(), survey, 8, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 10000, quote.Budget)
+		// 300 standard fee, 500 priority placement, 2 filters at 200, 3 extra days at 100
+		assert.Equal(t, 1500, quote.PlatformFee)
+		assert.Equal(t, quote.Budget+quote.PlatformFee, quote.TotalCost)
+
+		survey.RewardAmount = 50
+		_, err = service.QuoteSurvey(context.Background(), survey, 8, "")
+		assert.Error(t, err)
+		_, err = service.QuoteSurvey(context.Background(), &models.Survey{RewardAmount: 200, TargetResponses: 10, RunDays: services.BaseSurveyDays}, 0, "")
+		assert.Error(t, err)
+	})
 }
 
 func TestOTPService(t *testing.T) {
@@ -54,3 +102,2752 @@ func TestOTPService(t *testing.T) {
 		assert.True(t, expired)
 	})
 }
+
+func TestSurveyLogicService(t *testing.T) {
+	service := services.NewSurveyLogicService()
+
+	newQuestion := func(order int, rules ...models.DisplayRule) models.Question {
+		q := models.Question{ID: uuid.New(), Type: "single", Required: true, OrderIndex: order}
+		if len(rules) > 0 {
+			q.Logic, _ = json.Marshal(rules)
+		}
+		return q
+	}
+
+	t.Run("Screener Jump And Show Rules", func(t *testing.T) {
+		q1 := newQuestion(1)
+		q3 := newQuestion(3)
+		q2 := newQuestion(2, models.DisplayRule{
+			Action:     models.LogicActionShow,
+			Conditions: []models.RuleCondition{{QuestionID: q1.ID.String(), Operator: "equals", Value: "Yes"}},
+		})
+		q1.Logic, _ = json.Marshal([]models.DisplayRule{{
+			Action:     models.LogicActionJumpTo,
+			Match:      "any",
+			Conditions: []models.RuleCondition{{QuestionID: q1.ID.String(), Operator: "equals", Value: "Under 18"}},
+			Target:     models.LogicTargetEnd,
+		}})
+		questions := []models.Question{q1, q2, q3}
+
+		assert.NoError(t, service.Validate(questions))
+
+		visible := service.VisibleQuestions(questions, map[string]interface{}{q1.ID.String(): "Yes"})
+		assert.True(t, visible[q2.ID])
+		assert.True(t, visible[q3.ID])
+
+		visible = service.VisibleQuestions(questions, map[string]interface{}{q1.ID.String(): "No"})
+		assert.False(t, visible[q2.ID])
+		assert.True(t, visible[q3.ID])
+
+		visible = service.VisibleQuestions(questions, map[string]interface{}{q1.ID.String(): "Under 18"})
+		assert.True(t, visible[q1.ID])
+		assert.False(t, visible[q3.ID])
+	})
+
+	t.Run("Reject Cycles And Unreachable Questions", func(t *testing.T) {
+		q1 := newQuestion(1)
+		q2 := newQuestion(2)
+		q1.Logic, _ = json.Marshal([]models.DisplayRule{{
+			Action:     models.LogicActionShow,
+			Conditions: []models.RuleCondition{{QuestionID: q2.ID.String(), Operator: "answered"}},
+		}})
+		assert.Error(t, service.Validate([]models.Question{q1, q2}))
+
+		q1 = newQuestion(1, models.DisplayRule{Action: models.LogicActionJumpTo, Target: models.LogicTargetEnd})
+		err := service.Validate([]models.Question{q1, newQuestion(2)})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unreachable")
+	})
+}
+
+func TestAnswerValidator(t *testing.T) {
+	validator := services.NewAnswerValidator()
+
+	single := models.Question{ID: uuid.New(), Type: "single", Required: true, Options: json.RawMessage(`["Yes","No"]`)}
+	rating := models.Question{ID: uuid.New(), Type: "rating", Settings: json.RawMessage(`{"scale":10}`)}
+	matrix := models.Question{ID: uuid.New(), Type: "matrix", Required: true, Settings: json.RawMessage(`{"rows":["Price","Quality"],"cols":["Bad","Good"]}`)}
+	questions := []models.Question{single, rating, matrix}
+
+	t.Run("Valid Answers", func(t *testing.T) {
+		errs := validator.Validate(questions, map[string]interface{}{
+			single.ID.String(): "Yes",
+			rating.ID.String(): float64(9),
+			matrix.ID.String(): map[string]interface{}{"Price": "Good", "Quality": "Bad"},
+		})
+		assert.Empty(t, errs)
+	})
+
+	t.Run("Invalid Answers", func(t *testing.T) {
+		errs := validator.Validate(questions, map[string]interface{}{
+			rating.ID.String(): float64(11),
+			matrix.ID.String(): map[string]interface{}{"Price": "Good"},
+			uuid.NewString():   "stray",
+		})
+
+		codes := map[string]bool{}
+		for _, err := range errs {
+			codes[err.Code] = true
+		}
+		assert.Len(t, errs, 4)
+		assert.True(t, codes[services.AnswerErrRequired])
+		assert.True(t, codes[services.AnswerErrOutOfRange])
+		assert.True(t, codes[services.AnswerErrIncomplete])
+		assert.True(t, codes[services.AnswerErrUnknownQuestion])
+	})
+}
+
+func TestQualityScoringService(t *testing.T) {
+	scorer := services.NewQualityScoringService()
+	survey := &models.Survey{ID: uuid.New(), EstimatedDuration: 10}
+
+	attention := models.Question{ID: uuid.New(), Type: "single", Title: "Select Blue", Settings: json.RawMessage(`{"expected_answer":"Blue"}`)}
+	like := models.Question{ID: uuid.New(), Type: "rating", Title: "Like it"}
+	dislike := models.Question{ID: uuid.New(), Type: "rating", Title: "Dislike it", Settings: json.RawMessage(`{"consistency_with":"` + like.ID.String() + `","consistency_mode":"reverse"}`)}
+	opinion := models.Question{ID: uuid.New(), Type: "text", Title: "Why"}
+	questions := []models.Question{attention, like, dislike, opinion}
+
+	t.Run("Careful Response", func(t *testing.T) {
+		result := scorer.Score(services.QualityInput{
+			Survey:    survey,
+			Questions: questions,
+			Duration:  9 * time.Minute,
+			Answers: map[string]interface{}{
+				attention.ID.String(): "Blue",
+				like.ID.String():      float64(5),
+				dislike.ID.String():   float64(1),
+				opinion.ID.String():   "The checkout flow was quick and easy to follow",
+			},
+		})
+		assert.Equal(t, 10, result.Score)
+		assert.Empty(t, result.Flags)
+	})
+
+	t.Run("Careless Response", func(t *testing.T) {
+		result := scorer.Score(services.QualityInput{
+			Survey:    survey,
+			Questions: questions,
+			Duration:  90 * time.Second,
+			Answers: map[string]interface{}{
+				attention.ID.String(): "Red",
+				like.ID.String():      float64(5),
+				dislike.ID.String():   float64(5),
+				opinion.ID.String():   "asdfasdf",
+			},
+		})
+
+		checks := map[string]bool{}
+		for _, flag := range result.Flags {
+			checks[flag.Check] = true
+		}
+		assert.Equal(t, 0, result.Score)
+		assert.True(t, checks["speeding"])
+		assert.True(t, checks["attention_check"])
+		assert.True(t, checks["consistency"])
+		assert.True(t, checks["open_text"])
+	})
+
+	t.Run("Attention Answer Hidden From Fillers", func(t *testing.T) {
+		public := attention.ForFiller()
+		assert.Nil(t, public.ParsedSettings().ExpectedAnswer)
+		assert.Equal(t, "Blue", attention.ParsedSettings().ExpectedAnswer)
+	})
+}
+
+func TestTargetingService(t *testing.T) {
+	targeting := services.NewTargetingService()
+	lagos, female, adult := "Lagos", "Female", "25-34"
+	profile := &models.FillerProfile{State: &lagos, Gender: &female, AgeRange: &adult}
+
+	t.Run("Criteria Matching", func(t *testing.T) {
+		assert.Empty(t, targeting.Mismatches(models.TargetingCriteria{Locations: []string{"lagos"}, Genders: []string{"female", "male"}}, profile))
+		assert.Equal(t, []string{models.SegmentAgeGroup}, targeting.Mismatches(models.TargetingCriteria{AgeGroups: []string{"18-24"}}, profile))
+		assert.NotEmpty(t, targeting.Mismatches(models.TargetingCriteria{Genders: []string{"female"}}, nil))
+		assert.Empty(t, targeting.Mismatches(models.TargetingCriteria{}, nil))
+	})
+
+	t.Run("Applicable Quotas", func(t *testing.T) {
+		quotas := []models.SegmentQuota{
+			{Field: models.SegmentLocation, Value: "Lagos", Max: 50},
+			{Field: models.SegmentGender, Value: "Male", Max: 50},
+			{Field: models.SegmentGender, Value: "Female", Max: 50},
+		}
+		assert.NoError(t, targeting.ValidateQuotas(quotas))
+		assert.Len(t, targeting.ApplicableQuotas(quotas, profile), 2)
+	})
+
+	t.Run("Invalid Quotas", func(t *testing.T) {
+		assert.Error(t, targeting.ValidateQuotas([]models.SegmentQuota{{Field: "height", Value: "tall", Max: 5}}))
+		assert.Error(t, targeting.ValidateQuotas([]models.SegmentQuota{{Field: models.SegmentGender, Value: "Male", Max: 0}}))
+	})
+}
+
+// memSubmissionStore keeps one survey's submission state, counting responses per quota segment
+type memSubmissionStore struct {
+	state     models.SubmissionState
+	segments  map[models.SegmentQuota]int
+	responses []models.Response
+	earnings  []models.Earning
+}
+
+func (m *memSubmissionStore) RecordSubmission(ctx context.Context, response *models.Response, earning *models.Earning, answered []uuid.UUID, quotas []models.SegmentQuota, admit func(*models.SubmissionState) error) error {
+	state := m.state
+	for _, quota := range quotas {
+		if m.segments[quota] >= quota.Max {
+			state.FullSegments = append(state.FullSegments, quota)
+		}
+	}
+	if err := admit(&state); err != nil {
+		return err
+	}
+
+	m.responses = append(m.responses, *response)
+	if response.Status == models.ResponseStatusRejected {
+		return nil
+	}
+	m.state.CurrentResponses++
+	for _, quota := range quotas {
+		m.segments[quota]++
+	}
+	if m.state.TargetResponses > 0 && m.state.CurrentResponses >= m.state.TargetResponses {
+		m.state.SurveyStatus = models.SurveyStatusCompleted
+	}
+	m.earnings = append(m.earnings, *earning)
+	return nil
+}
+
+func TestResponseSubmission(t *testing.T) {
+	ctx := context.Background()
+	female := models.SegmentQuota{Field: "gender", Value: "female", Max: 2}
+	newStore := func() *memSubmissionStore {
+		return &memSubmissionStore{
+			state:    models.SubmissionState{SurveyStatus: models.SurveyStatusActive, TargetResponses: 3, Version: 2},
+			segments: map[models.SegmentQuota]int{},
+		}
+	}
+	submit := func(submitter *services.ResponseSubmitter, version int, quotas ...models.SegmentQuota) error {
+		response := &models.Response{ID: uuid.New(), FillerID: uuid.New(), Status: models.ResponseStatusCompleted, SurveyVersion: version}
+		earning := &models.Earning{ID: uuid.New(), UserID: response.FillerID, Amount: 200}
+		return submitter.Submit(ctx, response, earning, nil, quotas)
+	}
+
+	t.Run("Counts Admitted Responses", func(t *testing.T) {
+		store := newStore()
+		assert.NoError(t, submit(services.NewResponseSubmitter(store), 2, female))
+		assert.Equal(t, 1, store.state.CurrentResponses)
+		assert.Equal(t, 1, store.segments[female])
+		assert.Len(t, store.earnings, 1)
+	})
+
+	t.Run("Rejects Over Quota", func(t *testing.T) {
+		store := newStore()
+		submitter := services.NewResponseSubmitter(store)
+		assert.NoError(t, submit(submitter, 2, female))
+		assert.NoError(t, submit(submitter, 2, female))
+
+		err := submit(submitter, 2, female)
+		assert.ErrorIs(t, err, services.ErrSegmentFull)
+		assert.Equal(t, 2, store.state.CurrentResponses)
+		assert.Len(t, store.responses, 2)
+		assert.Len(t, store.earnings, 2)
+
+		// Fillers outside the full segment are still admitted
+		assert.NoError(t, submit(submitter, 2, models.SegmentQuota{Field: "gender", Value: "male", Max: 2}))
+	})
+
+	t.Run("Rejects Stale Version", func(t *testing.T) {
+		store := newStore()
+		err := submit(services.NewResponseSubmitter(store), 1)
+		assert.ErrorIs(t, err, services.ErrVersionChanged)
+		assert.Empty(t, store.responses)
+		assert.Equal(t, 0, store.state.CurrentResponses)
+	})
+
+	t.Run("Closes At Target", func(t *testing.T) {
+		store := newStore()
+		store.state.CurrentResponses = 2
+		submitter := services.NewResponseSubmitter(store)
+		assert.NoError(t, submit(submitter, 2))
+		assert.Equal(t, models.SurveyStatusCompleted, store.state.SurveyStatus)
+
+		assert.ErrorIs(t, submit(submitter, 2), services.ErrSurveyClosed)
+		assert.Len(t, store.earnings, 1)
+	})
+
+	t.Run("Rejects After Expiry", func(t *testing.T) {
+		store := newStore()
+		expired := time.Now().Add(-time.Minute)
+		store.state.ExpiresAt = &expired
+		assert.ErrorIs(t, submit(services.NewResponseSubmitter(store), 2), services.ErrSurveyClosed)
+		assert.Empty(t, store.responses)
+	})
+}
+
+func TestSurveySessions(t *testing.T) {
+	t.Run("Progress Timings", func(t *testing.T) {
+		current, first, second, skipped := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+		shown, answered := services.SessionTimings(current.String(), map[string]interface{}{
+			first.String():   "Lagos",
+			second.String():  []interface{}{"a", "b"},
+			skipped.String(): "  ",
+			"not-a-question": "ignored",
+		})
+		assert.Equal(t, []uuid.UUID{current}, shown)
+		assert.ElementsMatch(t, []uuid.UUID{first, second}, answered)
+
+		shown, answered = services.SessionTimings("", nil)
+		assert.Empty(t, shown)
+		assert.Empty(t, answered)
+	})
+
+	t.Run("Resumable Sessions", func(t *testing.T) {
+		assert.False(t, models.IsFinishedResponseStatus(models.ResponseStatusInProgress))
+		assert.False(t, models.IsFinishedResponseStatus(models.ResponseStatusAbandoned))
+		assert.True(t, models.IsFinishedResponseStatus(models.ResponseStatusCompleted))
+		assert.True(t, models.IsFinishedResponseStatus(models.ResponseStatusRejected))
+	})
+}
+
+// memReviewStore keeps one survey's submitted responses and the earnings held for them
+type memReviewStore struct {
+	surveyStatus string
+	current      int
+	responses    map[uuid.UUID]*models.Response
+	earnings     map[uuid.UUID]*models.Earning // by response ID
+}
+
+func (m *memReviewStore) settle(response *models.Response, outcome *models.ReviewOutcome) models.ReviewedResponse {
+	response.ReviewStatus = &outcome.ReviewStatus
+	response.Status = outcome.ResponseStatus
+	reviewed := models.ReviewedResponse{ResponseID: response.ID, FillerID: response.FillerID, SurveyID: response.SurveyID}
+	if earning := m.earnings[response.ID]; earning != nil && earning.Status == models.EarningStatusPending {
+		earning.Status = outcome.EarningStatus
+		reviewed.EarningID = &earning.ID
+		reviewed.Amount = earning.Amount
+	}
+	if outcome.Uncounted {
+		m.current--
+		if m.surveyStatus == models.SurveyStatusCompleted {
+			m.surveyStatus = models.SurveyStatusActive
+		}
+	}
+	return reviewed
+}
+
+func (m *memReviewStore) SettleReview(ctx context.Context, surveyID, responseID uuid.UUID, note *string, decide func(reviewStatus *string) (*models.ReviewOutcome, error)) (*models.ReviewedResponse, error) {
+	response := m.responses[responseID]
+	if response == nil || response.SurveyID != surveyID {
+		return nil, errors.New("response not found")
+	}
+	outcome, err := decide(response.ReviewStatus)
+	if err != nil {
+		return nil, err
+	}
+	response.ReviewNote = note
+	reviewed := m.settle(response, outcome)
+	return &reviewed, nil
+}
+
+func (m *memReviewStore) ApprovePendingReviews(ctx context.Context, cutoff time.Time) ([]models.ReviewedResponse, error) {
+	approved := []models.ReviewedResponse{}
+	outcome := &models.ReviewOutcome{ReviewStatus: models.ReviewStatusApproved, ResponseStatus: models.ResponseStatusCompleted, EarningStatus: models.EarningStatusAvailable}
+	for _, r := range m.responses {
+		if r.ReviewStatus != nil && *r.ReviewStatus == models.ReviewStatusPending && r.CompletedAt.Before(cutoff) {
+			approved = append(approved, m.settle(r, outcome))
+		}
+	}
+	return approved, nil
+}
+
+func TestResponseReview(t *testing.T) {
+	ctx := context.Background()
+	surveyID := uuid.New()
+	newStore := func() *memReviewStore {
+		return &memReviewStore{
+			surveyStatus: models.SurveyStatusCompleted,
+			current:      2,
+			responses:    map[uuid.UUID]*models.Response{},
+			earnings:     map[uuid.UUID]*models.Earning{},
+		}
+	}
+	submitted := func(store *memReviewStore, age time.Duration) uuid.UUID {
+		pending := models.ReviewStatusPending
+		completedAt := time.Now().Add(-age)
+		response := &models.Response{ID: uuid.New(), SurveyID: surveyID, FillerID: uuid.New(), Status: models.ResponseStatusCompleted, ReviewStatus: &pending, CompletedAt: &completedAt}
+		store.responses[response.ID] = response
+		store.earnings[response.ID] = &models.Earning{ID: uuid.New(), UserID: response.FillerID, Amount: 300, Status: models.EarningStatusPending}
+		return response.ID
+	}
+
+	t.Run("Approval Releases The Earning", func(t *testing.T) {
+		store := newStore()
+		responseID := submitted(store, time.Hour)
+		reviewed, err := services.NewResponseReviewer(store).Review(ctx, surveyID, responseID, models.ReviewStatusApproved, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 300, reviewed.Amount)
+		assert.Equal(t, models.ReviewStatusApproved, *store.responses[responseID].ReviewStatus)
+		assert.Equal(t, models.EarningStatusAvailable, store.earnings[responseID].Status)
+		assert.Equal(t, 2, store.current)
+	})
+
+	t.Run("Rejection Reverses And Reopens", func(t *testing.T) {
+		store := newStore()
+		responseID := submitted(store, time.Hour)
+		reason := "Straight-lined every question"
+		_, err := services.NewResponseReviewer(store).Review(ctx, surveyID, responseID, models.ReviewStatusRejected, &reason)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ResponseStatusRejected, store.responses[responseID].Status)
+		assert.Equal(t, models.EarningStatusReversed, store.earnings[responseID].Status)
+		assert.Equal(t, 1, store.current)
+		assert.Equal(t, models.SurveyStatusActive, store.surveyStatus)
+	})
+
+	t.Run("Reviews Once", func(t *testing.T) {
+		store := newStore()
+		reviewer := services.NewResponseReviewer(store)
+		responseID := submitted(store, time.Hour)
+		_, err := reviewer.Review(ctx, surveyID, responseID, models.ReviewStatusApproved, nil)
+		assert.NoError(t, err)
+
+		_, err = reviewer.Review(ctx, surveyID, responseID, models.ReviewStatusRejected, nil)
+		assert.ErrorIs(t, err, services.ErrAlreadyReviewed)
+		assert.Equal(t, models.EarningStatusAvailable, store.earnings[responseID].Status)
+
+		// Responses submitted before reviews existed were never held
+		store.responses[responseID].ReviewStatus = nil
+		_, err = reviewer.Review(ctx, surveyID, responseID, models.ReviewStatusApproved, nil)
+		assert.ErrorIs(t, err, services.ErrAlreadyReviewed)
+	})
+
+	t.Run("Auto Approval After The Window", func(t *testing.T) {
+		store := newStore()
+		overdue := submitted(store, 80*time.Hour)
+		recent := submitted(store, time.Hour)
+
+		approved, err := services.NewResponseReviewer(store).AutoApprove(ctx, 72*time.Hour)
+		assert.NoError(t, err)
+		assert.Len(t, approved, 1)
+		assert.Equal(t, overdue, approved[0].ResponseID)
+		assert.Equal(t, models.EarningStatusAvailable, store.earnings[overdue].Status)
+		assert.Equal(t, models.ReviewStatusPending, *store.responses[recent].ReviewStatus)
+		assert.Equal(t, models.EarningStatusPending, store.earnings[recent].Status)
+	})
+}
+
+func TestSurveyLifecycle(t *testing.T) {
+	t.Run("Allowed Transitions", func(t *testing.T) {
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusDraft, models.SurveyStatusPendingReview))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusPendingReview, models.SurveyStatusActive))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusPendingReview, models.SurveyStatusRejected))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusActive, models.SurveyStatusPaused))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusPaused, models.SurveyStatusActive))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusActive, models.SurveyStatusCompleted))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusCompleted, models.SurveyStatusArchived))
+	})
+
+	t.Run("Forbidden Transitions", func(t *testing.T) {
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusDraft, models.SurveyStatusActive))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusRejected, models.SurveyStatusActive))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusActive, models.SurveyStatusArchived))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusArchived, models.SurveyStatusActive))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusExpired, models.SurveyStatusActive))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusActive, models.SurveyStatusActive))
+		assert.False(t, models.CanTransitionSurvey(models.SurveyStatusScheduled, models.SurveyStatusPaused))
+	})
+
+	t.Run("Scheduled Publishing", func(t *testing.T) {
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusPendingReview, models.SurveyStatusScheduled))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusScheduled, models.SurveyStatusActive))
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusPaused, models.SurveyStatusExpired))
+		assert.True(t, models.IsClosingSurveyStatus(models.SurveyStatusExpired))
+		assert.False(t, models.IsClosingSurveyStatus(models.SurveyStatusPaused))
+	})
+
+	t.Run("Editable States", func(t *testing.T) {
+		for _, status := range []string{models.SurveyStatusDraft, models.SurveyStatusPendingReview, models.SurveyStatusScheduled, models.SurveyStatusActive, models.SurveyStatusPaused} {
+			assert.True(t, models.IsEditableSurveyStatus(status), status)
+		}
+		for _, status := range []string{models.SurveyStatusCompleted, models.SurveyStatusExpired, models.SurveyStatusArchived, models.SurveyStatusRejected} {
+			assert.False(t, models.IsEditableSurveyStatus(status), status)
+		}
+		// A rejected survey is edited again once it has gone back to draft
+		assert.True(t, models.CanTransitionSurvey(models.SurveyStatusRejected, models.SurveyStatusDraft))
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	t.Run("Runs Jobs Until Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		runs := make(chan struct{}, 10)
+
+		scheduler := services.NewScheduler()
+		scheduler.Every("tick", 10*time.Millisecond, func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		})
+		scheduler.Start(ctx)
+
+		for i := 0; i < 3; i++ {
+			select {
+			case <-runs:
+			case <-time.After(time.Second):
+				t.Fatal("job did not run")
+			}
+		}
+		cancel()
+	})
+
+	t.Run("Survives Failing Jobs", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		runs := make(chan struct{}, 10)
+
+		calls := 0
+		scheduler := services.NewScheduler()
+		scheduler.Every("failing", 10*time.Millisecond, func(ctx context.Context) error {
+			calls++
+			runs <- struct{}{}
+			if calls == 1 {
+				panic("boom")
+			}
+			return errors.New("job failed")
+		})
+		scheduler.Start(ctx)
+
+		for i := 0; i < 3; i++ {
+			select {
+			case <-runs:
+			case <-time.After(time.Second):
+				t.Fatal("job stopped after a failure")
+			}
+		}
+	})
+}
+
+func TestSurveyVersioning(t *testing.T) {
+	t.Run("Drafts Are Edited In Place", func(t *testing.T) {
+		assert.Equal(t, 1, models.EditedVersion(1, nil))
+	})
+
+	t.Run("Published Edits Start A Version", func(t *testing.T) {
+		published := time.Now().Add(-time.Hour)
+		assert.Equal(t, 2, models.EditedVersion(1, &published))
+		assert.Equal(t, 3, models.EditedVersion(2, &published))
+	})
+
+	t.Run("Responses To The Old Version Are Turned Away", func(t *testing.T) {
+		published := time.Now().Add(-time.Hour)
+		store := &memSubmissionStore{
+			state:    models.SubmissionState{SurveyStatus: models.SurveyStatusActive, Version: 1},
+			segments: map[models.SegmentQuota]int{},
+		}
+		submitter := services.NewResponseSubmitter(store)
+		answer := func(version int) error {
+			response := &models.Response{ID: uuid.New(), FillerID: uuid.New(), Status: models.ResponseStatusCompleted, SurveyVersion: version}
+			return submitter.Submit(context.Background(), response, &models.Earning{ID: uuid.New(), Amount: 100}, nil, nil)
+		}
+
+		assert.NoError(t, answer(1))
+		store.state.Version = models.EditedVersion(store.state.Version, &published)
+		assert.ErrorIs(t, answer(1), services.ErrVersionChanged)
+		assert.NoError(t, answer(2))
+		assert.Equal(t, []int{1, 2}, []int{store.responses[0].SurveyVersion, store.responses[1].SurveyVersion})
+	})
+}
+
+func TestSurveyDefinitionService(t *testing.T) {
+	definitions := services.NewSurveyDefinitionService()
+	def := &models.SurveyDefinition{
+		FormatVersion: models.SurveyDefinitionVersion,
+		Title:         "Transport Survey",
+		Description:   "How you get to work",
+		RewardAmount:  200,
+		TargetCount:   50,
+		Questions: []models.QuestionRequest{
+			{ID: "q1", Type: "single", Title: "Do you drive?", Options: []string{"Yes", "No"}, Required: true},
+			{ID: "q2", Type: "text", Title: "Which car?", Logic: []models.DisplayRule{
+				{Action: "show", Conditions: []models.RuleCondition{{QuestionID: "q1", Operator: "equals", Value: "Yes"}}},
+			}},
+		},
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		for _, format := range []string{services.DefinitionFormatJSON, services.DefinitionFormatYAML} {
+			data, err := definitions.Encode(def, format)
+			assert.NoError(t, err)
+			decoded, err := definitions.Decode(data, format)
+			assert.NoError(t, err)
+			assert.NoError(t, definitions.Validate(decoded))
+			assert.Equal(t, def.Title, decoded.Title)
+			assert.Len(t, decoded.Questions, 2)
+			assert.Equal(t, "q1", decoded.Questions[1].Logic[0].Conditions[0].QuestionID)
+		}
+		assert.Equal(t, services.DefinitionFormatYAML, services.DefinitionFormat("survey.yml"))
+		assert.Equal(t, services.DefinitionFormatJSON, services.DefinitionFormat("application/json"))
+	})
+
+	t.Run("Unknown Fields", func(t *testing.T) {
+		_, err := definitions.Decode([]byte(`{"format_version": 1, "titel": "Typo"}`), services.DefinitionFormatJSON)
+		assert.Error(t, err)
+		_, err = definitions.Decode([]byte("format_version: 1\ntitel: Typo\n"), services.DefinitionFormatYAML)
+		assert.Error(t, err)
+	})
+
+	t.Run("Validation Errors", func(t *testing.T) {
+		invalid := *def
+		invalid.FormatVersion = models.SurveyDefinitionVersion + 1
+		invalid.Questions = []models.QuestionRequest{
+			{ID: "q1", Type: "hologram", Title: "Unknown type"},
+			{ID: "q2", Type: "text", Title: "Bad reference", ConsistencyWith: "q9"},
+		}
+		err := definitions.Validate(&invalid)
+		var validationErr *services.DefinitionValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Len(t, validationErr.Errors, 3)
+	})
+
+	t.Run("From Survey", func(t *testing.T) {
+		first, second := uuid.New(), uuid.New()
+		logic, _ := json.Marshal([]models.DisplayRule{
+			{Action: "show", Conditions: []models.RuleCondition{{QuestionID: first.String(), Operator: "answered"}}},
+		})
+		survey := &models.Survey{ID: uuid.New(), Title: "Stored", Description: "Stored survey"}
+		questions := []models.Question{
+			{ID: first, Type: "text", Title: "First"},
+			{ID: second, Type: "text", Title: "Second", Logic: logic},
+		}
+
+		exported := definitions.FromSurvey(survey, questions)
+		assert.Equal(t, models.SurveyDefinitionVersion, exported.FormatVersion)
+		assert.Equal(t, "q2", exported.Questions[1].ID)
+		assert.Equal(t, "q1", exported.Questions[1].Logic[0].Conditions[0].QuestionID)
+		assert.NoError(t, definitions.Validate(exported))
+	})
+}
+
+func TestSurveyTemplates(t *testing.T) {
+	owner := uuid.New()
+	definition, _ := json.Marshal(models.SurveyDefinition{FormatVersion: models.SurveyDefinitionVersion, Title: "Template", Description: "From a template"})
+
+	t.Run("Categories", func(t *testing.T) {
+		assert.True(t, models.IsSurveyCategory(models.SurveyCategoryCustomerExperience))
+		assert.False(t, models.IsSurveyCategory("business"))
+	})
+
+	t.Run("Visibility", func(t *testing.T) {
+		curated := models.SurveyTemplate{Definition: definition}
+		private := models.SurveyTemplate{OwnerID: &owner, Definition: definition}
+		assert.True(t, curated.VisibleTo(uuid.New()))
+		assert.True(t, private.VisibleTo(owner))
+		assert.False(t, private.VisibleTo(uuid.New()))
+
+		def, err := private.ParsedDefinition()
+		assert.NoError(t, err)
+		assert.Equal(t, "Template", def.Title)
+	})
+}
+
+func TestQuestionTypeRegistry(t *testing.T) {
+	types := services.NewQuestionTypeRegistry()
+	validator := services.NewAnswerValidator()
+	surveyID := uuid.New()
+
+	t.Run("Aliases", func(t *testing.T) {
+		single, _ := types.Lookup("single")
+		alias, ok := types.Lookup("multiple_choice")
+		assert.True(t, ok)
+		assert.Equal(t, single.Schema().Type, alias.Schema().Type)
+		_, ok = types.Lookup("hologram")
+		assert.False(t, ok)
+	})
+
+	t.Run("Config Validation", func(t *testing.T) {
+		errs := types.ValidateQuestions([]models.QuestionRequest{
+			{Type: "ranking", Title: "Rank", Options: []string{"Only"}},
+			{Type: "slider", Title: "Slide", Min: 10, Max: 5},
+			{Type: "date", Title: "When", DateMode: "week"},
+			{Type: "likert", Title: "Agree", Rows: []string{"Fast"}},
+		})
+		assert.Len(t, errs, 3)
+		assert.Equal(t, "questions[0].options", errs[0].Field)
+	})
+
+	t.Run("Answers", func(t *testing.T) {
+		ranking := models.Question{ID: uuid.New(), Type: "ranking", Options: json.RawMessage(`["Price","Speed","Quality"]`)}
+		nps := models.Question{ID: uuid.New(), Type: "nps"}
+		slider := models.Question{ID: uuid.New(), Type: "slider", Settings: json.RawMessage(`{"min":0,"max":10,"step":0.5}`)}
+		date := models.Question{ID: uuid.New(), Type: "date", Settings: json.RawMessage(`{"min_date":"2024-01-01"}`)}
+		likert := models.Question{ID: uuid.New(), Type: "likert", Settings: json.RawMessage(`{"rows":["Easy to use"]}`)}
+		upload := models.Question{ID: uuid.New(), SurveyID: surveyID, Type: "file_upload", Settings: json.RawMessage(`{"allowed_types":["pdf"]}`)}
+		questions := []models.Question{ranking, nps, slider, date, likert, upload}
+		fileURL := "https://bucket.s3.amazonaws.com/" + services.ResponseFileKey(surveyID, upload.ID, "user", ".pdf")
+
+		valid := map[string]interface{}{
+			ranking.ID.String(): []interface{}{"Speed", "Price", "Quality"},
+			nps.ID.String():     float64(9),
+			slider.ID.String():  7.5,
+			date.ID.String():    "2024-03-15",
+			likert.ID.String():  map[string]interface{}{"Easy to use": "Agree"},
+			upload.ID.String():  fileURL,
+		}
+		assert.Empty(t, validator.Validate(questions, valid))
+
+		invalid := map[string]interface{}{
+			ranking.ID.String(): []interface{}{"Speed", "Price"},
+			nps.ID.String():     float64(11),
+			slider.ID.String():  7.3,
+			date.ID.String():    "2023-12-31",
+			likert.ID.String():  map[string]interface{}{"Easy to use": "Maybe"},
+			upload.ID.String():  "https://example.com/other.pdf",
+		}
+		assert.Len(t, validator.Validate(questions, invalid), 6)
+	})
+
+	t.Run("Aggregate And Format", func(t *testing.T) {
+		nps, _ := types.Lookup("nps")
+		summary := nps.Aggregate(models.Question{Type: "nps"}, []interface{}{float64(10), float64(9), float64(7), float64(3)})
+		assert.Equal(t, 2, summary["promoters"])
+		assert.Equal(t, 1, summary["detractors"])
+		assert.Equal(t, 25.0, summary["score"])
+
+		likert := models.Question{Type: "likert", Settings: json.RawMessage(`{"rows":["Fast","Cheap"]}`)}
+		lookup, _ := types.Lookup("likert")
+		means := lookup.Aggregate(likert, []interface{}{
+			map[string]interface{}{"Fast": "Agree", "Cheap": "Disagree"},
+			map[string]interface{}{"Fast": "Strongly agree"},
+		})["means"].(map[string]float64)
+		assert.Equal(t, 4.5, means["Fast"])
+		assert.Equal(t, 2.0, means["Cheap"])
+
+		assert.Equal(t, "Fast: Agree; Cheap: Disagree", types.Format(likert, map[string]interface{}{"Cheap": "Disagree", "Fast": "Agree"}))
+		assert.Equal(t, "B > A", types.Format(models.Question{Type: "ranking"}, []interface{}{"B", "A"}))
+	})
+}
+
+func TestSurveyDelivery(t *testing.T) {
+	delivery := services.NewSurveyDeliveryService()
+	logic := services.NewSurveyLogicService()
+
+	block := func(name string) json.RawMessage {
+		return json.RawMessage(`{"block":"` + name + `"}`)
+	}
+	intro := models.Question{ID: uuid.New(), Type: "single", Title: "Do you drive?", Options: json.RawMessage(`["Yes","No"]`)}
+	a1 := models.Question{ID: uuid.New(), Type: "text", Title: "A1", Settings: block("a")}
+	a2 := models.Question{ID: uuid.New(), Type: "text", Title: "A2", Settings: block("a")}
+	b1 := models.Question{ID: uuid.New(), Type: "text", Title: "B1", Settings: block("b")}
+	brand := models.Question{ID: uuid.New(), Type: "single", Title: "Which brand?", Options: json.RawMessage(`["Toyota","Honda","Kia","Other"]`),
+		Settings: json.RawMessage(`{"randomize_options":true,"pinned_options":["Other"]}`)}
+	questions := []models.Question{intro, a1, a2, b1, brand}
+
+	t.Run("Served Order", func(t *testing.T) {
+		order := delivery.ServeOrder(questions, 1, 42)
+		assert.Equal(t, order, delivery.ServeOrder(questions, 1, 42))
+		assert.Len(t, order.Questions, 5)
+		assert.Equal(t, intro.ID.String(), order.Questions[0])
+		assert.Equal(t, brand.ID.String(), order.Questions[4])
+		a1At, a2At := -1, -1
+		for i, id := range order.Questions {
+			switch id {
+			case a1.ID.String():
+				a1At = i
+			case a2.ID.String():
+				a2At = i
+			}
+		}
+		assert.Equal(t, a1At+1, a2At)
+
+		options := order.Options[brand.ID.String()]
+		assert.ElementsMatch(t, []string{"Toyota", "Honda", "Kia", "Other"}, options)
+		assert.Equal(t, "Other", options[3])
+
+		arranged := delivery.Arrange(questions, &order)
+		assert.Equal(t, options, arranged[4].OptionList())
+	})
+
+	t.Run("Piping", func(t *testing.T) {
+		description := "You said {{" + intro.ID.String() + "}}"
+		follow := models.Question{ID: uuid.New(), Type: "text", Title: "Why {{" + intro.ID.String() + "|that}}?", Description: &description}
+		piped := delivery.Pipe([]models.Question{intro, follow}, map[string]interface{}{intro.ID.String(): "Yes"})
+		assert.Equal(t, "Why Yes?", piped[1].Title)
+		assert.Equal(t, "You said Yes", *piped[1].Description)
+		assert.Equal(t, "Why that?", delivery.Pipe([]models.Question{intro, follow}, nil)[1].Title)
+		assert.Equal(t, []string{"q1"}, services.PipeReferences("About {{ q1 | it }}"))
+	})
+
+	t.Run("Logic Across Blocks", func(t *testing.T) {
+		assert.NoError(t, logic.Validate(questions))
+
+		crossBlock := b1
+		crossBlock.Title = "About {{" + a1.ID.String() + "}}"
+		assert.Error(t, logic.Validate([]models.Question{intro, a1, a2, crossBlock, brand}))
+
+		sameBlock := a2
+		sameBlock.Title = "About {{" + a1.ID.String() + "}}"
+		assert.NoError(t, logic.Validate([]models.Question{intro, a1, sameBlock, b1, brand}))
+
+		split := []models.Question{intro, a1, b1, a2, brand}
+		assert.Error(t, logic.Validate(split))
+	})
+}
+
+func TestSurveyTranslations(t *testing.T) {
+	translations := services.NewTranslationService()
+
+	survey := &models.Survey{
+		Title:        "Transport survey",
+		Locale:       models.LocaleEnglish,
+		Translations: json.RawMessage(`{"yo":{"title":"Ìwádìí ìrìnnà"},"pcm":{"title":"Transport wahala"}}`),
+	}
+	drive := models.Question{ID: uuid.New(), Type: "single", Title: "Do you drive?", Options: json.RawMessage(`["Yes","No"]`),
+		Settings:     json.RawMessage(`{"randomize_options":true,"pinned_options":["No"]}`),
+		Translations: json.RawMessage(`{"yo":{"title":"Ṣé o máa ń wakọ̀?","options":{"Yes":"Bẹ́ẹ̀ni","No":"Rárá"}}}`)}
+	grid := models.Question{ID: uuid.New(), Type: "likert", Title: "Rate", Settings: json.RawMessage(`{"rows":["Price"],"cols":["Good","Bad"]}`),
+		Translations: json.RawMessage(`{"yo":{"title":"Ṣe ìdíyelé","rows":{"Price":"Owó"},"cols":{"Good":"Dára","Bad":"Burú"}}}`)}
+	questions := []models.Question{drive, grid}
+
+	t.Run("Negotiation", func(t *testing.T) {
+		assert.Equal(t, []string{"en", "pcm", "yo"}, survey.Locales())
+		assert.Equal(t, "yo", translations.NegotiateLocale(survey, "", "yo-NG"))
+		assert.Equal(t, "pcm", translations.NegotiateLocale(survey, "fr", "pcm"))
+		assert.Equal(t, "en", translations.NegotiateLocale(survey, "ha"))
+		assert.Equal(t, []string{"yo", "en-GB", "en"}, services.ParseAcceptLanguage("en;q=0.5, yo, en-GB;q=0.8, *;q=0.1, fr;q=0"))
+		assert.Equal(t, "Ìwádìí ìrìnnà", translations.LocalizeSurvey(survey, "yo").Title)
+		assert.Equal(t, "Transport survey", survey.Title)
+	})
+
+	t.Run("Localize And Map Back", func(t *testing.T) {
+		localized := translations.LocalizeQuestions(questions, "yo")
+		assert.Equal(t, []string{"Bẹ́ẹ̀ni", "Rárá"}, localized[0].OptionList())
+		assert.Equal(t, []string{"Rárá"}, localized[0].ParsedSettings().PinnedOptions)
+		assert.Equal(t, []string{"Owó"}, localized[1].ParsedSettings().Rows)
+		assert.Equal(t, "Do you drive?", translations.LocalizeQuestions(questions, "pcm")[0].Title)
+
+		answers := map[string]interface{}{
+			drive.ID.String(): "Rárá",
+			grid.ID.String():  map[string]interface{}{"Owó": "Dára"},
+		}
+		canonical := translations.CanonicalAnswers(questions, "yo", answers)
+		assert.Equal(t, "No", canonical[drive.ID.String()])
+		assert.Equal(t, map[string]interface{}{"Price": "Good"}, canonical[grid.ID.String()])
+		assert.Equal(t, canonical, translations.CanonicalAnswers(questions, "yo", canonical))
+		assert.Equal(t, "Rárá", answers[drive.ID.String()])
+		assert.Equal(t, "Bẹ́ẹ̀ni", translations.LocalizeAnswers(questions, "yo", map[string]interface{}{drive.ID.String(): "Yes"})[drive.ID.String()])
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		req := &models.SurveyRequest{
+			Translations: map[string]models.SurveyTranslation{"yo": {Title: "Ìwádìí"}, "en": {Title: "Survey"}, "fr": {Title: "Enquête"}},
+			Questions: []models.QuestionRequest{{
+				Title:   "Do you drive {{q0}}?",
+				Options: []string{"Yes", "No"},
+				Translations: map[string]models.QuestionTranslation{"yo": {
+					Title:   "Ṣé o máa ń wakọ̀ {{q9}}?",
+					Options: map[string]string{"Yes": "No", "Maybe": "Bóyá"},
+				}},
+			}},
+		}
+		fields := map[string]bool{}
+		for _, err := range translations.Validate(req) {
+			fields[err.Field] = true
+		}
+		assert.True(t, fields["translations.en"])
+		assert.True(t, fields["translations.fr"])
+		assert.False(t, fields["translations.yo"])
+		assert.True(t, fields["questions[0].translations.yo.title"])
+		assert.True(t, fields["questions[0].translations.yo.options"])
+
+		valid := &models.SurveyRequest{Locale: "yo", Translations: map[string]models.SurveyTranslation{"en": {Title: "Survey"}}}
+		assert.Empty(t, translations.Validate(valid))
+	})
+}
+
+func TestLedger(t *testing.T) {
+	creator, filler, survey := uuid.New(), uuid.New(), uuid.New()
+
+	t.Run("Journals Must Balance", func(t *testing.T) {
+		journal := models.Transfer(models.JournalEarningAccrued, "earning:1:accrued", "Survey reward",
+			models.EscrowAccount(survey), models.FillerPendingAccount(filler), 500)
+		assert.NoError(t, journal.Validate())
+		assert.Equal(t, -500, journal.Postings[0].Amount)
+		assert.Equal(t, 500, journal.Postings[1].Amount)
+
+		funding := &models.LedgerJournal{Kind: models.JournalSurveyFunding, Reference: "survey:1:funding", Postings: []models.LedgerPosting{
+			{Account: models.CreatorCreditsAccount(creator), Amount: -1150},
+			{Account: models.EscrowAccount(survey), Amount: 1000},
+			{Account: models.PlatformAccount(models.LedgerAccountPlatformFees), Amount: 150},
+		}}
+		assert.NoError(t, funding.Validate())
+
+		funding.Postings[2].Amount = 100
+		assert.ErrorIs(t, funding.Validate(), models.ErrJournalUnbalanced)
+		assert.ErrorIs(t, (&models.LedgerJournal{Kind: "x", Reference: "x", Postings: funding.Postings[:1]}).Validate(), models.ErrJournalTooShort)
+		assert.Error(t, models.Transfer(models.JournalEarningAccrued, "earning:2:accrued", "", models.EscrowAccount(survey), models.FillerPendingAccount(filler), 0).Validate())
+		assert.Error(t, models.Transfer("", "", "", models.EscrowAccount(survey), models.FillerPendingAccount(filler), 5).Validate())
+	})
+
+	t.Run("Accounts", func(t *testing.T) {
+		assert.True(t, models.PlatformAccount(models.LedgerAccountPaymentGateway).MayOverdraw())
+		assert.True(t, models.EscrowAccount(survey).MayOverdraw())
+		assert.False(t, models.FillerWalletAccount(filler).MayOverdraw())
+		assert.False(t, models.CreatorCreditsAccount(creator).MayOverdraw())
+		assert.Equal(t, "payout_clearing", models.PlatformAccount(models.LedgerAccountPayoutClearing).String())
+		assert.Equal(t, "filler_wallet:"+filler.String(), models.FillerWalletAccount(filler).String())
+
+		// Postings are locked in account order whatever order the journal lists them in
+		journal := models.Transfer("withdrawal_returned", "withdrawal:1:returned", "",
+			models.PlatformAccount(models.LedgerAccountPayoutClearing), models.FillerWalletAccount(filler), 5000)
+		sorted := journal.SortedPostings()
+		assert.Equal(t, models.LedgerAccountFillerWallet, sorted[0].Account.Kind)
+		assert.Equal(t, models.LedgerAccountPayoutClearing, sorted[1].Account.Kind)
+		assert.Equal(t, models.LedgerAccountPayoutClearing, journal.Postings[0].Account.Kind)
+	})
+}
+
+// fakePaystack stands in for the Paystack API: it initializes and verifies transactions, echoing
+// back the metadata a transaction was initialized with, and signs webhook payloads like Paystack.
+// Transfers are answered with transferStatus; with loseResponses set they are accepted but the
+// response fails, and with rejectTransfers set they are refused.
+type fakePaystack struct {
+	*httptest.Server
+	secret          string
+	metadata        map[string]map[string]interface{}
+	transfers       map[string]*services.PaystackTransferData
+	transferCalls   map[string]int
+	charges         []services.PaystackChargeData
+	recipients      int
+	bulkCalls       int
+	transferStatus  string
+	loseResponses   bool
+	rejectTransfers bool
+}
+
+func newFakePaystack(t *testing.T, secret string) *fakePaystack {
+	fake := &fakePaystack{
+		secret:         secret,
+		metadata:       map[string]map[string]interface{}{},
+		transfers:      map[string]*services.PaystackTransferData{},
+		transferCalls:  map[string]int{},
+		transferStatus: "pending",
+	}
+	transfer := func(req services.TransferInitRequest) *services.PaystackTransferData {
+		fake.transferCalls[req.Reference]++
+		if existing, ok := fake.transfers[req.Reference]; ok {
+			return existing
+		}
+		created := &services.PaystackTransferData{
+			Reference: req.Reference, TransferCode: "TRF_" + req.Reference, Amount: req.Amount, Status: fake.transferStatus,
+		}
+		fake.transfers[req.Reference] = created
+		return created
+	}
+	fake.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+secret {
+			w.WriteHeader(401)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Invalid key"})
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/transaction/initialize":
+			var req services.PaystackInitRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			fake.metadata[req.Ref] = req.Metadata
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": map[string]interface{}{
+				"reference": req.Ref, "access_code": "ac_" + req.Ref, "authorization_url": fake.URL + "/checkout/" + req.Ref,
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/transaction/charge_authorization":
+			var req services.ChargeAuthorizationRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			fake.metadata[req.Reference] = req.Metadata
+			status, gatewayResponse := "success", "Approved"
+			switch req.AuthorizationCode {
+			case "AUTH_declined":
+				status, gatewayResponse = "failed", "Insufficient Funds"
+			case "AUTH_pending":
+				status, gatewayResponse = "pending", "Awaiting bank"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": map[string]interface{}{
+				"reference": req.Reference, "amount": req.Amount, "status": status, "gateway_response": gatewayResponse,
+				"metadata": req.Metadata,
+			}})
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/transaction/verify/"):
+			reference := strings.TrimPrefix(r.URL.Path, "/transaction/verify/")
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": map[string]interface{}{
+				"reference": reference, "amount": 500000, "status": "success", "metadata": fake.metadata[reference],
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/transferrecipient":
+			fake.recipients++
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": map[string]interface{}{
+				"recipient_code": fmt.Sprintf("RCP_%d", fake.recipients),
+			}})
+		case r.Method == http.MethodPost && (r.URL.Path == "/transfer" || r.URL.Path == "/transfer/bulk"):
+			var reqs []services.TransferInitRequest
+			if r.URL.Path == "/transfer" {
+				var req services.TransferInitRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				reqs = append(reqs, req)
+			} else {
+				var bulk services.BulkTransferRequest
+				json.NewDecoder(r.Body).Decode(&bulk)
+				reqs = bulk.Transfers
+				fake.bulkCalls++
+			}
+			if fake.rejectTransfers {
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Insufficient balance"})
+				return
+			}
+			var created []*services.PaystackTransferData
+			for _, req := range reqs {
+				created = append(created, transfer(req))
+			}
+			if fake.loseResponses {
+				w.WriteHeader(502)
+				return
+			}
+			var data interface{} = created
+			if r.URL.Path == "/transfer" {
+				data = created[0]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": data})
+		case r.Method == http.MethodGet && (r.URL.Path == "/transaction" || r.URL.Path == "/transfer"):
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			perPage, _ := strconv.Atoi(r.URL.Query().Get("perPage"))
+			var all []interface{}
+			if r.URL.Path == "/transaction" {
+				for _, c := range fake.charges {
+					all = append(all, c)
+				}
+			} else {
+				for _, t := range fake.transfers {
+					all = append(all, t)
+				}
+			}
+			start, end := min((page-1)*perPage, len(all)), min(page*perPage, len(all))
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": append([]interface{}{}, all[start:end]...)})
+		case r.Method == http.MethodPost && r.URL.Path == "/refund":
+			var req services.RefundRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if _, ok := fake.metadata[req.Transaction]; !ok {
+				w.WriteHeader(400)
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Transaction reference not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": map[string]interface{}{
+				"id": 3018284, "amount": req.Amount, "status": "pending", "merchant_note": req.MerchantNote,
+			}})
+		case r.Method == http.MethodPost && r.URL.Path == "/transfer/finalize_transfer":
+			var req struct {
+				TransferCode string `json:"transfer_code"`
+				OTP          string `json:"otp"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			for _, t := range fake.transfers {
+				if t.TransferCode == req.TransferCode && req.OTP == "123456" {
+					t.Status = "success"
+					json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": t})
+					return
+				}
+			}
+			w.WriteHeader(400)
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Invalid OTP"})
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/transfer/verify/"):
+			t, ok := fake.transfers[strings.TrimPrefix(r.URL.Path, "/transfer/verify/")]
+			if !ok {
+				w.WriteHeader(404)
+				json.NewEncoder(w).Encode(map[string]interface{}{"status": false, "message": "Transfer not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": true, "data": t})
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func (f *fakePaystack) sign(body []byte) string {
+	mac := hmac.New(sha512.New, []byte(f.secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestPaystackWebhook(t *testing.T) {
+	fake := newFakePaystack(t, "sk_test_fake")
+	paystack := services.NewPaystackService("sk_test_fake").WithBaseURL(fake.URL)
+	userID := uuid.New()
+
+	t.Run("Signature", func(t *testing.T) {
+		body := []byte(`{"event":"charge.success","data":{"reference":"ref_1","amount":500000,"status":"success"}}`)
+		assert.True(t, paystack.VerifyWebhookSignature(body, fake.sign(body)))
+		assert.False(t, paystack.VerifyWebhookSignature(body, ""))
+		assert.False(t, paystack.VerifyWebhookSignature(append(body, ' '), fake.sign(body)))
+		assert.False(t, services.NewPaystackService("sk_test_other").VerifyWebhookSignature(body, fake.sign(body)))
+		assert.False(t, services.NewPaystackService("").VerifyWebhookSignature(body, fake.sign(body)))
+	})
+
+	t.Run("Charge Metadata Round Trip", func(t *testing.T) {
+		_, err := paystack.InitializeTransaction("creator@example.com", 500000, "ref_2", map[string]interface{}{"user_id": userID.String(), "credits": 5000})
+		assert.NoError(t, err)
+
+		verified, err := paystack.VerifyTransaction("ref_2")
+		assert.NoError(t, err)
+		assert.Equal(t, "success", verified.Data.Status)
+		assert.Equal(t, userID.String(), verified.Data.Metadata["user_id"])
+
+		var refund services.PaystackRefundData
+		json.Unmarshal([]byte(`{"id":42,"transaction_reference":"ref_2","amount":100000}`), &refund)
+		assert.Equal(t, "42", refund.Reference())
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		app.Post("/api/webhooks/paystack", controllers.NewWebhookController(paystack, nil, nil).HandlePaystack)
+		post := func(body []byte, signature string) int {
+			req := httptest.NewRequest(http.MethodPost, "/api/webhooks/paystack", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("x-paystack-signature", signature)
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		body := []byte(`{"event":"transfer.success","data":{"reference":"` + uuid.NewString() + `","amount":150000}}`)
+		assert.Equal(t, 401, post(body, "forged"))
+		assert.Equal(t, 401, post(body, fake.sign([]byte(`{"event":"transfer.failed"}`))))
+		assert.Equal(t, 400, post([]byte(`not json`), fake.sign([]byte(`not json`))))
+		// A verified event is only acknowledged once it can be stored
+		assert.Equal(t, 503, post(body, fake.sign(body)))
+	})
+}
+
+// memPayoutStore keeps withdrawals in memory for the payout worker
+type memPayoutStore struct {
+	withdrawals map[uuid.UUID]*models.Withdrawal
+	recipients  map[string]string
+}
+
+func (m *memPayoutStore) ClaimPayouts(ctx context.Context, ids []uuid.UUID, limit int) ([]models.Withdrawal, error) {
+	claimed := []models.Withdrawal{}
+	for _, w := range m.withdrawals {
+		if w.Status != models.WithdrawalStatusApproved || (w.NextAttemptAt != nil && w.NextAttemptAt.After(time.Now())) {
+			continue
+		}
+		w.Status = models.WithdrawalStatusProcessing
+		w.Attempts++
+		claimed = append(claimed, *w)
+	}
+	return claimed, nil
+}
+
+func (m *memPayoutStore) UnsettledTransfers(ctx context.Context, olderThan time.Duration, limit int) ([]models.Withdrawal, error) {
+	unsettled := []models.Withdrawal{}
+	for _, w := range m.withdrawals {
+		if w.Status == models.WithdrawalStatusProcessing && w.PaystackReference != nil {
+			unsettled = append(unsettled, *w)
+		}
+	}
+	return unsettled, nil
+}
+
+func (m *memPayoutStore) TransferRecipient(ctx context.Context, userID uuid.UUID, bankCode, accountNumber string) (string, error) {
+	return m.recipients[userID.String()+bankCode+accountNumber], nil
+}
+
+func (m *memPayoutStore) SaveTransferRecipient(ctx context.Context, r *models.TransferRecipient) error {
+	m.recipients[r.UserID.String()+r.BankCode+r.AccountNumber] = r.RecipientCode
+	return nil
+}
+
+func (m *memPayoutStore) MarkTransferInitiated(ctx context.Context, id uuid.UUID, transferCode, status string) error {
+	m.withdrawals[id].Status = status
+	m.withdrawals[id].PaystackReference = &transferCode
+	return nil
+}
+
+func (m *memPayoutStore) RetryPayout(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	m.withdrawals[id].Status = models.WithdrawalStatusApproved
+	m.withdrawals[id].LastError = &reason
+	m.withdrawals[id].NextAttemptAt = &at
+	return nil
+}
+
+func (m *memPayoutStore) FailPayout(ctx context.Context, id uuid.UUID, reason string) (*models.Withdrawal, error) {
+	m.withdrawals[id].Status = models.WithdrawalStatusFailed
+	m.withdrawals[id].LastError = &reason
+	return m.withdrawals[id], nil
+}
+
+func (m *memPayoutStore) CompleteTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error) {
+	return m.settleTransfer(reference, models.WithdrawalStatusCompleted)
+}
+
+func (m *memPayoutStore) FailTransfer(ctx context.Context, reference, transferCode string) (*models.Withdrawal, error) {
+	return m.settleTransfer(reference, models.WithdrawalStatusFailed)
+}
+
+func (m *memPayoutStore) settleTransfer(reference, status string) (*models.Withdrawal, error) {
+	id, err := uuid.Parse(reference)
+	w := m.withdrawals[id]
+	if err != nil || w == nil {
+		return nil, repository.ErrWithdrawalNotFound
+	}
+	w.Status = status
+	return w, nil
+}
+
+func TestPayouts(t *testing.T) {
+	ctx := context.Background()
+	fillerID := uuid.New()
+	setup := func() (*fakePaystack, *memPayoutStore, *services.PayoutWorker) {
+		fake := newFakePaystack(t, "sk_test_fake")
+		store := &memPayoutStore{withdrawals: map[uuid.UUID]*models.Withdrawal{}, recipients: map[string]string{}}
+		return fake, store, services.NewPayoutWorker(services.NewPaystackService("sk_test_fake").WithBaseURL(fake.URL), store)
+	}
+	approve := func(store *memPayoutStore, amount int) *models.Withdrawal {
+		w := &models.Withdrawal{
+			ID: uuid.New(), UserID: fillerID, Amount: amount, AccountName: "Ada Obi",
+			AccountNumber: "0123456789", BankCode: "058", Status: models.WithdrawalStatusApproved,
+		}
+		store.withdrawals[w.ID] = w
+		return w
+	}
+	due := func(store *memPayoutStore) {
+		for _, w := range store.withdrawals {
+			w.NextAttemptAt = nil
+		}
+	}
+
+	t.Run("Bulk Transfer", func(t *testing.T) {
+		fake, store, worker := setup()
+		first, second := approve(store, 5000), approve(store, 7500)
+
+		result, err := worker.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Claimed)
+		assert.Equal(t, 2, result.Initiated)
+		assert.Equal(t, 1, fake.bulkCalls)
+		assert.Equal(t, 1, fake.recipients, "the bank account's recipient is created once")
+		assert.Equal(t, 750000, fake.transfers[second.TransferReference()].Amount, "amounts are sent in kobo")
+		assert.Equal(t, models.WithdrawalStatusProcessing, first.Status)
+		assert.Equal(t, "TRF_"+first.ID.String(), *first.PaystackReference)
+
+		// A transfer whose webhook never arrived is settled from its status at Paystack
+		fake.transfers[first.TransferReference()].Status = "success"
+		fake.transfers[second.TransferReference()].Status = "reversed"
+		result, err = worker.Reconcile(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Completed)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, models.WithdrawalStatusCompleted, first.Status)
+		assert.Equal(t, models.WithdrawalStatusFailed, second.Status)
+	})
+
+	t.Run("OTP", func(t *testing.T) {
+		fake, store, worker := setup()
+		fake.transferStatus = "otp"
+		w := approve(store, 5000)
+
+		result, err := worker.Run(ctx, []uuid.UUID{w.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.AwaitingOTP)
+		assert.Equal(t, 0, fake.bulkCalls)
+		assert.Equal(t, models.WithdrawalStatusAwaitingOTP, w.Status)
+
+		_, err = worker.Finalize(ctx, w, "000000")
+		assert.Error(t, err)
+		assert.Equal(t, models.WithdrawalStatusAwaitingOTP, w.Status)
+
+		result, err = worker.Finalize(ctx, w, "123456")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Completed)
+		assert.Equal(t, models.WithdrawalStatusCompleted, w.Status)
+
+		_, err = worker.Finalize(ctx, w, "123456")
+		assert.ErrorIs(t, err, services.ErrPayoutNotAwaitingOTP)
+	})
+
+	t.Run("Lost Response Is Not Paid Twice", func(t *testing.T) {
+		fake, store, worker := setup()
+		fake.loseResponses = true
+		w := approve(store, 5000)
+
+		result, err := worker.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Retrying)
+		assert.Equal(t, models.WithdrawalStatusApproved, w.Status)
+		assert.NotNil(t, w.NextAttemptAt)
+		assert.NotNil(t, w.LastError)
+
+		// Not due yet
+		result, _ = worker.Run(ctx, nil)
+		assert.Equal(t, 0, result.Claimed)
+
+		due(store)
+		result, err = worker.Run(ctx, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Initiated)
+		assert.Equal(t, 1, fake.transferCalls[w.TransferReference()], "the retry found the first transfer instead of sending another")
+		assert.Equal(t, models.WithdrawalStatusProcessing, w.Status)
+	})
+
+	t.Run("Gives Up After Max Attempts", func(t *testing.T) {
+		fake, store, worker := setup()
+		fake.rejectTransfers = true
+		w := approve(store, 5000)
+
+		for i := 0; i < 10 && w.Status != models.WithdrawalStatusFailed; i++ {
+			due(store)
+			worker.Run(ctx, nil)
+		}
+		assert.Equal(t, models.WithdrawalStatusFailed, w.Status)
+		assert.Equal(t, 6, w.Attempts, "five attempts, then a last check before failing")
+		assert.Empty(t, fake.transfers)
+	})
+}
+
+func TestRefunds(t *testing.T) {
+	fake := newFakePaystack(t, "sk_test_fake")
+	paystack := services.NewPaystackService("sk_test_fake").WithBaseURL(fake.URL)
+
+	t.Run("Paystack Refund", func(t *testing.T) {
+		_, err := paystack.InitializeTransaction("creator@example.com", 500000, "ref_refund", nil)
+		assert.NoError(t, err)
+
+		refund, err := paystack.CreateRefund("ref_refund", 200000, "Duplicate purchase")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3018284), refund.ID)
+		assert.Equal(t, 200000, refund.Amount)
+		assert.Equal(t, "pending", refund.Status)
+
+		_, err = paystack.CreateRefund("ref_unknown", 0, "Duplicate purchase")
+		assert.Error(t, err)
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user_id", uuid.NewString())
+			return c.Next()
+		})
+		app.Post("/api/admin/payments/:id/refund", controllers.NewPaymentController(nil, paystack, nil, nil).RefundTransaction)
+		post := func(id, body string) int {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/payments/"+id+"/refund", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, 400, post("not-a-uuid", `{"reason":"Duplicate purchase"}`))
+		assert.Equal(t, 400, post(uuid.NewString(), `{"reason":"  "}`), "a refund needs a reason")
+		assert.Equal(t, 400, post(uuid.NewString(), `{"reason":"Duplicate purchase","amount":-5}`))
+		assert.Equal(t, 503, post(uuid.NewString(), `{"reason":"Duplicate purchase","amount":2000}`))
+	})
+
+	t.Run("Bonus Credits Come Back", func(t *testing.T) {
+		// A full refund of a package takes back its bonus along with what was paid
+		assert.Equal(t, 150000, models.RefundedCredits(120000, 150000, 0, 120000))
+
+		first := models.RefundedCredits(120000, 150000, 0, 60000)
+		second := models.RefundedCredits(120000, 150000, 60000, 60000)
+		assert.Equal(t, 75000, first)
+		assert.Equal(t, 150000, first+second)
+
+		// Partial refunds that don't divide evenly still add up to every credit
+		total := 0
+		for refunded := 0; refunded < 3000; refunded += 1000 {
+			total += models.RefundedCredits(3000, 3100, refunded, 1000)
+		}
+		assert.Equal(t, 3100, total)
+
+		// A custom purchase has no bonus
+		assert.Equal(t, 5000, models.RefundedCredits(20000, 20000, 0, 5000))
+	})
+}
+
+func TestReconciliation(t *testing.T) {
+	charge := func(reference string, amount int, status string) models.SettlementRecord {
+		return models.SettlementRecord{Kind: models.SettlementKindCharge, Reference: reference, Amount: amount, Status: status, Settled: status == "success"}
+	}
+	transfer := func(reference string, amount int, status string) models.SettlementRecord {
+		r := charge(reference, amount, status)
+		r.Kind = models.SettlementKindTransfer
+		r.Settled = status == "success" || status == models.WithdrawalStatusCompleted
+		return r
+	}
+
+	t.Run("Match Records", func(t *testing.T) {
+		internal := []models.SettlementRecord{
+			charge("ref_ok", 5000, "success"),
+			charge("ref_short", 5000, "success"),
+			charge("ref_ours_only", 2000, "success"),
+			charge("ref_pending", 3000, "pending"),
+			charge("ref_abandoned", 1000, "pending"),
+			charge("ref_dup_ours", 1000, "success"),
+			charge("ref_dup_ours", 1000, "success"),
+			transfer("w1", 7500, models.WithdrawalStatusCompleted),
+			transfer("w2", 5000, models.WithdrawalStatusProcessing),
+		}
+		paystack := []models.SettlementRecord{
+			charge("ref_ok", 5000, "success"),
+			charge("ref_short", 4500, "success"),
+			charge("ref_theirs_only", 8000, "success"),
+			charge("ref_failed_theirs_only", 8000, "failed"),
+			charge("ref_pending", 3000, "success"),
+			charge("ref_dup_ours", 1000, "success"),
+			charge("ref_dup_theirs", 1000, "success"),
+			charge("ref_dup_theirs", 1000, "success"),
+			transfer("w1", 7500, "success"),
+			transfer("w2", 5000, "pending"),
+		}
+
+		matched, items := services.ReconcileRecords(internal, paystack)
+		assert.Equal(t, 3, matched, "ref_ok, w1 and the unsettled w2")
+		issues := map[string]string{}
+		for _, it := range items {
+			issues[it.Reference] = it.Issue
+			assert.Equal(t, models.ReconciliationItemOpen, it.Status)
+		}
+		assert.Equal(t, map[string]string{
+			"ref_short":       models.ReconciliationAmountMismatch,
+			"ref_ours_only":   models.ReconciliationMissingPaystack,
+			"ref_theirs_only": models.ReconciliationMissingInternal,
+			"ref_pending":     models.ReconciliationStatusMismatch,
+			"ref_dup_ours":    models.ReconciliationDuplicate,
+			"ref_dup_theirs":  models.ReconciliationDuplicate,
+		}, issues)
+	})
+
+	t.Run("Settlement CSV", func(t *testing.T) {
+		records, err := services.ParseSettlementCSV(strings.NewReader(
+			"\ufeffTransaction Reference,Amount,Type,Status\n" +
+				"ref_1,\"5,000.00\",charge,success\n" +
+				"w1,7500,transfer,success\n" +
+				"ref_2,250.50,charge,failed\n" +
+				",100,charge,success\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, []models.SettlementRecord{
+			{Kind: models.SettlementKindCharge, Reference: "ref_1", Amount: 5000, Status: "success", Settled: true},
+			{Kind: models.SettlementKindTransfer, Reference: "w1", Amount: 7500, Status: "success", Settled: true},
+			{Kind: models.SettlementKindCharge, Reference: "ref_2", Amount: 251, Status: "failed", Settled: false},
+		}, records)
+
+		_, err = services.ParseSettlementCSV(strings.NewReader("reference,fee\nref_1,100\n"))
+		assert.Error(t, err)
+		_, err = services.ParseSettlementCSV(strings.NewReader("reference,amount\nref_1,abc\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Paystack Listings", func(t *testing.T) {
+		fake := newFakePaystack(t, "sk_test_fake")
+		for i := 0; i < 150; i++ {
+			fake.charges = append(fake.charges, services.PaystackChargeData{Reference: fmt.Sprintf("ref_%d", i), Amount: 500000, Status: "success"})
+		}
+		fake.transfers["w1"] = &services.PaystackTransferData{Reference: "w1", Amount: 750000, Status: "success"}
+
+		reconciler := services.NewReconciler(services.NewPaystackService("sk_test_fake").WithBaseURL(fake.URL), nil)
+		records, err := reconciler.FetchPaystack(time.Now().AddDate(0, 0, -1), time.Now())
+		assert.NoError(t, err)
+		assert.Len(t, records, 151, "every page is fetched")
+		assert.Equal(t, 5000, records[0].Amount, "amounts are converted to naira")
+		assert.Equal(t, models.SettlementKindTransfer, records[150].Kind)
+
+		_, err = services.NewReconciler(nil, nil).FetchPaystack(time.Now(), time.Now())
+		assert.ErrorIs(t, err, services.ErrPaystackUnavailable)
+	})
+}
+
+// memPricingStore keeps pricing versions, organization rates and promo codes in memory
+type memPricingStore struct {
+	versions []models.PricingVersion
+	rates    map[uuid.UUID]*models.OrganizationRate
+	promos   map[string]*models.PromoCode
+	err      error
+}
+
+func (m *memPricingStore) ActivePricing(ctx context.Context, at time.Time) (*models.PricingVersion, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var active *models.PricingVersion
+	for i, v := range m.versions {
+		if !v.EffectiveFrom.After(at) && (active == nil || v.EffectiveFrom.After(active.EffectiveFrom)) {
+			active = &m.versions[i]
+		}
+	}
+	return active, nil
+}
+
+func (m *memPricingStore) OrganizationRate(ctx context.Context, creatorID uuid.UUID, at time.Time) (*models.OrganizationRate, error) {
+	rate := m.rates[creatorID]
+	if rate == nil || (rate.ExpiresAt != nil && !rate.ExpiresAt.After(at)) {
+		return nil, nil
+	}
+	return rate, nil
+}
+
+func (m *memPricingStore) PromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
+	return m.promos[strings.ToUpper(code)], nil
+}
+
+func TestPricing(t *testing.T) {
+	ctx := context.Background()
+	creatorID := uuid.New()
+	survey := func() *models.Survey {
+		return &models.Survey{CreatorID: creatorID, RewardAmount: 200, TargetResponses: 50, RunDays: services.BaseSurveyDays}
+	}
+
+	rules := models.DefaultPricingRules()
+	rules.Tiers[1].PlatformFee = 400
+	rules.PlatformFeePercentage = 10
+	limit := 1
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	store := &memPricingStore{
+		versions: []models.PricingVersion{
+			{ID: uuid.New(), Version: 1, Rules: models.DefaultPricingRules(), EffectiveFrom: past.Add(-time.Hour)},
+			{ID: uuid.New(), Version: 2, Rules: rules, EffectiveFrom: past},
+			{ID: uuid.New(), Version: 3, Rules: models.PricingRules{}, EffectiveFrom: future},
+		},
+		rates: map[uuid.UUID]*models.OrganizationRate{},
+		promos: map[string]*models.PromoCode{
+			"HALF":    {ID: uuid.New(), Code: "HALF", PercentOff: 50, Active: true},
+			"FLAT":    {ID: uuid.New(), Code: "FLAT", AmountOff: 5000, Active: true},
+			"OFF":     {ID: uuid.New(), Code: "OFF", PercentOff: 50},
+			"EXPIRED": {ID: uuid.New(), Code: "EXPIRED", PercentOff: 50, Active: true, ExpiresAt: &past},
+			"USED":    {ID: uuid.New(), Code: "USED", PercentOff: 50, Active: true, MaxRedemptions: &limit, Redemptions: 1},
+		},
+	}
+	service := services.NewBillingService().WithStore(store)
+
+	t.Run("Defaults Without Stored Rules", func(t *testing.T) {
+		pricing, err := services.NewBillingService().WithStore(&memPricingStore{}).Pricing(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, pricing.Version)
+		assert.Equal(t, models.DefaultPricingRules(), pricing.Rules)
+		assert.NoError(t, services.ValidatePricingRules(pricing.Rules))
+
+		_, err = services.NewBillingService().QuoteSurvey(ctx, survey(), 8, "HALF")
+		assert.ErrorIs(t, err, services.ErrPromoCodeInvalid)
+
+		// A store that cannot be read prices on the defaults too
+		pricing, err = services.NewBillingService().WithStore(&memPricingStore{err: errors.New("no connection")}).Pricing(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DefaultPricingRules(), pricing.Rules)
+	})
+
+	t.Run("Effective Version", func(t *testing.T) {
+		pricing, err := service.Pricing(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pricing.Version, "the future version is not in force yet")
+
+		quote, err := service.QuoteSurvey(ctx, survey(), 8, "")
+		assert.NoError(t, err)
+		// 400 standard fee plus 10% of the 10000 rewards
+		assert.Equal(t, 1400, quote.PlatformFee)
+		assert.Equal(t, 11400, quote.TotalCost)
+		assert.Equal(t, 2, quote.PricingVersion)
+		assert.Equal(t, 2, quote.Terms.Version)
+
+		result, err := service.CalculateSurveyCost(services.SurveyBilling{Pages: 8, RewardPerUser: 200, Respondents: 50})
+		assert.NoError(t, err)
+		assert.Equal(t, 1400, result.PlatformFee)
+		assert.Equal(t, 2, result.PricingVersion)
+
+		assert.NoError(t, service.ValidateRewardRange(8, 200))
+		assert.Error(t, service.ValidateRewardRange(8, 300))
+	})
+
+	t.Run("Promo Codes", func(t *testing.T) {
+		quote, err := service.QuoteSurvey(ctx, survey(), 8, "half")
+		assert.NoError(t, err)
+		assert.Equal(t, 700, quote.Discount)
+		assert.Equal(t, 700, quote.PlatformFee)
+		assert.Equal(t, 10700, quote.TotalCost)
+		assert.Equal(t, "HALF", quote.PromoCode)
+
+		quote, err = service.QuoteSurvey(ctx, survey(), 8, "FLAT")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, quote.PlatformFee, "discounts never go below a free fee")
+		assert.Equal(t, 10000, quote.TotalCost, "rewards are never discounted")
+
+		for _, code := range []string{"NOPE", "OFF", "EXPIRED", "USED"} {
+			_, err = service.QuoteSurvey(ctx, survey(), 8, code)
+			assert.ErrorIs(t, err, services.ErrPromoCodeInvalid, code)
+		}
+	})
+
+	t.Run("Organization Rate", func(t *testing.T) {
+		fee := 1000
+		store.rates[creatorID] = &models.OrganizationRate{CreatorID: creatorID, PlatformFee: &fee, FeeDiscountPercent: 20}
+		defer delete(store.rates, creatorID)
+
+		quote, err := service.QuoteSurvey(ctx, survey(), 8, "")
+		assert.NoError(t, err)
+		// 1000 agreed fee plus 1000 commission, less 20%
+		assert.Equal(t, 400, quote.Discount)
+		assert.Equal(t, 1600, quote.PlatformFee)
+
+		// The organization discount comes off before the promo code
+		quote, err = service.QuoteSurvey(ctx, survey(), 8, "HALF")
+		assert.NoError(t, err)
+		assert.Equal(t, 800, quote.PlatformFee)
+		assert.Equal(t, 1200, quote.Discount)
+
+		expired := past
+		store.rates[creatorID].ExpiresAt = &expired
+		quote, err = service.QuoteSurvey(ctx, survey(), 8, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 1400, quote.PlatformFee)
+	})
+
+	t.Run("Locked Terms", func(t *testing.T) {
+		quote, err := service.QuoteSurvey(ctx, survey(), 8, "HALF")
+		assert.NoError(t, err)
+		funded := survey()
+		now := time.Now()
+		funded.FundedAt = &now
+		funded.PricingTerms, _ = json.Marshal(quote.Terms)
+
+		// Rules change and the promo code runs out after the survey was funded
+		changed := rules
+		changed.PlatformFeePercentage = 50
+		store.versions = append(store.versions, models.PricingVersion{ID: uuid.New(), Version: 4, Rules: changed, EffectiveFrom: time.Now()})
+		store.promos["HALF"].Active = false
+		defer func() {
+			store.versions = store.versions[:len(store.versions)-1]
+			store.promos["HALF"].Active = true
+		}()
+
+		relocked, err := service.QuoteSurvey(ctx, funded, 8, "")
+		assert.NoError(t, err)
+		assert.Equal(t, quote.PlatformFee, relocked.PlatformFee)
+		assert.Equal(t, 2, relocked.PricingVersion)
+		assert.Equal(t, "HALF", relocked.PromoCode)
+
+		// An edit is repriced on the locked rules too
+		funded.TargetResponses = 100
+		relocked, err = service.QuoteSurvey(ctx, funded, 8, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 20000, relocked.Budget)
+		assert.Equal(t, 1200, relocked.PlatformFee, "half of 400 plus 10% of the rewards")
+
+		unfunded, err := service.QuoteSurvey(ctx, survey(), 8, "")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, unfunded.PricingVersion)
+		assert.Equal(t, 5400, unfunded.PlatformFee)
+	})
+
+	t.Run("Validate Rules", func(t *testing.T) {
+		broken := func(change func(r *models.PricingRules)) models.PricingRules {
+			r := models.DefaultPricingRules()
+			r.Tiers = append([]models.PricingTier(nil), r.Tiers...)
+			change(&r)
+			return r
+		}
+		for name, r := range map[string]models.PricingRules{
+			"no tiers":          {MinReward: 100},
+			"unordered tiers":   broken(func(r *models.PricingRules) { r.Tiers[1].MaxPages = 3 }),
+			"bounded last tier": broken(func(r *models.PricingRules) { r.Tiers[3].MaxPages = 50 }),
+			"open middle tier":  broken(func(r *models.PricingRules) { r.Tiers[1].MaxPages = 0 }),
+			"negative fee":      broken(func(r *models.PricingRules) { r.Tiers[0].PlatformFee = -1 }),
+			"reward range":      broken(func(r *models.PricingRules) { r.Tiers[2].MaxReward = 100 }),
+			"negative add-on":   broken(func(r *models.PricingRules) { r.DataExport = -300 }),
+			"percentage":        broken(func(r *models.PricingRules) { r.PlatformFeePercentage = 120 }),
+		} {
+			assert.Error(t, services.ValidatePricingRules(r), name)
+		}
+		assert.NoError(t, services.ValidatePricingRules(rules))
+	})
+}
+
+func TestCreditPackages(t *testing.T) {
+	t.Run("Validation", func(t *testing.T) {
+		pkg := models.CreditPackage{ID: "professional", Name: "Professional Pack", Price: 40000, Credits: 45000}
+		assert.NoError(t, pkg.Validate())
+		assert.Equal(t, 5000, pkg.Bonus())
+
+		pkg.Credits = 40000
+		assert.NoError(t, pkg.Validate())
+		assert.Equal(t, 0, pkg.Bonus())
+
+		pkg.Credits = 39999
+		assert.Error(t, pkg.Validate(), "a package never grants fewer credits than it costs")
+		assert.Error(t, (&models.CreditPackage{ID: "tiny", Name: "Tiny", Price: 100, Credits: 100}).Validate())
+		assert.Error(t, (&models.CreditPackage{Name: "Nameless", Price: 5000, Credits: 5000}).Validate())
+
+		// Bonus credits come from promotions, which may run negative
+		assert.True(t, models.PlatformAccount(models.LedgerAccountPromotions).MayOverdraw())
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user_id", uuid.NewString())
+			return c.Next()
+		})
+		credits := controllers.NewCreditsController(nil, nil, nil, nil, nil)
+		app.Get("/api/credits/packages", credits.GetPackages)
+		app.Post("/api/credits/purchase", credits.PurchaseCredits)
+		app.Post("/api/credits/purchase/custom", credits.PurchaseCustom)
+		send := func(method, path, body string) int {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, 503, send(http.MethodGet, "/api/credits/packages", ""))
+		assert.Equal(t, 400, send(http.MethodPost, "/api/credits/purchase", `{"amount":100,"credits":999999}`), "a package is required")
+		assert.Equal(t, 503, send(http.MethodPost, "/api/credits/purchase", `{"package_id":"starter"}`))
+		assert.Equal(t, 400, send(http.MethodPost, "/api/credits/purchase/custom", `{"credits":2999}`))
+		assert.Equal(t, 503, send(http.MethodPost, "/api/credits/purchase/custom", `{"credits":3000}`))
+	})
+}
+
+func TestInvoices(t *testing.T) {
+	t.Run("VAT", func(t *testing.T) {
+		// Prices include VAT: ₦15,000 at 7.5% is ₦13,953.49 before VAT
+		subtotal, vat := models.SplitVAT(1500000, 7.5)
+		assert.Equal(t, 1395349, subtotal)
+		assert.Equal(t, 104651, vat)
+
+		subtotal, vat = models.SplitVAT(4000000, 0)
+		assert.Equal(t, 4000000, subtotal)
+		assert.Equal(t, 0, vat)
+
+		assert.Equal(t, "OTS-000042", models.InvoiceNumber(42))
+	})
+
+	t.Run("PDF", func(t *testing.T) {
+		organization := "Acme Research Ltd"
+		invoice := &models.Invoice{
+			Number:           models.InvoiceNumber(1),
+			CustomerName:     "Ada Obi",
+			CustomerEmail:    "ada@acme.example",
+			OrganizationName: &organization,
+			SellerName:       "OneTime Survey",
+			SellerAddress:    "12 Marina, Lagos",
+			SellerTaxID:      "12345678-0001",
+			Description:      "Professional Pack",
+			Credits:          45000,
+			Reference:        uuid.NewString(),
+			VATRate:          7.5,
+			Total:            4000000,
+			PaidAt:           time.Now(),
+			IssuedAt:         time.Now(),
+		}
+		invoice.Subtotal, invoice.VAT = models.SplitVAT(invoice.Total, invoice.VATRate)
+
+		pdf, err := services.RenderInvoice(invoice)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF")))
+	})
+
+	t.Run("Endpoint", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user_id", uuid.NewString())
+			return c.Next()
+		})
+		app.Get("/api/payment/history/:id/invoice", controllers.NewPaymentController(nil, nil, nil, nil).GetInvoice)
+		get := func(id string) int {
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/payment/history/"+id+"/invoice", nil))
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, 400, get("not-a-uuid"))
+		assert.Equal(t, 503, get(uuid.NewString()))
+	})
+}
+
+// memInvoiceStore keeps credit purchases by id, and the invoices issued for them, in memory
+type memInvoiceStore struct {
+	purchases map[uuid.UUID]*models.InvoicedPurchase
+	invoices  map[uuid.UUID]*models.Invoice // by payment id
+	emailed   map[uuid.UUID]bool
+}
+
+func (m *memInvoiceStore) IssueInvoice(ctx context.Context, paymentID uuid.UUID, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error) {
+	if invoice := m.invoices[paymentID]; invoice != nil {
+		return invoice, nil
+	}
+	invoice, err := issue(m.purchases[paymentID], len(m.invoices)+1)
+	if err != nil {
+		return nil, err
+	}
+	m.invoices[paymentID] = invoice
+	return invoice, nil
+}
+
+func (m *memInvoiceStore) IssueInvoiceForReference(ctx context.Context, reference string, issue func(purchase *models.InvoicedPurchase, sequence int) (*models.Invoice, error)) (*models.Invoice, error) {
+	for id, purchase := range m.purchases {
+		if purchase.Reference == reference {
+			return m.IssueInvoice(ctx, id, issue)
+		}
+	}
+	return m.IssueInvoice(ctx, uuid.New(), issue)
+}
+
+func (m *memInvoiceStore) ClaimInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) (bool, error) {
+	if m.emailed[invoiceID] {
+		return false, nil
+	}
+	m.emailed[invoiceID] = true
+	return true, nil
+}
+
+func (m *memInvoiceStore) ReleaseInvoiceEmail(ctx context.Context, invoiceID uuid.UUID) error {
+	delete(m.emailed, invoiceID)
+	return nil
+}
+
+// memMailer records the invoices it emails, failing while fail is set
+type memMailer struct {
+	sent []string
+	fail bool
+}
+
+func (m *memMailer) SendInvoice(invoice *models.Invoice, pdf []byte) error {
+	if m.fail {
+		return errors.New("smtp unavailable")
+	}
+	m.sent = append(m.sent, invoice.Number)
+	return nil
+}
+
+func TestInvoicer(t *testing.T) {
+	ctx := context.Background()
+	issuer := models.InvoiceIssuer{Name: "OneTime Survey", Address: "12 Marina, Lagos", TaxID: "12345678-0001", VATRate: 7.5}
+	setup := func() (*memInvoiceStore, *memMailer, *services.Invoicer) {
+		store := &memInvoiceStore{purchases: map[uuid.UUID]*models.InvoicedPurchase{}, invoices: map[uuid.UUID]*models.Invoice{}, emailed: map[uuid.UUID]bool{}}
+		mailer := &memMailer{}
+		return store, mailer, services.NewInvoicer(store, mailer, issuer)
+	}
+	purchase := func(store *memInvoiceStore, buyer uuid.UUID, status string) *models.InvoicedPurchase {
+		p := &models.InvoicedPurchase{
+			ID: uuid.New(), UserID: buyer, Reference: uuid.NewString(), Amount: 15000, Credits: 15000,
+			Status: status, PaidAt: time.Now(), CustomerName: "Ada Obi", CustomerEmail: "ada@acme.example",
+		}
+		store.purchases[p.ID] = p
+		return p
+	}
+
+	t.Run("Sequential Numbers", func(t *testing.T) {
+		store, _, invoices := setup()
+		buyer := uuid.New()
+		first := purchase(store, buyer, models.PaymentStatusSuccess)
+		unpaid := purchase(store, buyer, models.PaymentStatusPending)
+		second := purchase(store, buyer, models.PaymentStatusRefunded)
+
+		invoice, err := invoices.Invoice(ctx, buyer, first.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "OTS-000001", invoice.Number)
+		assert.Equal(t, 1500000, invoice.Total)
+		assert.Equal(t, 1395349, invoice.Subtotal)
+		assert.Equal(t, issuer.Name, invoice.SellerName)
+		assert.Equal(t, "Credit purchase", invoice.Description)
+
+		// Refusals draw no number, so the next invoice follows straight on
+		_, err = invoices.Invoice(ctx, buyer, unpaid.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentNotPaid)
+		_, err = invoices.Invoice(ctx, buyer, uuid.New())
+		assert.ErrorIs(t, err, services.ErrPaymentNotFound)
+
+		invoice, err = invoices.Invoice(ctx, buyer, second.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "OTS-000002", invoice.Number, "a refunded purchase was still paid")
+
+		// Asking again returns the invoice already issued
+		invoice, err = invoices.Invoice(ctx, buyer, first.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "OTS-000001", invoice.Number)
+		assert.Len(t, store.invoices, 2)
+	})
+
+	t.Run("Ownership", func(t *testing.T) {
+		store, _, invoices := setup()
+		buyer, other := uuid.New(), uuid.New()
+		p := purchase(store, buyer, models.PaymentStatusSuccess)
+
+		_, err := invoices.Invoice(ctx, other, p.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentNotFound)
+		assert.Empty(t, store.invoices, "another buyer's request issues nothing")
+
+		_, err = invoices.Invoice(ctx, buyer, p.ID)
+		assert.NoError(t, err)
+		_, err = invoices.Invoice(ctx, other, p.ID)
+		assert.ErrorIs(t, err, services.ErrPaymentNotFound, "nor returns the issued invoice")
+	})
+
+	t.Run("Emailed Once", func(t *testing.T) {
+		store, mailer, invoices := setup()
+		p := purchase(store, uuid.New(), models.PaymentStatusSuccess)
+
+		invoices.Send(ctx, p.Reference)
+		invoices.Send(ctx, p.Reference)
+		assert.Equal(t, []string{"OTS-000001"}, mailer.sent)
+
+		unpaid := purchase(store, uuid.New(), models.PaymentStatusFailed)
+		invoices.Send(ctx, unpaid.Reference)
+		assert.Len(t, store.invoices, 1)
+	})
+
+	t.Run("Failed Email Is Sent Again", func(t *testing.T) {
+		store, mailer, invoices := setup()
+		p := purchase(store, uuid.New(), models.PaymentStatusSuccess)
+
+		mailer.fail = true
+		invoices.Send(ctx, p.Reference)
+		assert.Empty(t, mailer.sent)
+		assert.Empty(t, store.emailed)
+
+		mailer.fail = false
+		invoices.Send(ctx, p.Reference)
+		assert.Equal(t, []string{"OTS-000001"}, mailer.sent)
+	})
+}
+
+// memPurchaseStore keeps credit purchases by reference, their refunds and the journals posted for
+// them in memory
+type memPurchaseStore struct {
+	purchases map[string]*models.PaymentTransaction
+	refunds   map[uuid.UUID]*models.Refund
+	recorded  map[string]bool // refund references Paystack reported processed
+	journals  map[string]*models.LedgerJournal
+}
+
+func newMemPurchaseStore() *memPurchaseStore {
+	return &memPurchaseStore{
+		purchases: map[string]*models.PaymentTransaction{},
+		refunds:   map[uuid.UUID]*models.Refund{},
+		recorded:  map[string]bool{},
+		journals:  map[string]*models.LedgerJournal{},
+	}
+}
+
+// post records a journal once per reference, as the ledger does
+func (m *memPurchaseStore) post(journal *models.LedgerJournal) (bool, error) {
+	if err := journal.Validate(); err != nil {
+		return false, err
+	}
+	if m.journals[journal.Reference] != nil {
+		return false, nil
+	}
+	m.journals[journal.Reference] = journal
+	return true, nil
+}
+
+func (m *memPurchaseStore) balance(account models.LedgerAccountRef) int {
+	balance := 0
+	for _, journal := range m.journals {
+		for _, posting := range journal.Postings {
+			if posting.Account == account {
+				balance += posting.Amount
+			}
+		}
+	}
+	return balance
+}
+
+func (m *memPurchaseStore) SettleCharge(ctx context.Context, reference string, settle func(purchase *models.PaymentTransaction) (*models.PaymentTransaction, *models.LedgerJournal, error)) (bool, error) {
+	// settle works on a copy, so a failed settlement leaves the purchase as it was
+	var purchase *models.PaymentTransaction
+	if recorded := m.purchases[reference]; recorded != nil {
+		copied := *recorded
+		purchase = &copied
+	}
+	settled, journal, err := settle(purchase)
+	if err != nil {
+		return false, err
+	}
+	m.purchases[reference] = settled
+	if journal == nil {
+		return false, nil
+	}
+	return m.post(journal)
+}
+
+// refundable describes the purchase with a payment id or reference as a refund decision sees it
+func (m *memPurchaseStore) refundable(match func(p *models.PaymentTransaction) bool) *models.RefundablePurchase {
+	for reference, p := range m.purchases {
+		if !match(p) {
+			continue
+		}
+		purchase := &models.RefundablePurchase{ID: p.ID, UserID: *p.UserID, Reference: reference, Amount: p.Amount, Credits: p.Credits, Status: p.Status}
+		for _, refund := range m.refunds {
+			if refund.PaymentID == p.ID && refund.Status != models.RefundStatusFailed {
+				purchase.Refunded += refund.Amount
+			}
+		}
+		purchase.Balance = m.balance(models.CreatorCreditsAccount(purchase.UserID))
+		return purchase
+	}
+	return nil
+}
+
+func (m *memPurchaseStore) IssueRefund(ctx context.Context, refund *models.Refund, issue func(purchase *models.RefundablePurchase) (*models.LedgerJournal, error)) error {
+	purchase := m.refundable(func(p *models.PaymentTransaction) bool { return p.ID == refund.PaymentID })
+	clawback, err := issue(purchase)
+	if err != nil {
+		return err
+	}
+	refund.CreatedAt = time.Now()
+	recorded := *refund
+	m.refunds[refund.ID] = &recorded
+	_, err = m.post(clawback)
+	return err
+}
+
+func (m *memPurchaseStore) FailRefund(ctx context.Context, refundID uuid.UUID, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	refund := m.refunds[refundID]
+	if refund == nil {
+		return errors.New("refund not found")
+	}
+	return m.failRefund(refund, fail)
+}
+
+func (m *memPurchaseStore) FailIssuedRefund(ctx context.Context, transactionReference, refundReference string, amount int, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	if refund := m.issuedRefund(transactionReference, refundReference, amount); refund != nil {
+		return m.failRefund(refund, fail)
+	}
+	_, err := fail(nil, nil)
+	return err
+}
+
+func (m *memPurchaseStore) failRefund(refund *models.Refund, fail func(refund *models.Refund, clawback *models.LedgerJournal) (*models.LedgerJournal, error)) error {
+	changed := *refund
+	restore, err := fail(&changed, m.journals[models.RefundClawbackReference(refund.ID)])
+	if err != nil {
+		return err
+	}
+	m.refunds[refund.ID] = &changed
+	if restore == nil {
+		return nil
+	}
+	_, err = m.post(restore)
+	return err
+}
+
+// issuedRefund finds a refund by Paystack's refund id, or else the oldest unsettled one of amount
+func (m *memPurchaseStore) issuedRefund(transactionReference, refundReference string, amount int) *models.Refund {
+	var oldest *models.Refund
+	for _, refund := range m.refunds {
+		if refund.PaystackReference != transactionReference {
+			continue
+		}
+		if refund.PaystackRefundID != nil && *refund.PaystackRefundID == refundReference {
+			return refund
+		}
+		unsettled := refund.Status == models.RefundStatusPending || refund.Status == models.RefundStatusProcessing
+		if unsettled && refund.Amount == amount && (oldest == nil || refund.CreatedAt.Before(oldest.CreatedAt)) {
+			oldest = refund
+		}
+	}
+	return oldest
+}
+
+func (m *memPurchaseStore) RecordRefund(ctx context.Context, transactionReference, refundReference string, amount int, record func(purchase *models.RefundablePurchase, issued *models.Refund) (*models.Refund, *models.LedgerJournal, error)) error {
+	purchase := m.refundable(func(p *models.PaymentTransaction) bool { return *p.PaystackReference == transactionReference })
+	if purchase == nil {
+		_, _, err := record(nil, nil)
+		return err
+	}
+	if m.recorded[refundReference] {
+		return nil
+	}
+
+	var issued *models.Refund
+	if found := m.issuedRefund(transactionReference, refundReference, amount); found != nil {
+		copied := *found
+		issued = &copied
+	}
+	refund, clawback, err := record(purchase, issued)
+	if err != nil {
+		return err
+	}
+	m.recorded[refundReference] = true
+	m.refunds[refund.ID] = refund
+	m.purchases[transactionReference].Status = purchase.Status
+	if clawback == nil {
+		return nil
+	}
+	_, err = m.post(clawback)
+	return err
+}
+
+func TestChargeFulfilment(t *testing.T) {
+	ctx := context.Background()
+	purchase := func(store *memPurchaseStore, buyer uuid.UUID, amount, credits int) string {
+		reference := uuid.NewString()
+		store.purchases[reference] = &models.PaymentTransaction{
+			ID: uuid.New(), UserID: &buyer, Type: models.PaymentTypePurchase, Amount: amount, Credits: credits,
+			Status: models.PaymentStatusPending, PaystackReference: &reference,
+		}
+		return reference
+	}
+
+	t.Run("Grants Package Credits Once", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		charges := services.NewChargeFulfiller(store)
+		buyer := uuid.New()
+		reference := purchase(store, buyer, 10000, 11000)
+
+		// Verification and the webhook report the same charge, in either order
+		credits, granted, err := charges.Fulfil(ctx, reference, &buyer, 10000)
+		assert.NoError(t, err)
+		assert.True(t, granted)
+		assert.Equal(t, 11000, credits, "a package grants the credits it was priced with")
+
+		credits, granted, err = charges.Fulfil(ctx, reference, &buyer, 10000)
+		assert.NoError(t, err)
+		assert.False(t, granted)
+		assert.Equal(t, 11000, credits)
+
+		assert.Equal(t, models.PaymentStatusSuccess, store.purchases[reference].Status)
+		assert.Equal(t, 11000, store.balance(models.CreatorCreditsAccount(buyer)))
+		assert.Equal(t, -1000, store.balance(models.PlatformAccount(models.LedgerAccountPromotions)))
+		assert.Equal(t, -10000, store.balance(models.PlatformAccount(models.LedgerAccountPaymentGateway)))
+	})
+
+	t.Run("Paid After A Failed Initialization", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		buyer := uuid.New()
+		reference := purchase(store, buyer, 5000, 5000)
+		store.purchases[reference].Status = models.PaymentStatusFailed
+
+		_, granted, err := services.NewChargeFulfiller(store).Fulfil(ctx, reference, &buyer, 5000)
+		assert.NoError(t, err)
+		assert.True(t, granted)
+		assert.Equal(t, models.PaymentStatusSuccess, store.purchases[reference].Status)
+	})
+
+	t.Run("Amount Mismatch", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		buyer := uuid.New()
+		reference := purchase(store, buyer, 10000, 11000)
+
+		_, granted, err := services.NewChargeFulfiller(store).Fulfil(ctx, reference, &buyer, 100)
+		assert.ErrorIs(t, err, services.ErrPaymentAmountMismatch)
+		assert.False(t, granted)
+		assert.Equal(t, models.PaymentStatusFailed, store.purchases[reference].Status, "kept failed for reconciliation")
+		assert.Zero(t, store.balance(models.CreatorCreditsAccount(buyer)))
+	})
+
+	t.Run("Unknown Reference With Metadata", func(t *testing.T) {
+		// A charge made from the Paystack dashboard names its buyer in the metadata
+		store := newMemPurchaseStore()
+		buyer := uuid.New()
+
+		credits, granted, err := services.NewChargeFulfiller(store).Fulfil(ctx, "dashboard_ref", &buyer, 7500)
+		assert.NoError(t, err)
+		assert.True(t, granted)
+		assert.Equal(t, 7500, credits, "the amount paid is granted")
+		recorded := store.purchases["dashboard_ref"]
+		if assert.NotNil(t, recorded) {
+			assert.Equal(t, models.PaymentStatusSuccess, recorded.Status)
+			assert.Equal(t, buyer, *recorded.UserID)
+		}
+		assert.Equal(t, 7500, store.balance(models.CreatorCreditsAccount(buyer)))
+	})
+
+	t.Run("Unknown Reference Without Metadata", func(t *testing.T) {
+		store := newMemPurchaseStore()
+
+		_, granted, err := services.NewChargeFulfiller(store).Fulfil(ctx, "stray_ref", nil, 7500)
+		assert.ErrorIs(t, err, services.ErrPaymentNotFound)
+		assert.False(t, granted)
+		assert.Empty(t, store.purchases, "nothing is recorded without a buyer")
+		assert.Empty(t, store.journals)
+	})
+}
+
+func TestPurchaseRefunds(t *testing.T) {
+	ctx := context.Background()
+	// paid records a purchase of 10,000 naira granting 11,000 credits, and grants them
+	paid := func(t *testing.T, store *memPurchaseStore, buyer uuid.UUID) (uuid.UUID, string) {
+		reference := uuid.NewString()
+		payment := &models.PaymentTransaction{
+			ID: uuid.New(), UserID: &buyer, Type: models.PaymentTypePurchase, Amount: 10000, Credits: 11000,
+			Status: models.PaymentStatusPending, PaystackReference: &reference,
+		}
+		store.purchases[reference] = payment
+		_, _, err := services.NewChargeFulfiller(store).Fulfil(ctx, reference, &buyer, 10000)
+		assert.NoError(t, err)
+		return payment.ID, reference
+	}
+	// spend moves credits into a survey's escrow, out of reach of a refund
+	spend := func(t *testing.T, store *memPurchaseStore, buyer uuid.UUID, amount int) {
+		_, err := store.post(models.Transfer(models.JournalSurveyFunding, uuid.NewString(), "Survey funded",
+			models.CreatorCreditsAccount(buyer), models.EscrowAccount(uuid.New()), amount))
+		assert.NoError(t, err)
+	}
+	credits := func(store *memPurchaseStore, buyer uuid.UUID) int {
+		return store.balance(models.CreatorCreditsAccount(buyer))
+	}
+	promotions := func(store *memPurchaseStore) int {
+		return store.balance(models.PlatformAccount(models.LedgerAccountPromotions))
+	}
+
+	t.Run("Issue Takes Credits Back", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		buyer := uuid.New()
+		paymentID, reference := paid(t, store, buyer)
+
+		refund := &models.Refund{ID: uuid.New(), PaymentID: paymentID, Amount: 5000, Reason: "Duplicate purchase"}
+		assert.NoError(t, services.NewPurchaseRefunder(store).Issue(ctx, refund))
+		assert.Equal(t, models.RefundStatusPending, refund.Status)
+		assert.Equal(t, buyer, refund.UserID)
+		assert.Equal(t, reference, refund.PaystackReference)
+		assert.Equal(t, 5500, credits(store, buyer), "half the price takes back half the credits, bonus included")
+		assert.Equal(t, -500, promotions(store))
+	})
+
+	t.Run("Spent Credits Are Not Refunded", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		paymentID, _ := paid(t, store, buyer)
+		spend(t, store, buyer, 8000)
+
+		err := refunds.Issue(ctx, &models.Refund{ID: uuid.New(), PaymentID: paymentID, Reason: "Changed their mind"})
+		assert.ErrorIs(t, err, services.ErrCreditsSpent)
+		assert.Empty(t, store.refunds)
+		assert.Equal(t, 3000, credits(store, buyer))
+
+		// What is left still covers a smaller refund
+		assert.NoError(t, refunds.Issue(ctx, &models.Refund{ID: uuid.New(), PaymentID: paymentID, Amount: 2000, Reason: "Partial"}))
+		assert.Equal(t, 800, credits(store, buyer))
+	})
+
+	t.Run("Refusals", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		paymentID, reference := paid(t, store, buyer)
+
+		assert.ErrorIs(t, refunds.Issue(ctx, &models.Refund{ID: uuid.New(), PaymentID: uuid.New()}), services.ErrPaymentNotFound)
+		assert.ErrorIs(t, refunds.Issue(ctx, &models.Refund{ID: uuid.New(), PaymentID: paymentID, Amount: 10001}), services.ErrRefundExceedsPayment)
+		store.purchases[reference].Status = models.PaymentStatusPending
+		assert.ErrorIs(t, refunds.Issue(ctx, &models.Refund{ID: uuid.New(), PaymentID: paymentID}), services.ErrPaymentNotRefundable)
+	})
+
+	t.Run("Failed Refund Reverses Its Clawback", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		paymentID, _ := paid(t, store, buyer)
+
+		refund := &models.Refund{ID: uuid.New(), PaymentID: paymentID, Amount: 5000, Reason: "Duplicate purchase"}
+		assert.NoError(t, refunds.Issue(ctx, refund))
+		assert.Equal(t, 5500, credits(store, buyer))
+
+		assert.NoError(t, refunds.Fail(ctx, refund.ID, "Paystack refused the refund"))
+		assert.Equal(t, 11000, credits(store, buyer), "exactly what the clawback took comes back")
+		assert.Equal(t, -1000, promotions(store))
+		assert.Equal(t, models.RefundStatusFailed, store.refunds[refund.ID].Status)
+		assert.Equal(t, "Paystack refused the refund", *store.refunds[refund.ID].LastError)
+
+		// Failing it again, as a refund.failed webhook would, restores nothing more
+		assert.NoError(t, refunds.FailIssued(ctx, refund.PaystackReference, "rfnd_1", 5000))
+		assert.NoError(t, refunds.Fail(ctx, refund.ID, "again"))
+		assert.Equal(t, 11000, credits(store, buyer))
+	})
+
+	t.Run("Refund Failed Webhook", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		paymentID, reference := paid(t, store, buyer)
+
+		refund := &models.Refund{ID: uuid.New(), PaymentID: paymentID, Amount: 4000, Reason: "Duplicate purchase"}
+		assert.NoError(t, refunds.Issue(ctx, refund))
+		// The event names Paystack's refund id, unknown here, so the refund is matched by amount
+		assert.NoError(t, refunds.FailIssued(ctx, reference, "rfnd_9", 4000))
+		assert.Equal(t, models.RefundStatusFailed, store.refunds[refund.ID].Status)
+		assert.Equal(t, 11000, credits(store, buyer))
+
+		assert.NoError(t, refunds.FailIssued(ctx, "unknown_ref", "rfnd_10", 4000), "a dashboard refund failing leaves nothing to undo")
+	})
+
+	t.Run("Dashboard Refund Shortfall", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		_, reference := paid(t, store, buyer)
+		spend(t, store, buyer, 8000)
+
+		shortfall, err := refunds.Record(ctx, reference, "rfnd_1", 5000)
+		assert.NoError(t, err)
+		assert.Equal(t, 2500, shortfall, "5,500 credits were refunded and only 3,000 were left")
+		assert.Equal(t, 0, credits(store, buyer))
+		assert.Equal(t, models.PaymentStatusPartiallyRefunded, store.purchases[reference].Status)
+
+		// Paystack redelivers the same refund
+		shortfall, err = refunds.Record(ctx, reference, "rfnd_1", 5000)
+		assert.NoError(t, err)
+		assert.Zero(t, shortfall)
+		assert.Len(t, store.refunds, 1)
+
+		_, err = refunds.Record(ctx, "unknown_ref", "rfnd_2", 5000)
+		assert.ErrorIs(t, err, services.ErrPaymentNotFound)
+	})
+
+	t.Run("Issued Refund Processed", func(t *testing.T) {
+		store := newMemPurchaseStore()
+		refunds := services.NewPurchaseRefunder(store)
+		buyer := uuid.New()
+		paymentID, reference := paid(t, store, buyer)
+
+		refund := &models.Refund{ID: uuid.New(), PaymentID: paymentID, Reason: "Duplicate purchase"}
+		assert.NoError(t, refunds.Issue(ctx, refund))
+		assert.Equal(t, 0, credits(store, buyer))
+
+		shortfall, err := refunds.Record(ctx, reference, "rfnd_1", 10000)
+		assert.NoError(t, err)
+		assert.Zero(t, shortfall, "its credits were taken back when it was issued")
+		assert.Equal(t, 0, credits(store, buyer))
+		assert.Equal(t, models.RefundStatusProcessed, store.refunds[refund.ID].Status)
+		assert.Equal(t, "rfnd_1", *store.refunds[refund.ID].PaystackRefundID)
+		assert.Equal(t, models.PaymentStatusRefunded, store.purchases[reference].Status)
+	})
+}
+
+// memEventStore keeps Paystack events by payload hash, alongside credit purchases, in memory. While
+// fail is set, settling a charge fails as it would with the database down.
+type memEventStore struct {
+	*memPurchaseStore
+	events map[string]*models.PaystackEvent
+	fail   error
+}
+
+func (m *memEventStore) RecordEvent(ctx context.Context, event *models.PaystackEvent) (bool, error) {
+	if stored, ok := m.events[event.PayloadHash]; ok {
+		stored.Attempts++
+		*event = *stored
+		return stored.ProcessedAt != nil, nil
+	}
+	event.ID = uuid.New()
+	event.Attempts = 1
+	stored := *event
+	m.events[event.PayloadHash] = &stored
+	return false, nil
+}
+
+func (m *memEventStore) FinishEvent(ctx context.Context, eventID uuid.UUID, processErr error) error {
+	for _, event := range m.events {
+		if event.ID != eventID {
+			continue
+		}
+		if processErr != nil {
+			reason := processErr.Error()
+			event.LastError = &reason
+			return nil
+		}
+		now := time.Now()
+		event.ProcessedAt, event.LastError = &now, nil
+	}
+	return nil
+}
+
+func (m *memEventStore) SettleCharge(ctx context.Context, reference string, settle func(purchase *models.PaymentTransaction) (*models.PaymentTransaction, *models.LedgerJournal, error)) (bool, error) {
+	if m.fail != nil {
+		return false, m.fail
+	}
+	return m.memPurchaseStore.SettleCharge(ctx, reference, settle)
+}
+
+// event returns the stored event about a reference
+func (m *memEventStore) event(reference string) *models.PaystackEvent {
+	for _, event := range m.events {
+		if event.Reference == reference {
+			return event
+		}
+	}
+	return nil
+}
+
+func TestPaystackWebhookEvents(t *testing.T) {
+	const secret = "sk_test_webhook"
+	paystack := services.NewPaystackService(secret)
+	setup := func() (*memEventStore, *memPayoutStore, *fiber.App) {
+		events := &memEventStore{memPurchaseStore: newMemPurchaseStore(), events: map[string]*models.PaystackEvent{}}
+		transfers := &memPayoutStore{withdrawals: map[uuid.UUID]*models.Withdrawal{}, recipients: map[string]string{}}
+		app := fiber.New()
+		app.Post("/api/webhooks/paystack", controllers.NewWebhookController(paystack, events, transfers).HandlePaystack)
+		return events, transfers, app
+	}
+	deliver := func(t *testing.T, app *fiber.App, body string) (int, map[string]interface{}) {
+		mac := hmac.New(sha512.New, []byte(secret))
+		mac.Write([]byte(body))
+		req := httptest.NewRequest(http.MethodPost, "/api/webhooks/paystack", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-paystack-signature", hex.EncodeToString(mac.Sum(nil)))
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var reply map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&reply)
+		return resp.StatusCode, reply
+	}
+	// pending records a purchase initialized at a checkout and not yet paid
+	pending := func(store *memEventStore, buyer uuid.UUID, amount, credits int) string {
+		reference := uuid.NewString()
+		store.purchases[reference] = &models.PaymentTransaction{
+			ID: uuid.New(), UserID: &buyer, Type: models.PaymentTypePurchase, Amount: amount, Credits: credits,
+			Status: models.PaymentStatusPending, PaystackReference: &reference,
+		}
+		return reference
+	}
+	// charge is a charge.success event; Paystack reports amounts in kobo
+	charge := func(reference string, kobo int, metadata string) string {
+		return fmt.Sprintf(`{"event":"charge.success","data":{"reference":%q,"amount":%d,"status":"success","metadata":%s}}`, reference, kobo, metadata)
+	}
+	credits := func(store *memEventStore, buyer uuid.UUID) int {
+		return store.balance(models.CreatorCreditsAccount(buyer))
+	}
+
+	t.Run("Charge Grants Credits", func(t *testing.T) {
+		store, _, app := setup()
+		buyer := uuid.New()
+		reference := pending(store, buyer, 10000, 11000)
+
+		status, _ := deliver(t, app, charge(reference, 1000000, `{}`))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, 11000, credits(store, buyer))
+		assert.Equal(t, models.PaymentStatusSuccess, store.purchases[reference].Status)
+		assert.NotNil(t, store.event(reference).ProcessedAt)
+
+		// A charge that did not go through grants nothing
+		declined := pending(store, buyer, 5000, 5000)
+		status, _ = deliver(t, app, fmt.Sprintf(`{"event":"charge.success","data":{"reference":%q,"amount":500000,"status":"failed"}}`, declined))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, 11000, credits(store, buyer))
+		assert.Equal(t, models.PaymentStatusPending, store.purchases[declined].Status)
+	})
+
+	t.Run("Duplicate Delivery", func(t *testing.T) {
+		store, _, app := setup()
+		buyer := uuid.New()
+		reference := pending(store, buyer, 10000, 11000)
+		body := charge(reference, 1000000, `{}`)
+
+		deliver(t, app, body)
+		status, reply := deliver(t, app, body)
+		assert.Equal(t, 200, status)
+		assert.Equal(t, true, reply["duplicate"])
+		assert.Equal(t, 2, store.event(reference).Attempts)
+		assert.Equal(t, 11000, credits(store, buyer))
+
+		// The same charge sent again with a different payload is processed, and still granted once
+		status, reply = deliver(t, app, charge(reference, 1000000, `{"resent":true}`))
+		assert.Equal(t, 200, status)
+		assert.Nil(t, reply["duplicate"])
+		assert.Equal(t, 11000, credits(store, buyer))
+	})
+
+	t.Run("Charges That Can Never Apply Are Acknowledged", func(t *testing.T) {
+		store, _, app := setup()
+		buyer := uuid.New()
+
+		unknown := uuid.NewString()
+		status, _ := deliver(t, app, charge(unknown, 500000, `{}`))
+		assert.Equal(t, 200, status)
+		assert.Nil(t, store.event(unknown).ProcessedAt)
+		assert.Equal(t, services.ErrPaymentNotFound.Error(), *store.event(unknown).LastError)
+
+		// Paid from the Paystack dashboard for a known buyer: granted at the amount paid
+		dashboard := uuid.NewString()
+		status, _ = deliver(t, app, charge(dashboard, 500000, fmt.Sprintf(`{"user_id":%q}`, buyer)))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, 5000, credits(store, buyer))
+
+		mismatched := pending(store, buyer, 10000, 11000)
+		status, _ = deliver(t, app, charge(mismatched, 400000, `{}`))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, models.PaymentStatusFailed, store.purchases[mismatched].Status)
+		assert.Equal(t, 5000, credits(store, buyer))
+	})
+
+	t.Run("Failures Are Redelivered", func(t *testing.T) {
+		store, _, app := setup()
+		buyer := uuid.New()
+		reference := pending(store, buyer, 10000, 11000)
+		body := charge(reference, 1000000, `{}`)
+
+		store.fail = errors.New("connection reset")
+		status, _ := deliver(t, app, body)
+		assert.Equal(t, 500, status)
+		assert.Nil(t, store.event(reference).ProcessedAt)
+		assert.Equal(t, "connection reset", *store.event(reference).LastError)
+		assert.Equal(t, 0, credits(store, buyer))
+
+		store.fail = nil
+		status, reply := deliver(t, app, body)
+		assert.Equal(t, 200, status)
+		assert.Nil(t, reply["duplicate"], "an event that failed is processed again")
+		assert.Equal(t, 11000, credits(store, buyer))
+	})
+
+	t.Run("Transfers", func(t *testing.T) {
+		_, transfers, app := setup()
+		withdrawal := func() *models.Withdrawal {
+			w := &models.Withdrawal{ID: uuid.New(), UserID: uuid.New(), Amount: 1500, Status: models.WithdrawalStatusProcessing}
+			transfers.withdrawals[w.ID] = w
+			return w
+		}
+		transfer := func(event string, w *models.Withdrawal) string {
+			return fmt.Sprintf(`{"event":%q,"data":{"reference":%q,"transfer_code":"TRF_1","amount":150000}}`, event, w.TransferReference())
+		}
+
+		paid, failed, reversed := withdrawal(), withdrawal(), withdrawal()
+		for event, w := range map[string]*models.Withdrawal{
+			models.PaystackEventTransferSuccess:  paid,
+			models.PaystackEventTransferFailed:   failed,
+			models.PaystackEventTransferReversed: reversed,
+		} {
+			status, _ := deliver(t, app, transfer(event, w))
+			assert.Equal(t, 200, status, event)
+		}
+		assert.Equal(t, models.WithdrawalStatusCompleted, paid.Status)
+		assert.Equal(t, models.WithdrawalStatusFailed, failed.Status)
+		assert.Equal(t, models.WithdrawalStatusFailed, reversed.Status)
+
+		status, _ := deliver(t, app, transfer(models.PaystackEventTransferSuccess, &models.Withdrawal{ID: uuid.New()}))
+		assert.Equal(t, 200, status, "a transfer that is not ours is acknowledged")
+
+		// Without withdrawals the event is kept for a redelivery to apply
+		events := &memEventStore{memPurchaseStore: newMemPurchaseStore(), events: map[string]*models.PaystackEvent{}}
+		noTransfers := fiber.New()
+		noTransfers.Post("/api/webhooks/paystack", controllers.NewWebhookController(paystack, events, nil).HandlePaystack)
+		status, _ = deliver(t, noTransfers, transfer(models.PaystackEventTransferSuccess, withdrawal()))
+		assert.Equal(t, 500, status)
+	})
+
+	t.Run("Refunds", func(t *testing.T) {
+		store, _, app := setup()
+		buyer := uuid.New()
+		reference := pending(store, buyer, 10000, 11000)
+		deliver(t, app, charge(reference, 1000000, `{}`))
+		refund := func(event string, id, kobo int) string {
+			return fmt.Sprintf(`{"event":%q,"data":{"id":%d,"transaction_reference":%q,"amount":%d,"status":"processed"}}`, event, id, reference, kobo)
+		}
+
+		// Refunded from the Paystack dashboard: half the price takes back half the credits
+		status, _ := deliver(t, app, refund(models.PaystackEventRefundProcessed, 1, 500000))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, 5500, credits(store, buyer))
+		assert.Equal(t, models.PaymentStatusPartiallyRefunded, store.purchases[reference].Status)
+
+		// An issued refund Paystack could not complete gives its credits back
+		issued := &models.Refund{ID: uuid.New(), PaymentID: store.purchases[reference].ID, Amount: 2000, Reason: "Duplicate purchase"}
+		assert.NoError(t, services.NewPurchaseRefunder(store).Issue(context.Background(), issued))
+		assert.Equal(t, 3300, credits(store, buyer))
+		status, _ = deliver(t, app, refund(models.PaystackEventRefundFailed, 2, 200000))
+		assert.Equal(t, 200, status)
+		assert.Equal(t, 5500, credits(store, buyer))
+		assert.Equal(t, models.RefundStatusFailed, store.refunds[issued.ID].Status)
+	})
+}
+
+// memTopUpStore keeps purchases, one saved card per creator and auto top-ups in memory for top-ups
+type memTopUpStore struct {
+	*memPurchaseStore
+	methods   map[uuid.UUID]*models.PaymentMethod
+	autoTopUp []models.AutoTopUp
+	failures  map[uuid.UUID]string
+	disabled  map[uuid.UUID]bool
+}
+
+func (m *memTopUpStore) CreatePurchase(ctx context.Context, payment *models.PaymentTransaction) error {
+	payment.Status = models.PaymentStatusPending
+	m.purchases[*payment.PaystackReference] = payment
+	return nil
+}
+
+func (m *memTopUpStore) FailPurchase(ctx context.Context, reference string) error {
+	m.purchases[reference].Status = models.PaymentStatusFailed
+	return nil
+}
+
+func (m *memTopUpStore) PaymentMethod(ctx context.Context, userID, id uuid.UUID) (*models.PaymentMethod, error) {
+	method := m.methods[userID]
+	if method == nil || (id != uuid.Nil && id != method.ID) {
+		return nil, nil
+	}
+	return method, nil
+}
+
+func (m *memTopUpStore) MarkPaymentMethodUsed(ctx context.Context, id uuid.UUID) error {
+	return nil
+}
+
+func (m *memTopUpStore) ClaimAutoTopUps(ctx context.Context, cooldown time.Duration, limit int) ([]models.AutoTopUp, error) {
+	return m.autoTopUp, nil
+}
+
+func (m *memTopUpStore) FinishAutoTopUp(ctx context.Context, userID uuid.UUID, failure string, disable bool) error {
+	m.failures[userID] = failure
+	m.disabled[userID] = disable
+	return nil
+}
+
+func TestPaymentMethods(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Sealing", func(t *testing.T) {
+		sealer, err := security.NewSealer("payment-method-key")
+		assert.NoError(t, err)
+		sealed, err := sealer.Seal("AUTH_8dfhjjdt")
+		assert.NoError(t, err)
+		assert.NotContains(t, sealed, "AUTH_8dfhjjdt")
+
+		again, _ := sealer.Seal("AUTH_8dfhjjdt")
+		assert.NotEqual(t, sealed, again, "every seal uses a fresh nonce")
+
+		opened, err := sealer.Open(sealed)
+		assert.NoError(t, err)
+		assert.Equal(t, "AUTH_8dfhjjdt", opened)
+
+		other, _ := security.NewSealer("another-key")
+		_, err = other.Open(sealed)
+		assert.Error(t, err, "only the key that sealed it opens it")
+		tampered := []byte(sealed)
+		tampered[len(tampered)-3] ^= 1
+		_, err = sealer.Open(string(tampered))
+		assert.Error(t, err)
+
+		_, err = security.NewSealer("")
+		assert.Error(t, err)
+	})
+
+	t.Run("AutoTopUpValidation", func(t *testing.T) {
+		assert.NoError(t, (&models.AutoTopUp{Enabled: true, Threshold: 5000, Amount: models.MinCustomPurchase}).Validate())
+		assert.Error(t, (&models.AutoTopUp{Threshold: -1, Amount: 10000}).Validate())
+		assert.Error(t, (&models.AutoTopUp{Threshold: 5000, Amount: models.MinCustomPurchase - 1}).Validate())
+	})
+
+	setup := func() (*memTopUpStore, *services.TopUpService) {
+		fake := newFakePaystack(t, "sk_test_fake")
+		store := &memTopUpStore{
+			memPurchaseStore: newMemPurchaseStore(),
+			methods:          map[uuid.UUID]*models.PaymentMethod{},
+			failures:         map[uuid.UUID]string{},
+			disabled:         map[uuid.UUID]bool{},
+		}
+		return store, services.NewTopUpService(services.NewPaystackService("sk_test_fake").WithBaseURL(fake.URL), store, store)
+	}
+	saveCard := func(store *memTopUpStore, userID uuid.UUID, code string) *models.PaymentMethod {
+		method := &models.PaymentMethod{ID: uuid.New(), UserID: userID, AuthorizationCode: code, Email: "ada@acme.example", IsDefault: true}
+		store.methods[userID] = method
+		return method
+	}
+
+	t.Run("TopUp", func(t *testing.T) {
+		store, topUps := setup()
+		userID := uuid.New()
+
+		_, err := topUps.TopUp(ctx, userID, uuid.Nil, &models.PaymentTransaction{Amount: 5000, Credits: 5000})
+		assert.ErrorIs(t, err, services.ErrNoPaymentMethod)
+		assert.Empty(t, store.purchases, "nothing is recorded without a card to charge")
+
+		method := saveCard(store, userID, "AUTH_ok")
+		payment, err := topUps.TopUp(ctx, userID, method.ID, &models.PaymentTransaction{Amount: 40000, Credits: 45000})
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusSuccess, payment.Status)
+		assert.Equal(t, 45000, payment.Credits)
+		assert.Equal(t, userID, *payment.UserID)
+
+		_, err = topUps.TopUp(ctx, userID, uuid.New(), &models.PaymentTransaction{Amount: 5000, Credits: 5000})
+		assert.ErrorIs(t, err, services.ErrNoPaymentMethod, "another creator's card is never charged")
+
+		method.AuthorizationCode = "AUTH_pending"
+		payment, err = topUps.TopUp(ctx, userID, uuid.Nil, &models.PaymentTransaction{Amount: 5000, Credits: 5000})
+		assert.NoError(t, err)
+		assert.Equal(t, models.PaymentStatusPending, store.purchases[*payment.PaystackReference].Status, "the webhook settles it")
+
+		method.AuthorizationCode = "AUTH_declined"
+		payment, err = topUps.TopUp(ctx, userID, uuid.Nil, &models.PaymentTransaction{Amount: 5000, Credits: 5000})
+		assert.ErrorIs(t, err, services.ErrChargeDeclined)
+		assert.Contains(t, err.Error(), "Insufficient Funds")
+		assert.Equal(t, models.PaymentStatusFailed, store.purchases[*payment.PaystackReference].Status)
+	})
+
+	t.Run("AutoTopUp", func(t *testing.T) {
+		store, topUps := setup()
+		funded, declined := uuid.New(), uuid.New()
+		saveCard(store, funded, "AUTH_ok")
+		saveCard(store, declined, "AUTH_declined")
+		store.autoTopUp = []models.AutoTopUp{
+			{UserID: funded, Enabled: true, Threshold: 1000, Amount: 10000},
+			{UserID: declined, Enabled: true, Threshold: 1000, Amount: 10000},
+		}
+
+		result, err := topUps.RunAutoTopUps(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, &services.AutoTopUpRunResult{Claimed: 2, Charged: 1, Declined: 1}, result)
+		assert.Empty(t, store.failures[funded])
+		assert.False(t, store.disabled[funded])
+		assert.Contains(t, store.failures[declined], "Insufficient Funds")
+		assert.True(t, store.disabled[declined], "a declined card switches auto top-up off")
+		for _, payment := range store.purchases {
+			assert.Equal(t, 10000, payment.Amount)
+			assert.Equal(t, 10000, payment.Credits)
+		}
+	})
+
+	t.Run("Endpoints", func(t *testing.T) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user_id", uuid.NewString())
+			return c.Next()
+		})
+		payments := controllers.NewPaymentController(nil, nil, nil, nil)
+		credits := controllers.NewCreditsController(nil, nil, nil, nil, nil)
+		app.Post("/api/payment/methods", payments.AddPaymentMethod)
+		app.Put("/api/payment/methods/:id/default", payments.SetDefaultPaymentMethod)
+		app.Delete("/api/payment/methods/:id", payments.DeletePaymentMethod)
+		app.Put("/api/payment/auto-top-up", payments.SaveAutoTopUp)
+		app.Post("/api/credits/top-up", credits.TopUp)
+		send := func(method, path, body string) int {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			assert.NoError(t, err)
+			return resp.StatusCode
+		}
+
+		assert.Equal(t, 400, send(http.MethodPost, "/api/payment/methods", `{"card_token":"tok_123"}`), "a payment reference is required")
+		assert.Equal(t, 400, send(http.MethodPut, "/api/payment/methods/pm_001/default", ""))
+		assert.Equal(t, 503, send(http.MethodPut, "/api/payment/methods/"+uuid.NewString()+"/default", ""))
+		assert.Equal(t, 503, send(http.MethodDelete, "/api/payment/methods/"+uuid.NewString(), ""))
+		assert.Equal(t, 400, send(http.MethodPut, "/api/payment/auto-top-up", `{"enabled":true,"threshold":5000,"amount":100}`))
+		assert.Equal(t, 503, send(http.MethodPut, "/api/payment/auto-top-up", `{"enabled":true,"threshold":5000,"amount":10000}`))
+		assert.Equal(t, 400, send(http.MethodPost, "/api/credits/top-up", `{"credits":100}`))
+		assert.Equal(t, 400, send(http.MethodPost, "/api/credits/top-up", `{"credits":5000,"payment_method_id":"pm_001"}`))
+		assert.Equal(t, 503, send(http.MethodPost, "/api/credits/top-up", `{"package_id":"starter"}`))
+	})
+
+	t.Run("Without An Encryption Key", func(t *testing.T) {
+		// A database is configured, so no mock card stands in for the saved ones
+		paymentRepo := repository.NewPaymentRepository(repository.NewBaseRepository(&database.SupabaseDB{}))
+		payments := controllers.NewPaymentController(nil, nil, paymentRepo, nil)
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user_id", uuid.NewString())
+			return c.Next()
+		})
+		app.Get("/api/payment/methods", payments.GetPaymentMethods)
+		app.Post("/api/payment/methods", payments.AddPaymentMethod)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/payment/methods", nil))
+		assert.NoError(t, err)
+		assert.Equal(t, 503, resp.StatusCode)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/payment/methods", strings.NewReader(`{"reference":"ref_123"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, 503, resp.StatusCode)
+	})
+}
@@ -252,10 +252,59 @@ class ApiClient {
     return this.request<Record<string, unknown>>('/creator/credits')
   }
 
-  async purchaseCredits(amount: number) {
+  async purchaseCredits(packageId: string, saveCard = false) {
     return this.request<Record<string, unknown>>('/credits/purchase', {
       method: 'POST',
-      body: JSON.stringify({ amount }),
+      body: JSON.stringify({ package_id: packageId, save_card: saveCard }),
+    })
+  }
+
+  async purchaseCustomCredits(credits: number, saveCard = false) {
+    return this.request<Record<string, unknown>>('/credits/purchase/custom', {
+      method: 'POST',
+      body: JSON.stringify({ credits, save_card: saveCard }),
+    })
+  }
+
+  async topUpCredits(purchase: { package_id?: string; credits?: number; payment_method_id?: string }) {
+    return this.request<Record<string, unknown>>('/credits/top-up', {
+      method: 'POST',
+      body: JSON.stringify(purchase),
+    })
+  }
+
+  // Saved cards
+  async getPaymentMethods() {
+    return this.request<Record<string, unknown>>('/payment/methods')
+  }
+
+  async addPaymentMethod(reference: string, setDefault = false) {
+    return this.request<Record<string, unknown>>('/payment/methods', {
+      method: 'POST',
+      body: JSON.stringify({ reference, set_default: setDefault }),
+    })
+  }
+
+  async setDefaultPaymentMethod(methodId: string) {
+    return this.request<Record<string, unknown>>(`/payment/methods/${methodId}/default`, {
+      method: 'PUT',
+    })
+  }
+
+  async deletePaymentMethod(methodId: string) {
+    return this.request<Record<string, unknown>>(`/payment/methods/${methodId}`, {
+      method: 'DELETE',
+    })
+  }
+
+  async getAutoTopUp() {
+    return this.request<Record<string, unknown>>('/payment/auto-top-up')
+  }
+
+  async saveAutoTopUp(settings: { enabled: boolean; threshold: number; amount: number }) {
+    return this.request<Record<string, unknown>>('/payment/auto-top-up', {
+      method: 'PUT',
+      body: JSON.stringify(settings),
     })
   }
 
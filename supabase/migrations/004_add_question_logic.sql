@@ -0,0 +1,4 @@
+-- Per-question display rules for branching and skip logic.
+-- Stored as a JSON array of {action, match, conditions, target} objects.
+ALTER TABLE questions
+  ADD COLUMN IF NOT EXISTS logic JSONB;
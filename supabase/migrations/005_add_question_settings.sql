@@ -0,0 +1,4 @@
+-- Type-specific question configuration used for answer validation
+-- (rating scale, matrix rows and columns).
+ALTER TABLE questions
+  ADD COLUMN IF NOT EXISTS settings JSONB;
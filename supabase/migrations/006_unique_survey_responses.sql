@@ -0,0 +1,15 @@
+-- Enforce one response per filler per survey and support idempotent submissions.
+
+-- Remove duplicate submissions, keeping the earliest response per filler
+DELETE FROM responses r
+USING responses d
+WHERE r.survey_id = d.survey_id
+  AND r.filler_id = d.filler_id
+  AND (r.started_at, r.id) > (d.started_at, d.id);
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_responses_survey_filler ON responses(survey_id, filler_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_responses_idempotency ON responses(filler_id, idempotency_key)
+WHERE idempotency_key IS NOT NULL;
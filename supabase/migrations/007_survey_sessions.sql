@@ -0,0 +1,26 @@
+-- Persist survey sessions as responses rows.
+-- Responses move through in_progress -> completed, or in_progress -> abandoned when idle;
+-- abandoned sessions can be resumed.
+
+ALTER TABLE responses DROP CONSTRAINT IF EXISTS responses_status_check;
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS progress INTEGER DEFAULT 0;
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP;
+
+UPDATE responses SET last_activity_at = COALESCE(completed_at, started_at) WHERE last_activity_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_responses_status_activity ON responses(status, last_activity_at);
+
+-- When each question was first shown to a filler and when they answered it
+CREATE TABLE IF NOT EXISTS response_question_timings (
+    response_id UUID REFERENCES responses(id) ON DELETE CASCADE,
+    question_id UUID REFERENCES questions(id) ON DELETE CASCADE,
+    first_shown_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    answered_at TIMESTAMP,
+    PRIMARY KEY (response_id, question_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_response_question_timings_question ON response_question_timings(question_id);
@@ -0,0 +1,13 @@
+-- Response quality scoring.
+-- Each submitted response gets a 0-10 quality_score plus the flags that lowered it; responses
+-- scoring below the survey's min_quality_score are stored with status 'rejected' and not paid.
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS quality_flags JSONB DEFAULT '[]';
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS min_quality_score INTEGER DEFAULT 0;
+
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS surveys_min_quality_score_check;
+ALTER TABLE surveys
+  ADD CONSTRAINT surveys_min_quality_score_check CHECK (min_quality_score BETWEEN 0 AND 10);
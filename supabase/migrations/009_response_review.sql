@@ -0,0 +1,34 @@
+-- Creator review of survey responses.
+-- Submitted responses start in pending_review and are approved or rejected by the creator, or
+-- auto-approved once the review window passes. Survey earnings are linked to their response and
+-- move pending -> available on approval or pending -> reversed on rejection.
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS review_status VARCHAR(20);
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP;
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS review_note TEXT;
+
+ALTER TABLE responses DROP CONSTRAINT IF EXISTS responses_review_status_check;
+ALTER TABLE responses
+  ADD CONSTRAINT responses_review_status_check CHECK (review_status IN ('pending_review', 'approved', 'rejected'));
+
+CREATE INDEX IF NOT EXISTS idx_responses_review ON responses(review_status, completed_at);
+
+-- Responses submitted before review existed were already paid
+UPDATE responses SET review_status = 'approved', reviewed_at = completed_at
+WHERE status = 'completed' AND review_status IS NULL;
+
+UPDATE responses SET review_status = 'rejected', reviewed_at = completed_at
+WHERE status = 'rejected' AND review_status IS NULL;
+
+ALTER TABLE earnings
+  ADD COLUMN IF NOT EXISTS response_id UUID REFERENCES responses(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_earnings_response ON earnings(response_id);
+
+-- 'completed' earnings were withdrawable; that state is now called 'available'
+UPDATE earnings SET status = 'available' WHERE status = 'completed';
@@ -0,0 +1,12 @@
+-- Demographic targeting and per-segment quotas.
+-- targeting holds lists of allowed age groups, genders, locations, education, employment and
+-- income ranges matched against user_profiles; quotas caps completed responses per segment,
+-- e.g. [{"field": "location", "value": "Lagos", "max": 50}].
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS targeting JSONB DEFAULT '{}';
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS quotas JSONB DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_user_profiles_user ON user_profiles(user_id);
@@ -0,0 +1,52 @@
+-- Survey lifecycle state machine.
+-- Surveys move draft -> pending_review -> active <-> paused -> completed/expired/archived, with
+-- rejected for surveys an admin turns down. SurveyRepository.TransitionStatus enforces the allowed
+-- moves, stamps the time each state was entered and records every change in survey_status_history.
+
+-- Statuses used before the lifecycle existed
+UPDATE surveys SET status = 'pending_review' WHERE status = 'pending';
+UPDATE surveys SET status = 'active' WHERE status = 'approved';
+UPDATE surveys SET status = 'paused' WHERE status = 'suspended';
+
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS surveys_status_check;
+ALTER TABLE surveys
+  ADD CONSTRAINT surveys_status_check CHECK (status IN ('draft', 'pending_review', 'active', 'paused', 'completed', 'expired', 'archived', 'rejected'));
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS published_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS paused_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS expired_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS rejected_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS survey_status_history (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  survey_id UUID REFERENCES surveys(id) ON DELETE CASCADE,
+  from_status VARCHAR(50),
+  to_status VARCHAR(50) NOT NULL,
+  actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  reason TEXT,
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_survey_status_history_survey ON survey_status_history(survey_id, created_at);
+
+-- Seed history with each existing survey's current state
+INSERT INTO survey_status_history (survey_id, from_status, to_status, reason, created_at)
+SELECT id, NULL, status, 'state before lifecycle tracking', updated_at
+FROM surveys s
+WHERE NOT EXISTS (SELECT 1 FROM survey_status_history h WHERE h.survey_id = s.id);
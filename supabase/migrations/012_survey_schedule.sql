@@ -0,0 +1,26 @@
+-- Scheduled publishing and automatic expiry of surveys.
+-- Approved surveys with a future starts_at wait in the new 'scheduled' state until the backend
+-- scheduler publishes them. expires_at is set on first publication from run_days (the base run
+-- plus purchased extra days), and surveys still running at expires_at move to 'expired'.
+-- When a survey closes short of its target, the rewards budgeted for the missing responses are
+-- returned to the creator's credits; refunded_amount records what has been returned so far.
+
+ALTER TABLE surveys DROP CONSTRAINT IF EXISTS surveys_status_check;
+ALTER TABLE surveys
+  ADD CONSTRAINT surveys_status_check CHECK (status IN ('draft', 'pending_review', 'scheduled', 'active', 'paused', 'completed', 'expired', 'archived', 'rejected'));
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS starts_at TIMESTAMP;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS run_days INTEGER DEFAULT 0;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS budget INTEGER DEFAULT 0;
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS refunded_amount INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_surveys_starts ON surveys(status, starts_at);
+
+CREATE INDEX IF NOT EXISTS idx_surveys_expires ON surveys(status, expires_at);
@@ -0,0 +1,77 @@
+-- Survey template library.
+-- Each template stores a complete survey definition (the same versioned format used for survey
+-- import/export) so instantiating it creates a draft survey with all of its questions.
+-- Curated templates have no owner and are managed by admins; private templates belong to the
+-- creator who saved them and are only visible to that creator.
+
+CREATE TABLE IF NOT EXISTS survey_templates (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  description TEXT DEFAULT '',
+  category VARCHAR(50) NOT NULL CHECK (category IN ('market_research', 'customer_experience', 'product_feedback', 'academic_research', 'other')),
+  definition JSONB NOT NULL,
+  question_count INTEGER DEFAULT 0,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_survey_templates_owner ON survey_templates(owner_id, category);
+
+-- Starter library, replacing the hard-coded template list
+INSERT INTO survey_templates (id, name, description, category, definition, question_count) VALUES
+(
+  'a1f4c2d0-0b6e-4f3e-9a51-6c1d2e7f0001',
+  'Customer Satisfaction Survey',
+  'Measure customer satisfaction and feedback',
+  'customer_experience',
+  '{
+    "format_version": 1,
+    "title": "Customer Satisfaction Survey",
+    "description": "Tell us about your recent experience with our service",
+    "category": "customer_experience",
+    "reward_amount": 200,
+    "target_count": 100,
+    "estimated_duration": 5,
+    "targeting": {},
+    "questions": [
+      {"id": "q1", "type": "rating", "title": "How satisfied are you with our service overall?", "required": true, "scale": 5, "order": 1},
+      {"id": "q2", "type": "single", "title": "How often do you use our service?", "required": true, "options": ["Daily", "Weekly", "Monthly", "Rarely"], "order": 2},
+      {"id": "q3", "type": "rating", "title": "How likely are you to recommend us to a friend?", "required": true, "scale": 10, "order": 3},
+      {"id": "q4", "type": "text", "title": "What could we do better?", "required": false, "order": 4,
+       "logic": [{"action": "show", "conditions": [{"question_id": "q1", "operator": "lte", "value": 3}]}]},
+      {"id": "q5", "type": "text", "title": "Anything else you would like to share?", "required": false, "order": 5}
+    ]
+  }',
+  5
+),
+(
+  'a1f4c2d0-0b6e-4f3e-9a51-6c1d2e7f0002',
+  'Market Research Survey',
+  'Understand market trends and preferences',
+  'market_research',
+  '{
+    "format_version": 1,
+    "title": "Market Research Survey",
+    "description": "Help us understand how people choose and buy products",
+    "category": "market_research",
+    "reward_amount": 300,
+    "target_count": 200,
+    "estimated_duration": 8,
+    "targeting": {},
+    "questions": [
+      {"id": "q1", "type": "single", "title": "Which age group are you in?", "required": true, "options": ["18-24", "25-34", "35-44", "45+"], "order": 1},
+      {"id": "q2", "type": "multi", "title": "Where do you usually shop?", "required": true, "options": ["Open market", "Supermarket", "Online", "Neighbourhood shop"], "order": 2},
+      {"id": "q3", "type": "single", "title": "What matters most when choosing a product?", "required": true, "options": ["Price", "Quality", "Brand", "Availability"], "order": 3},
+      {"id": "q4", "type": "single", "title": "How much do you spend on groceries each month?", "required": true, "options": ["Under ₦20,000", "₦20,000 - ₦50,000", "₦50,000 - ₦100,000", "Over ₦100,000"], "order": 4},
+      {"id": "q5", "type": "single", "title": "Have you bought anything online in the last month?", "required": true, "options": ["Yes", "No"], "order": 5},
+      {"id": "q6", "type": "text", "title": "What did you buy online?", "required": false, "order": 6,
+       "logic": [{"action": "show", "conditions": [{"question_id": "q5", "operator": "equals", "value": "Yes"}]}]},
+      {"id": "q7", "type": "matrix", "title": "How do you rate these brands?", "required": false, "rows": ["Brand A", "Brand B", "Brand C"], "cols": ["Poor", "Fair", "Good", "Excellent"], "order": 7},
+      {"id": "q8", "type": "text", "title": "Which product would you like to see more of?", "required": false, "order": 8}
+    ]
+  }',
+  8
+)
+ON CONFLICT (id) DO NOTHING;
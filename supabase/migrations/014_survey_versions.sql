@@ -0,0 +1,42 @@
+-- Immutable survey versions.
+-- surveys.version is the version respondents currently see. Until a survey is first published its
+-- version is edited in place; once published, every edit creates the next version instead, with its
+-- own question rows (questions.survey_version) and a snapshot of the survey settings in
+-- survey_versions. Each response records the version it was answered against, so analytics and
+-- exports can be grouped or filtered by version.
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS version INTEGER DEFAULT 1;
+
+ALTER TABLE questions
+  ADD COLUMN IF NOT EXISTS survey_version INTEGER DEFAULT 1;
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS survey_version INTEGER DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS survey_versions (
+  survey_id UUID REFERENCES surveys(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  reward_amount INTEGER DEFAULT 0,
+  target_responses INTEGER DEFAULT 0,
+  estimated_duration INTEGER DEFAULT 0,
+  min_quality_score INTEGER DEFAULT 0,
+  targeting JSONB DEFAULT '{}',
+  quotas JSONB DEFAULT '[]',
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (survey_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_questions_survey_version ON questions(survey_id, survey_version, order_index);
+
+CREATE INDEX IF NOT EXISTS idx_responses_survey_version ON responses(survey_id, survey_version);
+
+-- Existing surveys become version 1 of themselves
+INSERT INTO survey_versions (survey_id, version, title, description, reward_amount, target_responses, estimated_duration, min_quality_score, targeting, quotas, created_by, created_at)
+SELECT id, 1, title, COALESCE(description, ''), COALESCE(reward_amount, 0), COALESCE(target_responses, 0), COALESCE(estimated_duration, 0),
+       COALESCE(min_quality_score, 0), COALESCE(targeting, '{}'), COALESCE(quotas, '[]'), creator_id, created_at
+FROM surveys
+ON CONFLICT (survey_id, version) DO NOTHING;
@@ -0,0 +1,9 @@
+-- Served order of randomized surveys.
+-- Questions can shuffle their options (settings.randomize_options, keeping settings.pinned_options in
+-- place) and named question blocks (settings.block) are served in random order. The order drawn for a
+-- filler's session is stored on the response so resumed sessions see the same layout and exports can
+-- report what each filler was shown:
+--   {"version": 2, "questions": ["<question id>", ...], "options": {"<question id>": ["B", "A", "Other"]}}
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS served_order JSONB;
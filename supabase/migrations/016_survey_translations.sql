@@ -0,0 +1,24 @@
+-- Survey translations.
+-- A survey is written in one language (surveys.locale) and can carry translations of its title and
+-- description, and of each question's text, options, rows and columns, keyed by locale:
+--   surveys.translations   {"yo": {"title": "...", "description": "..."}}
+--   questions.translations {"yo": {"title": "...", "options": {"Yes": "Bẹẹni", "No": "Rara"}}}
+-- Fillers are served the language they ask for (?lang, their preferred_language, Accept-Language).
+-- Answers are stored against the canonical labels and responses record the language they were served in.
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en',
+  ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE survey_versions
+  ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en',
+  ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE questions
+  ADD COLUMN IF NOT EXISTS translations JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE responses
+  ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en';
+
+ALTER TABLE user_profiles
+  ADD COLUMN IF NOT EXISTS preferred_language VARCHAR(10);
@@ -0,0 +1,124 @@
+-- Double-entry ledger.
+-- Every balance lives in a ledger account: creator credits, filler pending earnings and wallets
+-- (one account per user), survey escrow (one per survey) and the platform's fees, payout clearing
+-- and payment gateway accounts (owner_id is the nil UUID). Each money movement is a journal whose
+-- entries sum to zero; a positive amount moves money into an account. Journals and entries are
+-- append-only, and journal references are unique so a movement is never recorded twice.
+-- ledger_balances is the running balance of each account, updated with every posting, and
+-- ledger_balance_snapshots holds each account's closing balance per day.
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  kind VARCHAR(30) NOT NULL,
+  owner_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (kind, owner_id)
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_owner ON ledger_accounts(owner_id);
+
+CREATE TABLE IF NOT EXISTS ledger_journals (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  kind VARCHAR(30) NOT NULL,
+  reference VARCHAR(255) NOT NULL UNIQUE,
+  description TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+  id BIGSERIAL PRIMARY KEY,
+  journal_id UUID NOT NULL REFERENCES ledger_journals(id),
+  account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+  amount BIGINT NOT NULL CHECK (amount <> 0),
+  balance_after BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(account_id, id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_journal ON ledger_entries(journal_id);
+
+CREATE TABLE IF NOT EXISTS ledger_balances (
+  account_id UUID PRIMARY KEY REFERENCES ledger_accounts(id),
+  balance BIGINT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ledger_balance_snapshots (
+  account_id UUID NOT NULL REFERENCES ledger_accounts(id),
+  snapshot_date DATE NOT NULL,
+  balance BIGINT NOT NULL,
+  taken_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (account_id, snapshot_date)
+);
+
+CREATE OR REPLACE FUNCTION ledger_reject_change() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION '% rows are immutable', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_journals_immutable ON ledger_journals;
+CREATE TRIGGER ledger_journals_immutable BEFORE UPDATE OR DELETE ON ledger_journals
+  FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+DROP TRIGGER IF EXISTS ledger_entries_immutable ON ledger_entries;
+CREATE TRIGGER ledger_entries_immutable BEFORE UPDATE OR DELETE ON ledger_entries
+  FOR EACH ROW EXECUTE FUNCTION ledger_reject_change();
+
+-- Opening balances carried over from the tables balances used to be summed from: credits for
+-- creators, pending and available earnings for fillers less the withdrawals still in flight, which
+-- move to payout clearing. The payment gateway account takes the other side so the journal balances.
+DO $$
+DECLARE
+  opening_journal UUID := uuid_generate_v4();
+BEGIN
+  IF EXISTS (SELECT 1 FROM ledger_journals WHERE reference = 'opening-balances') THEN
+    RETURN;
+  END IF;
+
+  CREATE TEMP TABLE opening_balances ON COMMIT DROP AS
+  SELECT 'creator_credits'::VARCHAR(30) AS kind, user_id AS owner_id, SUM(amount)::BIGINT AS amount
+  FROM credits WHERE user_id IS NOT NULL GROUP BY user_id HAVING SUM(amount) > 0
+  UNION ALL
+  SELECT 'filler_pending', user_id, SUM(amount)
+  FROM earnings WHERE user_id IS NOT NULL AND status = 'pending' GROUP BY user_id HAVING SUM(amount) > 0
+  UNION ALL
+  SELECT 'filler_wallet', e.user_id, e.available - COALESCE(w.held, 0)
+  FROM (
+    SELECT user_id, SUM(amount) AS available FROM earnings
+    WHERE user_id IS NOT NULL AND status = 'available' GROUP BY user_id
+  ) e
+  LEFT JOIN (
+    SELECT user_id, SUM(amount) AS held FROM withdrawals
+    WHERE status IN ('pending', 'processing') GROUP BY user_id
+  ) w ON w.user_id = e.user_id
+  WHERE e.available - COALESCE(w.held, 0) > 0
+  UNION ALL
+  SELECT 'payout_clearing', '00000000-0000-0000-0000-000000000000'::UUID, SUM(amount)
+  FROM withdrawals WHERE status IN ('pending', 'processing') HAVING SUM(amount) > 0;
+
+  IF NOT EXISTS (SELECT 1 FROM opening_balances) THEN
+    RETURN;
+  END IF;
+
+  INSERT INTO opening_balances (kind, owner_id, amount)
+  SELECT 'payment_gateway', '00000000-0000-0000-0000-000000000000'::UUID, -SUM(amount) FROM opening_balances;
+
+  INSERT INTO ledger_journals (id, kind, reference, description)
+  VALUES (opening_journal, 'opening_balance', 'opening-balances', 'Balances carried over from credits, earnings and withdrawals');
+
+  INSERT INTO ledger_accounts (kind, owner_id)
+  SELECT kind, owner_id FROM opening_balances
+  ON CONFLICT (kind, owner_id) DO NOTHING;
+
+  INSERT INTO ledger_balances (account_id, balance)
+  SELECT a.id, o.amount FROM opening_balances o
+  JOIN ledger_accounts a ON a.kind = o.kind AND a.owner_id = o.owner_id
+  ON CONFLICT (account_id) DO UPDATE SET balance = ledger_balances.balance + EXCLUDED.balance, updated_at = NOW();
+
+  INSERT INTO ledger_entries (journal_id, account_id, amount, balance_after)
+  SELECT opening_journal, a.id, o.amount, b.balance FROM opening_balances o
+  JOIN ledger_accounts a ON a.kind = o.kind AND a.owner_id = o.owner_id
+  JOIN ledger_balances b ON b.account_id = a.id;
+END $$;
+
+COMMENT ON COLUMN fillers.balance IS 'Unused: filler balances are read from the ledger';
+COMMENT ON COLUMN creators.credits IS 'Unused: creator credits are read from the ledger';
@@ -0,0 +1,17 @@
+-- Survey escrow.
+-- Submitting a survey for review prices it with the billing rules and moves the whole cost from the
+-- creator's credits into the survey's escrow account, refusing the submission when credits fall
+-- short. The platform fee (complexity fee and add-ons) stays in escrow until the survey is first
+-- published and then moves to the platform; rewards leave escrow as responses come in and are
+-- released to fillers on approval. Editing a funded survey reprices it, rejection or withdrawal to
+-- draft returns the whole funding, and closing returns whatever is left in escrow.
+
+ALTER TABLE surveys
+  ADD COLUMN IF NOT EXISTS platform_fee INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS funded_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS priority_placement BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS data_export BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN surveys.budget IS 'Respondent rewards quoted when the survey was last funded';
+COMMENT ON COLUMN surveys.platform_fee IS 'Fee and add-ons quoted on top of the rewards, taken on first publication';
+COMMENT ON COLUMN surveys.funded_at IS 'When the survey''s cost was locked in escrow; NULL for unfunded surveys';
@@ -0,0 +1,25 @@
+-- Paystack webhooks.
+-- POST /api/webhooks/paystack accepts events signed with the secret key (x-paystack-signature, a hex
+-- HMAC-SHA512 of the body). Each delivery is stored raw, keyed by a hash of its payload, so a
+-- redelivered event is recognised and applied once. processed_at stays NULL, with last_error set,
+-- for events that failed or reference nothing on record.
+-- charge.success grants credits for a purchase, transfer.success/failed/reversed settle withdrawals
+-- and refund.processed claws refunded credits back. Payment transactions are unique per Paystack
+-- reference; payouts use 'transfer:<withdrawal id>' and refunds 'refund:<refund reference>'.
+
+CREATE TABLE IF NOT EXISTS paystack_events (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  event VARCHAR(50) NOT NULL,
+  reference VARCHAR(255),
+  payload_hash CHAR(64) NOT NULL UNIQUE,
+  payload JSONB NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 1,
+  last_error TEXT,
+  received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_paystack_events_reference ON paystack_events(reference);
+CREATE INDEX IF NOT EXISTS idx_paystack_events_unprocessed ON paystack_events(received_at) WHERE processed_at IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_transactions_reference
+  ON payment_transactions(paystack_reference) WHERE paystack_reference IS NOT NULL;
@@ -0,0 +1,30 @@
+-- Payout execution.
+-- A requested withdrawal waits as 'pending' until an admin approves it ('approved'). The payout
+-- worker then claims it ('processing'), creates or reuses the filler's Paystack transfer recipient
+-- and initiates the transfer, referenced by the withdrawal's ID on every attempt so it can never be
+-- paid twice; several withdrawals go out as one bulk transfer. Paystack may hold a transfer for an
+-- OTP ('awaiting_otp') until an admin finalizes it. The transfer webhooks complete or fail the
+-- withdrawal; a failure returns its amount to the filler's wallet.
+-- Failed attempts are retried with backoff (attempts, last_error, next_attempt_at), checking with
+-- Paystack first whether an earlier attempt got through; after 5 the withdrawal fails.
+
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS approved_by UUID REFERENCES users(id);
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS approved_at TIMESTAMP;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS transfer_recipients (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  bank_code VARCHAR(10) NOT NULL,
+  account_number VARCHAR(50) NOT NULL,
+  account_name VARCHAR(255),
+  recipient_code VARCHAR(100) NOT NULL,
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE (user_id, bank_code, account_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_withdrawals_payout_queue
+  ON withdrawals(status, next_attempt_at) WHERE status IN ('approved', 'processing');
@@ -0,0 +1,25 @@
+-- Refunds.
+-- Admins refund all or part of a credit purchase with POST /api/admin/payments/:id/refund (also
+-- /api/payment/refund/:id), giving a reason. The refunded credits are taken back from the creator
+-- when the refund is issued, so it is refused once they have been spent, escrowed survey budgets
+-- included. The refund is then sent to Paystack: 'pending' until Paystack accepts it, 'processing'
+-- until refund.processed arrives, 'processed' after. A refusal or refund.failed marks it 'failed'
+-- and gives the credits back. Refunds made on the Paystack dashboard are recorded from the webhook.
+-- Purchases move to 'partially_refunded' or 'refunded'; issuing a refund is audited.
+
+CREATE TABLE IF NOT EXISTS refunds (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  payment_id UUID NOT NULL REFERENCES payment_transactions(id),
+  user_id UUID NOT NULL REFERENCES users(id),
+  paystack_reference VARCHAR(255) NOT NULL,
+  paystack_refund_id VARCHAR(100),
+  amount INTEGER NOT NULL CHECK (amount > 0),
+  reason TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending',
+  issued_by UUID REFERENCES users(id),
+  last_error TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds(payment_id);
@@ -0,0 +1,38 @@
+-- Financial reconciliation.
+-- Every day, once it is over, a job lists the day's Paystack transactions and transfers and matches
+-- them by reference against credit purchases (payment_transactions.paystack_reference) and
+-- withdrawals (transferred under their ID). Finance can also run a period on demand or upload a
+-- Paystack settlement CSV with POST /api/super-admin/financials/reconciliation/run.
+-- Each run is stored as a report with an item per discrepancy: missing_internal (Paystack settled
+-- it, we have no record), missing_paystack, duplicate, amount_mismatch or status_mismatch. Items
+-- stay open until resolved with a note; a report is resolved with its last item. Amounts are naira.
+
+CREATE TABLE IF NOT EXISTS reconciliation_reports (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  period_start TIMESTAMPTZ NOT NULL,
+  period_end TIMESTAMPTZ NOT NULL,
+  source VARCHAR(20) NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  matched INTEGER NOT NULL DEFAULT 0,
+  issues INTEGER NOT NULL DEFAULT 0,
+  internal_total BIGINT NOT NULL DEFAULT 0,
+  paystack_total BIGINT NOT NULL DEFAULT 0,
+  created_by UUID REFERENCES users(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_reports_period ON reconciliation_reports(period_start, period_end, source);
+CREATE TABLE IF NOT EXISTS reconciliation_items (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  report_id UUID NOT NULL REFERENCES reconciliation_reports(id) ON DELETE CASCADE,
+  kind VARCHAR(20) NOT NULL,
+  reference VARCHAR(255) NOT NULL,
+  issue VARCHAR(30) NOT NULL,
+  internal_amount INTEGER,
+  paystack_amount INTEGER,
+  detail TEXT NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL DEFAULT 'open',
+  resolved_by UUID REFERENCES users(id),
+  resolution_note TEXT,
+  resolved_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_reconciliation_items_report ON reconciliation_items(report_id, status);
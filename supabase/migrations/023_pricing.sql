@@ -0,0 +1,52 @@
+-- Dynamic pricing.
+-- Surveys are priced on rules stored as immutable, numbered pricing_versions: page tiers with their
+-- platform fee and reward range, add-on prices and an optional percentage of the rewards. The rules
+-- in force are those of the latest version whose effective_from has passed; with none stored the
+-- built-in defaults apply. Super admins manage them under /api/super-admin/pricing.
+-- Promo codes and organization_rates (keyed by the creator account) discount the platform fee only,
+-- never the rewards. When a survey is funded the terms it was priced on are locked in
+-- surveys.pricing_terms and its promo code is redeemed, so later rule changes do not reprice it;
+-- going back to draft or being rejected unlocks them.
+
+CREATE TABLE IF NOT EXISTS pricing_versions (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  version INTEGER NOT NULL UNIQUE,
+  rules JSONB NOT NULL,
+  effective_from TIMESTAMPTZ NOT NULL,
+  note TEXT,
+  created_by UUID REFERENCES users(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_pricing_versions_effective ON pricing_versions(effective_from DESC, version DESC);
+CREATE TABLE IF NOT EXISTS promo_codes (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  code VARCHAR(50) NOT NULL UNIQUE,
+  description TEXT,
+  percent_off INTEGER NOT NULL DEFAULT 0 CHECK (percent_off BETWEEN 0 AND 100),
+  amount_off INTEGER NOT NULL DEFAULT 0 CHECK (amount_off >= 0),
+  max_redemptions INTEGER CHECK (max_redemptions > 0),
+  starts_at TIMESTAMPTZ,
+  expires_at TIMESTAMPTZ,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_by UUID REFERENCES users(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+  promo_code_id UUID NOT NULL REFERENCES promo_codes(id),
+  survey_id UUID NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+  creator_id UUID NOT NULL REFERENCES users(id),
+  discount INTEGER NOT NULL DEFAULT 0,
+  redeemed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (promo_code_id, survey_id)
+);
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_survey ON promo_redemptions(survey_id);
+CREATE TABLE IF NOT EXISTS organization_rates (
+  creator_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  platform_fee INTEGER CHECK (platform_fee >= 0),
+  fee_discount_percent INTEGER NOT NULL DEFAULT 0 CHECK (fee_discount_percent BETWEEN 0 AND 100),
+  expires_at TIMESTAMPTZ,
+  note TEXT,
+  updated_by UUID REFERENCES users(id),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+ALTER TABLE surveys ADD COLUMN IF NOT EXISTS pricing_terms JSONB;
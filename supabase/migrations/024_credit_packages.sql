@@ -0,0 +1,29 @@
+-- Credit packages.
+-- The credit packages creators can buy are stored in credit_packages with their price in naira and
+-- the credits they grant. Credits are held in naira, so credits beyond the price are a bonus; the
+-- seeded packages carry the old catalog's prices with the professional and enterprise packs'
+-- discounts turned into bonus credits. Purchases are priced on the server from the package, or
+-- from the amount of a custom purchase, and written to payment_transactions as pending before
+-- Paystack is called, with the package they are for. Fulfilment grants the pending row's credits
+-- once, and only if Paystack settled the amount the row was priced at.
+
+CREATE TABLE IF NOT EXISTS credit_packages (
+  id VARCHAR(50) PRIMARY KEY,
+  name VARCHAR(100) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  price INTEGER NOT NULL CHECK (price > 0),
+  credits INTEGER NOT NULL CHECK (credits >= price),
+  popular BOOLEAN NOT NULL DEFAULT FALSE,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  sort_order INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO credit_packages (id, name, description, price, credits, popular, sort_order) VALUES
+  ('starter', 'Starter Pack', 'Perfect for small surveys', 15000, 15000, FALSE, 1),
+  ('professional', 'Professional Pack', 'Most popular choice', 40000, 45000, TRUE, 2),
+  ('enterprise', 'Enterprise Pack', 'For large-scale research', 120000, 150000, FALSE, 3)
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE payment_transactions ADD COLUMN IF NOT EXISTS package_id VARCHAR(50) REFERENCES credit_packages(id);
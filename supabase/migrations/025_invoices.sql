@@ -0,0 +1,34 @@
+-- Invoices and receipts for credit purchases.
+-- Every paid credit purchase gets one tax invoice, which doubles as its receipt, numbered OTS-000001
+-- onwards in an unbroken sequence. It is issued when the payment is verified, or when first
+-- downloaded from /api/payment/history/:id/invoice, and emailed to the buyer once. Invoices never
+-- change after they are issued: the buyer's name, email and organization from creators, the
+-- seller's details and the VAT rate are copied onto them. Prices include VAT, so the paid amount is
+-- the total, split into the amount before VAT and the VAT on it. Amounts are in kobo.
+
+CREATE TABLE IF NOT EXISTS invoices (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  number VARCHAR(20) NOT NULL UNIQUE,
+  sequence INTEGER NOT NULL UNIQUE,
+  payment_id UUID NOT NULL UNIQUE REFERENCES payment_transactions(id),
+  user_id UUID NOT NULL REFERENCES users(id),
+  customer_name VARCHAR(255) NOT NULL,
+  customer_email VARCHAR(255) NOT NULL,
+  customer_address VARCHAR(255),
+  organization_name VARCHAR(255),
+  organization_type VARCHAR(50),
+  seller_name VARCHAR(255) NOT NULL,
+  seller_address TEXT NOT NULL DEFAULT '',
+  seller_tax_id VARCHAR(50) NOT NULL DEFAULT '',
+  description TEXT NOT NULL,
+  credits INTEGER NOT NULL,
+  reference VARCHAR(255) NOT NULL,
+  vat_rate NUMERIC(5,2) NOT NULL,
+  subtotal BIGINT NOT NULL,
+  vat BIGINT NOT NULL,
+  total BIGINT NOT NULL,
+  paid_at TIMESTAMPTZ NOT NULL,
+  issued_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  emailed_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_invoices_user ON invoices(user_id, issued_at DESC);
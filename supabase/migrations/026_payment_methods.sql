@@ -0,0 +1,39 @@
+-- Saved payment methods and auto top-up.
+-- A creator can save the card they paid with, from the reusable Paystack authorization on a
+-- verified payment, and buy credits with it again without a checkout. Authorization codes can charge
+-- the card, so they are stored AES-GCM encrypted under PAYMENT_METHOD_ENCRYPTION_KEY and never
+-- returned. The same card saved twice shares its Paystack signature and is kept once. Each creator
+-- has at most one default card, which /api/credits/top-up and auto top-up charge.
+-- With auto top-up on, amount credits are bought with the default card whenever the creator's
+-- credits fall below threshold, at most once an hour. A declined card switches it off and keeps
+-- the reason in last_error.
+
+CREATE TABLE IF NOT EXISTS payment_methods (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  authorization_code TEXT NOT NULL,
+  signature VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  bin VARCHAR(10) NOT NULL DEFAULT '',
+  last4 VARCHAR(4) NOT NULL DEFAULT '',
+  exp_month VARCHAR(2) NOT NULL DEFAULT '',
+  exp_year VARCHAR(4) NOT NULL DEFAULT '',
+  brand VARCHAR(50) NOT NULL DEFAULT '',
+  card_type VARCHAR(50) NOT NULL DEFAULT '',
+  bank VARCHAR(255) NOT NULL DEFAULT '',
+  is_default BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_used_at TIMESTAMPTZ,
+  UNIQUE (user_id, signature)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_methods_default ON payment_methods(user_id) WHERE is_default;
+
+CREATE TABLE IF NOT EXISTS auto_top_ups (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  enabled BOOLEAN NOT NULL DEFAULT FALSE,
+  threshold INTEGER NOT NULL CHECK (threshold >= 0),
+  amount INTEGER NOT NULL CHECK (amount > 0),
+  last_triggered_at TIMESTAMPTZ,
+  last_error TEXT,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);